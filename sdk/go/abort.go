@@ -0,0 +1,139 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// pythonReplArgv is the command line the sandbox portal starts the Python REPL with.
+var pythonReplArgv = []string{"python3", "-q", "-u", "-i", "-c", "import sys; sys.ps1=sys.ps2=''"}
+
+// callTable tracks the Run and Command calls in flight through one handle, so AbortAll can cancel
+// them. The zero value is ready to use.
+type callTable struct {
+	mu    sync.Mutex
+	next  int
+	calls map[int]inflightCall
+}
+
+// inflightCall is a call in flight; argv is the command line it runs, nil for REPL code.
+type inflightCall struct {
+	cancel  context.CancelCauseFunc
+	argv    []string
+	pidFile string // where a background process started with Command().Start records its PID
+	lang    progLang
+}
+
+// track derives a context for a call that AbortAll cancels, and registers the call until the
+// returned function is called.
+func (t *callTable) track(ctx context.Context, call inflightCall) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	call.cancel = cancel
+
+	t.mu.Lock()
+	if t.calls == nil {
+		t.calls = make(map[int]inflightCall)
+	}
+	id := t.next
+	t.next++
+	t.calls[id] = call
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.calls, id)
+		t.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelAll cancels every call in flight and returns them.
+func (t *callTable) cancelAll() []inflightCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	calls := make([]inflightCall, 0, len(t.calls))
+	for id, call := range t.calls {
+		call.cancel(ErrAborted)
+		calls = append(calls, call)
+		delete(t.calls, id)
+	}
+	return calls
+}
+
+// abortedErr marks err as caused by AbortAll if ctx was cancelled by it.
+func abortedErr(ctx context.Context, err error) error {
+	if !errors.Is(err, ErrAborted) && errors.Is(context.Cause(ctx), ErrAborted) {
+		return fmt.Errorf("%w: %w", ErrAborted, err)
+	}
+	return err
+}
+
+// AbortAll cancels every Run, RunContext and Command call in flight through this handle, including
+// background processes started with Command().Start, which then fail with ErrAborted. The server
+// is asked to terminate what they were running: command processes are killed along with all their
+// descendants, and Python code in the REPL is interrupted with SIGINT, which raises
+// KeyboardInterrupt but keeps the REPL's state. Node.js code can't be interrupted without losing
+// the REPL, so it is left to finish.
+//
+// Background processes are found by the PIDs they record, and the shells launching them by a
+// command line naming their own PID file. Commands run with Run and RunContext are told apart by
+// their command line alone, so identical commands run through other handles at the same time are
+// killed too.
+func (ls *langSandbox) AbortAll(ctx context.Context) error {
+	if ls.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	calls := ls.b.calls.cancelAll()
+	if len(calls) == 0 {
+		return nil
+	}
+
+	// targets are "SIGNAL:ARGV" with the arguments of ARGV separated by newlines, as the script
+	// reads /proc/PID/cmdline, or "PIDFILE:PATH" for the process whose PID is recorded in PATH
+	var targets []string
+	interruptRepl := false
+	for _, call := range calls {
+		if call.pidFile != "" {
+			targets = append(targets, "PIDFILE:"+call.pidFile)
+		}
+		switch {
+		case call.argv != nil:
+			targets = append(targets, "KILL:"+strings.Join(call.argv, "\n"))
+		case call.lang == langPython && !interruptRepl:
+			targets = append(targets, "INT:"+strings.Join(pythonReplArgv, "\n"))
+			interruptRepl = true
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	// processes are stopped before their children are collected, so they can't spawn new ones
+	script := strings.Join([]string{
+		`kill_tree() { kill -s STOP "$1" 2>/dev/null; for c in $(cat /proc/"$1"/task/*/children 2>/dev/null); do kill_tree "$c"; done; kill -s KILL "$1" 2>/dev/null; }`,
+		`for t in "$@"; do`,
+		`  case "$t" in PIDFILE:*) f=${t#PIDFILE:}; [ -s "$f" ] && kill_tree "$(cat "$f")";; esac`,
+		`done`,
+		`for p in /proc/[0-9]*; do`,
+		`  cmdline=$(tr '\0' '\n' < "$p/cmdline" 2>/dev/null) || continue`,
+		`  for t in "$@"; do`,
+		`    [ "$cmdline" = "${t#*:}" ] || continue`,
+		`    if [ "${t%%:*}" = KILL ]; then kill_tree "${p#/proc/}"; else kill -s "${t%%:*}" "${p#/proc/}" 2>/dev/null; fi`,
+		`  done`,
+		`done`,
+		`true`,
+	}, "\n")
+	args := append([]string{"-c", script, "sh"}, targets...)
+	if _, err := ls.b.rpcClient.runCommand(ctx, &ls.b.cfg, RootUser, "sh", args); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToSignalProcess, err)
+	}
+	return nil
+}
+
+// Abort-related errors
+var (
+	ErrAborted = errors.New("aborted")
+)
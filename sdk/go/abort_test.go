@@ -0,0 +1,64 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+// TestAbortAllTargetsProcessPIDFiles checks that AbortAll kills background processes by the PID
+// they recorded rather than by their command line, which other handles' processes may share.
+func TestAbortAllTargetsProcessPIDFiles(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	running, release := make(chan struct{}), make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	killArgs := make(chan []string, 1)
+	srv.Handle("sandbox.command.run", func(params json.RawMessage) (any, error) {
+		var p struct {
+			Args []string `json:"args"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if len(p.Args) > 1 && strings.Contains(p.Args[1], "kill_tree") {
+			killArgs <- p.Args
+		} else {
+			close(running)
+			<-release
+		}
+		return map[string]any{"command": "sh", "exit_code": 0, "success": true, "output": []any{}}, nil
+	})
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"))
+	if err := sandbox.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+
+	ctx := context.Background()
+	process, err := sandbox.Command().Start(ctx, "sleep", []string{"60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-running
+	if err := sandbox.AbortAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	args := <-killArgs
+	if want := "PIDFILE:" + procDir + "/" + process.ID + ".pid"; !slices.Contains(args, want) {
+		t.Errorf("abort targets %q, want %q among them", args[3:], want)
+	}
+	for _, target := range args[3:] {
+		if target == "KILL:sleep\n60" {
+			t.Error("abort targets every sleep 60 in the sandbox")
+		}
+	}
+	if _, err := process.Wait(); !errors.Is(err, ErrAborted) {
+		t.Errorf("Wait = %v, want ErrAborted", err)
+	}
+}
@@ -0,0 +1,145 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var _ ArtifactStore = (*FileArtifactStore)(nil)
+
+// FileArtifactStore is an ArtifactStore backed by a directory on the local filesystem.
+// Each artifact is stored as a regular file at root/<key>.
+type FileArtifactStore struct {
+	root string
+}
+
+// NewFileArtifactStore creates a filesystem-backed store rooted at dir, creating the directory if needed.
+func NewFileArtifactStore(dir string) (*FileArtifactStore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToCreateArtifactStore, err)
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToCreateArtifactStore, err)
+	}
+	return &FileArtifactStore{root: abs}, nil
+}
+
+// Put writes the artifact to a temporary file first and renames it into place,
+// so concurrent readers never observe a partially written artifact.
+func (s *FileArtifactStore) Put(ctx context.Context, key string, r io.Reader) (err error) {
+	if err := validateArtifactKey(key); err != nil {
+		return err
+	}
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".artifact-*")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, contextReader{ctx, r}); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+	return nil
+}
+
+func (s *FileArtifactStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateArtifactKey(key); err != nil {
+		return nil, err
+	}
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToGetArtifact, err)
+	}
+	return f, nil
+}
+
+func (s *FileArtifactStore) List(ctx context.Context, prefix string) ([]ArtifactInfo, error) {
+	var infos []ArtifactInfo
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".artifact-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, ArtifactInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToListArtifacts, err)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+// path returns the file of key, which must be valid. Keys that wouldn't be local to the root on
+// this host, such as Windows device names, fail with ErrInvalidArtifactKey.
+func (s *FileArtifactStore) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return "", ErrInvalidArtifactKey
+	}
+	return filepath.Join(s.root, name), nil
+}
+
+// contextReader stops an in-progress copy once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
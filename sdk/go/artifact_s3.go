@@ -0,0 +1,381 @@
+package msb
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var _ ArtifactStore = (*S3ArtifactStore)(nil)
+
+// S3Config configures an S3ArtifactStore.
+//
+// Any S3-compatible service can be used by setting Endpoint, e.g. MinIO, or Google Cloud Storage
+// through its XML API ("https://storage.googleapis.com") with HMAC interoperability keys.
+type S3Config struct {
+	Bucket          string       // Bucket name (required)
+	Region          string       // Region used for request signing; defaults to "us-east-1"
+	Endpoint        string       // Service endpoint; defaults to "https://s3.<region>.amazonaws.com"
+	AccessKeyID     string       // Access key; defaults to the AWS_ACCESS_KEY_ID environment variable
+	SecretAccessKey string       // Secret key; defaults to the AWS_SECRET_ACCESS_KEY environment variable
+	SessionToken    string       // Optional session token; defaults to the AWS_SESSION_TOKEN environment variable
+	Prefix          string       // Optional key prefix prepended to every artifact key
+	PathStyle       bool         // Address the bucket as <endpoint>/<bucket> instead of <bucket>.<endpoint>
+	HTTPClient      *http.Client // HTTP client to use; defaults to http.DefaultClient
+}
+
+// S3ArtifactStore is an ArtifactStore backed by an S3-compatible object store.
+// Requests are signed with AWS Signature Version 4 using only the standard library.
+type S3ArtifactStore struct {
+	cfg      S3Config
+	endpoint *url.URL
+}
+
+// NewS3ArtifactStore creates an S3-backed store, filling unset credentials from the environment.
+func NewS3ArtifactStore(cfg S3Config) (*S3ArtifactStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("%w: bucket must be specified", ErrFailedToCreateArtifactStore)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if cfg.SecretAccessKey == "" {
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if cfg.SessionToken == "" {
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%w: S3 credentials must be specified", ErrFailedToCreateArtifactStore)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("%w: invalid endpoint %q", ErrFailedToCreateArtifactStore, cfg.Endpoint)
+	}
+	return &S3ArtifactStore{cfg: cfg, endpoint: endpoint}, nil
+}
+
+// Put uploads the artifact. Readers that are not seekable are spooled to a temporary file first,
+// since S3 requires the content length up front; the content is never buffered in memory.
+func (s *S3ArtifactStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := validateArtifactKey(key); err != nil {
+		return err
+	}
+
+	body, size, cleanup, err := sizedBody(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+	defer cleanup()
+
+	// hide any Close method so the transport doesn't close a caller-owned file
+	req, err := s.newRequest(ctx, http.MethodPut, s.cfg.Prefix+key, nil, struct{ io.Reader }{body})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %w", ErrFailedToPutArtifact, s3ResponseError(resp))
+	}
+	return nil
+}
+
+func (s *S3ArtifactStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateArtifactKey(key); err != nil {
+		return nil, err
+	}
+	req, err := s.newRequest(ctx, http.MethodGet, s.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToGetArtifact, err)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToGetArtifact, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrArtifactNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("%w: %w", ErrFailedToGetArtifact, s3ResponseError(resp))
+	}
+}
+
+func (s *S3ArtifactStore) List(ctx context.Context, prefix string) ([]ArtifactInfo, error) {
+	var (
+		infos []ArtifactInfo
+		token string
+	)
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {s.cfg.Prefix + prefix},
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToListArtifacts, err)
+		}
+
+		page, err := s.listPage(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToListArtifacts, err)
+		}
+		for _, obj := range page.Contents {
+			infos = append(infos, ArtifactInfo{
+				Key:     strings.TrimPrefix(obj.Key, s.cfg.Prefix),
+				Size:    obj.Size,
+				ModTime: obj.LastModified,
+			})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+type s3ListBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+func (s *S3ArtifactStore) listPage(req *http.Request) (*s3ListBucketResult, error) {
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3ResponseError(resp)
+	}
+	var page s3ListBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// newRequest builds a request against the bucket; an empty objectKey addresses the bucket itself.
+func (s *S3ArtifactStore) newRequest(ctx context.Context, method, objectKey string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	objectPath := "/" + objectKey
+	if s.cfg.PathStyle {
+		objectPath = "/" + s.cfg.Bucket + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	u.Path = objectPath
+	u.RawPath = s3URIEncode(objectPath, false)
+	u.RawQuery = s3CanonicalQuery(query)
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+func (s *S3ArtifactStore) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now().UTC())
+	return s.cfg.HTTPClient.Do(req)
+}
+
+// sign applies AWS Signature Version 4 to req. The payload is left unsigned so bodies can be streamed.
+func (s *S3ArtifactStore) sign(req *http.Request, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// sizedBody returns a seekable body for r along with its size, spooling to a temporary file if needed.
+func sizedBody(r io.Reader) (io.ReadSeeker, int64, func(), error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		end, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if _, err := rs.Seek(start, io.SeekStart); err != nil {
+			return nil, 0, nil, err
+		}
+		return rs, end - start, func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "msb-artifact-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return tmp, size, cleanup, nil
+}
+
+// s3ResponseError summarizes a non-successful S3 response using its XML error code when present.
+func s3ResponseError(resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err == nil && body.Code != "" {
+		return fmt.Errorf("%w: status %d: %s: %s", ErrS3RequestFailed, resp.StatusCode, body.Code, body.Message)
+	}
+	return fmt.Errorf("%w: status %d", ErrS3RequestFailed, resp.StatusCode)
+}
+
+// s3URIEncode percent-encodes s as required by SigV4: everything but unreserved characters
+// (and '/', unless encodeSlash is set) is encoded with upper-case hex digits.
+func s3URIEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery encodes query parameters sorted by key, as SigV4 expects.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3URIEncode(k, true)+"="+s3URIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// S3-artifact-store-related errors
+var (
+	ErrS3RequestFailed = errors.New("S3 request failed")
+)
@@ -0,0 +1,58 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ArtifactStore persists execution outputs and other artifacts outside of process memory.
+// Keys are slash-separated paths (e.g. "runs/42/stdout.txt") without backslashes or colons, which
+// Windows file systems would read as separators and volume names; implementations map them onto
+// their own storage layout. All implementations are safe for concurrent use.
+type ArtifactStore interface {
+	// Put stores the content read from r under key, replacing any existing artifact.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the artifact stored under key. The caller must close the returned reader.
+	// Returns ErrArtifactNotFound if no such artifact exists.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns every artifact whose key starts with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]ArtifactInfo, error)
+}
+
+// ArtifactInfo describes a stored artifact.
+type ArtifactInfo struct {
+	Key     string    // Key the artifact is stored under
+	Size    int64     // Size in bytes
+	ModTime time.Time // Last modification time
+}
+
+// validateArtifactKey rejects keys that are empty, absolute, or would escape the store's root,
+// on any host the store may be kept on.
+func validateArtifactKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") || strings.ContainsAny(key, `\:`) {
+		return ErrInvalidArtifactKey
+	}
+	if path.Clean(key) != key {
+		return ErrInvalidArtifactKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." || segment == "." {
+			return ErrInvalidArtifactKey
+		}
+	}
+	return nil
+}
+
+// Artifact-store-related errors
+var (
+	ErrFailedToCreateArtifactStore = errors.New("failed to create artifact store")
+	ErrArtifactNotFound            = errors.New("artifact not found")
+	ErrInvalidArtifactKey          = errors.New("invalid artifact key")
+	ErrFailedToPutArtifact         = errors.New("failed to put artifact")
+	ErrFailedToGetArtifact         = errors.New("failed to get artifact")
+	ErrFailedToListArtifacts       = errors.New("failed to list artifacts")
+)
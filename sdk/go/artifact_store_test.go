@@ -0,0 +1,70 @@
+package msb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateArtifactKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"runs/42/stdout.txt", true},
+		{"a.b-c_d", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"runs/", false},
+		{"runs//a", false},
+		{"runs/./a", false},
+		{"../a", false},
+		{"runs/../../a", false},
+		{`..\..\a`, false},
+		{`runs\a`, false},
+		{"C:a", false},
+		{"runs/C:/a", false},
+	}
+	for _, tt := range tests {
+		err := validateArtifactKey(tt.key)
+		if tt.valid && err != nil {
+			t.Errorf("validateArtifactKey(%q) = %v, want valid", tt.key, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidArtifactKey) {
+			t.Errorf("validateArtifactKey(%q) = %v, want ErrInvalidArtifactKey", tt.key, err)
+		}
+	}
+}
+
+func TestFileArtifactStoreStaysInRoot(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileArtifactStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{`..\escaped`, "../escaped", "C:escaped"} {
+		if err := store.Put(ctx, key, bytes.NewReader([]byte("x"))); !errors.Is(err, ErrInvalidArtifactKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidArtifactKey", key, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("files written next to the store: %v", entries)
+	}
+
+	if err := store.Put(ctx, "runs/1/out.txt", bytes.NewReader([]byte("out"))); err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.Get(ctx, "runs/1/out.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if data, _ := io.ReadAll(r); string(data) != "out" {
+		t.Errorf("Get = %q, want %q", data, "out")
+	}
+}
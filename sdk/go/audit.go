@@ -0,0 +1,93 @@
+package msb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"time"
+)
+
+// AuditAction is the kind of operation an AuditEvent records.
+type AuditAction string
+
+const (
+	AuditStart      AuditAction = "start"       // A sandbox was started (or attached to)
+	AuditStop       AuditAction = "stop"        // A sandbox was stopped
+	AuditRunCode    AuditAction = "run_code"    // Code was run in a sandbox's REPL
+	AuditRunCommand AuditAction = "run_command" // A command was run in a sandbox
+)
+
+// AuditEvent records who did what to which sandbox, and when. Its JSON encoding is meant to be
+// shipped to a SIEM as is.
+type AuditEvent struct {
+	Time       time.Time     `json:"time"`                 // When the operation began
+	ServerTime time.Time     `json:"server_time,omitzero"` // Time by the server's clock, once its skew is known
+	Duration   time.Duration `json:"duration"`             // How long it took
+	Action     AuditAction   `json:"action"`
+	Sandbox    string        `json:"sandbox"`          // Sandbox name
+	Server     string        `json:"server"`           // Server URL
+	Tenant     string        `json:"tenant,omitempty"` // Tenant of clients scoped with Client.WithTenant
+
+	Image      string   `json:"image,omitempty"`       // Image started, for AuditStart
+	Language   string   `json:"language,omitempty"`    // REPL language, for AuditRunCode
+	CodeSHA256 string   `json:"code_sha256,omitempty"` // Hex SHA-256 of the code run; the code itself isn't recorded
+	CodeBytes  int      `json:"code_bytes,omitempty"`  // Size of the code run
+	Command    string   `json:"command,omitempty"`     // Command run, for AuditRunCommand
+	Args       []string `json:"args,omitempty"`        // Arguments of the command
+	User       string   `json:"user,omitempty"`        // User the command ran as, if not the sandbox's default
+
+	Caller map[string]string `json:"caller,omitempty"` // Set with ContextWithAuditCaller
+	Error  string            `json:"error,omitempty"`  // Why the operation failed; empty on success
+}
+
+// WithAuditSink has every start, stop, code run and command run through the sandbox reported to
+// sink once it completes, successfully or not, so security teams can feed SIEM systems without
+// proxying the HTTP layer. Commands run on behalf of higher-level calls, such as Pipeline or
+// Command().Start, are reported as the shell command actually run. sink is called synchronously
+// and concurrently, so it should hand events off quickly, e.g. to a buffered channel.
+func WithAuditSink(sink func(AuditEvent)) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.auditSink = sink
+	}
+}
+
+type auditCallerKey struct{}
+
+// ContextWithAuditCaller returns a copy of ctx carrying metadata on who is behind the operations
+// done with it, such as the end user or API client, for the Caller field of audit events.
+func ContextWithAuditCaller(ctx context.Context, caller map[string]string) context.Context {
+	return context.WithValue(ctx, auditCallerKey{}, maps.Clone(caller))
+}
+
+// audit reports an operation that began at begin and failed with err, if it did, to the audit
+// sink. e carries the details specific to the operation.
+func (b *baseMicroSandbox) audit(ctx context.Context, begin time.Time, err error, e AuditEvent) {
+	sink := b.cfg.auditSink
+	if sink == nil {
+		return
+	}
+	e.Time = begin
+	if skew, ok := b.cfg.clock.estimate(); ok {
+		e.ServerTime = skew.ToServer(begin)
+	}
+	e.Duration = time.Since(begin)
+	e.Sandbox = b.cfg.name
+	e.Server = b.cfg.currentServerURL()
+	e.Tenant = b.cfg.tenant
+	e.Args = slices.Clone(e.Args)
+	if caller, ok := ctx.Value(auditCallerKey{}).(map[string]string); ok {
+		e.Caller = maps.Clone(caller)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	sink(e)
+}
+
+// codeSHA256 returns the hex SHA-256 of code.
+func codeSHA256(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,73 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// AuthErrorHandler is called when the server rejects the API key (HTTP 401 or 403), with the
+// status code received. It returns a new API key, e.g. fetched from a secret store, with which
+// the request is retried once; returning an error gives up.
+type AuthErrorHandler func(ctx context.Context, status int) (apiKey string, err error)
+
+// WithAuthErrorHandler configures a handler for rejected API keys. Without one, such requests
+// fail with ErrUnauthorized right away. Keys returned by the handler replace the configured key
+// for every later request of the sandbox, and of all sandboxes derived from the same Client.
+func WithAuthErrorHandler(handler AuthErrorHandler) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.authHandler = handler
+	}
+}
+
+// refreshedKey holds an API key obtained from the AuthErrorHandler, shared by the copies of a
+// config so that a refresh through one sandbox benefits all of them.
+type refreshedKey struct {
+	key atomic.Pointer[string]
+}
+
+// currentAPIKey returns the API key to authenticate requests with.
+func (c *config) currentAPIKey() string {
+	if c.refreshed != nil {
+		if key := c.refreshed.key.Load(); key != nil {
+			return *key
+		}
+	}
+	return c.apiKey
+}
+
+// refreshAPIKey asks the AuthErrorHandler for a new key after the server answered with status.
+// It reports whether the request should be retried.
+func (c *config) refreshAPIKey(ctx context.Context, status int) (bool, error) {
+	if c.authHandler == nil || c.refreshed == nil {
+		return false, nil
+	}
+	key, err := c.authHandler(ctx, status)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrAuthRefreshFailed, err)
+	}
+	c.refreshed.key.Store(&key)
+	c.log(ctx).Info("API key refreshed after authentication failure", "status", status)
+	return true, nil
+}
+
+// UnauthorizedError reports that the server rejected the API key.
+// It matches ErrUnauthorized with errors.Is.
+type UnauthorizedError struct {
+	Status int // HTTP status code, 401 or 403
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("%v: status %d", ErrUnauthorized, e.Status)
+}
+
+func (e *UnauthorizedError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// Authentication-related errors
+var (
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrAuthRefreshFailed = errors.New("failed to refresh API key")
+)
@@ -1,6 +1,7 @@
 package msb
 
 import (
+	"context"
 	"errors"
 	"sync/atomic"
 )
@@ -24,7 +25,18 @@ func newBaseWithOptions(options ...Option) *baseMicroSandbox {
 type baseMicroSandbox struct {
 	cfg       config
 	state     atomic.Uint32 // we use a lightweight primitive to prevent racing starts / stops; every other method is safe to route concurrently to the underlying (thread-safe) http client
+	lifecycle lifecycle     // serializes starts and stops, collapsing concurrent ones into a single request
 	rpcClient rpcClient
+	lease     atomic.Pointer[leaseKeeper]        // set while a lease is held (see WithLease)
+	locks     lockTable                          // server-side locks held through this handle
+	calls     callTable                          // executions in flight through this handle
+	info      atomic.Pointer[SandboxInfo]        // configuration of the running sandbox, set on start
+	keepAlive atomic.Pointer[context.CancelFunc] // stops the keep-alive pings (see WithKeepAlive)
+	gauges    atomic.Pointer[context.CancelFunc] // stops reading metrics into the gauges (see WithMetricsGauges)
+	image     atomic.Pointer[imageRef]           // ID of the running sandbox's image (see WithExecutionCache)
+	envGen    atomic.Pointer[envGeneration]      // packages installed into the running sandbox (see WithExecutionCache)
+	venv      atomic.Pointer[activeVenv]         // virtualenv in use (see VenvManager)
+	node      atomic.Pointer[nodeProject]        // project installed into (see NodeManager)
 }
 
 var (
@@ -0,0 +1,96 @@
+package msb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+)
+
+// MetricsAll returns the metrics of every sandbox in the tenant namespace, keyed by sandbox name,
+// read in a single request rather than one per sandbox. An empty namespace stands for the client's
+// tenant, or every sandbox on the server if the client isn't scoped to one. A client scoped to a
+// tenant can't read another tenant's metrics: it fails with ErrInvalidTenant.
+func (c *Client) MetricsAll(ctx context.Context, namespace string) (map[string]MetricsV2, error) {
+	if namespace != "" {
+		if err := checkName(nameKindTenant, namespace, maxTenantIDLen, "-"); err != nil {
+			return nil, err
+		}
+		if c.cfg.tenant != "" && namespace != c.cfg.tenant {
+			return nil, fmt.Errorf("%w: scoped to %s", ErrInvalidTenant, c.cfg.tenant)
+		}
+	}
+	scope := config{tenant: cmp.Or(namespace, c.cfg.tenant)}
+
+	ctx, cancel := withTimeout(ctx, c.cfg.timeouts.Metrics)
+	defer cancel()
+	all, err := c.rpcClient.listMetrics(ctx, &c.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
+	}
+	metrics := make(map[string]MetricsV2)
+	for _, m := range all {
+		if scope.inScope(m.Name) {
+			metrics[m.Name] = m.publicV2()
+		}
+	}
+	return metrics, nil
+}
+
+// Metrics returns the metrics of the pool's sandboxes, idle or checked out, keyed by sandbox name.
+// The sandboxes of each server are read in a single request rather than one each; those the server
+// doesn't report on have IsRunning false.
+func (p *Pool) Metrics(ctx context.Context) (map[string]MetricsV2, error) {
+	return batchMetrics(ctx, p.Sandboxes())
+}
+
+// metricsSource identifies the sandboxes whose metrics one request reads.
+type metricsSource struct {
+	rpcClient rpcClient
+	server    string
+}
+
+// batchMetrics reads the metrics of sandboxes with a request per server.
+func batchMetrics(ctx context.Context, sandboxes []LangSandBox) (map[string]MetricsV2, error) {
+	metrics := make(map[string]MetricsV2, len(sandboxes))
+	groups := make(map[metricsSource][]*baseMicroSandbox)
+	var order []metricsSource
+	for _, sb := range sandboxes {
+		ls, ok := sb.(*langSandbox)
+		if !ok {
+			m, err := sb.Metrics().AllV2()
+			if err != nil {
+				return nil, err
+			}
+			metrics[m.Name] = m
+			continue
+		}
+		src := metricsSource{ls.b.rpcClient, ls.b.cfg.currentServerURL()}
+		if _, ok := groups[src]; !ok {
+			order = append(order, src)
+		}
+		groups[src] = append(groups[src], ls.b)
+	}
+
+	for _, src := range order {
+		group := groups[src]
+		cfg := &group[0].cfg
+		reqCtx, cancel := withTimeout(ctx, cfg.timeouts.Metrics)
+		all, err := src.rpcClient.listMetrics(reqCtx, cfg)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
+		}
+		reported := make(map[string]*sandboxMetrics, len(all))
+		for i := range all {
+			reported[all[i].Name] = &all[i]
+		}
+		for _, b := range group {
+			if m, ok := reported[b.cfg.name]; ok {
+				metrics[b.cfg.name] = m.publicV2()
+			} else {
+				metrics[b.cfg.name] = MetricsV2{Name: b.cfg.name}
+			}
+		}
+	}
+	return metrics, nil
+}
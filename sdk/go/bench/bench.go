@@ -0,0 +1,55 @@
+// Package bench runs the SDK's benchmarks and checks their results for regressions.
+//
+// The benchmarks live in the SDK's _test.go files, next to the code they measure, and run with
+// `go test -bench`. By default they run against the in-process fake server from package msbtest,
+// so they measure the cost of the SDK and its transport rather than sandbox execution time;
+// Target points them at a real server instead. Run drives them through the go command, which must
+// be on PATH, from within the SDK's module, and Compare checks the results against saved ones.
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// sdkPackage is the package whose benchmarks are run.
+const sdkPackage = "github.com/microsandbox/microsandbox/sdk/go"
+
+// Target is a server to run the benchmarks against.
+type Target struct {
+	ServerURL string // Server URL; empty for a fresh in-process fake server per benchmark
+	APIKey    string // API key for ServerURL
+	Image     string // Image sandboxes are started from; empty for the server's default
+}
+
+// env returns the environment variables the benchmarks read their target from.
+func (t Target) env() []string {
+	return []string{
+		"MSB_BENCH_SERVER=" + t.ServerURL,
+		"MSB_BENCH_API_KEY=" + t.APIKey,
+		"MSB_BENCH_IMAGE=" + t.Image,
+	}
+}
+
+// Run runs the benchmarks whose names match the regular expression filter against target, count
+// times each, and returns for each the run with the median time per operation, which is less
+// sensitive to a noisy machine than a single run or the mean. The go command's own output goes to
+// stderr.
+func Run(target Target, filter string, count int) ([]Result, error) {
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", filter, "-benchmem",
+		"-count", strconv.Itoa(max(count, 1)), sdkPackage)
+	cmd.Env = append(os.Environ(), target.env()...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go test: %w\n%s", err, bytes.TrimSpace(out))
+	}
+	results, err := Parse(bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	return Median(results), nil
+}
@@ -0,0 +1,137 @@
+package bench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Result is the outcome of a benchmark, in a form that can be saved and compared across runs.
+type Result struct {
+	Name        string             `json:"name"`
+	N           int                `json:"n"`
+	NsPerOp     float64            `json:"ns_per_op"`
+	AllocsPerOp int64              `json:"allocs_per_op"`
+	BytesPerOp  int64              `json:"bytes_per_op"`
+	Extra       map[string]float64 `json:"extra,omitempty"` // Custom metrics, e.g. "runs/s"
+}
+
+// String formats r like `go test -bench` does.
+func (r Result) String() string {
+	s := fmt.Sprintf("Benchmark%-24s %8d\t%10.0f ns/op", r.Name, r.N, r.NsPerOp)
+	for _, unit := range slices.Sorted(maps.Keys(r.Extra)) {
+		s += fmt.Sprintf("\t%10.2f %s", r.Extra[unit], unit)
+	}
+	return s + fmt.Sprintf("\t%8d B/op\t%8d allocs/op", r.BytesPerOp, r.AllocsPerOp)
+}
+
+// procsSuffix is the GOMAXPROCS suffix `go test` appends to benchmark names, e.g. "-8".
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// Parse reads the results of benchmarks from the output of `go test -bench`, one per line and
+// run, in order. Other lines are skipped.
+func Parse(r io.Reader) ([]Result, error) {
+	var results []Result
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// a name and an iteration count, followed by value-unit pairs
+		if len(fields) < 4 || len(fields)%2 != 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		result := Result{Name: procsSuffix.ReplaceAllString(strings.TrimPrefix(fields[0], "Benchmark"), ""), N: n}
+		for i := 2; i < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("benchmark %s: invalid %s value %q", result.Name, fields[i+1], fields[i])
+			}
+			switch unit := fields[i+1]; unit {
+			case "ns/op":
+				result.NsPerOp = value
+			case "B/op":
+				result.BytesPerOp = int64(value)
+			case "allocs/op":
+				result.AllocsPerOp = int64(value)
+			default:
+				if result.Extra == nil {
+					result.Extra = make(map[string]float64)
+				}
+				result.Extra[unit] = value
+			}
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+// Median keeps, of the runs of each benchmark, the one with the median time per operation. The
+// benchmarks keep the order of their first runs.
+func Median(results []Result) []Result {
+	var names []string
+	runs := make(map[string][]Result)
+	for _, r := range results {
+		if runs[r.Name] == nil {
+			names = append(names, r.Name)
+		}
+		runs[r.Name] = append(runs[r.Name], r)
+	}
+	medians := make([]Result, 0, len(names))
+	for _, name := range names {
+		rs := runs[name]
+		slices.SortFunc(rs, func(a, b Result) int {
+			switch {
+			case a.NsPerOp < b.NsPerOp:
+				return -1
+			case a.NsPerOp > b.NsPerOp:
+				return 1
+			default:
+				return 0
+			}
+		})
+		medians = append(medians, rs[len(rs)/2])
+	}
+	return medians
+}
+
+// Regression is a benchmark that got slower than its baseline.
+type Regression struct {
+	Name     string
+	Baseline float64 // ns/op of the baseline
+	Current  float64 // ns/op now
+	Change   float64 // Relative slowdown, e.g. 0.25 for 25% slower
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %.0f ns/op -> %.0f ns/op (+%.1f%%)", r.Name, r.Baseline, r.Current, r.Change*100)
+}
+
+// Compare returns the benchmarks of current whose time per operation exceeds their baseline's by
+// more than threshold, e.g. 0.1 for 10%. Benchmarks missing from either side are ignored.
+func Compare(baseline, current []Result, threshold float64) []Regression {
+	var regressions []Regression
+	for _, cur := range current {
+		i := slices.IndexFunc(baseline, func(r Result) bool { return r.Name == cur.Name })
+		if i == -1 || baseline[i].NsPerOp <= 0 {
+			continue
+		}
+		change := cur.NsPerOp/baseline[i].NsPerOp - 1
+		if change > threshold {
+			regressions = append(regressions, Regression{
+				Name:     cur.Name,
+				Baseline: baseline[i].NsPerOp,
+				Current:  cur.NsPerOp,
+				Change:   change,
+			})
+		}
+	}
+	return regressions
+}
@@ -0,0 +1,28 @@
+package msb
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize caps the capacity of buffers returned to the pool, so a single huge
+// request or response doesn't pin a large allocation for the lifetime of the process.
+const maxPooledBufferSize = 1 << 20 // 1 MiB
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the shared pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets buf and returns it to the shared pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,53 @@
+package msb
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// BenchmarkReadResponse compares reading responses into pooled buffers, as readJSONRPCResponse
+// does, with reading them into fresh ones.
+func BenchmarkReadResponse(b *testing.B) {
+	for _, size := range []struct {
+		name string
+		body string
+	}{
+		{"small", `{"jsonrpc":"2.0","id":"1","result":{"status":"success","output":[]}}`},
+		{"256KiB", `{"jsonrpc":"2.0","id":"1","result":{"output":"` + strings.Repeat("0123456789abcdef", 16<<10) + `"}}`},
+	} {
+		b.Run(size.name+"/pooled", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(size.body)))
+			for b.Loop() {
+				buf := getBuffer()
+				if _, err := buf.ReadFrom(strings.NewReader(size.body)); err != nil {
+					b.Fatal(err)
+				}
+				putBuffer(buf)
+			}
+		})
+		b.Run(size.name+"/fresh", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(size.body)))
+			for b.Loop() {
+				// what the client did before buffers were pooled
+				if _, err := io.ReadAll(strings.NewReader(size.body)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestBufferPoolCap checks that buffers grown past maxPooledBufferSize aren't kept.
+func TestBufferPoolCap(t *testing.T) {
+	big := bytes.NewBuffer(make([]byte, 0, 2*maxPooledBufferSize))
+	putBuffer(big)
+	for range 100 {
+		if buf := getBuffer(); buf == big {
+			t.Fatal("oversized buffer returned to the pool")
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// Builder holds the configuration of sandboxes that aren't started yet, and hands out a Sandbox
+// handle only once one runs. Unlike the handles NewPythonSandbox and NewNodeSandbox return, which
+// accept calls before Start and fail them with ErrSandboxNotStarted, there is no handle on a
+// sandbox that isn't running, and invalid options or StartConfig fields are reported when the
+// Builder is created rather than when it is used.
+//
+// A Builder can start any number of sandboxes and is safe for concurrent use. Without WithName,
+// each one gets a name of its own.
+//
+// Example:
+//
+//	builder, err := msb.NewPythonBuilder(msb.StartConfig{Memory: 1024}, msb.WithApiKey(key))
+//	if err != nil {
+//		log.Fatal(err) // invalid configuration
+//	}
+//	sandbox, err := builder.Start(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer sandbox.Stop()
+type Builder struct {
+	lang    progLang
+	options []Option
+	cfg     StartConfig
+}
+
+// NewPythonBuilder returns a Builder for Python sandboxes started with cfg and options, or the
+// first problem found with them. A missing API key fails with ErrAPIKeyMustBeSpecified instead of
+// panicking.
+func NewPythonBuilder(cfg StartConfig, options ...Option) (*Builder, error) {
+	return newBuilder(langPython, cfg, nil, options)
+}
+
+// NewNodeBuilder returns a Builder for Node.js sandboxes started with cfg and options, or the
+// first problem found with them. A missing API key fails with ErrAPIKeyMustBeSpecified instead of
+// panicking.
+func NewNodeBuilder(cfg StartConfig, options ...Option) (*Builder, error) {
+	return newBuilder(langNodeJs, cfg, nil, options)
+}
+
+// NewPythonBuilder is like the package-level NewPythonBuilder, but the sandboxes share the
+// client's transport and settings.
+func (c *Client) NewPythonBuilder(cfg StartConfig, options ...Option) (*Builder, error) {
+	return newBuilder(langPython, cfg, []Option{c.inherit()}, options)
+}
+
+// NewNodeBuilder is like the package-level NewNodeBuilder, but the sandboxes share the client's
+// transport and settings.
+func (c *Client) NewNodeBuilder(cfg StartConfig, options ...Option) (*Builder, error) {
+	return newBuilder(langNodeJs, cfg, []Option{c.inherit()}, options)
+}
+
+func newBuilder(lang progLang, cfg StartConfig, base, options []Option) (*Builder, error) {
+	options = append(base, options...)
+	probe := &baseMicroSandbox{}
+	for _, opt := range options {
+		opt(probe)
+	}
+	if probe.cfg.needsAPIKey() && os.Getenv("MSB_API_KEY") == "" {
+		return nil, ErrAPIKeyMustBeSpecified
+	}
+	probeOptions := slices.Clip(options)
+	if probe.cfg.name == "" {
+		// keeps name generators from handing out a name for the probe
+		probeOptions = append(probeOptions, WithName("builder"))
+	}
+	b := newBaseWithOptions(probeOptions...)
+	if err := b.cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Image == "" {
+		cfg.Image = lang.DefaultImage()
+	}
+	if _, err := cfg.params(isLocalServer(b.cfg.serverUrl)); err != nil {
+		return nil, err
+	}
+	return &Builder{lang: lang, options: slices.Clip(options), cfg: cfg}, nil
+}
+
+// Start starts a new sandbox and returns a handle on it. A failed start leaves nothing running:
+// a sandbox the server already created is stopped again (one attached to with
+// NameConflictAttach is left alone).
+func (b *Builder) Start(ctx context.Context) (Sandbox, error) {
+	sb := newLangSandbox(b.lang, b.options...)
+	if err := sb.StartContext(ctx, b.cfg); err != nil {
+		if sb.b.state.Load() == started {
+			if info := sb.b.info.Load(); info != nil && info.Attached {
+				abandon(sb.b)
+			} else if stopErr := sb.StopContext(context.WithoutCancel(ctx)); stopErr != nil {
+				sb.b.cfg.log(ctx).Error("Failed to stop sandbox after failed start", "name", sb.b.cfg.name, "error", stopErr)
+				abandon(sb.b)
+			}
+		}
+		return nil, err
+	}
+	return sb, nil
+}
+
+// Attach returns a handle on the sandbox named name already running on the server, e.g. one
+// started by another process, using the builder's options. It waits for a sandbox that is still
+// starting, and fails with ErrSandboxNotRunning if there is none. The handle's Info reports the
+// builder's StartConfig, with Attached set.
+func (b *Builder) Attach(ctx context.Context, name string) (Sandbox, error) {
+	sb := newLangSandbox(b.lang, append(b.options, WithName(name))...)
+	begin := time.Now()
+	err := b.attach(ctx, sb)
+	sb.b.audit(ctx, begin, err, AuditEvent{Action: AuditStart, Image: b.cfg.Image})
+	if err != nil {
+		return nil, err
+	}
+	return sb, nil
+}
+
+func (b *Builder) attach(ctx context.Context, sb *langSandbox) error {
+	if sb.b.cfg.nameErr != nil {
+		return sb.b.cfg.nameErr
+	}
+	sc, err := b.cfg.params(isLocalServer(sb.b.cfg.serverUrl))
+	if err != nil {
+		return err
+	}
+	status, err := readStatus(ctx, sb.b)
+	if err != nil {
+		return err
+	}
+	if status == StatusStarting {
+		if err := waitUntilRunning(ctx, sb.b); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrSandboxNotRunning, sb.b.cfg.name, err)
+		}
+	} else if status != StatusRunning {
+		return fmt.Errorf("%w: %s is %s", ErrSandboxNotRunning, sb.b.cfg.name, status)
+	}
+	if !liveNames.reserve(sb.b.cfg.serverUrl, sb.b.cfg.name) {
+		return &NameConflictError{Name: sb.b.cfg.name}
+	}
+	if err := (starter{b: sb.b}).adopt(ctx, b.cfg, sc); err != nil {
+		abandon(sb.b)
+		return err
+	}
+	return nil
+}
+
+// abandon lets go of a started handle without stopping its sandbox.
+func abandon(b *baseMicroSandbox) {
+	b.state.Store(off)
+	markStopped(b)
+}
+
+// validate returns the error an invalid option left in the configuration, which would otherwise
+// only surface on the first request.
+func (c *config) validate() error {
+	if c.serverUrlErr != nil {
+		return c.serverUrlErr
+	}
+	if c.nameErr != nil {
+		return c.nameErr
+	}
+	if c.discovery != nil {
+		if err := c.discovery.validate(); err != nil {
+			return err
+		}
+	}
+	if c.policy != nil && c.policy.err != nil {
+		return c.policy.err
+	}
+	return nil
+}
+
+// Builder-related errors
+var (
+	ErrSandboxNotRunning = errors.New("sandbox not running")
+)
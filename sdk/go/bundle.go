@@ -0,0 +1,304 @@
+package msb
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bundleVersion is the version of the replay bundle layout, recorded in its manifest.
+const bundleVersion = 1
+
+// maxBundleArtifactBytes bounds the size of each file a bundle carries as an artifact.
+const maxBundleArtifactBytes = 1 << 20
+
+// execRequest is what an execution ran: code, or a command as the caller gave it.
+type execRequest struct {
+	Language string   `json:"language,omitempty"` // REPL language, for code
+	Code     string   `json:"-"`                  // bundled as a file of its own
+	Command  string   `json:"command,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	User     string   `json:"user,omitempty"`
+	Env      []string `json:"env,omitempty"` // names of the variables the command ran with; values may be secrets
+
+	lang progLang
+}
+
+// execSource is where an execution ran, for its replay bundle.
+type execSource struct {
+	b       *baseMicroSandbox
+	info    *SandboxInfo // configuration of the sandbox at the time; nil if unknown
+	request execRequest
+}
+
+// bundleManifest is the manifest.json of a replay bundle.
+type bundleManifest struct {
+	Version    int         `json:"version"`
+	Kind       string      `json:"kind"` // "code" or "command"
+	ExportedAt time.Time   `json:"exported_at"`
+	Sandbox    string      `json:"sandbox,omitempty"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Server     string      `json:"server,omitempty"`
+	Request    execRequest `json:"request"`
+	CodeFile   string      `json:"code_file,omitempty"`
+	Sent       time.Time   `json:"sent,omitzero"`
+	Received   time.Time   `json:"received,omitzero"`
+	Cached     bool        `json:"cached,omitempty"`  // the result came from the execution cache
+	Skipped    []string    `json:"skipped,omitempty"` // what was left out of the bundle, and why
+}
+
+// ExportBundle writes a replay bundle of the execution to w: a gzipped tar archive with what is
+// needed to understand, and reproduce, a run that went wrong, to attach to a bug report:
+//
+//	manifest.json  what ran, where and when, and what was left out of the bundle
+//	code.py        the code that ran (code.js for JavaScript)
+//	config.json    the configuration the sandbox was started with, as a SandboxInfo
+//	result.json    the result exactly as the server sent it
+//	stdout.txt     standard output
+//	stderr.txt     standard error
+//	metrics.json   the sandbox's metrics when the bundle was exported
+//
+// Metrics are read from the sandbox at export, so they are skipped if it has stopped since.
+// Credentials are never bundled, nor are the values of environment variables, only their names. msbtest.Server.Replay serves a bundle's result to tests.
+func (ce CodeExecution) ExportBundle(ctx context.Context, w io.Writer) error {
+	stdout, _ := ce.GetOutput()
+	stderr, _ := ce.GetError()
+	bw := newBundleWriter(w, "code", ce.source, ce.Output, stdout, stderr)
+	bw.manifest.Sent, bw.manifest.Received, bw.manifest.Cached = ce.sent, ce.received, ce.cached
+	return bw.write(ctx, nil)
+}
+
+// ExportBundle writes a replay bundle of the command to w, as CodeExecution.ExportBundle does,
+// holding the command and its arguments in manifest.json rather than code. For commands run with
+// RunOptions.TrackFSChanges, it also holds the files the command changed:
+//
+//	fs_changes.json  the files the command created, modified and deleted, as an FSChanges
+//	artifacts/...    the content of the files it created and modified, up to 1 MiB each
+//
+// Artifacts are read from the sandbox at export, like metrics, so they show the files as they are
+// then.
+func (ce CommandExecution) ExportBundle(ctx context.Context, w io.Writer) error {
+	stdout, _ := ce.GetOutput()
+	stderr, _ := ce.GetError()
+	bw := newBundleWriter(w, "command", ce.source, ce.Output, stdout, stderr)
+	bw.manifest.Sent, bw.manifest.Received = ce.sent, ce.received
+	return bw.write(ctx, ce.fsChanges)
+}
+
+type bundleWriter struct {
+	w        io.Writer
+	source   *execSource
+	manifest bundleManifest
+	files    []bundleFile
+}
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+func newBundleWriter(w io.Writer, kind string, source *execSource, result json.RawMessage, stdout, stderr string) *bundleWriter {
+	bw := &bundleWriter{
+		w:        w,
+		source:   source,
+		manifest: bundleManifest{Version: bundleVersion, Kind: kind, ExportedAt: time.Now()},
+	}
+	bw.add("result.json", result)
+	bw.add("stdout.txt", []byte(stdout))
+	bw.add("stderr.txt", []byte(stderr))
+	return bw
+}
+
+func (bw *bundleWriter) add(name string, data []byte) {
+	bw.files = append(bw.files, bundleFile{name, data})
+}
+
+func (bw *bundleWriter) addJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	bw.add(name, data)
+	return nil
+}
+
+// skip records in the manifest why something was left out of the bundle.
+func (bw *bundleWriter) skip(what string, err error) {
+	bw.manifest.Skipped = append(bw.manifest.Skipped, fmt.Sprintf("%s: %v", what, err))
+}
+
+func (bw *bundleWriter) write(ctx context.Context, changes *FSChanges) error {
+	if err := bw.collect(ctx, changes); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToExportBundle, err)
+	}
+	if err := bw.pack(); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToExportBundle, err)
+	}
+	return nil
+}
+
+// collect adds what the execution's source knows to the bundle.
+func (bw *bundleWriter) collect(ctx context.Context, changes *FSChanges) error {
+	src := bw.source
+	if src == nil {
+		bw.skip("request, config and metrics", errors.New("not recorded for this execution"))
+		return nil
+	}
+	m := &bw.manifest
+	m.Sandbox, m.Namespace, m.Server = src.b.cfg.name, src.b.cfg.tenant, src.b.cfg.currentServerURL()
+	m.Request = src.request
+	if m.Kind == "code" {
+		m.CodeFile = "code" + src.request.lang.fileExtension()
+		bw.add(m.CodeFile, []byte(src.request.Code))
+	}
+	if src.info != nil {
+		info := *src.info
+		info.Language = cmp.Or(info.Language, src.request.Language)
+		if err := bw.addJSON("config.json", info); err != nil {
+			return err
+		}
+	} else {
+		bw.skip("config", ErrSandboxNotStarted)
+	}
+
+	if src.b.state.Load() != started {
+		bw.skip("metrics", ErrSandboxNotStarted)
+	} else if metrics, err := bw.metrics(ctx); err != nil {
+		bw.skip("metrics", err)
+	} else if err := bw.addJSON("metrics.json", metrics); err != nil {
+		return err
+	}
+
+	if changes == nil {
+		return nil
+	}
+	if err := bw.addJSON("fs_changes.json", changes); err != nil {
+		return err
+	}
+	paths := append(append([]string(nil), changes.Created...), changes.Modified...)
+	if len(paths) == 0 {
+		return nil
+	}
+	if src.b.state.Load() != started {
+		bw.skip("artifacts", ErrSandboxNotStarted)
+	} else if err := bw.artifacts(ctx, changes.Workdir, paths); err != nil {
+		bw.skip("artifacts", err)
+	}
+	return nil
+}
+
+func (bw *bundleWriter) metrics(ctx context.Context) (*sandboxMetrics, error) {
+	ctx, cancel := withTimeout(ctx, bw.source.b.cfg.timeouts.Metrics)
+	defer cancel()
+	return bw.source.b.rpcClient.getMetrics(ctx, &bw.source.b.cfg)
+}
+
+// artifactScript prints each readable file named by its arguments, relative to the directory $1,
+// in base64 after a line "@<index>", or "!<index> <size>" instead for files that are too large.
+const artifactScript = `cd "$1" || exit 1; shift; i=0
+for f; do
+	if [ -f "$f" ] && [ -r "$f" ]; then
+		size=$(wc -c <"$f")
+		if [ "$size" -gt "$MAX" ]; then echo "!$i $size"; else echo "@$i"; base64 <"$f"; fi
+	fi
+	i=$((i + 1))
+done`
+
+// artifacts adds the files at paths under workdir to the bundle, as they are in the sandbox now.
+func (bw *bundleWriter) artifacts(ctx context.Context, workdir string, paths []string) error {
+	src := bw.source
+	script := "MAX=" + strconv.Itoa(maxBundleArtifactBytes) + "; " + artifactScript
+	args := append([]string{"-c", script, "sh", workdir}, paths...)
+	result, err := src.b.rpcClient.runCommand(ctx, &src.b.cfg, src.request.User, "sh", args)
+	if err != nil {
+		return err
+	}
+	exec := newCommandExecution(result.output)
+	if !exec.IsSuccess() {
+		stderr, _ := exec.GetError()
+		return errors.New(strings.TrimSpace(stderr))
+	}
+
+	current := -1
+	var encoded strings.Builder
+	flush := func() error {
+		if current < 0 {
+			return nil
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", paths[current], err)
+		}
+		bw.add("artifacts/"+paths[current], data)
+		current = -1
+		encoded.Reset()
+		return nil
+	}
+	for _, line := range exec.parsed.Output {
+		if line.Stream != "stdout" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line.Text, "@"), strings.HasPrefix(line.Text, "!"):
+			if err := flush(); err != nil {
+				return err
+			}
+			index, size, _ := strings.Cut(line.Text[1:], " ")
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 || i >= len(paths) {
+				return fmt.Errorf("unexpected output %q", line.Text)
+			}
+			if line.Text[0] == '!' {
+				bw.skip("artifacts/"+paths[i], fmt.Errorf("%s bytes is over the %d byte limit", size, maxBundleArtifactBytes))
+				continue
+			}
+			current = i
+		default:
+			encoded.WriteString(line.Text)
+		}
+	}
+	return flush()
+}
+
+// pack writes the bundle's files, after its manifest, to the archive.
+func (bw *bundleWriter) pack() error {
+	manifest, err := json.MarshalIndent(bw.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(bw.w)
+	tw := tar.NewWriter(gz)
+	for _, f := range append([]bundleFile{{"manifest.json", manifest}}, bw.files...) {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0o644,
+			Size:    int64(len(f.data)),
+			ModTime: bw.manifest.ExportedAt,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, bytes.NewReader(f.data)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Bundle-related errors
+var (
+	ErrFailedToExportBundle = errors.New("failed to export bundle")
+)
@@ -0,0 +1,227 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Cell is a snapshot of a cell of Cells.
+type Cell struct {
+	ID        string        // Identifier assigned when the cell was added
+	Code      string        // Code of the cell
+	Ran       bool          // Whether the cell has run since it was added or last edited
+	Stale     bool          // Whether a cell before it ran since it last ran, so its result may be outdated
+	Execution CodeExecution // Result of the latest run; zero if the cell hasn't run
+	Err       error         // Error of the latest run, if it failed
+}
+
+// Cells models REPL executions as a notebook of cells with IDs, so frontends can re-run a cell, or
+// everything from a cell on, without keeping track of the code themselves. Cells run one at a time
+// in the order they are run, not the order they were added in, as the REPL's state depends on it.
+// A cell becomes stale when a cell before it runs after it did; an edited cell counts as not having
+// run. Cells is safe for concurrent use.
+type Cells struct {
+	code  CodeRunner
+	runMu sync.Mutex // serializes runs, which share the REPL
+
+	mu    sync.Mutex
+	next  int
+	seq   int // incremented for every run
+	cells []*cellState
+}
+
+type cellState struct {
+	id     string
+	code   string
+	ranSeq int // seq of the latest run; 0 if the cell hasn't run since it was added or edited
+	exec   CodeExecution
+	err    error
+}
+
+// NewCells returns an empty notebook whose cells run with code, typically a sandbox's Code().
+func NewCells(code CodeRunner) *Cells {
+	return &Cells{code: code}
+}
+
+// Add appends a cell without running it and returns its ID.
+func (c *Cells) Add(code string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next++
+	id := "cell-" + strconv.Itoa(c.next)
+	c.cells = append(c.cells, &cellState{id: id, code: code})
+	return id
+}
+
+// Run appends a cell with code and runs it.
+func (c *Cells) Run(ctx context.Context, code string) (Cell, error) {
+	return c.ReRun(ctx, c.Add(code))
+}
+
+// Edit replaces the code of the cell id. The cell counts as not having run until it runs again.
+// Returns ErrCellNotFound if there is no such cell.
+func (c *Cells) Edit(id, code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.index(id)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrCellNotFound, id)
+	}
+	c.cells[i] = &cellState{id: id, code: code}
+	return nil
+}
+
+// Remove deletes the cell id. The REPL state it created is not undone.
+// Returns ErrCellNotFound if there is no such cell.
+func (c *Cells) Remove(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.index(id)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrCellNotFound, id)
+	}
+	c.cells = append(c.cells[:i], c.cells[i+1:]...)
+	return nil
+}
+
+// ReRun runs the cell id again, which makes the cells after it stale.
+// Returns ErrCellNotFound if there is no such cell, or the error of running it.
+func (c *Cells) ReRun(ctx context.Context, id string) (Cell, error) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	return c.run(ctx, id)
+}
+
+// RunFrom re-runs the cell id and every cell after it, in order, stopping at the first that fails
+// to run or reports an error, and returns the cells that ran. Returns ErrCellNotFound if there is
+// no such cell.
+func (c *Cells) RunFrom(ctx context.Context, id string) ([]Cell, error) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	c.mu.Lock()
+	i := c.index(id)
+	var ids []string
+	if i >= 0 {
+		for _, cell := range c.cells[i:] {
+			ids = append(ids, cell.id)
+		}
+	}
+	c.mu.Unlock()
+	if ids == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCellNotFound, id)
+	}
+
+	var ran []Cell
+	for _, id := range ids {
+		cell, err := c.run(ctx, id)
+		if errors.Is(err, ErrCellNotFound) {
+			continue // removed while the cells before it ran
+		}
+		ran = append(ran, cell)
+		if err != nil {
+			return ran, err
+		}
+		if cell.Execution.HasError() {
+			return ran, nil
+		}
+	}
+	return ran, nil
+}
+
+// run runs the cell id; the caller holds runMu.
+func (c *Cells) run(ctx context.Context, id string) (Cell, error) {
+	c.mu.Lock()
+	i := c.index(id)
+	if i < 0 {
+		c.mu.Unlock()
+		return Cell{}, fmt.Errorf("%w: %s", ErrCellNotFound, id)
+	}
+	state := c.cells[i]
+	c.mu.Unlock()
+
+	exec, err := c.code.RunContext(ctx, state.code)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.index(id) < 0 || c.cells[c.index(id)] != state {
+		// edited or removed while running; the result belongs to code that is gone
+		return Cell{ID: id, Code: state.code, Ran: true, Execution: exec, Err: err}, err
+	}
+	c.seq++
+	state.ranSeq = c.seq
+	state.exec, state.err = exec, err
+	return c.snapshot(c.index(id)), err
+}
+
+// Get returns the cell id.
+func (c *Cells) Get(id string) (Cell, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.index(id)
+	if i < 0 {
+		return Cell{}, false
+	}
+	return c.snapshot(i), true
+}
+
+// All returns all cells in notebook order.
+func (c *Cells) All() []Cell {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cells := make([]Cell, len(c.cells))
+	for i := range c.cells {
+		cells[i] = c.snapshot(i)
+	}
+	return cells
+}
+
+// Stale returns the IDs of the cells that ran but are stale, in notebook order.
+func (c *Cells) Stale() []string {
+	var ids []string
+	for _, cell := range c.All() {
+		if cell.Stale {
+			ids = append(ids, cell.ID)
+		}
+	}
+	return ids
+}
+
+func (c *Cells) index(id string) int {
+	for i, cell := range c.cells {
+		if cell.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// snapshot returns the cell at i; the caller holds mu.
+func (c *Cells) snapshot(i int) Cell {
+	state := c.cells[i]
+	cell := Cell{
+		ID:        state.id,
+		Code:      state.code,
+		Ran:       state.ranSeq > 0,
+		Execution: state.exec,
+		Err:       state.err,
+	}
+	if !cell.Ran {
+		return cell
+	}
+	for _, before := range c.cells[:i] {
+		if before.ranSeq > state.ranSeq {
+			cell.Stale = true
+			break
+		}
+	}
+	return cell
+}
+
+// Cell-related errors
+var (
+	ErrCellNotFound = errors.New("cell not found")
+)
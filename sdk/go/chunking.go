@@ -0,0 +1,106 @@
+package msb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultCodeChunkThreshold is the code size from which code is uploaded as a file, unless
+	// WithCodeChunking sets another.
+	defaultCodeChunkThreshold = 1 << 20
+	// codeChunkBytes is how much code each upload command carries. Its base64 encoding is passed
+	// as a single argument, which Linux limits to 128 KiB.
+	codeChunkBytes = 64 << 10
+)
+
+// WithCodeChunking sets the size in bytes from which code passed to Run is uploaded to the
+// sandbox as a file in chunks and then executed from it, rather than sent in a single request
+// (1 MiB by default). The code runs in the REPL all the same, sharing its state. A negative
+// threshold turns chunking off.
+func WithCodeChunking(threshold int) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.codeChunkThreshold = threshold
+	}
+}
+
+// chunked reports whether code is large enough to be uploaded as a file.
+func (c *config) chunked(code string) bool {
+	threshold := c.codeChunkThreshold
+	if threshold == 0 {
+		threshold = defaultCodeChunkThreshold
+	}
+	return threshold > 0 && len(code) >= threshold
+}
+
+// runChunked uploads code to a file in the sandbox and runs the file in the REPL. The file is
+// removed afterwards.
+func (cr codeRunner) runChunked(ctx context.Context, code string) (*executionResult, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	path := "/tmp/.msb-code-" + hex.EncodeToString(nonce) + cr.l.fileExtension()
+	defer func() {
+		// best effort; the sandbox's /tmp goes away with it anyway
+		rm := []string{"-f", path}
+		if _, err := cr.b.rpcClient.runCommand(context.WithoutCancel(ctx), &cr.b.cfg, "", "rm", rm); err != nil {
+			cr.b.cfg.log(ctx).Debug("Failed to remove uploaded code", "path", path, "error", err)
+		}
+	}()
+
+	cr.b.cfg.log(ctx).Debug("Uploading large code as a file", "path", path, "size", len(code))
+	if err := cr.b.upload(ctx, path, []byte(code)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUploadCode, err)
+	}
+	return cr.b.rpcClient.runRepl(ctx, &cr.b.cfg, cr.l, cr.l.runFileCode(path))
+}
+
+// upload writes data to the file at path in the sandbox, replacing it, with a command per
+// codeChunkBytes of data.
+func (b *baseMicroSandbox) upload(ctx context.Context, path string, data []byte) error {
+	redirect := ">"
+	for offset := 0; offset == 0 || offset < len(data); offset += codeChunkBytes {
+		chunk := data[offset:min(offset+codeChunkBytes, len(data))]
+		script := `printf %s "$1" | base64 -d ` + redirect + ` "$2"`
+		args := []string{"-c", script, "sh", base64.StdEncoding.EncodeToString(chunk), path}
+		result, err := b.rpcClient.runCommand(ctx, &b.cfg, "", "sh", args)
+		if err != nil {
+			return err
+		}
+		if exec := newCommandExecution(result.output); !exec.IsSuccess() {
+			stderr, _ := exec.GetError()
+			return errors.New(strings.TrimSpace(stderr))
+		}
+		redirect = ">>"
+	}
+	return nil
+}
+
+// fileExtension returns the extension of source files of the language.
+func (l progLang) fileExtension() string {
+	if l == langNodeJs {
+		return ".js"
+	}
+	return ".py"
+}
+
+// runFileCode returns REPL code that runs the source file at path in the REPL's global scope.
+func (l progLang) runFileCode(path string) string {
+	p := strconv.Quote(path)
+	if l == langNodeJs {
+		return `(0, eval)(require("fs").readFileSync(` + p + `, "utf8"))`
+	}
+	return `exec(compile(open(` + p + `).read(), ` + p + `, "exec"))`
+}
+
+// Chunking-related errors
+var (
+	ErrFailedToUploadCode = errors.New("failed to upload code")
+)
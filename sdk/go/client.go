@@ -0,0 +1,86 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Client holds the settings shared by many sandboxes: server URL, authentication, logging,
+// request ID generation, retry policy and, most importantly, a single HTTP transport.
+//
+// Every NewPythonSandbox/NewNodeSandbox call made at package level builds its own transport and
+// connection pool; services managing hundreds of sandboxes should instead create one Client and
+// derive their sandboxes from it, so that all of them share one pool of connections.
+//
+// Example:
+//
+//	client := msb.NewClient(
+//		msb.WithServerUrl("http://localhost:5555"),
+//		msb.WithLogger(msb.NewDefaultSlogAdapter()),
+//		msb.WithRetryPolicy(msb.RetryPolicy{MaxAttempts: 3}),
+//	)
+//
+//	sandbox := client.NewPythonSandbox(msb.WithName("worker-1"))
+type Client struct {
+	cfg       config
+	rpcClient rpcClient
+}
+
+// NewClient creates a Client from the given options. It accepts the same options as the sandbox
+// constructors and applies the same defaults; WithName is ignored since names are per sandbox.
+func NewClient(options ...Option) *Client {
+	// a placeholder name keeps the defaults from consuming a generated name for the client itself
+	b := newBaseWithOptions(append(options, WithName("client"))...)
+	c := &Client{
+		cfg:       b.cfg,
+		rpcClient: b.rpcClient,
+	}
+	c.cfg.name = "" // every sandbox gets its own name
+	return c
+}
+
+// DialClient creates a Client like NewClient and verifies it with Ping before returning it, so a
+// misconfigured server URL or a rejected API key fails at startup rather than on the first
+// user-facing request. The connection established by the ping stays pooled for later requests.
+func DialClient(ctx context.Context, options ...Option) (*Client, error) {
+	c := NewClient(options...)
+	if err := c.Ping(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Ping checks that the server is reachable and accepts the client's API key, by making an
+// authenticated request that doesn't touch any sandbox.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.rpcClient.ping(ctx, &c.cfg); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrPingFailed, c.cfg.currentServerURL(), err)
+	}
+	return nil
+}
+
+// NewPythonSandbox creates a Python sandbox sharing the client's transport and settings.
+// The options are applied on top of the client's, so individual settings can still be overridden.
+func (c *Client) NewPythonSandbox(options ...Option) *langSandbox {
+	return newLangSandbox(langPython, append([]Option{c.inherit()}, options...)...)
+}
+
+// NewNodeSandbox creates a Node.js sandbox sharing the client's transport and settings.
+// The options are applied on top of the client's, so individual settings can still be overridden.
+func (c *Client) NewNodeSandbox(options ...Option) *langSandbox {
+	return newLangSandbox(langNodeJs, append([]Option{c.inherit()}, options...)...)
+}
+
+// inherit seeds a sandbox with the client's configuration and transport.
+func (c *Client) inherit() Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg = c.cfg
+		msb.rpcClient = c.rpcClient
+	}
+}
+
+// Client-related errors
+var (
+	ErrPingFailed = errors.New("server ping failed")
+)
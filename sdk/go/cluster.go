@@ -0,0 +1,288 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ClusterMember is a sandbox together with the configuration it should be started with.
+// Config.DependsOn names other sandboxes by their sandbox name; dependencies that are members of the
+// same cluster are started first, while names outside the cluster are forwarded to the server as-is.
+type ClusterMember struct {
+	Sandbox LangSandBox
+	Config  StartConfig
+}
+
+// StartProgress reports a phase change of a single cluster member.
+type StartProgress struct {
+	Sandbox string     // Sandbox name
+	Phase   StartPhase // Phase the sandbox just entered
+	Err     error      // Failure cause when Phase is PhaseFailed
+}
+
+// ClusterStartOptions configures Cluster.Start.
+type ClusterStartOptions struct {
+	// Progress, if set, is invoked for every phase change of every member.
+	// Calls are serialized, so the callback does not need to be safe for concurrent use.
+	Progress func(StartProgress)
+}
+
+// Cluster is a group of sandboxes started and stopped together in dependency order.
+// Members that don't depend on each other are started in parallel.
+//
+// Example:
+//
+//	cluster, err := msb.NewCluster(
+//		msb.ClusterMember{Sandbox: db, Config: msb.StartConfig{Image: "postgres"}},
+//		msb.ClusterMember{Sandbox: app, Config: msb.StartConfig{DependsOn: []string{"db"}}},
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = cluster.Start(ctx, msb.ClusterStartOptions{
+//		Progress: func(p msb.StartProgress) { fmt.Println(p.Sandbox, p.Phase) },
+//	})
+type Cluster struct {
+	members []ClusterMember
+	names   []string
+	index   map[string]int // sandbox name -> member index
+	deps    [][]int        // member index -> indices of in-cluster dependencies
+
+	mu        sync.Mutex
+	started   []bool
+	debugName string // set while the cluster is reported by RegisterDebugHandlers
+}
+
+// ClusterStats is a snapshot of a cluster's state.
+type ClusterStats struct {
+	Members int      `json:"members"` // Number of sandboxes in the cluster
+	Started []string `json:"started"` // Names of the members currently started
+}
+
+// NewCluster validates the members' dependency graph and creates a cluster.
+// Returns ErrDuplicateClusterMember if two members share a name and ErrDependencyCycle
+// if the in-cluster dependencies form a cycle.
+func NewCluster(members ...ClusterMember) (*Cluster, error) {
+	c := &Cluster{
+		members: members,
+		names:   make([]string, len(members)),
+		index:   make(map[string]int, len(members)),
+		deps:    make([][]int, len(members)),
+		started: make([]bool, len(members)),
+	}
+	for i, m := range members {
+		name, ok := nameOf(m.Sandbox)
+		if !ok {
+			return nil, fmt.Errorf("%w: member %d", ErrUnknownClusterMember, i)
+		}
+		if _, dup := c.index[name]; dup {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateClusterMember, name)
+		}
+		c.names[i] = name
+		c.index[name] = i
+	}
+	for i, m := range members {
+		for _, dep := range m.Config.DependsOn {
+			if j, ok := c.index[dep]; ok {
+				c.deps[i] = append(c.deps[i], j)
+			}
+		}
+	}
+	if cycle := c.findCycle(); cycle != "" {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyCycle, cycle)
+	}
+	return c, nil
+}
+
+// findCycle returns a member name participating in a dependency cycle, or "" if there is none.
+func (c *Cluster) findCycle() string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(c.members))
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		switch marks[i] {
+		case visiting:
+			return true
+		case visited:
+			return false
+		}
+		marks[i] = visiting
+		for _, j := range c.deps[i] {
+			if visit(j) {
+				return true
+			}
+		}
+		marks[i] = visited
+		return false
+	}
+	for i := range c.members {
+		if visit(i) {
+			return c.names[i]
+		}
+	}
+	return ""
+}
+
+// Start starts all members, each as soon as its in-cluster dependencies are ready.
+// If a member fails, its dependents are not started and fail with ErrDependencyFailed; independent
+// members still proceed. Members that did start stay running, so callers should call Stop to tear
+// down a partially started cluster. All failures are returned joined together.
+func (c *Cluster) Start(ctx context.Context, opts ClusterStartOptions) error {
+	c.mu.Lock()
+	if c.debugName == "" {
+		c.debugName = debugTargets.add("", "cluster", c)
+	}
+	c.mu.Unlock()
+
+	var progressMu sync.Mutex
+	report := func(i int, phase StartPhase, err error) {
+		if opts.Progress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		opts.Progress(StartProgress{Sandbox: c.names[i], Phase: phase, Err: err})
+	}
+
+	done := make([]chan struct{}, len(c.members))
+	errs := make([]error, len(c.members))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for i, m := range c.members {
+		wg.Add(1)
+		go func(i int, m ClusterMember) {
+			defer wg.Done()
+			defer close(done[i])
+
+			report(i, PhaseWaiting, nil)
+			for _, j := range c.deps[i] {
+				select {
+				case <-done[j]:
+				case <-ctx.Done():
+					errs[i] = fmt.Errorf("%s: %w", c.names[i], ctx.Err())
+					report(i, PhaseFailed, errs[i])
+					return
+				}
+				if errs[j] != nil {
+					errs[i] = fmt.Errorf("%s: %w: %s", c.names[i], ErrDependencyFailed, c.names[j])
+					report(i, PhaseFailed, errs[i])
+					return
+				}
+			}
+
+			if c.isStarted(i) {
+				report(i, PhaseReady, nil)
+				return
+			}
+			observe := func(phase StartPhase) { report(i, phase, nil) }
+			if err := m.Sandbox.StartContext(withStartObserver(ctx, observe), m.Config); err != nil {
+				// a sandbox that never became ready may still exist server-side and must then be
+				// stopped, unless Start already stopped or let go of it (NewCluster only accepts
+				// sandboxes of this package)
+				if m.Sandbox.(*langSandbox).b.state.Load() == started {
+					c.setStarted(i, true)
+				}
+				errs[i] = fmt.Errorf("%s: %w", c.names[i], err)
+				report(i, PhaseFailed, errs[i])
+				return
+			}
+			c.setStarted(i, true)
+			report(i, PhaseReady, nil)
+		}(i, m)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Stop stops all started members in reverse dependency order: a member is stopped only after every
+// member depending on it has been stopped. Independent members are stopped in parallel.
+func (c *Cluster) Stop(ctx context.Context) error {
+	// dependents[j] lists members that depend on j and must therefore stop before it
+	dependents := make([][]int, len(c.members))
+	for i, deps := range c.deps {
+		for _, j := range deps {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	done := make([]chan struct{}, len(c.members))
+	errs := make([]error, len(c.members))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for i, m := range c.members {
+		wg.Add(1)
+		go func(i int, m ClusterMember) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, j := range dependents[i] {
+				<-done[j]
+			}
+			if !c.isStarted(i) {
+				return
+			}
+			if err := m.Sandbox.StopContext(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", c.names[i], err)
+				return
+			}
+			c.setStarted(i, false)
+		}(i, m)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	if c.debugName != "" && !slices.Contains(c.started, true) {
+		debugTargets.remove(c.debugName)
+		c.debugName = ""
+	}
+	c.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// Stats returns a snapshot of the cluster's state.
+func (c *Cluster) Stats() ClusterStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := ClusterStats{Members: len(c.members), Started: []string{}}
+	for i, started := range c.started {
+		if started {
+			stats.Started = append(stats.Started, c.names[i])
+		}
+	}
+	return stats
+}
+
+func (c *Cluster) isStarted(i int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started[i]
+}
+
+func (c *Cluster) setStarted(i int, started bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started[i] = started
+}
+
+// Cluster-related errors
+var (
+	ErrUnknownClusterMember   = errors.New("cluster members must be created by this package")
+	ErrDuplicateClusterMember = errors.New("duplicate cluster member")
+	ErrDependencyCycle        = errors.New("dependency cycle")
+	ErrDependencyFailed       = errors.New("dependency failed to start")
+)
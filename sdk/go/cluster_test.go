@@ -0,0 +1,47 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+// TestClusterStopAfterFailedInit checks that a member whose Start stopped it again after its
+// initialization failed isn't stopped a second time.
+func TestClusterStopAfterFailedInit(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.Handle("sandbox.command.run", func(params json.RawMessage) (any, error) {
+		if strings.Contains(string(params), "fail") {
+			return map[string]any{"command": "fail", "exit_code": 1, "success": false, "output": []any{}}, nil
+		}
+		return map[string]any{"command": "true", "exit_code": 0, "success": true, "output": []any{}}, nil
+	})
+	client := NewClient(WithServerUrl(srv.URL), WithApiKey("k"))
+	db := client.NewPythonSandbox(WithName("db"))
+	app := client.NewPythonSandbox(WithName("app"))
+	cluster, err := NewCluster(
+		ClusterMember{Sandbox: db, Config: StartConfig{}},
+		ClusterMember{Sandbox: app, Config: StartConfig{InitCommands: []CmdSpec{Cmd("fail")}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cluster.Start(context.Background(), ClusterStartOptions{})
+	if !errors.Is(err, ErrInitFailed) {
+		t.Fatalf("Start = %v, want ErrInitFailed", err)
+	}
+	if started := cluster.Stats().Started; len(started) != 1 || started[0] != "db" {
+		t.Errorf("started members = %v, want [db]", started)
+	}
+	if err := cluster.Stop(context.Background()); err != nil {
+		t.Errorf("Stop = %v", err)
+	}
+	if srv.Running("db") || srv.Running("app") {
+		t.Error("cluster member left running")
+	}
+}
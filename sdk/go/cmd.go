@@ -0,0 +1,104 @@
+package msb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CmdSpec is a command and its arguments, built with Cmd. Every argument is passed to the command
+// verbatim, so no quoting is needed unless an argument is itself a shell script (see Scriptf).
+//
+// CmdSpec values are immutable; each method returns an extended copy, so a common prefix can be
+// shared between several commands:
+//
+//	bash := msb.Cmd("bash").Arg("-c")
+//	exec, err := bash.Scriptf("ls -la %s | wc -l", userPath).Run(sandbox.Command())
+type CmdSpec struct {
+	Name string   // Program to run
+	Args []string // Arguments, passed verbatim
+}
+
+// Cmd starts building a command running the program name.
+func Cmd(name string) CmdSpec {
+	return CmdSpec{Name: name}
+}
+
+// Arg returns a copy of c with args appended.
+func (c CmdSpec) Arg(args ...string) CmdSpec {
+	c.Args = append(append(make([]string, 0, len(c.Args)+len(args)), c.Args...), args...)
+	return c
+}
+
+// Script returns a copy of c with script appended as a single argument, typically after "-c".
+// The script is passed as-is; use Scriptf to splice untrusted values into it.
+func (c CmdSpec) Script(script string) CmdSpec {
+	return c.Arg(script)
+}
+
+// Scriptf is like Script, but formats the script with fmt.Sprintf and shell-quotes every
+// formatted argument, so values such as user-supplied paths are always treated as single words.
+// Any verb can be used; numbers come out as they would unquoted:
+//
+//	msb.Cmd("sh").Arg("-c").Scriptf("head -n %d %s | grep %s", lines, path, pattern)
+func (c CmdSpec) Scriptf(format string, args ...any) CmdSpec {
+	quoted := make([]any, len(args))
+	for i, arg := range args {
+		quoted[i] = shellArg{arg}
+	}
+	return c.Script(fmt.Sprintf(format, quoted...))
+}
+
+// shellArg is a Scriptf argument: it formats its value as the verb asks, then shell-quotes the
+// result.
+type shellArg struct {
+	v any
+}
+
+func (a shellArg) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, ShellQuote(fmt.Sprintf(fmt.FormatString(f, verb), a.v)))
+}
+
+// Run runs the command through r, e.g. sandbox.Command().
+func (c CmdSpec) Run(r CommandRunner) (CommandExecution, error) {
+	return r.Run(c.Name, c.Args)
+}
+
+// String returns the command as a shell-quoted command line.
+func (c CmdSpec) String() string {
+	return ShellJoin(append([]string{c.Name}, c.Args...)...)
+}
+
+// ShellQuote quotes s for POSIX shells so that it is interpreted as exactly one word with no
+// expansions. Strings made only of characters that are never special are returned unchanged.
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, needsShellQuoting) < 0 {
+		return s
+	}
+	// inside single quotes nothing is special except the closing quote itself,
+	// which is emitted as '\'' (close, escaped quote, reopen)
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellJoin quotes each of args with ShellQuote and joins them with spaces.
+func ShellJoin(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func needsShellQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case strings.ContainsRune("-_./,:=@%+", r):
+		return false
+	default:
+		return true
+	}
+}
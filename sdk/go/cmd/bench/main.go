@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/microsandbox/microsandbox/sdk/go/bench"
+)
+
+// main runs the SDK benchmarks with `go test -bench`, from within the SDK's module, and prints
+// one line per benchmark in the same format. By default they run against the in-process fake
+// server; -server points them at a real one. Results can be saved with -save and checked against
+// a saved baseline with -baseline, in which case a slowdown beyond -threshold makes the command
+// exit with status 1.
+func main() {
+	var target bench.Target
+	filter := flag.String("run", ".", "regular expression selecting the benchmarks to run")
+	count := flag.Int("count", 1, "run each benchmark this many times and keep the median")
+	save := flag.String("save", "", "write the results as JSON to this file")
+	baseline := flag.String("baseline", "", "compare the results to those saved in this file")
+	threshold := flag.Float64("threshold", 0.1, "slowdown relative to the baseline reported as a regression")
+	flag.StringVar(&target.ServerURL, "server", "", "run against this server instead of the fake one")
+	flag.StringVar(&target.APIKey, "api-key", os.Getenv("MSB_API_KEY"), "API key for -server")
+	flag.StringVar(&target.Image, "image", "", "image to start sandboxes from on -server")
+	flag.Parse()
+
+	if _, err := regexp.Compile(*filter); err != nil {
+		fmt.Printf("Invalid -run pattern: %v\n", err)
+		os.Exit(2)
+	}
+
+	results, err := bench.Run(target, *filter, *count)
+	if err != nil {
+		fmt.Printf("Failed to run benchmarks: %v\n", err)
+		os.Exit(1)
+	}
+	for _, result := range results {
+		fmt.Println(result)
+	}
+
+	if *save != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*save, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Printf("Failed to save results: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *baseline != "" {
+		data, err := os.ReadFile(*baseline)
+		if err != nil {
+			fmt.Printf("Failed to read baseline: %v\n", err)
+			os.Exit(1)
+		}
+		var base []bench.Result
+		if err := json.Unmarshal(data, &base); err != nil {
+			fmt.Printf("Invalid baseline: %v\n", err)
+			os.Exit(1)
+		}
+		regressions := bench.Compare(base, results, *threshold)
+		for _, r := range regressions {
+			fmt.Printf("REGRESSION %v\n", r)
+		}
+		if len(regressions) > 0 {
+			os.Exit(1)
+		}
+	}
+}
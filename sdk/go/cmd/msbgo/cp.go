@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+	"github.com/spf13/cobra"
+)
+
+// copyChunkSize is how many bytes are uploaded per command; their base64 encoding is passed as a
+// command argument, which Linux limits to 128 KiB.
+const copyChunkSize = 48 << 10
+
+func newCopyCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cp SRC DST",
+		Short: "Copy a file between the host and a sandbox",
+		Long:  "Copy a file between the host and a sandbox. One of SRC and DST is a sandbox path NAME:PATH, the\nother a local path or \"-\" for stdin/stdout.",
+		Args:  usageArgs(cobra.ExactArgs(2)),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			if len(args) >= 2 || strings.Contains(toComplete, ":") {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			// sandbox paths start with a name; anything else is a local path
+			names, err := sandboxNames(cmd.Context(), g, toComplete)
+			if err != nil || len(names) == 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			for i, name := range names {
+				names[i] = name + ":"
+			}
+			return names, cobra.ShellCompDirectiveNoSpace
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			srcName, srcPath, srcRemote := splitRemotePath(args[0])
+			dstName, dstPath, dstRemote := splitRemotePath(args[1])
+			switch {
+			case srcRemote == dstRemote:
+				return usageError{errors.New("exactly one of SRC and DST must be a sandbox path (NAME:PATH)")}
+			case dstRemote:
+				sandbox, err := attach(cmd.Context(), opts, "python", dstName)
+				if err != nil {
+					return err
+				}
+				return upload(sandbox, args[0], dstPath)
+			default:
+				sandbox, err := attach(cmd.Context(), opts, "python", srcName)
+				if err != nil {
+					return err
+				}
+				return download(sandbox, srcPath, args[1])
+			}
+		},
+	}
+}
+
+// splitRemotePath splits a NAME:PATH argument. Windows drive letters ("C:\data") are local paths.
+func splitRemotePath(arg string) (name, path string, remote bool) {
+	name, path, remote = strings.Cut(arg, ":")
+	if !remote || name == "" || len(name) == 1 || strings.ContainsAny(name, `/\`) {
+		return "", arg, false
+	}
+	return name, path, true
+}
+
+// upload copies the local file src to dst in the sandbox. The sandbox API has no file transfer,
+// so the content is sent base64-encoded in command arguments, a chunk at a time.
+func upload(sandbox msb.LangSandBox, src, dst string) error {
+	var r io.Reader = os.Stdin
+	if src != "-" {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if err := runScript(sandbox, `: > "$1"`, dst); err != nil {
+		return err
+	}
+	buf := make([]byte, copyChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			chunk := base64.StdEncoding.EncodeToString(buf[:n])
+			if err := runScript(sandbox, `printf %s "$1" | base64 -d >> "$2"`, chunk, dst); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// download copies the file src in the sandbox to the local path dst.
+func download(sandbox msb.LangSandBox, src, dst string) error {
+	exec, err := sandbox.Command().Run("base64", []string{src})
+	if err != nil {
+		return err
+	}
+	if !exec.IsSuccess() {
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("reading %s: %s", src, strings.TrimSpace(stderr))
+	}
+	encoded, _ := exec.GetOutput()
+	content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", src, err)
+	}
+	if dst == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	return os.WriteFile(dst, content, 0o644)
+}
+
+// runScript runs a shell script in the sandbox, with args as its positional parameters.
+func runScript(sandbox msb.LangSandBox, script string, args ...string) error {
+	exec, err := sandbox.Command().Run("sh", append([]string{"-c", script, "sh"}, args...))
+	if err != nil {
+		return err
+	}
+	if !exec.IsSuccess() {
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("%s", strings.TrimSpace(stderr))
+	}
+	return nil
+}
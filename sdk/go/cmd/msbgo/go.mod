@@ -0,0 +1,16 @@
+module github.com/microsandbox/microsandbox/sdk/go/cmd/msbgo
+
+go 1.24
+
+require (
+	github.com/microsandbox/microsandbox/sdk/go v0.0.0
+	github.com/spf13/cobra v1.10.2
+)
+
+require (
+	github.com/google/uuid v1.5.0 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/spf13/pflag v1.0.9 // indirect
+)
+
+replace github.com/microsandbox/microsandbox/sdk/go => ../../
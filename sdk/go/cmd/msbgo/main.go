@@ -0,0 +1,180 @@
+// Command msbgo is a command-line client for Microsandbox servers, built on the Go SDK.
+//
+// It manages sandboxes by name across invocations: start creates a sandbox that keeps running on
+// the server, and the other subcommands attach to it by name.
+//
+//	msbgo start --image python my-sandbox
+//	msbgo run my-sandbox 'print(1 + 1)'
+//	msbgo exec my-sandbox ls -la /
+//	msbgo cp ./data.csv my-sandbox:/tmp/data.csv
+//	msbgo metrics my-sandbox
+//	msbgo logs -f my-sandbox
+//	msbgo watch my-sandbox other-sandbox
+//	msbgo stop my-sandbox
+//
+// The server URL and API key are taken from the MSB_SERVER_URL and MSB_API_KEY environment
+// variables unless given with --server and --api-key. Every subcommand has its own --help, and
+// "msbgo completion" prints shell completion scripts, which complete sandbox names too.
+//
+// msbgo is a module of its own, so the SDK doesn't depend on its command-line libraries.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+	"github.com/spf13/cobra"
+)
+
+// exitCodeError makes msbgo exit with a command's exit code, without printing anything.
+type exitCodeError int
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// usageError is a misuse of a command, which makes msbgo exit with status 2.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	cmd, err := newRootCmd().ExecuteContextC(ctx)
+	stop()
+
+	var exitCode exitCodeError
+	var usage usageError
+	switch {
+	case errors.As(err, &exitCode):
+		os.Exit(int(exitCode))
+	case errors.As(err, &usage):
+		fmt.Fprintf(os.Stderr, "%s: %v\nRun '%s --help' for usage.\n", cmd.CommandPath(), err, cmd.CommandPath())
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+}
+
+// globalFlags are the flags every subcommand accepts.
+type globalFlags struct {
+	server  string
+	apiKey  string
+	noAuth  bool
+	verbose bool
+}
+
+// options returns the SDK options the flags select.
+func (g *globalFlags) options() ([]msb.Option, error) {
+	var opts []msb.Option
+	if g.server != "" {
+		opts = append(opts, msb.WithServerUrl(g.server))
+	}
+	switch {
+	case g.noAuth:
+		opts = append(opts, msb.WithNoAuth())
+	case g.apiKey != "":
+		opts = append(opts, msb.WithApiKey(g.apiKey))
+	case os.Getenv("MSB_API_KEY") == "":
+		return nil, usageError{errors.New("no API key: set MSB_API_KEY, or pass --api-key or --no-auth")}
+	}
+	if g.verbose {
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		opts = append(opts, msb.WithLogger(msb.NewSlogAdapter(logger)))
+	}
+	return opts, nil
+}
+
+func newRootCmd() *cobra.Command {
+	g := &globalFlags{}
+	root := &cobra.Command{
+		Use:   "msbgo",
+		Short: "Command-line client for Microsandbox servers",
+		Long:  "msbgo manages sandboxes on a Microsandbox server by name: start creates a sandbox that keeps\nrunning, and the other commands address it by name.",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// without RunE, cobra would print the help for unknown commands rather than fail
+			return cmd.Help()
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&g.server, "server", "", "server URL (default $MSB_SERVER_URL or http://127.0.0.1:5555)")
+	flags.StringVar(&g.apiKey, "api-key", "", "API key (default $MSB_API_KEY)")
+	flags.BoolVar(&g.noAuth, "no-auth", false, "talk to a server that doesn't require authentication")
+	flags.BoolVarP(&g.verbose, "verbose", "v", false, "log SDK activity to stderr")
+	root.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return usageError{err}
+	})
+
+	root.AddCommand(
+		newStartCmd(g),
+		newStopCmd(g),
+		newRunCmd(g),
+		newExecCmd(g),
+		newCopyCmd(g),
+		newMetricsCmd(g),
+		newLogsCmd(g),
+		newWatchCmd(g),
+	)
+	return root
+}
+
+// usageArgs reports the errors of validate as usage errors.
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := validate(cmd, args); err != nil {
+			return usageError{err}
+		}
+		return nil
+	}
+}
+
+// completeNames completes the names of the sandboxes on the server; up to max names are completed
+// per command line, or any number if max is negative.
+func completeNames(g *globalFlags, max int) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if max >= 0 && len(args) >= max {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names, err := sandboxNames(cmd.Context(), g, toComplete)
+		if err != nil {
+			cobra.CompErrorln(err.Error())
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// sandboxNames returns the names of the sandboxes on the server starting with prefix, sorted.
+func sandboxNames(ctx context.Context, g *globalFlags, prefix string) ([]string, error) {
+	opts, err := g.options()
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := msb.NewClient(opts...).MetricsAll(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range metrics {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
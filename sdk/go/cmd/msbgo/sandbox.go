@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+	"github.com/spf13/cobra"
+)
+
+// newSandbox creates a handle for the named sandbox in the given language ("python" or "node").
+func newSandbox(opts []msb.Option, lang, name string) (msb.LangSandBox, error) {
+	if name != "" {
+		opts = append(opts, msb.WithName(name))
+	}
+	switch lang {
+	case "python":
+		return msb.NewPythonSandbox(opts...), nil
+	case "node":
+		return msb.NewNodeSandbox(opts...), nil
+	default:
+		return nil, usageError{fmt.Errorf("unknown language %q (want python or node)", lang)}
+	}
+}
+
+// attach returns a handle for a sandbox started by an earlier invocation, failing if it isn't running.
+func attach(ctx context.Context, opts []msb.Option, lang, name string) (msb.LangSandBox, error) {
+	sandbox, err := newSandbox(opts, lang, name)
+	if err != nil {
+		return nil, err
+	}
+	status, err := sandbox.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status != msb.StatusRunning {
+		return nil, fmt.Errorf("sandbox %q is %s", name, status)
+	}
+	if err := sandbox.StartContext(ctx, msb.StartConfig{OnNameConflict: msb.NameConflictAttach}); err != nil {
+		return nil, err
+	}
+	return sandbox, nil
+}
+
+// addLangFlag adds the --lang flag selecting the REPL language to cmd.
+func addLangFlag(cmd *cobra.Command, lang *string) {
+	cmd.Flags().StringVar(lang, "lang", "python", "REPL language, python or node")
+	_ = cmd.RegisterFlagCompletionFunc("lang", cobra.FixedCompletions([]string{"python", "node"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func newStartCmd(g *globalFlags) *cobra.Command {
+	var (
+		lang string
+		pull string
+		cfg  msb.StartConfig
+	)
+	cmd := &cobra.Command{
+		Use:   "start [flags] [NAME]",
+		Short: "Start a sandbox and print its name",
+		Long:  "Start a sandbox that keeps running on the server, and print its name. Without NAME, a name is\ngenerated.",
+		Args:  usageArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			cfg.PullPolicy = msb.PullPolicy(pull)
+			cfg.OnNameConflict = msb.NameConflictFail
+
+			progress := msb.WithStartProgress(func(p msb.PullProgress) {
+				if p.Total > 0 {
+					fmt.Fprintf(os.Stderr, "Pulling %s: %d/%d MiB\n", p.Image, p.Downloaded>>20, p.Total>>20)
+				}
+			})
+			var name string
+			if len(args) > 0 {
+				name = args[0]
+			}
+			sandbox, err := newSandbox(append(opts, progress), lang, name)
+			if err != nil {
+				return err
+			}
+			if err := sandbox.StartContext(cmd.Context(), cfg); err != nil {
+				return err
+			}
+			info, err := sandbox.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Println(info.Name)
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	addLangFlag(cmd, &lang)
+	flags.StringVar(&cfg.Image, "image", "", "image to start (default: the language's default image)")
+	flags.IntVar(&cfg.Memory, "memory", 512, "memory limit in MiB")
+	flags.IntVar(&cfg.CPUs, "cpus", 1, "number of CPUs")
+	flags.IntVar(&cfg.DiskMiB, "disk", 0, "root filesystem size limit in MiB (default: server default)")
+	flags.StringVar(&cfg.Workdir, "workdir", "", "working directory")
+	flags.StringVar(&cfg.User, "user", "", `user to run code and commands as ("name", "uid" or "uid:gid")`)
+	flags.StringVar(&cfg.Platform, "platform", "", `image platform, e.g. "linux/arm64"`)
+	flags.StringVar(&pull, "pull", "", "pull policy: always, if_not_present or never (default: server default)")
+	flags.DurationVar(&cfg.StartTimeout, "timeout", 0, "give up if the sandbox isn't running after this long")
+	flags.StringArrayVar(&cfg.Envs, "env", nil, "environment variable K=V (repeatable)")
+	flags.StringArrayVar(&cfg.Volumes, "volume", nil, "volume HOST:GUEST (repeatable)")
+	flags.StringArrayVar(&cfg.Ports, "port", nil, "port HOST:GUEST to expose (repeatable)")
+	_ = cmd.RegisterFlagCompletionFunc("pull", cobra.FixedCompletions([]string{"always", "if_not_present", "never"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.MarkFlagDirname("workdir")
+	cmd.ValidArgsFunction = cobra.NoFileCompletions
+	return cmd
+}
+
+func newStopCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:               "stop NAME...",
+		Short:             "Stop sandboxes",
+		Args:              usageArgs(cobra.MinimumNArgs(1)),
+		ValidArgsFunction: completeNames(g, -1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			var errs []error
+			for _, name := range args {
+				sandbox, err := attach(ctx, opts, "python", name)
+				if err == nil {
+					err = sandbox.StopContext(ctx)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+func newRunCmd(g *globalFlags) *cobra.Command {
+	var lang string
+	cmd := &cobra.Command{
+		Use:               "run [flags] NAME [CODE]",
+		Short:             "Run code in a sandbox's REPL",
+		Long:              "Run code in a sandbox's REPL. Without CODE, or with CODE \"-\", the code is read from stdin.",
+		Args:              usageArgs(cobra.RangeArgs(1, 2)),
+		ValidArgsFunction: completeNames(g, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			var code string
+			if len(args) > 1 {
+				code = args[1]
+			}
+			if code == "" || code == "-" {
+				b, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				code = string(b)
+			}
+
+			sandbox, err := attach(cmd.Context(), opts, lang, args[0])
+			if err != nil {
+				return err
+			}
+			exec, err := sandbox.Code().RunContext(cmd.Context(), code)
+			if err != nil {
+				return err
+			}
+			if output, _ := exec.GetOutput(); output != "" {
+				fmt.Println(output)
+			}
+			if exec.HasError() {
+				stderr, _ := exec.GetError()
+				fmt.Fprintln(os.Stderr, stderr)
+				return exitCodeError(1)
+			}
+			return nil
+		},
+	}
+	addLangFlag(cmd, &lang)
+	// code starting with '-' isn't a flag
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+func newExecCmd(g *globalFlags) *cobra.Command {
+	var (
+		user string
+		envs []string
+	)
+	cmd := &cobra.Command{
+		Use:   "exec [flags] NAME COMMAND [ARG...]",
+		Short: "Run a command in a sandbox",
+		Long:  "Run a command in a sandbox, exiting with its exit code. Flags go before NAME; everything after\nCOMMAND is passed to it.",
+		Args:  usageArgs(cobra.MinimumNArgs(2)),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return completeNames(g, 1)(cmd, args, toComplete)
+			}
+			// the command and its arguments are paths and names inside the sandbox
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			sandbox, err := attach(cmd.Context(), opts, "python", args[0])
+			if err != nil {
+				return err
+			}
+			runner := sandbox.Command()
+			if user != "" {
+				runner = runner.As(user)
+			}
+			if len(envs) > 0 {
+				env := make(map[string]string, len(envs))
+				for _, kv := range envs {
+					k, v, _ := strings.Cut(kv, "=")
+					env[k] = v
+				}
+				runner = runner.WithEnv(env)
+			}
+			exec, err := runner.Run(args[1], args[2:])
+			if err != nil {
+				return err
+			}
+			if output, _ := exec.GetOutput(); output != "" {
+				fmt.Println(output)
+			}
+			if stderr, _ := exec.GetError(); stderr != "" {
+				fmt.Fprintln(os.Stderr, stderr)
+			}
+			if code := exec.GetExitCode(); code != 0 {
+				return exitCodeError(code)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&user, "user", "", `user to run the command as ("name", "uid" or "uid:gid")`)
+	cmd.Flags().StringArrayVar(&envs, "env", nil, "environment variable K=V (repeatable)")
+	// the command's own flags aren't msbgo's
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+func newMetricsCmd(g *globalFlags) *cobra.Command {
+	var (
+		asJSON bool
+		watch  time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:               "metrics [flags] NAME",
+		Short:             "Show a sandbox's resource usage",
+		Args:              usageArgs(cobra.ExactArgs(1)),
+		ValidArgsFunction: completeNames(g, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			sandbox, err := attach(ctx, opts, "python", args[0])
+			if err != nil {
+				return err
+			}
+			for {
+				metrics, err := sandbox.Metrics().All()
+				if err != nil {
+					return err
+				}
+				if asJSON {
+					if err := json.NewEncoder(os.Stdout).Encode(metrics); err != nil {
+						return err
+					}
+				} else {
+					fmt.Printf("%s\trunning=%t\tcpu=%.1f%%\tmemory=%dMiB\tdisk=%dMiB\n",
+						metrics.Name, metrics.IsRunning, metrics.CPU, metrics.MemoryMiB, metrics.DiskBytes>>20)
+				}
+				if watch <= 0 {
+					return nil
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(watch):
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the metrics as JSON")
+	cmd.Flags().DurationVar(&watch, "watch", 0, "refresh the metrics at this interval until interrupted")
+	return cmd
+}
+
+func newLogsCmd(g *globalFlags) *cobra.Command {
+	var follow bool
+	cmd := &cobra.Command{
+		Use:               "logs [flags] NAME",
+		Short:             "Show a sandbox's log",
+		Args:              usageArgs(cobra.ExactArgs(1)),
+		ValidArgsFunction: completeNames(g, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			// the log is kept after the sandbox stopped, so there is nothing to attach to
+			sandbox, err := newSandbox(opts, "python", args[0])
+			if err != nil {
+				return err
+			}
+			return sandbox.Logs(cmd.Context(), os.Stdout, follow)
+		},
+	}
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "follow the log until the sandbox stops or msbgo is interrupted")
+	return cmd
+}
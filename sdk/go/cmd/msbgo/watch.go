@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+	"github.com/spf13/cobra"
+)
+
+// ANSI escape sequences used to redraw the dashboard in place.
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+)
+
+func newWatchCmd(g *globalFlags) *cobra.Command {
+	var interval time.Duration
+	cmd := &cobra.Command{
+		Use:               "watch [flags] NAME...",
+		Short:             "Show live resource usage of sandboxes",
+		Long:              "Show a dashboard of the CPU, memory and disk usage of sandboxes, refreshed until interrupted.",
+		Args:              usageArgs(cobra.MinimumNArgs(1)),
+		ValidArgsFunction: completeNames(g, -1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return usageError{fmt.Errorf("invalid interval %v", interval)}
+			}
+			opts, err := g.options()
+			if err != nil {
+				return err
+			}
+			sandboxes := make([]msb.LangSandBox, len(args))
+			for i, name := range args {
+				sandbox, err := newSandbox(opts, "python", name)
+				if err != nil {
+					return err
+				}
+				sandboxes[i] = sandbox
+			}
+
+			// escape sequences would only garble output redirected to a file
+			tty := isTerminal(os.Stdout)
+			if tty {
+				fmt.Print(hideCursor)
+				defer fmt.Print(showCursor)
+			}
+			for samples := range msb.WatchMetrics(cmd.Context(), interval, sandboxes...) {
+				if tty {
+					fmt.Print(clearScreen)
+				}
+				renderDashboard(os.Stdout, samples)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "time between refreshes")
+	return cmd
+}
+
+// renderDashboard writes a table with one row per sample.
+func renderDashboard(w io.Writer, samples []msb.MetricsSample) {
+	running := 0
+	for _, s := range samples {
+		if s.Err == nil && s.Metrics.IsRunning {
+			running++
+		}
+	}
+	fmt.Fprintf(w, "%s  %d/%d running\n\n", time.Now().Format(time.TimeOnly), running, len(samples))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tCPU\t\tMEMORY\tDISK")
+	for _, s := range samples {
+		switch {
+		case s.Err != nil:
+			fmt.Fprintf(tw, "%s\terror: %v\t\t\t\t\n", s.Sandbox, s.Err)
+		case !s.Metrics.IsRunning:
+			fmt.Fprintf(tw, "%s\tstopped\t\t\t\t\n", s.Sandbox)
+		default:
+			m := s.Metrics
+			fmt.Fprintf(tw, "%s\trunning\t%s\t%5.1f%%\t%d MiB\t%s\n",
+				s.Sandbox, bar(m.CPU, 20), m.CPU, m.MemoryMiB, formatBytes(m.DiskBytes))
+		}
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// bar draws percent (0-100) as a bar width characters wide.
+func bar(percent float64, width int) string {
+	filled := int(percent/100*float64(width) + 0.5)
+	filled = min(max(filled, 0), width)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%d KiB", n>>10)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,28 @@
+package msb
+
+import "testing"
+
+func TestScriptf(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []any
+		want   string
+	}{
+		{"cat %s", []any{"a b"}, "cat 'a b'"},
+		{"head -n %d %s", []any{5, "it's"}, `head -n 5 'it'\''s'`},
+		{"sleep %.1f", []any{1.25}, "sleep 1.2"},
+		{"echo %v %t", []any{"$HOME", true}, "echo '$HOME' true"},
+		{"echo %x", []any{255}, "echo ff"},
+		{"echo %c", []any{';'}, "echo ';'"},
+		{"echo %q", []any{"a"}, `echo '"a"'`},
+		{"printf %5d", []any{42}, "printf '   42'"},
+		{"cp %[2]s %[1]s", []any{"dst", "src dir"}, "cp 'src dir' dst"},
+		{"echo %s", []any{ErrLockLost}, "echo 'lock lost'"},
+	}
+	for _, tt := range tests {
+		got := Cmd("sh").Arg("-c").Scriptf(tt.format, tt.args...)
+		if script := got.Args[1]; script != tt.want {
+			t.Errorf("Scriptf(%q, %v) = %s, want %s", tt.format, tt.args, script, tt.want)
+		}
+	}
+}
@@ -3,43 +3,156 @@ package msb
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ErrExecutionNotParsed is returned when execution output could not be parsed.
 var ErrExecutionNotParsed = errors.New("execution output could not be parsed")
 
+// ErrUnexpectedResultSchema is matched by UnexpectedResultSchemaError.
+var ErrUnexpectedResultSchema = errors.New("unexpected execution result schema")
+
+// UnexpectedResultSchemaError reports an execution result that isn't in the schema this SDK
+// expects, typically because the server is newer or older than the SDK. It matches both
+// ErrUnexpectedResultSchema and ErrExecutionNotParsed with errors.Is.
+type UnexpectedResultSchemaError struct {
+	Raw json.RawMessage // Result exactly as the server sent it
+	Err error           // What didn't match
+}
+
+// maxRawInError is how much of the raw result UnexpectedResultSchemaError.Error includes.
+const maxRawInError = 256
+
+func (e *UnexpectedResultSchemaError) Error() string {
+	raw := string(e.Raw)
+	if len(raw) > maxRawInError {
+		raw = raw[:maxRawInError] + "..."
+	}
+	return fmt.Sprintf("%v: %v: %s", ErrUnexpectedResultSchema, e.Err, raw)
+}
+
+func (e *UnexpectedResultSchemaError) Is(target error) bool {
+	return target == ErrUnexpectedResultSchema || target == ErrExecutionNotParsed
+}
+
+func (e *UnexpectedResultSchemaError) Unwrap() error {
+	return e.Err
+}
+
+// parseResult unmarshals the raw result into v. A result lacking any of the required fields is
+// rejected, so that a result in an unknown schema isn't mistaken for one without output.
+func parseResult(raw json.RawMessage, v any, required ...string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return &UnexpectedResultSchemaError{Raw: raw, Err: err}
+	}
+	var missing []string
+	for _, field := range required {
+		if _, ok := fields[field]; !ok {
+			missing = append(missing, strconv.Quote(field))
+		}
+	}
+	if len(missing) > 0 {
+		return &UnexpectedResultSchemaError{Raw: raw, Err: fmt.Errorf("missing %s", strings.Join(missing, ", "))}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &UnexpectedResultSchemaError{Raw: raw, Err: err}
+	}
+	return nil
+}
+
+// notParsedErr returns why an execution's result couldn't be parsed.
+func notParsedErr(parseErr error) error {
+	if parseErr != nil {
+		return parseErr
+	}
+	return ErrExecutionNotParsed
+}
+
 // CodeExecution represents the result of code execution in the sandbox.
-// Use the Get* methods for parsed access to output, or access Output directly for raw JSON.
+// Use the Get* methods for parsed access to output, Result for the typed payload,
+// or Raw for the JSON exactly as the server sent it.
 type CodeExecution struct {
 	Output   json.RawMessage // Raw JSON response from the server
-	parsed   executionData   // Parsed data for convenience methods
+	parsed   ReplResult      // Parsed data for convenience methods
 	parsedOK bool            // Whether parsing succeeded
+	parseErr error           // Why parsing failed
+	cached   bool            // Whether the result came from the execution cache
+	sent     time.Time       // When the request left this client
+	received time.Time       // When the result arrived
+	source   *execSource     // Where the code ran, for ExportBundle; nil if unknown
 }
 
-// Internal structures for parsing execution results
+// Execution result schemas, as returned by the server.
 type (
-	executionData struct {
-		OutputLines []outputLine `json:"output"`
-		Status      string       `json:"status"`
-		Language    string       `json:"language"`
+	// ReplResult is the payload of a REPL execution. To read fields this SDK doesn't know yet,
+	// decode the raw payload into a struct embedding ReplResult with CodeExecution.Decode.
+	ReplResult struct {
+		Status     string         `json:"status"`                // "success", "error" or "exception"
+		Language   string         `json:"language"`              // Language the code was run as
+		Output     []OutputLine   `json:"output"`                // Output lines in the order they were produced
+		Usage      *ResourceUsage `json:"usage,omitempty"`       // Resources the run consumed; nil if not reported
+		StartedAt  *time.Time     `json:"started_at,omitempty"`  // When the server started the run, by its clock; nil if not reported
+		FinishedAt *time.Time     `json:"finished_at,omitempty"` // When the run finished, by the server's clock; nil if not reported
 	}
 
-	outputLine struct {
-		Stream string `json:"stream"`
-		Text   string `json:"text"`
+	// OutputLine is a single line of execution output.
+	OutputLine struct {
+		Stream string `json:"stream"` // "stdout" or "stderr"
+		Text   string `json:"text"`   // Line content without the trailing newline
 	}
 )
 
+// newCodeExecution wraps a raw execution result, parsing it for the convenience methods.
+func newCodeExecution(output json.RawMessage) CodeExecution {
+	exec := CodeExecution{Output: output}
+	exec.parseErr = parseResult(output, &exec.parsed, "status", "output")
+	exec.parsedOK = exec.parseErr == nil
+	return exec
+}
+
+// Raw returns the execution result exactly as the server sent it.
+func (ce CodeExecution) Raw() json.RawMessage {
+	return ce.Output
+}
+
+// Result returns the typed execution result.
+// Returns an *UnexpectedResultSchemaError holding the raw JSON if it could not be parsed.
+func (ce CodeExecution) Result() (ReplResult, error) {
+	if !ce.parsedOK {
+		return ReplResult{}, notParsedErr(ce.parseErr)
+	}
+	return ce.parsed, nil
+}
+
+// Decode unmarshals the raw execution result into v, typically a struct embedding ReplResult
+// alongside fields newer servers return:
+//
+//	var res struct {
+//		msb.ReplResult
+//		DurationMs int `json:"duration_ms"`
+//	}
+//	err := execution.Decode(&res)
+func (ce CodeExecution) Decode(v any) error {
+	if err := json.Unmarshal(ce.Output, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+	}
+	return nil
+}
+
 // GetOutput returns the standard output from code execution as a string.
-// Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
+// Returns an *UnexpectedResultSchemaError holding the raw JSON if it could not be parsed, so that
+// a result in an unknown schema can be told from one without output.
 func (ce CodeExecution) GetOutput() (string, error) {
 	if !ce.parsedOK {
-		return "", ErrExecutionNotParsed
+		return "", notParsedErr(ce.parseErr)
 	}
 
 	var output strings.Builder
-	for _, line := range ce.parsed.OutputLines {
+	for _, line := range ce.parsed.Output {
 		if line.Stream == "stdout" {
 			output.WriteString(line.Text)
 			output.WriteString("\n")
@@ -49,14 +162,14 @@ func (ce CodeExecution) GetOutput() (string, error) {
 }
 
 // GetError returns the error output from code execution as a string.
-// Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
+// Returns an *UnexpectedResultSchemaError holding the raw JSON if it could not be parsed.
 func (ce CodeExecution) GetError() (string, error) {
 	if !ce.parsedOK {
-		return "", ErrExecutionNotParsed
+		return "", notParsedErr(ce.parseErr)
 	}
 
 	var errorOutput strings.Builder
-	for _, line := range ce.parsed.OutputLines {
+	for _, line := range ce.parsed.Output {
 		if line.Stream == "stderr" {
 			errorOutput.WriteString(line.Text)
 			errorOutput.WriteString("\n")
@@ -78,7 +191,7 @@ func (ce CodeExecution) HasError() bool {
 	}
 
 	// Check for stderr output
-	for _, line := range ce.parsed.OutputLines {
+	for _, line := range ce.parsed.Output {
 		if line.Stream == "stderr" && line.Text != "" {
 			return true
 		}
@@ -104,3 +217,9 @@ func (ce CodeExecution) GetLanguage() string {
 	return ce.parsed.Language
 }
 
+
+// GetUsage returns the CPU time and peak memory the execution consumed.
+// Returns false if the server didn't report them or the raw JSON could not be parsed.
+func (ce CodeExecution) GetUsage() (ResourceUsage, bool) {
+	return usageOf(ce.parsed.Usage, ce.parsedOK)
+}
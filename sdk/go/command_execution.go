@@ -2,35 +2,79 @@ package msb
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
+	"time"
 )
 
 // CommandExecution represents the result of command execution in the sandbox.
-// Use the Get* methods for parsed access to output, or access Output directly for raw JSON.
+// Use the Get* methods for parsed access to output, Result for the typed payload,
+// or Raw for the JSON exactly as the server sent it.
 type CommandExecution struct {
 	Output    json.RawMessage // Raw JSON response from the server
-	parsed    commandData     // Parsed data for convenience methods
+	parsed    CommandResult   // Parsed data for convenience methods
 	parsedOK  bool           // Whether parsing succeeded
+	parseErr  error          // Why parsing failed
+	fsChanges *FSChanges     // Files the command changed, if tracked
+	sent      time.Time      // When the request left this client
+	received  time.Time      // When the result arrived
+	source    *execSource    // Where the command ran, for ExportBundle; nil if unknown
 }
 
-// Internal structure for parsing command execution results
-type commandData struct {
-	OutputLines []outputLine `json:"output"`
-	Command     string       `json:"command"`
-	Args        []string     `json:"args"`
-	ExitCode    int          `json:"exit_code"`
-	Success     bool         `json:"success"`
+// CommandResult is the payload of a command execution, as returned by the server. To read fields
+// this SDK doesn't know yet, decode the raw payload into a struct embedding CommandResult with
+// CommandExecution.Decode.
+type CommandResult struct {
+	Command    string         `json:"command"`               // Command that was run
+	Args       []string       `json:"args"`                  // Arguments it was run with
+	ExitCode   int            `json:"exit_code"`             // Process exit code
+	Success    bool           `json:"success"`               // Whether the exit code was 0
+	Output     []OutputLine   `json:"output"`                // Output lines in the order they were produced
+	Usage      *ResourceUsage `json:"usage,omitempty"`       // Resources the run consumed; nil if not reported
+	StartedAt  *time.Time     `json:"started_at,omitempty"`  // When the server started the command, by its clock; nil if not reported
+	FinishedAt *time.Time     `json:"finished_at,omitempty"` // When the command finished, by the server's clock; nil if not reported
+}
+
+// newCommandExecution wraps a raw command result, parsing it for the convenience methods.
+func newCommandExecution(output json.RawMessage) CommandExecution {
+	exec := CommandExecution{Output: output}
+	exec.parseErr = parseResult(output, &exec.parsed, "exit_code", "output")
+	exec.parsedOK = exec.parseErr == nil
+	return exec
+}
+
+// Raw returns the command result exactly as the server sent it.
+func (ce CommandExecution) Raw() json.RawMessage {
+	return ce.Output
+}
+
+// Result returns the typed command result.
+// Returns an *UnexpectedResultSchemaError holding the raw JSON if it could not be parsed.
+func (ce CommandExecution) Result() (CommandResult, error) {
+	if !ce.parsedOK {
+		return CommandResult{}, notParsedErr(ce.parseErr)
+	}
+	return ce.parsed, nil
+}
+
+// Decode unmarshals the raw command result into v, typically a struct embedding CommandResult
+// alongside fields newer servers return.
+func (ce CommandExecution) Decode(v any) error {
+	if err := json.Unmarshal(ce.Output, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+	}
+	return nil
 }
 
 // GetOutput returns the standard output from command execution as a string.
-// Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
+// Returns an *UnexpectedResultSchemaError holding the raw JSON if it could not be parsed.
 func (ce CommandExecution) GetOutput() (string, error) {
 	if !ce.parsedOK {
-		return "", ErrExecutionNotParsed
+		return "", notParsedErr(ce.parseErr)
 	}
 	
 	var output strings.Builder
-	for _, line := range ce.parsed.OutputLines {
+	for _, line := range ce.parsed.Output {
 		if line.Stream == "stdout" {
 			output.WriteString(line.Text)
 			output.WriteString("\n")
@@ -40,14 +84,14 @@ func (ce CommandExecution) GetOutput() (string, error) {
 }
 
 // GetError returns the error output from command execution as a string.
-// Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
+// Returns an *UnexpectedResultSchemaError holding the raw JSON if it could not be parsed.
 func (ce CommandExecution) GetError() (string, error) {
 	if !ce.parsedOK {
-		return "", ErrExecutionNotParsed
+		return "", notParsedErr(ce.parseErr)
 	}
 	
 	var errorOutput strings.Builder
-	for _, line := range ce.parsed.OutputLines {
+	for _, line := range ce.parsed.Output {
 		if line.Stream == "stderr" {
 			errorOutput.WriteString(line.Text)
 			errorOutput.WriteString("\n")
@@ -90,4 +134,9 @@ func (ce CommandExecution) GetArgs() []string {
 		return nil
 	}
 	return ce.parsed.Args
-}
\ No newline at end of file
+}
+// GetUsage returns the CPU time and peak memory the command consumed.
+// Returns false if the server didn't report them or the raw JSON could not be parsed.
+func (ce CommandExecution) GetUsage() (ResourceUsage, bool) {
+	return usageOf(ce.parsed.Usage, ce.parsedOK)
+}
@@ -1,13 +1,64 @@
 package msb
 
+import "time"
+
 type ReqIdProducer func() string
 
 type config struct {
 	serverUrl string
 	name      string
+	nameErr   error // set if name is invalid, to fail every request with
+	nameGen   NameGenerator
 	apiKey    string
+	noAuth    bool
 	logger    Logger
+	logLevel  LogLevel
 	reqIDPrd  ReqIdProducer
+	retry     RetryPolicy
+	leaseTTL  time.Duration
+	keepAlive time.Duration
+	traceHdrs TraceHeaderExtractor
+	timeouts  Timeouts
+
+	endpointPath string
+	apiVersion   APIVersion
+	serverUrlErr error            // set if serverUrl is invalid, to fail every request with
+	discovery    *serverDiscovery // shared with the configs copied from this one
+
+	authHandler AuthErrorHandler
+	refreshed   *refreshedKey // shared with the configs copied from this one
+
+	pullProgress func(PullProgress)
+
+	ctxMetadata ContextMetadata // attached to every request as headers
+
+	outputLogging *OutputLogging // nil unless output is mirrored into the logger
+
+	nodeOptions NodeOptions
+
+	logSampler *logSampler     // shared with the configs copied from this one
+	scheduler  *fairScheduler  // shared with the configs copied from this one
+	clock      *clockEstimator // shared with the configs copied from this one
+
+	maxRequestBytes    int // 0 for the default, negative for no limit
+	codeChunkThreshold int // 0 for the default, negative to turn chunking off
+
+	sessionKey  []byte
+	sessionMeta map[string]string
+
+	tenant       string // set on clients derived with Client.WithTenant
+	tenantTokens TenantTokenProvider
+
+	auditSink func(AuditEvent)
+	gauges    *MetricsGauges // shared with the configs copied from this one
+	policy    *compiledPolicy
+	screener  CodeScreener
+	execCache ArtifactStore
+
+	stopOptions StopOptions
+	localEngine LocalEngine
+
+	containerRuntime string // set with WithContainerBackend
 }
 
 const (
@@ -0,0 +1,402 @@
+package msb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// containerLabel marks the containers the container backend runs sandboxes in.
+const containerLabel = "dev.microsandbox.sandbox"
+
+// WithContainerBackend runs sandboxes as containers of the Docker or Podman installation on this
+// host, driven through runtime's command-line tool ("docker", "podman" or a path to either), rather
+// than as microVMs on a microsandbox server. It is meant for hosts that can't run the server, such
+// as macOS setups without a supported hypervisor. An empty runtime picks docker, or podman if
+// docker isn't installed. No API key is needed.
+//
+// Containers share the host's kernel, so they isolate code far less than microVMs do: use the
+// backend for trusted code and development, never for untrusted workloads. Sandboxes started with
+// it report SandboxInfo.Container. The backend is degraded in other ways too:
+//   - every Run starts a fresh interpreter, so the REPL keeps no state between runs
+//   - stdout comes before stderr rather than interleaved with it
+//   - Mounts, DiskMiB and restricted network policies fail Start with ErrNotSupportedByContainers;
+//     NetworkNone runs the container without network
+//   - GPUs, DependsOn, Shell, Scripts and Exec are ignored
+//   - metrics report whether the sandbox runs, but no usage
+//   - leases are never reclaimed: containers live until they are stopped
+//   - image builds and loads, events and diagnostics fail with ErrMethodNotFound
+func WithContainerBackend(runtime string) Option {
+	return func(msb *baseMicroSandbox) {
+		if runtime == "" {
+			runtime = "docker"
+			if _, err := exec.LookPath(runtime); err != nil {
+				if _, err := exec.LookPath("podman"); err == nil {
+					runtime = "podman"
+				}
+			}
+		}
+		msb.cfg.containerRuntime = runtime
+	}
+}
+
+// containerRPCClient is the rpcClient of the container backend, set up with WithContainerBackend.
+type containerRPCClient struct {
+	runtime string
+	locks   localLocks
+}
+
+var _ rpcClient = &containerRPCClient{}
+
+// run runs the runtime's CLI with args, feeding it stdin. A non-zero exit code is reported in
+// exitCode rather than as an error.
+func (c *containerRPCClient) run(ctx context.Context, stdin string, args ...string) (stdout, stderr string, exitCode int, err error) {
+	cmd := exec.CommandContext(ctx, c.runtime, args...)
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = &outBuf, &errBuf
+	err = cmd.Run()
+	if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) && ctx.Err() == nil {
+		return outBuf.String(), errBuf.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", "", 0, fmt.Errorf("%w: %s %s: %w", ErrContainerRuntimeFailed, c.runtime, args[0], err)
+	}
+	return outBuf.String(), errBuf.String(), 0, nil
+}
+
+// cli runs the runtime's CLI with args, failing unless it succeeds, and returns its trimmed output.
+func (c *containerRPCClient) cli(ctx context.Context, args ...string) (string, error) {
+	stdout, stderr, exitCode, err := c.run(ctx, "", args...)
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("%w: %s %s: %s", ErrContainerRuntimeFailed, c.runtime, args[0], strings.TrimSpace(stderr))
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+func (c *containerRPCClient) startSandbox(ctx context.Context, cfg *config, sc startConfig) (*startResult, error) {
+	args := []string{"run", "-d", "--name", cfg.name, "--label", containerLabel + "=" + cfg.name}
+	if sc.Memory > 0 {
+		args = append(args, "--memory", strconv.Itoa(sc.Memory)+"m")
+		if sc.SwapMiB > 0 {
+			args = append(args, "--memory-swap", strconv.Itoa(sc.Memory+sc.SwapMiB)+"m")
+		}
+	}
+	if sc.CPUs > 0 {
+		args = append(args, "--cpus", strconv.Itoa(sc.CPUs))
+	}
+	if sc.Limits != nil {
+		if sc.Limits.MaxProcesses > 0 {
+			args = append(args, "--pids-limit", strconv.Itoa(sc.Limits.MaxProcesses))
+		}
+		if n := sc.Limits.MaxOpenFiles; n > 0 {
+			args = append(args, "--ulimit", fmt.Sprintf("nofile=%d:%d", n, n))
+		}
+		if n := sc.Limits.MaxFileSizeMiB << 20; n > 0 {
+			args = append(args, "--ulimit", fmt.Sprintf("fsize=%d:%d", n, n))
+		}
+	}
+	for _, v := range sc.Volumes {
+		args = append(args, "-v", v)
+	}
+	for _, p := range sc.Ports {
+		args = append(args, "-p", p)
+	}
+	for _, e := range sc.Envs {
+		args = append(args, "-e", e)
+	}
+	if sc.Workdir != "" {
+		args = append(args, "-w", sc.Workdir)
+	}
+	if sc.User != "" {
+		args = append(args, "--user", sc.User)
+	}
+	if sc.Platform != "" {
+		args = append(args, "--platform", sc.Platform)
+	}
+	switch sc.PullPolicy {
+	case PullAlways:
+		args = append(args, "--pull", "always")
+	case PullIfNotPresent:
+		args = append(args, "--pull", "missing")
+	case PullNever:
+		args = append(args, "--pull", "never")
+	}
+	// settings that contain the sandbox can't be dropped: it would run with more access than asked for
+	switch {
+	case len(sc.Mounts) > 0:
+		return nil, fmt.Errorf("%w: mounts", ErrNotSupportedByContainers)
+	case sc.DiskMiB > 0:
+		return nil, fmt.Errorf("%w: disk limit", ErrNotSupportedByContainers)
+	case sc.Network != nil && sc.Network.Mode == NetworkRestricted:
+		return nil, fmt.Errorf("%w: %q network policy", ErrNotSupportedByContainers, NetworkRestricted)
+	case sc.Network != nil && sc.Network.Mode == NetworkNone:
+		args = append(args, "--network", "none")
+	}
+	if sc.DNS != nil {
+		for _, server := range sc.DNS.Servers {
+			args = append(args, "--dns", server)
+		}
+		for _, host := range slices.Sorted(maps.Keys(sc.DNS.ExtraHosts)) {
+			args = append(args, "--add-host", host+":"+sc.DNS.ExtraHosts[host])
+		}
+	}
+	if sc.GPUs != nil || len(sc.DependsOn) > 0 || sc.Shell != "" || len(sc.Scripts) > 0 || sc.Exec != "" {
+		cfg.log(ctx).Info("Ignoring settings the container backend doesn't support", "sandbox", cfg.name)
+	}
+	// keep the container up without depending on the image's entrypoint
+	args = append(args, "--entrypoint", "sh", sc.Image, "-c", "sleep infinity || while :; do sleep 3600; done")
+
+	cfg.log(ctx).Debug("Starting sandbox container", "sandbox", cfg.name, "runtime", c.runtime, "image", sc.Image)
+	if _, err := c.cli(ctx, args...); err != nil {
+		return nil, err
+	}
+	return &startResult{container: true}, nil
+}
+
+func (c *containerRPCClient) stopSandbox(ctx context.Context, cfg *config) error {
+	c.locks.drop(cfg.name)
+	_, err := c.cli(ctx, "rm", "-f", cfg.name)
+	return err
+}
+
+// interpreters are the commands reading a program for each REPL language on stdin.
+var interpreters = map[progLang][]string{
+	langPython: {"python3", "-"},
+	langNodeJs: {"node", "-"},
+}
+
+func (c *containerRPCClient) runRepl(ctx context.Context, cfg *config, lang progLang, code string) (*executionResult, error) {
+	cfg.log(ctx).Debug("Executing code in container", "sandbox", cfg.name, "language", lang.String())
+	stdout, stderr, exitCode, err := c.run(ctx, code, append([]string{"exec", "-i", cfg.name}, interpreters[lang]...)...)
+	if err != nil {
+		return nil, err
+	}
+	result := ReplResult{Status: "success", Language: lang.String()}
+	if exitCode != 0 {
+		result.Status = "exception"
+	}
+	result.Output = append(localOutputLines("stdout", stdout), localOutputLines("stderr", stderr)...)
+	return marshalContainerResult(result)
+}
+
+func (c *containerRPCClient) runCommand(ctx context.Context, cfg *config, user, command string, args []string) (*executionResult, error) {
+	return c.runCommandTo(ctx, cfg, user, command, args, nil)
+}
+
+func (c *containerRPCClient) runCommandTo(ctx context.Context, cfg *config, user, command string, args []string, sink outputSink) (*executionResult, error) {
+	cliArgs := []string{"exec"}
+	if user != "" {
+		cliArgs = append(cliArgs, "--user", user)
+	}
+	cliArgs = append(append(cliArgs, cfg.name, command), args...)
+
+	cfg.log(ctx).Debug("Executing command in container", "sandbox", cfg.name, "command", command, "args", args)
+	stdout, stderr, exitCode, err := c.run(ctx, "", cliArgs...)
+	if err != nil {
+		return nil, err
+	}
+	result := CommandResult{Command: command, Args: args, ExitCode: exitCode, Success: exitCode == 0, Output: []OutputLine{}}
+	for _, line := range append(localOutputLines("stdout", stdout), localOutputLines("stderr", stderr)...) {
+		var w io.Writer
+		if sink != nil {
+			w = sink.writer(line.Stream)
+		}
+		if w == nil {
+			result.Output = append(result.Output, line)
+			continue
+		}
+		if _, err := io.WriteString(w, line.Text+"\n"); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToWriteOutput, err)
+		}
+	}
+	return marshalContainerResult(result)
+}
+
+func marshalContainerResult(result any) (*executionResult, error) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrContainerRuntimeFailed, err)
+	}
+	return &executionResult{output: raw}, nil
+}
+
+// containerState returns the runtime's state of the sandbox's container, "" if there is none.
+func (c *containerRPCClient) containerState(ctx context.Context, cfg *config) (string, error) {
+	stdout, stderr, exitCode, err := c.run(ctx, "", "inspect", "--type", "container", "-f", "{{.State.Status}}", cfg.name)
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		if strings.Contains(strings.ToLower(stderr), "no such") {
+			return "", nil
+		}
+		return "", fmt.Errorf("%w: %s inspect: %s", ErrContainerRuntimeFailed, c.runtime, strings.TrimSpace(stderr))
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// containerStatus maps a container state to the sandbox's status.
+func containerStatus(state string) SandboxStatus {
+	switch state {
+	case "created", "restarting":
+		return StatusStarting
+	case "running":
+		return StatusRunning
+	case "paused":
+		return StatusPaused
+	default: // none, "removing", "exited", "dead", "stopped"
+		return StatusStopped
+	}
+}
+
+func (c *containerRPCClient) getMetrics(ctx context.Context, cfg *config) (*sandboxMetrics, error) {
+	state, err := c.containerState(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" {
+		return &sandboxMetrics{}, nil
+	}
+	return &sandboxMetrics{Name: cfg.name, Running: state == "running"}, nil
+}
+
+func (c *containerRPCClient) listMetrics(ctx context.Context, cfg *config) ([]sandboxMetrics, error) {
+	out, err := c.cli(ctx, "ps", "-a", "--filter", "label="+containerLabel, "--format", "{{.Names}}\t{{.State}}")
+	if err != nil {
+		return nil, err
+	}
+	var sandboxes []sandboxMetrics
+	for _, line := range strings.Split(out, "\n") {
+		name, state, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		sandboxes = append(sandboxes, sandboxMetrics{Name: name, Running: strings.EqualFold(state, "running")})
+	}
+	return sandboxes, nil
+}
+
+func (c *containerRPCClient) ping(ctx context.Context, cfg *config) error {
+	_, err := c.cli(ctx, "version")
+	return err
+}
+
+func (c *containerRPCClient) getStatus(ctx context.Context, cfg *config) (SandboxStatus, error) {
+	state, err := c.containerState(ctx, cfg)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	return containerStatus(state), nil
+}
+
+func (c *containerRPCClient) getLogs(ctx context.Context, cfg *config, offset int) (*logsResult, error) {
+	stdout, stderr, exitCode, err := c.run(ctx, "", "logs", cfg.name)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("%w: %s logs: %s", ErrContainerRuntimeFailed, c.runtime, strings.TrimSpace(stderr))
+	}
+	state, err := c.containerState(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range append(localOutputLines("stdout", stdout), localOutputLines("stderr", stderr)...) {
+		lines = append(lines, line.Text)
+	}
+	return &logsResult{Lines: lines[min(offset, len(lines)):], Running: state == "running"}, nil
+}
+
+func (c *containerRPCClient) renewLease(ctx context.Context, cfg *config, holder string, ttl time.Duration) (time.Time, error) {
+	// nothing reclaims containers, so the lease is never at risk
+	return time.Now().Add(ttl), nil
+}
+
+func (c *containerRPCClient) releaseLease(ctx context.Context, cfg *config, holder string) error {
+	return nil
+}
+
+func (c *containerRPCClient) acquireLock(ctx context.Context, cfg *config, key, holder string, ttl time.Duration) (bool, error) {
+	return c.locks.acquire(cfg.name, key, holder), nil
+}
+
+func (c *containerRPCClient) releaseLock(ctx context.Context, cfg *config, key, holder string) error {
+	c.locks.release(cfg.name, key, holder)
+	return nil
+}
+
+func (c *containerRPCClient) inspectImage(ctx context.Context, cfg *config, ref string) (*ImageInfo, error) {
+	out, err := c.cli(ctx, "image", "inspect", "-f", "{{.Id}}\t{{.Size}}", ref)
+	if err != nil {
+		return nil, err
+	}
+	id, size, _ := strings.Cut(out, "\t")
+	n, _ := strconv.ParseInt(size, 10, 64)
+	return &ImageInfo{Ref: ref, ID: id, Size: n}, nil
+}
+
+// unsupported is the error of the methods the container backend has no equivalent for, which
+// matches ErrMethodNotFound like the error of a server too old to support them.
+func (c *containerRPCClient) unsupported(method rpcMethod) error {
+	return fmt.Errorf("%w: %w: %s", ErrNotSupportedLocally, ErrMethodNotFound, method)
+}
+
+func (c *containerRPCClient) buildImage(ctx context.Context, cfg *config, params imageBuildParams) (string, error) {
+	return "", c.unsupported(methodImageBuild)
+}
+
+func (c *containerRPCClient) buildLogs(ctx context.Context, cfg *config, buildID string, offset int) (*buildLogsResult, error) {
+	return nil, c.unsupported(methodImageBuildLogs)
+}
+
+func (c *containerRPCClient) beginImageLoad(ctx context.Context, cfg *config) (string, error) {
+	return "", c.unsupported(methodImageLoadBegin)
+}
+
+func (c *containerRPCClient) uploadImageChunk(ctx context.Context, cfg *config, uploadID string, offset int64, data []byte) error {
+	return c.unsupported(methodImageLoadChunk)
+}
+
+func (c *containerRPCClient) commitImageLoad(ctx context.Context, cfg *config, uploadID string) ([]string, error) {
+	return nil, c.unsupported(methodImageLoadCommit)
+}
+
+func (c *containerRPCClient) listImages(ctx context.Context, cfg *config) ([]ImageInfo, error) {
+	return nil, c.unsupported(methodImageList)
+}
+
+func (c *containerRPCClient) pruneImages(ctx context.Context, cfg *config, olderThan time.Duration) (*imagePruneResult, error) {
+	return nil, c.unsupported(methodImagePrune)
+}
+
+func (c *containerRPCClient) getPullProgress(ctx context.Context, cfg *config, image string) (*pullProgressResult, error) {
+	return nil, c.unsupported(methodImagePullProgress)
+}
+
+func (c *containerRPCClient) getEvents(ctx context.Context, cfg *config, params eventsParams) (*eventsResult, error) {
+	return nil, c.unsupported(methodSandboxEventsGet)
+}
+
+func (c *containerRPCClient) getDiagnostics(ctx context.Context, cfg *config, logLines int) (*Diagnostics, error) {
+	return nil, c.unsupported(methodSandboxDiagnostics)
+}
+
+// Container-backend-related errors
+var (
+	ErrContainerRuntimeFailed   = errors.New("container runtime failed")
+	ErrNotSupportedByContainers = errors.New("not supported by container sandboxes")
+)
@@ -0,0 +1,109 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// crashLogLines is how many of the sandbox's last log lines are collected for a crash report.
+const crashLogLines = 50
+
+// Diagnostics is the server's account of a sandbox that terminated unexpectedly.
+type Diagnostics struct {
+	Reason         string    `json:"reason"`                 // e.g. "oom" or "kernel_panic"; empty if the server doesn't know
+	Time           time.Time `json:"time"`                   // When the sandbox died; zero if unknown
+	ExitCode       int       `json:"exit_code"`              // Exit code of the VM process
+	MemoryMiB      int       `json:"memory_usage"`           // Memory in use when the sandbox died
+	MemoryLimitMiB int       `json:"memory_limit,omitempty"` // Memory limit the sandbox ran with
+	LogLines       []string  `json:"log_lines"`              // Last lines of the sandbox's log
+	Message        string    `json:"message,omitempty"`      // Details reported by the server
+}
+
+// OOM reports whether the sandbox was killed for running out of memory.
+func (d Diagnostics) OOM() bool {
+	return d.Reason == "oom" || d.Reason == "oom_killed"
+}
+
+// SandboxCrashedError reports an execution that failed because the sandbox's VM died during it,
+// e.g. of an out-of-memory kill or a kernel panic. It matches ErrSandboxCrashed with errors.Is.
+// The handle is moved back to the stopped state, so the sandbox can be started again.
+type SandboxCrashedError struct {
+	Sandbox     string       // Sandbox name
+	Diagnostics *Diagnostics // What the server knows about the crash; nil if it couldn't be retrieved
+	Err         error        // Error the execution failed with
+}
+
+func (e *SandboxCrashedError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v: %s", ErrSandboxCrashed, e.Sandbox)
+	if d := e.Diagnostics; d != nil {
+		if d.Reason != "" {
+			fmt.Fprintf(&sb, ": %s", d.Reason)
+		}
+		switch {
+		case d.MemoryLimitMiB > 0:
+			fmt.Fprintf(&sb, " (memory %d/%d MiB)", d.MemoryMiB, d.MemoryLimitMiB)
+		case d.MemoryMiB > 0:
+			fmt.Fprintf(&sb, " (memory %d MiB)", d.MemoryMiB)
+		}
+	}
+	fmt.Fprintf(&sb, ": %v", e.Err)
+	return sb.String()
+}
+
+func (e *SandboxCrashedError) Is(target error) bool {
+	return target == ErrSandboxCrashed
+}
+
+func (e *SandboxCrashedError) Unwrap() error {
+	return e.Err
+}
+
+// crashErr checks whether an execution failed with err because the sandbox died, and if so returns
+// a SandboxCrashedError with the server's diagnostics. Any other error is returned unchanged.
+func (b *baseMicroSandbox) crashErr(ctx context.Context, err error) error {
+	var authErr *UnauthorizedError
+	switch {
+	case ctx.Err() != nil, // cancelled or timed out by the caller
+		errors.Is(err, ErrAborted),
+		errors.Is(err, ErrExecutionTimedOut),
+		errors.Is(err, ErrDiskQuotaExceeded),
+		errors.Is(err, ErrFailedToWriteOutput),
+		errors.Is(err, ErrPayloadTooLarge),
+		errors.As(err, &authErr):
+		return err
+	}
+
+	// ctx is still live, but the check must not be cut short by the execution's own bounds either
+	ctx, cancel := withTimeout(context.WithoutCancel(ctx), b.cfg.timeouts.Metrics)
+	defer cancel()
+	status, statusErr := readStatus(ctx, b)
+	if statusErr != nil || (status != StatusCrashed && status != StatusStopped) {
+		return err
+	}
+	b.cfg.log(ctx).Error("Sandbox died during execution", "name", b.cfg.name, "status", status.String())
+
+	crashed := &SandboxCrashedError{Sandbox: b.cfg.name, Err: err}
+	diag, diagErr := b.rpcClient.getDiagnostics(ctx, &b.cfg, crashLogLines)
+	if errors.Is(diagErr, ErrMethodNotFound) {
+		// servers without the diagnostics RPC still have the log
+		diag, diagErr = &Diagnostics{}, nil
+		if logs, logsErr := b.rpcClient.getLogs(ctx, &b.cfg, 0); logsErr == nil {
+			diag.LogLines = logs.Lines[max(len(logs.Lines)-crashLogLines, 0):]
+		}
+	}
+	if diagErr != nil {
+		b.cfg.log(ctx).Debug("Failed to get crash diagnostics", "name", b.cfg.name, "error", diagErr)
+	} else {
+		crashed.Diagnostics = diag
+	}
+	return crashed
+}
+
+// Crash-related errors
+var (
+	ErrSandboxCrashed = errors.New("sandbox crashed")
+)
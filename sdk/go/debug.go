@@ -0,0 +1,108 @@
+package msb
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DebugPath is where RegisterDebugHandlers serves the SDK's debug counters.
+const DebugPath = "/debug/msb"
+
+// DebugSnapshot holds the counters of every open pool and started cluster, keyed by name.
+type DebugSnapshot struct {
+	Pools    map[string]PoolStats    `json:"pools"`
+	Clusters map[string]ClusterStats `json:"clusters"`
+}
+
+// RegisterDebugHandlers serves a JSON DebugSnapshot at DebugPath on mux, next to whatever pprof
+// handlers the operator's debug server already exposes. The snapshot is also published as the
+// expvar variable "msb", so it appears under /debug/vars wherever expvar.Handler is mounted.
+//
+// Pools are listed under PoolConfig.Name (or "pool-N"), clusters under "cluster-N".
+func RegisterDebugHandlers(mux *http.ServeMux) {
+	publishDebugVarOnce.Do(func() {
+		expvar.Publish("msb", expvar.Func(func() any { return debugTargets.snapshot() }))
+	})
+	mux.HandleFunc(DebugPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(debugTargets.snapshot())
+	})
+}
+
+var publishDebugVarOnce sync.Once
+
+// debugRegistry tracks the pools and clusters reported by RegisterDebugHandlers.
+type debugRegistry struct {
+	mu       sync.Mutex
+	seq      map[string]int
+	pools    map[string]*Pool
+	clusters map[string]*Cluster
+}
+
+var debugTargets = &debugRegistry{
+	seq:      make(map[string]int),
+	pools:    make(map[string]*Pool),
+	clusters: make(map[string]*Cluster),
+}
+
+// add registers target under name, or under a generated "<kind>-N" name if name is empty
+// or taken, and returns the name used.
+func (r *debugRegistry) add(name, kind string, target any) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name == "" || r.taken(name) {
+		r.seq[kind]++
+		name = fmt.Sprintf("%s-%d", kind, r.seq[kind])
+	}
+	switch t := target.(type) {
+	case *Pool:
+		r.pools[name] = t
+	case *Cluster:
+		r.clusters[name] = t
+	}
+	return name
+}
+
+func (r *debugRegistry) taken(name string) bool {
+	_, pool := r.pools[name]
+	_, cluster := r.clusters[name]
+	return pool || cluster
+}
+
+func (r *debugRegistry) remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pools, name)
+	delete(r.clusters, name)
+}
+
+func (r *debugRegistry) snapshot() DebugSnapshot {
+	r.mu.Lock()
+	pools := make(map[string]*Pool, len(r.pools))
+	for name, p := range r.pools {
+		pools[name] = p
+	}
+	clusters := make(map[string]*Cluster, len(r.clusters))
+	for name, c := range r.clusters {
+		clusters[name] = c
+	}
+	r.mu.Unlock()
+
+	// stats are gathered outside the registry lock, as clusters take their own
+	snap := DebugSnapshot{
+		Pools:    make(map[string]PoolStats, len(pools)),
+		Clusters: make(map[string]ClusterStats, len(clusters)),
+	}
+	for name, p := range pools {
+		snap.Pools[name] = p.Stats()
+	}
+	for name, c := range clusters {
+		snap.Clusters[name] = c.Stats()
+	}
+	return snap
+}
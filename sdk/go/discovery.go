@@ -0,0 +1,153 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryRefresh is how long a discovered server is used before the SRV records are checked
+// again.
+const discoveryRefresh = 30 * time.Second
+
+// WithDiscovery finds the server with a DNS SRV lookup of name, e.g. "_msb._tcp.example.com",
+// instead of a fixed URL, for clients in environments where servers come and go. Servers are
+// reached over http, or over https if name is given as "https://_msb._tcp.example.com".
+//
+// The server picked (by SRV priority and weight) is shared by a Client and the sandboxes derived
+// from it, and kept as long as it is listed and reachable, since sandboxes live on one server.
+// The records are checked again every 30 seconds, and right away after a request couldn't reach
+// the server. WithServerUrl turns discovery off again.
+func WithDiscovery(name string) Option {
+	return func(msb *baseMicroSandbox) {
+		scheme, name, ok := strings.Cut(name, "://")
+		if !ok {
+			scheme, name = "http", scheme
+		}
+		msb.cfg.discovery = &serverDiscovery{
+			scheme: strings.ToLower(scheme),
+			name:   strings.TrimSuffix(name, "."),
+			lookup: net.DefaultResolver.LookupSRV,
+		}
+	}
+}
+
+// serverDiscovery resolves the server URL from SRV records and remembers the server picked.
+type serverDiscovery struct {
+	scheme string
+	name   string
+	lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	mu      sync.Mutex
+	url     string    // server in use; empty until the first lookup
+	checked time.Time // when the records were last looked up
+}
+
+// serverURL returns the URL of the server to send requests to, looking it up if needed.
+func (d *serverDiscovery) serverURL(ctx context.Context, logger Logger) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.url != "" && time.Since(d.checked) < discoveryRefresh {
+		return d.url, nil
+	}
+	if err := d.validate(); err != nil {
+		return "", err
+	}
+
+	_, records, err := d.lookup(ctx, "", "", d.name)
+	if err == nil && len(records) == 0 {
+		err = errors.New("no SRV records")
+	}
+	if err != nil {
+		if d.url != "" {
+			// DNS hiccups don't take down a server that still answers
+			logger.Error("Failed to refresh server discovery; keeping server", "name", d.name, "url", d.url, "error", err)
+			d.checked = time.Now()
+			return d.url, nil
+		}
+		return "", fmt.Errorf("%w: %s: %w", ErrDiscoveryFailed, d.name, err)
+	}
+
+	urls := make([]string, len(records))
+	for i, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		urls[i] = d.scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))
+	}
+	d.checked = time.Now()
+	if !slices.Contains(urls, d.url) {
+		// records come sorted by priority and shuffled by weight
+		if d.url != "" {
+			logger.Info("Discovered server changed", "name", d.name, "from", d.url, "to", urls[0])
+		}
+		d.url = urls[0]
+	}
+	return d.url, nil
+}
+
+// validate checks the scheme given with WithDiscovery.
+func (d *serverDiscovery) validate() error {
+	if d.scheme != "http" && d.scheme != "https" {
+		return fmt.Errorf("%w: %s: unsupported scheme %q, use http or https", ErrDiscoveryFailed, d.name, d.scheme)
+	}
+	return nil
+}
+
+// forget drops the server at url after a request couldn't reach it, so the next request looks up
+// the records again.
+func (d *serverDiscovery) forget(url string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.url == url {
+		d.checked = time.Time{}
+	}
+}
+
+// current returns the server in use, or "" if none was discovered yet.
+func (d *serverDiscovery) current() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.url
+}
+
+// baseURL returns the URL of the server to send requests to: the discovered one with
+// WithDiscovery, the configured one otherwise.
+func (c *config) baseURL(ctx context.Context) (string, error) {
+	if c.discovery == nil {
+		return c.serverUrl, nil
+	}
+	return c.discovery.serverURL(ctx, c.log(ctx))
+}
+
+// currentServerURL returns the URL of the server requests currently go to, for reporting.
+func (c *config) currentServerURL() string {
+	if c.discovery != nil {
+		if u := c.discovery.current(); u != "" {
+			return u
+		}
+	}
+	return c.serverUrl
+}
+
+// bracketIPv6 puts brackets around a bare IPv6 host ("::1" becomes "[::1]") and escapes the
+// zone of a bracketed one ("[fe80::1%eth0]"), which url.Parse otherwise rejects.
+func bracketIPv6(hostport string) string {
+	if ip := net.ParseIP(hostport); ip != nil && strings.Contains(hostport, ":") {
+		return "[" + hostport + "]"
+	}
+	if host, rest, ok := strings.Cut(hostport, "]"); ok && strings.HasPrefix(host, "[") &&
+		strings.Contains(host, "%") && !strings.Contains(host, "%25") {
+		return strings.Replace(host, "%", "%25", 1) + "]" + rest
+	}
+	return hostport
+}
+
+// Discovery-related errors
+var (
+	ErrDiscoveryFailed = errors.New("server discovery failed")
+)
@@ -0,0 +1,38 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// diskFullMarkers are the messages the guest's libc and runtimes print for ENOSPC and EDQUOT.
+var diskFullMarkers = []string{
+	"No space left on device",
+	"Disk quota exceeded",
+	"ENOSPC",
+	"EDQUOT",
+}
+
+func isDiskFull(message string) bool {
+	for _, marker := range diskFullMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// diskQuotaErr returns an error wrapping ErrDiskQuotaExceeded if a failed execution's error
+// output shows it ran out of disk space, or nil otherwise.
+func diskQuotaErr(failed bool, stderr string) error {
+	if !failed || !isDiskFull(stderr) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrDiskQuotaExceeded, lastNonEmptyLine(stderr))
+}
+
+// Disk-related errors
+var (
+	ErrDiskQuotaExceeded = errors.New("disk quota exceeded")
+)
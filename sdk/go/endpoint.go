@@ -0,0 +1,147 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// APIVersion is a version of the server's JSON-RPC API, as it appears in the endpoint path.
+type APIVersion string
+
+const (
+	APIVersionAuto APIVersion = ""   // Newest version supported by both the SDK and the server (default)
+	APIv1          APIVersion = "v1" // The original API, served by every server
+	APIv2          APIVersion = "v2"
+)
+
+// apiVersions lists the versions the SDK can negotiate, newest first.
+var apiVersions = []APIVersion{APIv2, APIv1}
+
+// versionPlaceholder is replaced with the API version in endpoint paths.
+const versionPlaceholder = "{version}"
+
+const defaultEndpointPath = "/api/" + versionPlaceholder + "/rpc"
+
+// WithEndpointPath configures the path of the server's JSON-RPC endpoint, relative to the server
+// URL. Defaults to "/api/{version}/rpc". Useful when the server is mounted behind a reverse proxy
+// that rewrites paths, e.g. "/sandboxes/api/{version}/rpc". A "{version}" placeholder is replaced
+// with the API version; paths without one are used as is, and no version is negotiated.
+func WithEndpointPath(path string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.endpointPath = "/" + strings.TrimPrefix(path, "/")
+	}
+}
+
+// WithAPIVersion pins the version of the server's API. By default the newest version the server
+// supports is negotiated: requests start out with the newest version known to the SDK, and fall
+// back to older ones while the server answers that the endpoint doesn't exist. The outcome is
+// remembered per server for the lifetime of the process, so the probing happens only once.
+func WithAPIVersion(version APIVersion) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.apiVersion = version
+	}
+}
+
+// normalizeServerURL accepts server URLs with or without a scheme ("127.0.0.1:5555" means
+// "http://127.0.0.1:5555") and with or without a trailing slash, and returns them in the form
+// request URLs are built from: a lowercase http or https scheme, a host, and a path prefix
+// without a trailing slash, if any.
+func normalizeServerURL(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	scheme, rest, _ := strings.Cut(s, "://")
+	hostport, path := rest, ""
+	if i := strings.Index(rest, "/"); i != -1 {
+		hostport, path = rest[:i], rest[i:]
+	}
+	s = scheme + "://" + bracketIPv6(hostport) + path
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %w", ErrInvalidServerURL, raw, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "", fmt.Errorf("%w: %q: unsupported scheme %q, use http or https", ErrInvalidServerURL, raw, u.Scheme)
+	case u.Host == "":
+		return "", fmt.Errorf("%w: %q: no host", ErrInvalidServerURL, raw)
+	case u.RawQuery != "" || u.Fragment != "":
+		return "", fmt.Errorf("%w: %q: query and fragment not allowed", ErrInvalidServerURL, raw)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// endpointURL returns the URL requests are sent to on the server at base with version, which is
+// empty when the endpoint path has no version in it.
+func (c *config) endpointURL(base string, version APIVersion) string {
+	return base + strings.ReplaceAll(c.endpointPath, versionPlaceholder, string(version))
+}
+
+// currentAPIVersion returns the version to send the next request with.
+func (c *config) currentAPIVersion() APIVersion {
+	if !strings.Contains(c.endpointPath, versionPlaceholder) {
+		return APIVersionAuto
+	}
+	if c.apiVersion != APIVersionAuto {
+		return c.apiVersion
+	}
+	if version, ok := negotiatedVersions.get(c.serverUrl, c.endpointPath); ok {
+		return version
+	}
+	return apiVersions[0]
+}
+
+// fallBackAPIVersion records that the server has no endpoint for failed, and reports whether
+// there is an older version left to retry the request with.
+func (c *config) fallBackAPIVersion(failed APIVersion) bool {
+	if c.apiVersion != APIVersionAuto || failed == APIVersionAuto {
+		return false
+	}
+	i := slices.Index(apiVersions, failed)
+	if i == -1 || i == len(apiVersions)-1 {
+		return false
+	}
+	older := apiVersions[i+1]
+	negotiatedVersions.set(c.serverUrl, c.endpointPath, older)
+	c.logger.Info("Server doesn't support API version, falling back", "version", failed, "fallback", older)
+	return true
+}
+
+// versionRegistry remembers the API version negotiated with each server endpoint.
+type versionRegistry struct {
+	mu       sync.Mutex
+	versions map[string]APIVersion
+}
+
+var negotiatedVersions = &versionRegistry{versions: make(map[string]APIVersion)}
+
+func (r *versionRegistry) key(serverUrl, path string) string {
+	return serverUrl + "\x00" + path
+}
+
+func (r *versionRegistry) get(serverUrl, path string) (APIVersion, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	version, ok := r.versions[r.key(serverUrl, path)]
+	return version, ok
+}
+
+func (r *versionRegistry) set(serverUrl, path string, version APIVersion) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.versions[r.key(serverUrl, path)] = version
+}
+
+// Endpoint-related errors
+var (
+	ErrEndpointNotFound = errors.New("JSON-RPC endpoint not found")
+	ErrInvalidServerURL = errors.New("invalid server URL")
+)
@@ -0,0 +1,87 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func validateEnvName(name string) error {
+	if !envNameRe.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidEnvName, name)
+	}
+	return nil
+}
+
+// mergeEnvs combines the "K=V" entries of envs with env into a single list, validating every
+// name. Later entries of envs override earlier ones, and env overrides envs.
+func mergeEnvs(envs []string, env map[string]string) ([]string, error) {
+	var merged []string
+	index := make(map[string]int)
+	set := func(name, value string) error {
+		if err := validateEnvName(name); err != nil {
+			return err
+		}
+		if i, ok := index[name]; ok {
+			merged[i] = name + "=" + value
+			return nil
+		}
+		index[name] = len(merged)
+		merged = append(merged, name+"="+value)
+		return nil
+	}
+	for _, entry := range envs {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not in K=V form", ErrInvalidEnvName, entry)
+		}
+		if err := set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		if err := set(name, env[name]); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+// withEnv prefixes a command with env(1) so it runs with env added to the sandbox's environment.
+func withEnv(env map[string]string, cmd string, args []string) (string, []string) {
+	if len(env) == 0 {
+		return cmd, args
+	}
+	prefixed := make([]string, 0, len(env)+1+len(args))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		prefixed = append(prefixed, name+"="+env[name])
+	}
+	return "env", append(append(prefixed, cmd), args...)
+}
+
+// toolEnv returns env with the variables of the virtualenv or Node project in use, if any, added
+// beneath it.
+func (b *baseMicroSandbox) toolEnv(env map[string]string) map[string]string {
+	var tool map[string]string
+	if venv := b.venv.Load(); venv != nil {
+		tool = venv.env
+	} else if project := b.node.Load(); project != nil {
+		tool = project.env
+	}
+	if tool == nil {
+		return env
+	}
+	merged := maps.Clone(tool)
+	maps.Copy(merged, env)
+	return merged
+}
+
+// Environment-related errors
+var (
+	ErrInvalidEnvName = errors.New("invalid environment variable")
+)
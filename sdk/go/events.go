@@ -0,0 +1,183 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// eventPollInterval is how often Events asks the server for new events.
+const eventPollInterval = time.Second
+
+// EventType is the kind of a sandbox lifecycle event.
+type EventType string
+
+const (
+	EventCreated     EventType = "created"      // The sandbox was created
+	EventStarted     EventType = "started"      // The sandbox started running
+	EventStopped     EventType = "stopped"      // The sandbox stopped, for whatever reason
+	EventOOMKilled   EventType = "oom_killed"   // The sandbox's processes were killed for running out of memory
+	EventImagePulled EventType = "image_pulled" // The server pulled the sandbox's image
+)
+
+// Event is a lifecycle event of a sandbox on the server.
+type Event struct {
+	Type    EventType `json:"type"`
+	Sandbox string    `json:"sandbox"`           // Sandbox name
+	Time    time.Time `json:"time"`              // When the event happened
+	Image   string    `json:"image,omitempty"`   // Image involved, if any
+	Message string    `json:"message,omitempty"` // Details reported by the server
+}
+
+// EventFilter selects the events Events reports. Empty fields match everything.
+type EventFilter struct {
+	Types     []EventType // Event types to report
+	Sandboxes []string    // Names of the sandboxes to report events of
+}
+
+func (f EventFilter) matches(e Event) bool {
+	return (len(f.Types) == 0 || slices.Contains(f.Types, e.Type)) &&
+		(len(f.Sandboxes) == 0 || slices.Contains(f.Sandboxes, e.Sandbox))
+}
+
+// scoped confines the filter to the sandboxes of the client's tenant, if any.
+func (f EventFilter) scoped(c *config) EventFilter {
+	if c.tenant == "" {
+		return f
+	}
+	names := make([]string, len(f.Sandboxes))
+	for i, name := range f.Sandboxes {
+		names[i] = c.scopedName(name)
+	}
+	f.Sandboxes = names
+	return f
+}
+
+// Events streams the lifecycle events of all sandboxes the client's API key can see, from the
+// moment it is called until ctx is done, when the channel is closed. Control planes can use it to
+// react to changes they didn't initiate, such as sandboxes stopped by other services or killed
+// for running out of memory. Clients scoped with WithTenant only see their tenant's sandboxes.
+//
+// Servers without an event feed are watched through their metrics instead, which only reveals
+// EventStarted and EventStopped, and only with a delay of up to a second. Errors reaching the
+// server are returned right away; later ones are logged and the server is asked again.
+func (c *Client) Events(ctx context.Context, filter EventFilter) (<-chan Event, error) {
+	filter = filter.scoped(&c.cfg)
+	params := eventsParams{Types: filter.Types, Sandboxes: filter.Sandboxes}
+	first, err := c.rpcClient.getEvents(ctx, &c.cfg, params)
+	if errors.Is(err, ErrMethodNotFound) {
+		return c.inferEvents(ctx, filter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToGetEvents, err)
+	}
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		result := first
+		for {
+			params.Cursor = result.Cursor
+			for _, e := range result.Events {
+				if !filter.matches(e) || !c.cfg.inScope(e.Sandbox) {
+					continue
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(eventPollInterval):
+			}
+			next, err := c.rpcClient.getEvents(ctx, &c.cfg, params)
+			if err != nil {
+				if ctx.Err() == nil {
+					c.cfg.log(ctx).Error("Failed to get sandbox events", "error", err)
+				}
+				next = &eventsResult{Cursor: params.Cursor}
+			}
+			result = next
+		}
+	}()
+	return ch, nil
+}
+
+// inferEvents derives start and stop events from changes in the set of running sandboxes.
+func (c *Client) inferEvents(ctx context.Context, filter EventFilter) (<-chan Event, error) {
+	running, err := c.runningSandboxes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToGetEvents, err)
+	}
+	c.cfg.log(ctx).Debug("Server has no event feed; inferring events from metrics")
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(eventPollInterval):
+			}
+			now, err := c.runningSandboxes(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					c.cfg.log(ctx).Error("Failed to get sandbox events", "error", err)
+				}
+				continue
+			}
+
+			var events []Event
+			for name := range now {
+				if !running[name] {
+					events = append(events, Event{Type: EventStarted, Sandbox: name, Time: time.Now()})
+				}
+			}
+			for name := range running {
+				if !now[name] {
+					events = append(events, Event{Type: EventStopped, Sandbox: name, Time: time.Now()})
+				}
+			}
+			running = now
+			slices.SortFunc(events, func(a, b Event) int { return strings.Compare(a.Sandbox, b.Sandbox) })
+			for _, e := range events {
+				if !filter.matches(e) || !c.cfg.inScope(e.Sandbox) {
+					continue
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (c *Client) runningSandboxes(ctx context.Context) (map[string]bool, error) {
+	metrics, err := c.rpcClient.listMetrics(ctx, &c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string]bool, len(metrics))
+	for _, m := range metrics {
+		if m.Running {
+			running[m.Name] = true
+		}
+	}
+	return running, nil
+}
+
+// Event-related errors
+var (
+	ErrFailedToGetEvents = errors.New("failed to get events")
+)
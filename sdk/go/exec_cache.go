@@ -0,0 +1,190 @@
+package msb
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+)
+
+// execCachePrefix is where cached results are kept in the ArtifactStore.
+const execCachePrefix = "exec-cache/"
+
+// WithExecutionCache caches the results of code runs marked as pure computations with
+// ContextWithCacheInputs in store, so repeating one, e.g. when an agent retries, returns the
+// earlier result without running the code again. Results are keyed by the sandbox image's
+// content-addressed ID, the language, the code, the inputs, and the packages installed through
+// VenvManager.Install and NodeManager.InstallFromLockfile, and only successful runs are cached.
+// Runs not marked are never cached, since the REPL's state can make the same code give different
+// results.
+//
+// Images are identified by the ID the server reports, or by the digest of references pinned to
+// one ("name@sha256:..."). Runs in sandboxes started from a tag the server can't resolve to an ID,
+// e.g. because it predates the image API, aren't cached, as the tag may move to another image.
+// Packages installed by other means, such as running pip as a command, aren't accounted for, so
+// runs depending on them shouldn't be marked.
+//
+// The cache is an optimization: if store fails, the code is run as if there were no cache, and
+// the failure logged.
+func WithExecutionCache(store ArtifactStore) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.execCache = store
+	}
+}
+
+type cacheInputsKey struct{}
+
+// ContextWithCacheInputs returns a copy of ctx marking the code runs done with it as pure
+// computations, whose results depend on nothing but the code, the image, and inputs, such as data
+// the code reads from files or bound into it beforehand. Such runs are looked up in and added to
+// the cache set with WithExecutionCache.
+func ContextWithCacheInputs(ctx context.Context, inputs ...[]byte) context.Context {
+	h := sha256.New()
+	for _, input := range inputs {
+		writeHashField(h, input)
+	}
+	return context.WithValue(ctx, cacheInputsKey{}, h.Sum(nil))
+}
+
+// Cached reports whether the execution's result was served by the cache set with
+// WithExecutionCache rather than by running the code.
+func (ce CodeExecution) Cached() bool {
+	return ce.cached
+}
+
+// imageRef is the content-addressed ID of an image reference; empty if it has none.
+type imageRef struct {
+	ref string
+	id  string
+}
+
+// envGeneration identifies the packages installed into a sandbox through its handle. Every install
+// starts a new generation, whose digest chains the install onto that of the previous one, so
+// handles that installed the same packages share cache entries.
+type envGeneration struct {
+	n      uint64 // installs so far
+	digest []byte
+}
+
+// bumpEnvGeneration starts a new generation of the sandbox's packages for an install described by
+// fields. It is called before installing, as even a failed install may have changed packages.
+func (b *baseMicroSandbox) bumpEnvGeneration(ctx context.Context, fields ...string) {
+	for {
+		prev := b.envGen.Load()
+		next := &envGeneration{n: 1}
+		h := sha256.New()
+		if prev != nil {
+			next.n = prev.n + 1
+			writeHashField(h, prev.digest)
+		}
+		for _, field := range fields {
+			writeHashField(h, []byte(field))
+		}
+		next.digest = h.Sum(nil)
+		if b.envGen.CompareAndSwap(prev, next) {
+			b.cfg.log(ctx).Debug("Packages changed, starting new cache generation", "sandbox", b.cfg.name, "generation", next.n)
+			return
+		}
+	}
+}
+
+// execCacheKey returns the key the result of running code with ctx is cached under, or "" if the
+// run isn't to be cached.
+func (cr codeRunner) execCacheKey(ctx context.Context, code string) string {
+	inputs, ok := ctx.Value(cacheInputsKey{}).([]byte)
+	if cr.b.cfg.execCache == nil || !ok {
+		return ""
+	}
+	info := cr.b.info.Load()
+	if info == nil {
+		return ""
+	}
+	imageID := cr.b.imageID(ctx, info.Image)
+	if imageID == "" {
+		cr.b.cfg.log(ctx).Debug("Not caching execution, image has no ID", "image", info.Image)
+		return ""
+	}
+	h := sha256.New()
+	writeHashField(h, []byte(imageID))
+	writeHashField(h, []byte(cr.l.String()))
+	writeHashField(h, []byte(code))
+	if venv := cr.b.venv.Load(); venv != nil {
+		writeHashField(h, []byte(venv.name))
+	}
+	if gen := cr.b.envGen.Load(); gen != nil {
+		writeHashField(h, gen.digest)
+	}
+	writeHashField(h, inputs)
+	return execCachePrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedExecution returns the result cached under key, if any.
+func (cr codeRunner) cachedExecution(ctx context.Context, key string) (CodeExecution, bool) {
+	r, err := cr.b.cfg.execCache.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrArtifactNotFound) {
+			cr.b.cfg.log(ctx).Error("Failed to read execution cache", "key", key, "error", err)
+		}
+		return CodeExecution{}, false
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		cr.b.cfg.log(ctx).Error("Failed to read execution cache", "key", key, "error", err)
+		return CodeExecution{}, false
+	}
+	exec := newCodeExecution(raw)
+	if !exec.parsedOK {
+		return CodeExecution{}, false
+	}
+	exec.cached = true
+	return exec, true
+}
+
+// cacheExecution caches a successful exec under key.
+func (cr codeRunner) cacheExecution(ctx context.Context, key string, exec CodeExecution) {
+	if !exec.parsedOK || exec.HasError() {
+		return
+	}
+	if err := cr.b.cfg.execCache.Put(ctx, key, bytes.NewReader(exec.Output)); err != nil {
+		cr.b.cfg.log(ctx).Error("Failed to write execution cache", "key", key, "error", err)
+	}
+}
+
+// imageID returns the content-addressed ID of the image ref: the one the server reports, or else
+// the digest ref is pinned to. It returns "" if there is neither. Only answers are remembered, so
+// an inspection that failed, e.g. timing out, is retried on the next call.
+func (b *baseMicroSandbox) imageID(ctx context.Context, ref string) string {
+	if cached := b.image.Load(); cached != nil && cached.ref == ref {
+		return cached.id
+	}
+	var pinned string
+	if strings.Contains(ref, "@sha256:") {
+		pinned = ref
+	}
+	info, err := b.rpcClient.inspectImage(ctx, &b.cfg, ref)
+	switch {
+	case errors.Is(err, ErrMethodNotFound):
+		// the server predates the image API, and will keep doing so
+		b.image.Store(&imageRef{ref: ref, id: pinned})
+		return pinned
+	case err != nil:
+		b.cfg.log(ctx).Debug("Failed to get image ID", "image", ref, "error", err)
+		return pinned
+	}
+	id := cmp.Or(info.ID, pinned)
+	b.image.Store(&imageRef{ref: ref, id: id})
+	return id
+}
+
+// writeHashField writes data to h prefixed with its length, so field boundaries are unambiguous.
+func writeHashField(h hash.Hash, data []byte) {
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(data))))
+	h.Write(data)
+}
@@ -0,0 +1,89 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+func startCachingSandbox(t *testing.T, srv *msbtest.Server, image string) *langSandbox {
+	t.Helper()
+	store, err := NewFileArtifactStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"), WithExecutionCache(store))
+	if err := sandbox.Start(StartConfig{Image: image}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+	return sandbox
+}
+
+func TestExecCacheKey(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	var inspections atomic.Int32
+	srv.Handle("image.inspect", func(json.RawMessage) (any, error) {
+		if inspections.Add(1) == 1 {
+			return nil, errors.New("registry timed out")
+		}
+		return map[string]any{"ref": "python:3.12", "id": "sha256:0123"}, nil
+	})
+	sandbox := startCachingSandbox(t, srv, "python:3.12")
+	cr := codeRunner{sandbox.b, langPython}
+	ctx := ContextWithCacheInputs(context.Background(), []byte("input"))
+
+	if key := cr.execCacheKey(ctx, "print(1)"); key != "" {
+		t.Errorf("key = %q with the image ID unknown, want none", key)
+	}
+	key := cr.execCacheKey(ctx, "print(1)")
+	if key == "" {
+		t.Fatal("no key once the image ID is known")
+	}
+	if again := cr.execCacheKey(ctx, "print(1)"); again != key {
+		t.Errorf("key changed from %q to %q", key, again)
+	}
+	if n := inspections.Load(); n != 2 {
+		t.Errorf("image inspected %d times, want 2: the failure retried, the answer remembered", n)
+	}
+	if cr.execCacheKey(context.Background(), "print(1)") != "" {
+		t.Error("run not marked with ContextWithCacheInputs has a key")
+	}
+
+	sandbox.b.bumpEnvGeneration(ctx, "pip", "env", "numpy")
+	installed := cr.execCacheKey(ctx, "print(1)")
+	if installed == key {
+		t.Error("key unchanged by an install")
+	}
+
+	other := codeRunner{startCachingSandbox(t, srv, "python:3.12").b, langPython}
+	other.b.bumpEnvGeneration(ctx, "pip", "env", "numpy")
+	if got := other.execCacheKey(ctx, "print(1)"); got != installed {
+		t.Errorf("handles with the same installs have keys %q and %q", installed, got)
+	}
+	other.b.bumpEnvGeneration(ctx, "pip", "env", "pandas")
+	if got := other.execCacheKey(ctx, "print(1)"); got == installed {
+		t.Error("handles with different installs share a key")
+	}
+}
+
+func TestExecCacheKeyWithoutImageAPI(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.Handle("image.inspect", nil)
+	ctx := ContextWithCacheInputs(context.Background())
+
+	tagged := codeRunner{startCachingSandbox(t, srv, "python:3.12").b, langPython}
+	if key := tagged.execCacheKey(ctx, "print(1)"); key != "" {
+		t.Errorf("key = %q for a tag, want none", key)
+	}
+	pinned := codeRunner{startCachingSandbox(t, srv, "python@sha256:0123").b, langPython}
+	if key := pinned.execCacheKey(ctx, "print(1)"); key == "" {
+		t.Error("no key for an image pinned by digest")
+	}
+}
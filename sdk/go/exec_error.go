@@ -0,0 +1,183 @@
+package msb
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ExecError is an error raised by code running in the REPL, parsed from its Python traceback or
+// Node.js stack trace so it can be reported precisely without scraping stderr.
+type ExecError struct {
+	Type      string  // Exception class, e.g. "ZeroDivisionError" or "TypeError"; empty if unrecognized
+	Message   string  // Exception message
+	Traceback []Frame // Stack frames, outermost call first
+	Stderr    string  // Complete error output the error was parsed from
+}
+
+// Frame is a single stack frame of an ExecError.
+type Frame struct {
+	File     string // Source file, e.g. "<stdin>" or "/app/main.js"
+	Line     int    // 1-based line number; 0 if unknown
+	Column   int    // 1-based column number; 0 if unknown (Python doesn't report columns)
+	Function string // Enclosing function; empty for top-level code
+	Source   string // Source line, when the traceback includes it
+}
+
+func (e *ExecError) Error() string {
+	switch {
+	case e.Type == "":
+		return e.Message
+	case e.Message == "":
+		return e.Type
+	default:
+		return e.Type + ": " + e.Message
+	}
+}
+
+// ExecError returns the structured error raised by the executed code, or nil if it succeeded.
+// Error output that isn't a recognizable traceback yields an ExecError with an empty Type and the
+// last line of the output as Message.
+func (ce CodeExecution) ExecError() *ExecError {
+	if !ce.HasError() {
+		return nil
+	}
+	stderr, _ := ce.GetError()
+	var e *ExecError
+	switch ce.parsed.Language {
+	case langPython.String():
+		e = parsePythonTraceback(stderr)
+	case langNodeJs.String():
+		e = parseNodeStack(stderr)
+	}
+	if e == nil {
+		e = &ExecError{Message: lastNonEmptyLine(stderr)}
+	}
+	e.Stderr = stderr
+	return e
+}
+
+var (
+	pyFrameRe     = regexp.MustCompile(`^\s*File "(.*)", line (\d+)(?:, in (.+))?$`)
+	pyExceptionRe = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?::\s?(.*))?$`)
+)
+
+// parsePythonTraceback parses the last traceback in s; with chained exceptions
+// ("During handling of the above exception...") that is the one actually raised.
+func parsePythonTraceback(s string) *ExecError {
+	lines := strings.Split(s, "\n")
+	start := -1
+	for i, line := range lines {
+		// syntax errors are reported without the "Traceback" header, starting at the frame
+		if strings.HasPrefix(line, "Traceback (most recent call last):") ||
+			(start == -1 && pyFrameRe.MatchString(line)) {
+			start = i
+		}
+	}
+	if start == -1 {
+		return nil
+	}
+
+	e := &ExecError{}
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if m := pyFrameRe.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			frame := Frame{File: m[1], Line: n, Function: m[3]}
+			if frame.Function == "<module>" {
+				frame.Function = ""
+			}
+			// the source line follows indented; marker lines (^^^, ~~~) are skipped
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "    ") && !isCaretLine(lines[i+1]) {
+				frame.Source = strings.TrimSpace(lines[i+1])
+				i++
+			}
+			e.Traceback = append(e.Traceback, frame)
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "Traceback") || line == "" {
+			continue
+		}
+		if m := pyExceptionRe.FindStringSubmatch(line); m != nil {
+			e.Type, e.Message = m[1], m[2]
+			// multi-line messages run until the end of the output
+			if rest := strings.TrimRight(strings.Join(lines[i+1:], "\n"), "\n"); rest != "" {
+				e.Message += "\n" + rest
+			}
+			return e
+		}
+	}
+	if e.Type == "" {
+		return nil
+	}
+	return e
+}
+
+func isCaretLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && strings.Trim(trimmed, "^~") == ""
+}
+
+var (
+	nodeErrorRe    = regexp.MustCompile(`^(?:Uncaught )?([A-Za-z_$][\w$.]*)(?:: (.*))?$`)
+	nodeFrameRe    = regexp.MustCompile(`^\s+at (?:(.+?) \()?(.+?):(\d+):(\d+)\)?$`)
+	nodeLocationRe = regexp.MustCompile(`^(.+?):(\d+)$`)
+)
+
+// parseNodeStack parses a Node.js error: an optional "file:line" header with the offending source
+// line and a caret, followed by "Type: message" and the "at ..." frames, innermost first.
+func parseNodeStack(s string) *ExecError {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		m := nodeErrorRe.FindStringSubmatch(line)
+		if m == nil || !looksLikeNodeError(m[1], lines[i+1:]) {
+			continue
+		}
+		e := &ExecError{Type: m[1], Message: m[2]}
+		for _, frameLine := range lines[i+1:] {
+			fm := nodeFrameRe.FindStringSubmatch(frameLine)
+			// frames inside Node's own bootstrapping code say nothing about the user's code
+			if fm == nil || strings.HasPrefix(fm[2], "node:internal/") {
+				continue
+			}
+			ln, _ := strconv.Atoi(fm[3])
+			col, _ := strconv.Atoi(fm[4])
+			e.Traceback = append(e.Traceback, Frame{File: fm[2], Line: ln, Column: col, Function: fm[1]})
+		}
+		// Node lists the innermost frame first; report outermost first, like Python
+		slices.Reverse(e.Traceback)
+		// the "file:line" header names the throwing location and its source line, which the frames
+		// lack; syntax errors have no frame for it at all
+		if hm := nodeLocationRe.FindStringSubmatch(lines[0]); i >= 2 && hm != nil {
+			ln, _ := strconv.Atoi(hm[2])
+			source := strings.TrimSpace(lines[1])
+			if n := len(e.Traceback); n > 0 && e.Traceback[n-1].File == hm[1] && e.Traceback[n-1].Line == ln {
+				e.Traceback[n-1].Source = source
+			} else {
+				e.Traceback = append(e.Traceback, Frame{File: hm[1], Line: ln, Source: source})
+			}
+		}
+		return e
+	}
+	return nil
+}
+
+// looksLikeNodeError tells error headers from arbitrary output: the type must be an Error class,
+// or be followed by stack frames.
+func looksLikeNodeError(typ string, rest []string) bool {
+	if strings.HasSuffix(typ, "Error") || strings.HasSuffix(typ, "Exception") {
+		return true
+	}
+	return len(rest) > 0 && nodeFrameRe.MatchString(rest[0])
+}
+
+func lastNonEmptyLine(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
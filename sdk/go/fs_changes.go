@@ -0,0 +1,139 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// FSChanges lists the files a command created, modified and deleted under its working directory,
+// as tracked with RunOptions.TrackFSChanges. Paths are relative to Workdir, sorted, and only
+// name non-directories; directories show through the files in them.
+type FSChanges struct {
+	Workdir  string   // Absolute working directory the command ran in
+	Created  []string // Files that didn't exist before the command ran
+	Modified []string // Files whose content changed (by modification time)
+	Deleted  []string // Files that existed before but no longer do
+}
+
+// Empty reports whether the command changed no files.
+func (c FSChanges) Empty() bool {
+	return len(c.Created) == 0 && len(c.Modified) == 0 && len(c.Deleted) == 0
+}
+
+// FSChanges returns the files the command changed, if the command was run with
+// RunOptions.TrackFSChanges.
+func (ce CommandExecution) FSChanges() (FSChanges, bool) {
+	if ce.fsChanges == nil {
+		return FSChanges{}, false
+	}
+	return *ce.fsChanges, true
+}
+
+// fsSnapshot is the state of the working directory before a command ran.
+type fsSnapshot struct {
+	workdir string
+	marker  string // file created just before the command; what it changes is newer
+	paths   []string
+}
+
+// fsListing lists the non-directories under the working directory, staying on its filesystem.
+// Unreadable directories are skipped rather than failing the listing.
+const fsListing = `find . -xdev ! -type d 2>/dev/null`
+
+// snapshotFS records the files under the working directory of the runner's commands.
+func (cr commandRunner) snapshotFS(ctx context.Context) (*fsSnapshot, error) {
+	script := `m=$(mktemp) || exit 1; pwd; echo "$m"; ` + fsListing + `; exit 0`
+	lines, err := cr.fsScript(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("%w: unexpected listing", ErrFailedToTrackFSChanges)
+	}
+	snap := &fsSnapshot{workdir: lines[0], marker: lines[1]}
+	snap.paths = snap.relPaths(lines[2:])
+	return snap, nil
+}
+
+// diffFS compares the working directory with snap, and removes the snapshot's marker.
+func (cr commandRunner) diffFS(ctx context.Context, snap *fsSnapshot) (*FSChanges, error) {
+	// the full listing and the files modified since the marker, separated by an empty line
+	script := fsListing + `; echo; find . -xdev ! -type d -newer "$1" 2>/dev/null; rm -f "$1"; exit 0`
+	lines, err := cr.fsScript(ctx, script, snap.marker)
+	if err != nil {
+		return nil, err
+	}
+	sep := slices.Index(lines, "")
+	if sep == -1 {
+		return nil, fmt.Errorf("%w: unexpected listing", ErrFailedToTrackFSChanges)
+	}
+	after, newer := snap.relPaths(lines[:sep]), snap.relPaths(lines[sep+1:])
+
+	changes := &FSChanges{Workdir: snap.workdir}
+	for _, p := range after {
+		if _, found := slices.BinarySearch(snap.paths, p); !found {
+			changes.Created = append(changes.Created, p)
+		}
+	}
+	for _, p := range snap.paths {
+		if _, found := slices.BinarySearch(after, p); !found {
+			changes.Deleted = append(changes.Deleted, p)
+		}
+	}
+	for _, p := range newer {
+		if _, found := slices.BinarySearch(snap.paths, p); found {
+			changes.Modified = append(changes.Modified, p)
+		}
+	}
+	return changes, nil
+}
+
+// discardSnapshot removes the snapshot's marker when the command couldn't be run.
+func (cr commandRunner) discardSnapshot(ctx context.Context, snap *fsSnapshot) {
+	// best effort; the sandbox's /tmp goes away with it anyway
+	if _, err := cr.b.rpcClient.runCommand(context.WithoutCancel(ctx), &cr.b.cfg, cr.user, "rm", []string{"-f", snap.marker}); err != nil {
+		cr.b.cfg.log(ctx).Debug("Failed to remove file change marker", "path", snap.marker, "error", err)
+	}
+}
+
+// fsScript runs a shell script as the runner's user and returns its output lines.
+func (cr commandRunner) fsScript(ctx context.Context, script string, args ...string) ([]string, error) {
+	result, err := cr.b.rpcClient.runCommand(ctx, &cr.b.cfg, cr.user, "sh", append([]string{"-c", script, "sh"}, args...))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToTrackFSChanges, err)
+	}
+	exec := newCommandExecution(result.output)
+	if !exec.IsSuccess() {
+		stderr, _ := exec.GetError()
+		return nil, fmt.Errorf("%w: %s", ErrFailedToTrackFSChanges, strings.TrimSpace(stderr))
+	}
+	output, err := exec.GetOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToTrackFSChanges, err)
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// relPaths turns the "./"-prefixed paths find printed into sorted paths relative to the working
+// directory, leaving out the marker.
+func (s *fsSnapshot) relPaths(lines []string) []string {
+	paths := make([]string, 0, len(lines))
+	for _, line := range lines {
+		p, ok := strings.CutPrefix(line, "./")
+		if !ok || path.Join(s.workdir, p) == s.marker {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// File-change-related errors
+var (
+	ErrFailedToTrackFSChanges = errors.New("failed to track file changes")
+)
@@ -0,0 +1,55 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GPUConfig requests GPU devices passed through to the sandbox, e.g. for ML inference workloads.
+// Either a number of GPUs for the server to pick or specific device IDs can be requested:
+//
+//	msb.StartConfig{GPUs: msb.GPUConfig{Count: 1}}
+//	msb.StartConfig{GPUs: msb.GPUConfig{DeviceIDs: []string{"0", "2"}}}
+//
+// Passthrough requires a server on a host with GPUs; starting fails on servers that can't satisfy
+// the request.
+type GPUConfig struct {
+	Count     int      `json:"count,omitempty"`      // Number of GPUs, chosen by the server
+	DeviceIDs []string `json:"device_ids,omitempty"` // Specific devices, by index or UUID as the host's driver reports them
+}
+
+func (g GPUConfig) validate() error {
+	if g.Count < 0 {
+		return fmt.Errorf("%w: negative count %d", ErrInvalidGPUConfig, g.Count)
+	}
+	if g.Count > 0 && len(g.DeviceIDs) > 0 {
+		return fmt.Errorf("%w: count and device IDs are mutually exclusive", ErrInvalidGPUConfig)
+	}
+	for _, id := range g.DeviceIDs {
+		if id == "" {
+			return fmt.Errorf("%w: empty device ID", ErrInvalidGPUConfig)
+		}
+	}
+	return nil
+}
+
+// rpcParam returns the configuration as sent to the server, or nil if no GPU is requested.
+func (g GPUConfig) rpcParam() *GPUConfig {
+	if g.Count == 0 && len(g.DeviceIDs) == 0 {
+		return nil
+	}
+	return &g
+}
+
+// GPUMetrics contains usage information for one GPU passed through to a sandbox.
+type GPUMetrics struct {
+	ID                 string  `json:"id"`                  // Device ID, as in GPUConfig.DeviceIDs
+	UtilizationPercent float64 `json:"utilization_percent"` // GPU utilization percentage (0-100)
+	MemoryUsedMiB      int     `json:"memory_used_mib"`     // Device memory in use in mebibytes
+	MemoryTotalMiB     int     `json:"memory_total_mib"`    // Device memory in mebibytes
+}
+
+// GPU-related errors
+var (
+	ErrInvalidGPUConfig = errors.New("invalid GPU configuration")
+)
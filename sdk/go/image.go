@@ -0,0 +1,100 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PullPolicy decides when the server pulls a sandbox's image from its registry.
+type PullPolicy string
+
+const (
+	PullDefault      PullPolicy = ""               // Leave it to the server (pulls images that are missing)
+	PullAlways       PullPolicy = "always"         // Pull on every start, picking up updated tags
+	PullIfNotPresent PullPolicy = "if_not_present" // Pull only images missing from the server's cache
+	PullNever        PullPolicy = "never"          // Only use images already in the server's cache
+)
+
+func (p PullPolicy) validate() error {
+	switch p {
+	case PullDefault, PullAlways, PullIfNotPresent, PullNever:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidPullPolicy, p)
+	}
+}
+
+// ImageNotPresentError reports that a sandbox could not start because its image isn't in the
+// server's cache and the pull policy forbids pulling it. It matches ErrImageNotPresent with errors.Is.
+type ImageNotPresentError struct {
+	Image  string     // Image that would have had to be pulled
+	Policy PullPolicy // Policy in effect
+	Err    error      // Error reported by the server
+}
+
+func (e *ImageNotPresentError) Error() string {
+	return fmt.Sprintf("%v: %s (pull policy %q): %v", ErrImageNotPresent, e.Image, e.Policy, e.Err)
+}
+
+func (e *ImageNotPresentError) Is(target error) bool {
+	return target == ErrImageNotPresent
+}
+
+func (e *ImageNotPresentError) Unwrap() error {
+	return e.Err
+}
+
+var platformRe = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(?:/[a-z0-9]+)?$`)
+
+// validatePlatform checks an "os/arch[/variant]" platform, e.g. "linux/arm64" or "linux/arm/v7".
+func validatePlatform(platform string) error {
+	if platform != "" && !platformRe.MatchString(platform) {
+		return fmt.Errorf("%w: %q is not in os/arch[/variant] form", ErrInvalidPlatform, platform)
+	}
+	return nil
+}
+
+// PlatformNotAvailableError reports that a sandbox could not start because its image has no
+// variant for the requested platform. It matches ErrPlatformNotAvailable with errors.Is.
+type PlatformNotAvailableError struct {
+	Image    string // Image that was resolved
+	Platform string // Requested platform
+	Err      error  // Error reported by the server
+}
+
+func (e *PlatformNotAvailableError) Error() string {
+	return fmt.Sprintf("%v: %s for %s: %v", ErrPlatformNotAvailable, e.Image, e.Platform, e.Err)
+}
+
+func (e *PlatformNotAvailableError) Is(target error) bool {
+	return target == ErrPlatformNotAvailable
+}
+
+func (e *PlatformNotAvailableError) Unwrap() error {
+	return e.Err
+}
+
+// isPlatformMissing reports whether a start error says the image's manifest lacks a platform.
+func isPlatformMissing(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return (strings.Contains(msg, "platform") || strings.Contains(msg, "manifest")) &&
+		(strings.Contains(msg, "no match") || strings.Contains(msg, "not found") ||
+			strings.Contains(msg, "not available") || strings.Contains(msg, "unsupported"))
+}
+
+// isImageMissing reports whether a start error says the image isn't available locally.
+func isImageMissing(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "image") &&
+		(strings.Contains(msg, "not found") || strings.Contains(msg, "not present") || strings.Contains(msg, "no such"))
+}
+
+// Image-related errors
+var (
+	ErrInvalidPullPolicy    = errors.New("invalid pull policy")
+	ErrImageNotPresent      = errors.New("image not present on server")
+	ErrInvalidPlatform      = errors.New("invalid platform")
+	ErrPlatformNotAvailable = errors.New("image not available for platform")
+)
@@ -0,0 +1,351 @@
+package msb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ImageManager manages the images available to sandboxes on a server.
+type ImageManager struct {
+	cfg       config
+	rpcClient rpcClient
+}
+
+// Images returns an ImageManager configured with options, which are the same as the sandbox
+// constructors'. Use Client.Images to share a Client's transport instead.
+func Images(options ...Option) *ImageManager {
+	return NewClient(options...).Images()
+}
+
+// Images returns an ImageManager sharing the client's transport and settings.
+func (c *Client) Images() *ImageManager {
+	return &ImageManager{cfg: c.cfg, rpcClient: c.rpcClient}
+}
+
+// BuildSpec describes an image built from a Dockerfile with ImageManager.Build.
+type BuildSpec struct {
+	ContextDir string            // Directory sent to the server as build context
+	Dockerfile string            // Dockerfile path relative to ContextDir; defaults to "Dockerfile"
+	Tag        string            // Name the built image is tagged with, e.g. "my-python:1.2"
+	Args       map[string]string // Build arguments (ARG values)
+	Platform   string            // Target platform, e.g. "linux/amd64"; empty for the server's native one
+	Logs       io.Writer         // Receives the build log as it is produced; nil discards it
+}
+
+// BuildResult is the outcome of a successful image build.
+type BuildResult struct {
+	Tag     string // Tag the image was built under
+	ImageID string // Content-addressed ID of the image, as reported by the server
+}
+
+// buildLogPollInterval is how often Build asks the server for new build log lines.
+const buildLogPollInterval = 500 * time.Millisecond
+
+// Build builds an image from a Dockerfile on the server, so environment customizations (extra
+// packages, preinstalled wheels) can be automated from Go. The build context is packed into a
+// compressed archive honouring .dockerignore, then the build log is streamed to spec.Logs until
+// the build completes. Cancelling ctx stops waiting, not the build itself.
+func (m *ImageManager) Build(ctx context.Context, spec BuildSpec) (BuildResult, error) {
+	if spec.Tag == "" {
+		return BuildResult{}, fmt.Errorf("%w: tag must be specified", ErrInvalidBuildSpec)
+	}
+	if err := validatePlatform(spec.Platform); err != nil {
+		return BuildResult{}, err
+	}
+	if spec.Dockerfile == "" {
+		spec.Dockerfile = "Dockerfile"
+	}
+	if spec.Logs == nil {
+		spec.Logs = io.Discard
+	}
+	buildCtx, err := packBuildContext(spec.ContextDir, spec.Dockerfile)
+	if err != nil {
+		return BuildResult{}, err
+	}
+
+	m.cfg.log(ctx).Info("Building image", "tag", spec.Tag, "context", spec.ContextDir, "size", len(buildCtx))
+	buildID, err := m.rpcClient.buildImage(ctx, &m.cfg, imageBuildParams{
+		Tag:        spec.Tag,
+		Dockerfile: filepath.ToSlash(spec.Dockerfile),
+		Args:       spec.Args,
+		Platform:   spec.Platform,
+		Context:    buildCtx,
+	})
+	if err != nil {
+		return BuildResult{}, fmt.Errorf("%w: %w", ErrImageBuildFailed, err)
+	}
+
+	offset := 0
+	for {
+		logs, err := m.rpcClient.buildLogs(ctx, &m.cfg, buildID, offset)
+		if err != nil {
+			return BuildResult{}, fmt.Errorf("%w: %w", ErrImageBuildFailed, err)
+		}
+		for _, line := range logs.Lines {
+			if _, err := io.WriteString(spec.Logs, line+"\n"); err != nil {
+				return BuildResult{}, fmt.Errorf("%w: writing logs: %w", ErrImageBuildFailed, err)
+			}
+		}
+		offset += len(logs.Lines)
+		if logs.Done {
+			if logs.Error != "" {
+				return BuildResult{}, fmt.Errorf("%w: %s", ErrImageBuildFailed, logs.Error)
+			}
+			m.cfg.log(ctx).Info("Image built", "tag", spec.Tag, "id", logs.ImageID)
+			return BuildResult{Tag: spec.Tag, ImageID: logs.ImageID}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return BuildResult{}, fmt.Errorf("%w: %w", ErrImageBuildFailed, ctx.Err())
+		case <-time.After(buildLogPollInterval):
+		}
+	}
+}
+
+// LoadResult is the outcome of loading an image archive with ImageManager.Load.
+type LoadResult struct {
+	Images []string // References of the loaded images, e.g. "my-python:1.2"
+}
+
+// loadChunkSize is the size of the pieces image archives are uploaded in.
+const loadChunkSize = 4 << 20
+
+// Load uploads an image archive, either an OCI image layout or a "docker save" tarball (optionally
+// gzipped), to the server for use as sandbox images. This makes images available in air-gapped
+// environments that can't reach any registry. The archive is read and sent in chunks, so it is
+// never held in memory as a whole.
+func (m *ImageManager) Load(ctx context.Context, r io.Reader) (LoadResult, error) {
+	uploadID, err := m.rpcClient.beginImageLoad(ctx, &m.cfg)
+	if err != nil {
+		return LoadResult{}, fmt.Errorf("%w: %w", ErrImageLoadFailed, err)
+	}
+
+	buf := make([]byte, loadChunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := m.rpcClient.uploadImageChunk(ctx, &m.cfg, uploadID, offset, buf[:n]); err != nil {
+				return LoadResult{}, fmt.Errorf("%w: uploading at offset %d: %w", ErrImageLoadFailed, offset, err)
+			}
+			offset += int64(n)
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return LoadResult{}, fmt.Errorf("%w: reading archive: %w", ErrImageLoadFailed, readErr)
+		}
+	}
+	if offset == 0 {
+		return LoadResult{}, fmt.Errorf("%w: empty archive", ErrImageLoadFailed)
+	}
+
+	m.cfg.log(ctx).Info("Loading image archive", "size", offset)
+	images, err := m.rpcClient.commitImageLoad(ctx, &m.cfg, uploadID)
+	if err != nil {
+		return LoadResult{}, fmt.Errorf("%w: %w", ErrImageLoadFailed, err)
+	}
+	m.cfg.log(ctx).Info("Image archive loaded", "images", images)
+	return LoadResult{Images: images}, nil
+}
+
+// ImageInfo describes an image in the server's cache.
+type ImageInfo struct {
+	Ref     string      `json:"ref"`              // Reference, e.g. "microsandbox/python:latest"
+	ID      string      `json:"id"`               // Content-addressed image ID
+	Size    int64       `json:"size"`             // Size on disk in bytes
+	Created time.Time   `json:"created"`          // When the image was pulled, built or loaded
+	Layers  []LayerInfo `json:"layers,omitempty"` // Image layers, bottom first; only set by Inspect
+}
+
+// LayerInfo describes a layer of an image.
+type LayerInfo struct {
+	Digest string `json:"digest"` // Layer digest, e.g. "sha256:..."
+	Size   int64  `json:"size"`   // Size in bytes
+}
+
+// PruneResult is the outcome of ImageManager.Prune.
+type PruneResult struct {
+	Removed        []string // References of the removed images
+	ReclaimedBytes int64    // Disk space freed in bytes
+}
+
+// List returns the images in the server's cache.
+func (m *ImageManager) List(ctx context.Context) ([]ImageInfo, error) {
+	images, err := m.rpcClient.listImages(ctx, &m.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToListImages, err)
+	}
+	return images, nil
+}
+
+// Inspect returns details of the image ref, including its layers.
+// Returns an error matching ErrImageNotFound if the server has no such image.
+func (m *ImageManager) Inspect(ctx context.Context, ref string) (ImageInfo, error) {
+	info, err := m.rpcClient.inspectImage(ctx, &m.cfg, ref)
+	if err != nil {
+		if !errors.Is(err, ErrMethodNotFound) && strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return ImageInfo{}, fmt.Errorf("%w: %s: %w", ErrImageNotFound, ref, err)
+		}
+		return ImageInfo{}, fmt.Errorf("%w: %s: %w", ErrFailedToInspectImage, ref, err)
+	}
+	return *info, nil
+}
+
+// Prune removes the cached images created more than olderThan ago that no sandbox is using, to
+// keep the server's image cache from growing without bound. A zero olderThan removes every unused image.
+func (m *ImageManager) Prune(ctx context.Context, olderThan time.Duration) (PruneResult, error) {
+	result, err := m.rpcClient.pruneImages(ctx, &m.cfg, olderThan)
+	if err != nil {
+		return PruneResult{}, fmt.Errorf("%w: %w", ErrFailedToPruneImages, err)
+	}
+	m.cfg.log(ctx).Info("Pruned images", "removed", len(result.Removed), "reclaimed", result.ReclaimedBytes)
+	return PruneResult{Removed: result.Removed, ReclaimedBytes: result.ReclaimedBytes}, nil
+}
+
+// packBuildContext archives dir as a gzipped tarball, skipping paths matched by its .dockerignore.
+func packBuildContext(dir, dockerfile string) ([]byte, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: context: %w", ErrInvalidBuildSpec, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: context %s is not a directory", ErrInvalidBuildSpec, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, dockerfile)); err != nil {
+		return nil, fmt.Errorf("%w: dockerfile: %w", ErrInvalidBuildSpec, err)
+	}
+	ignore, err := readDockerignore(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: .dockerignore: %w", ErrInvalidBuildSpec, err)
+	}
+	dockerfile = filepath.ToSlash(filepath.Clean(dockerfile))
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// the Dockerfile is always sent, even if it is ignored
+		if rel != dockerfile && ignore.matches(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: packing context: %w", ErrInvalidBuildSpec, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// dockerignore holds the patterns of a .dockerignore file. Exclusions ("!pattern") re-include
+// paths, with the last matching pattern deciding, as in Docker.
+type dockerignore []string
+
+func readDockerignore(dir string) (dockerignore, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns dockerignore
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, sc.Err()
+}
+
+// matches reports whether rel, or one of its parent directories, is ignored.
+func (di dockerignore) matches(rel string) bool {
+	ignored := false
+	for _, pattern := range di {
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = path.Clean(strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "/"))
+		for p := rel; p != "."; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				ignored = !exclude
+				break
+			}
+		}
+	}
+	return ignored
+}
+
+// Image-management errors
+var (
+	ErrInvalidBuildSpec     = errors.New("invalid build spec")
+	ErrImageBuildFailed     = errors.New("image build failed")
+	ErrImageLoadFailed      = errors.New("image load failed")
+	ErrImageNotFound        = errors.New("image not found")
+	ErrFailedToListImages   = errors.New("failed to list images")
+	ErrFailedToInspectImage = errors.New("failed to inspect image")
+	ErrFailedToPruneImages  = errors.New("failed to prune images")
+)
@@ -0,0 +1,87 @@
+package msb
+
+import (
+	"context"
+	"time"
+)
+
+// SandboxInfo describes how a sandbox was configured when this handle started it.
+type SandboxInfo struct {
+	Name     string    // Sandbox name
+	Language string    // REPL language, e.g. "python"
+	Image    string    // Image the sandbox runs
+	Memory   int       // Memory limit in MB
+	CPUs     int       // CPU limit
+	DiskMiB  int       // Root filesystem size limit in MiB; 0 if left to the server
+	SwapMiB  int       // Swap limit in MiB; 0 if left to the server
+	GPUs     GPUConfig // GPU devices passed through
+	Limits   Limits    // Resource limits applied to processes in the sandbox
+	User     string    // User code and commands run as; empty for the image's default
+	Timezone string    // IANA time zone; empty for the image's default
+	Locale   string    // Locale; empty for the image's default
+
+	ExecTimeout time.Duration // Default bound on each execution; 0 if unlimited
+
+	// Attached is set when the handle attached to a sandbox that was already running (see
+	// NameConflictAttach). The configuration fields then describe what this handle requested,
+	// not necessarily what the running sandbox was started with.
+	Attached bool
+	// Local is set when no server was reachable at start, so the sandbox runs in-process on the
+	// engine set with WithLocalFallback.
+	Local bool
+	// Container is set when the sandbox runs in a Docker or Podman container set up with
+	// WithContainerBackend, which isolates it far less than a microVM.
+	Container bool
+}
+
+func newSandboxInfo(name string, cfg StartConfig, sc startConfig, attached bool) *SandboxInfo {
+	info := &SandboxInfo{
+		Name:        name,
+		Timezone:    cfg.Timezone,
+		Locale:      cfg.Locale,
+		ExecTimeout: cfg.ExecTimeout,
+		Image:       sc.Image,
+		Memory:      sc.Memory,
+		CPUs:        sc.CPUs,
+		DiskMiB:     sc.DiskMiB,
+		SwapMiB:     sc.SwapMiB,
+		User:        sc.User,
+		Attached:    attached,
+	}
+	if sc.GPUs != nil {
+		info.GPUs = *sc.GPUs
+	}
+	if sc.Limits != nil {
+		info.Limits = *sc.Limits
+	}
+	return info
+}
+
+// execContext bounds ctx by the sandbox's ExecTimeout, the client's Run timeout and the
+// execution policy's MaxTimeout.
+func (b *baseMicroSandbox) execContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	var execTimeout time.Duration
+	if info := b.info.Load(); info != nil {
+		execTimeout = info.ExecTimeout
+	}
+	ctx, cancelExec := withTimeout(ctx, execTimeout)
+	ctx, cancelRun := withTimeout(ctx, b.cfg.timeouts.Run)
+	ctx, cancelPolicy := withTimeout(ctx, b.cfg.policy.maxTimeout())
+	return ctx, func() {
+		cancelPolicy()
+		cancelRun()
+		cancelExec()
+	}
+}
+
+// Info returns the configuration the sandbox was started with.
+// Returns ErrSandboxNotStarted if the sandbox isn't running.
+func (ls *langSandbox) Info() (SandboxInfo, error) {
+	info := ls.b.info.Load()
+	if ls.b.state.Load() != started || info == nil {
+		return SandboxInfo{}, ErrSandboxNotStarted
+	}
+	i := *info
+	i.Language = ls.l.String()
+	return i, nil
+}
@@ -0,0 +1,65 @@
+package msb
+
+import (
+	"context"
+	"time"
+)
+
+// WithKeepAlive has a started sandbox ping the server with a status query every interval, so
+// services that hold a handle but call it rarely find the HTTP connection and the server's
+// session still warm, rather than paying for new ones on the first call after a long pause.
+// An interval below the transport's idle connection timeout keeps the connection open: that is 30
+// seconds for the SDK's own transport, and that of the transport passed to WithHTTPClient
+// otherwise (90 seconds for http.DefaultTransport). Pings begin once Start has returned
+// successfully. A sandbox the pings find no longer running is moved back to the stopped state, like
+// with Status. Pings stop when the sandbox is stopped.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.keepAlive = interval
+	}
+}
+
+// startKeepAlive starts pinging the server for a freshly started sandbox when WithKeepAlive was
+// configured.
+func startKeepAlive(b *baseMicroSandbox) {
+	if b.cfg.keepAlive <= 0 {
+		return
+	}
+	// pings must outlive the start call, so they don't inherit its context
+	ctx, cancel := context.WithCancel(context.Background())
+	if old := b.keepAlive.Swap(&cancel); old != nil {
+		(*old)()
+	}
+	go keepAliveLoop(ctx, b)
+}
+
+// stopKeepAlive stops the pings of a sandbox that no longer runs. It doesn't wait for an
+// in-flight ping, as it is also called from within one.
+func stopKeepAlive(b *baseMicroSandbox) {
+	if cancel := b.keepAlive.Swap(nil); cancel != nil {
+		(*cancel)()
+	}
+}
+
+func keepAliveLoop(ctx context.Context, b *baseMicroSandbox) {
+	ticker := time.NewTicker(b.cfg.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		timeout := b.cfg.keepAlive
+		if m := b.cfg.timeouts.Metrics; m > 0 {
+			timeout = min(timeout, m)
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		_, err := readStatus(pingCtx, b)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			b.cfg.log(ctx).Debug("Keep-alive ping failed", "name", b.cfg.name, "error", err)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package msb
 
-import "errors"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
 
 // LangSandBox provides a complete sandbox interface for a specific programming language.
 // It combines lifecycle management (Start/Stop) with execution capabilities (Code/Command)
@@ -20,10 +26,52 @@ import "errors"
 //	}
 type LangSandBox interface {
 	Starter
+	Sandbox
+}
+
+// Sandbox is a handle on a running sandbox: everything a LangSandBox offers but starting it. A
+// Builder returns one from Start or Attach only once the sandbox runs.
+type Sandbox interface {
 	Stopper
+	// Name returns the sandbox's name on the server, including a generated one and the
+	// "<tenantID>_" prefix of tenant-scoped clients.
+	Name() string
+	// Namespace returns the tenant the sandbox's name is scoped to (see Client.WithTenant);
+	// empty if it isn't.
+	Namespace() string
+	// ServerURL returns the URL of the server the sandbox's requests go to.
+	ServerURL() string
+	// ClockSkew returns the estimated skew between the server's clock and this client's, learned
+	// from the timing of executions; false until one reported it. See ExecutionTiming.
+	ClockSkew() (ClockSkew, bool)
 	Code() CodeRunner
 	Command() CommandRunner
 	Metrics() MetricsReader
+	// Venv manages the Python virtualenvs of the sandbox; see VenvManager.
+	Venv() VenvManager
+	// Node installs packages in a Node sandbox; see NodeManager.
+	Node() NodeManager
+	// Status returns the sandbox's lifecycle status from a lightweight server call.
+	Status(ctx context.Context) (SandboxStatus, error)
+	// Lease returns the lease held on the sandbox when it was created with WithLease.
+	Lease() Lease
+	// Lock blocks until this handle holds the server-arbitrated lock named key on the sandbox.
+	Lock(ctx context.Context, key string) error
+	// Unlock releases a lock acquired with Lock.
+	Unlock(ctx context.Context, key string) error
+	// LockLost returns a channel closed once a lock acquired with Lock is lost.
+	LockLost(key string) <-chan struct{}
+	// KillAll interrupts every background process started with Command().Start.
+	KillAll(ctx context.Context) error
+	// AbortAll cancels every Run and Command call in flight through this handle and has the
+	// server terminate what they were running.
+	AbortAll(ctx context.Context) error
+	// Info returns the configuration the running sandbox was started with.
+	Info() (SandboxInfo, error)
+	// Logs writes the sandbox's server-side log to w, following new lines if follow is set.
+	Logs(ctx context.Context, w io.Writer, follow bool) error
+	// Marshal returns an encrypted token Unmarshal resumes the session from in another process.
+	Marshal() ([]byte, error)
 }
 
 var _ LangSandBox = (*langSandbox)(nil)
@@ -69,14 +117,30 @@ func newLangSandbox(lang progLang, options ...Option) *langSandbox {
 }
 
 func (ls *langSandbox) Start(cfg StartConfig) error {
+	return ls.StartContext(context.Background(), cfg)
+}
+
+func (ls *langSandbox) StartSimple(image string, memory, cpus int) error {
+	return ls.Start(StartConfig{Image: image, Memory: memory, CPUs: cpus})
+}
+
+func (ls *langSandbox) StartContext(ctx context.Context, cfg StartConfig) error {
 	if cfg.Image == "" {
 		cfg.Image = ls.l.DefaultImage()
 	}
-	return starter{ls.b}.Start(cfg)
+	return starter{ls.b, ls.l}.StartContext(ctx, cfg)
 }
 
 func (ls *langSandbox) Stop() error {
-	return stopper{ls.b}.Stop()
+	return stopper{ls.b, ls.l}.Stop()
+}
+
+func (ls *langSandbox) StopContext(ctx context.Context) error {
+	return stopper{ls.b, ls.l}.StopContext(ctx)
+}
+
+func (ls *langSandbox) StopWithOptions(ctx context.Context, opts StopOptions) error {
+	return stopper{ls.b, ls.l}.StopWithOptions(ctx, opts)
 }
 
 func (ls *langSandbox) Code() CodeRunner {
@@ -84,13 +148,47 @@ func (ls *langSandbox) Code() CodeRunner {
 }
 
 func (ls *langSandbox) Command() CommandRunner {
-	return commandRunner{ls.b}
+	return commandRunner{b: ls.b}
 }
 
 func (ls *langSandbox) Metrics() MetricsReader {
 	return metricsReader{ls.b}
 }
 
+func (ls *langSandbox) language() progLang {
+	return ls.l
+}
+
+func (ls *langSandbox) Name() string {
+	return ls.b.cfg.name
+}
+
+func (ls *langSandbox) Namespace() string {
+	return ls.b.cfg.tenant
+}
+
+func (ls *langSandbox) ServerURL() string {
+	return ls.b.cfg.currentServerURL()
+}
+
+// nameOf resolves the sandbox name behind a LangSandBox, when it is one of ours.
+func nameOf(sb LangSandBox) (string, bool) {
+	ls, ok := sb.(*langSandbox)
+	if !ok {
+		return "", false
+	}
+	return ls.Name(), true
+}
+
+// languageOf resolves the programming language behind a LangSandBox, when it is one of ours.
+func languageOf(sb LangSandBox) (progLang, bool) {
+	ls, ok := sb.(interface{ language() progLang })
+	if !ok {
+		return langUnspecified, false
+	}
+	return ls.language(), true
+}
+
 type progLang int
 
 const (
@@ -122,6 +220,26 @@ func (p progLang) DefaultImage() string {
 	}
 }
 
+// bindJSON returns a statement in the language's syntax that assigns the JSON document
+// jsonDoc to the variable name. The document is embedded as a string literal and decoded
+// in the sandbox, so arbitrary JSON values are bound safely regardless of their content.
+func (p progLang) bindJSON(name string, jsonDoc []byte) (string, error) {
+	// a JSON string literal is also a valid Python and JavaScript string literal
+	literal, err := json.Marshal(string(jsonDoc))
+	if err != nil {
+		return "", err
+	}
+	switch p {
+	case langPython:
+		return fmt.Sprintf("import json as _msb_json\n%s = _msb_json.loads(%s)\n", name, literal), nil
+	case langNodeJs:
+		// var (rather than const/let) so repeated bindings in the same REPL don't collide
+		return fmt.Sprintf("var %s = JSON.parse(%s);\n", name, literal), nil
+	default:
+		return "", ErrUnknownLanguage
+	}
+}
+
 // Language-related errors
 var (
 	ErrUnknownLanguage = errors.New("unknown language")
@@ -0,0 +1,193 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lease is this handle's claim on a shared sandbox. While a lease is held, the SDK keeps renewing it
+// in the background; once every holder has released its lease or stopped renewing (for instance
+// because the process died), the server reclaims the sandbox after the lease's TTL expires.
+type Lease interface {
+	// Holder returns the identifier the server tracks this lease under.
+	Holder() string
+	// ExpiresAt returns the expiry the server confirmed on the last successful renewal.
+	ExpiresAt() time.Time
+	// Err returns the error of the last renewal attempt, or nil if it succeeded.
+	Err() error
+	// Release stops renewing the lease and gives it up on the server.
+	// The sandbox keeps running as long as other holders renew their leases.
+	Release() error
+	// ReleaseContext is like Release but carries ctx into the underlying request.
+	ReleaseContext(ctx context.Context) error
+}
+
+// WithLease makes the sandbox leased: once started, the SDK renews a lease with the given TTL on the
+// server every third of the TTL, so the server reclaims the sandbox if this client goes away without
+// stopping it. Several handles (in one or many processes) can lease the same named sandbox, typically
+// together with NameConflictAttach, to share ownership of it. TTLs are rounded up to whole seconds.
+func WithLease(ttl time.Duration) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.leaseTTL = ttl
+	}
+}
+
+// Lease returns the lease held on the sandbox. If the sandbox hasn't been started with WithLease,
+// or the lease was already released, the returned Lease reports ErrNoLease.
+func (ls *langSandbox) Lease() Lease {
+	if l := ls.b.lease.Load(); l != nil {
+		return l
+	}
+	return noLease{}
+}
+
+// minLeaseTTL is the granularity of lease TTLs on the wire.
+const minLeaseTTL = time.Second
+
+// holdLease takes out a lease on a freshly started sandbox when WithLease was configured.
+func holdLease(ctx context.Context, b *baseMicroSandbox) error {
+	if b.cfg.leaseTTL <= 0 {
+		return nil
+	}
+	l, err := acquireLease(ctx, b)
+	if err != nil {
+		return err
+	}
+	b.lease.Store(l)
+	return nil
+}
+
+// dropLease stops renewing the lease of a sandbox that no longer exists.
+func dropLease(b *baseMicroSandbox) {
+	if l := b.lease.Swap(nil); l != nil {
+		l.stop()
+	}
+}
+
+// leaseKeeper holds a lease on the server and renews it until stopped.
+type leaseKeeper struct {
+	b      *baseMicroSandbox
+	holder string
+	ttl    time.Duration
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	expires time.Time
+	err     error
+}
+
+// acquireLease takes out the initial lease synchronously, so servers without lease support are
+// detected at start, and then keeps renewing it in the background.
+func acquireLease(ctx context.Context, b *baseMicroSandbox) (*leaseKeeper, error) {
+	l := &leaseKeeper{
+		b:      b,
+		holder: uuid.NewString(),
+		ttl:    max(b.cfg.leaseTTL, minLeaseTTL),
+		done:   make(chan struct{}),
+	}
+	expires, err := b.rpcClient.renewLease(ctx, &b.cfg, l.holder, l.ttl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToAcquireLease, err)
+	}
+	l.expires = expires
+
+	// renewals must outlive the start call, so they don't inherit its context
+	renewCtx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	go l.renewLoop(renewCtx)
+	return l, nil
+}
+
+func (l *leaseKeeper) renewLoop(ctx context.Context) {
+	defer close(l.done)
+	interval := l.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, interval)
+		expires, err := l.b.rpcClient.renewLease(reqCtx, &l.b.cfg, l.holder, l.ttl)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		l.mu.Lock()
+		l.err = err
+		if err == nil {
+			l.expires = expires
+		}
+		expired := err != nil && time.Now().After(l.expires)
+		l.mu.Unlock()
+
+		if expired {
+			l.b.cfg.log(ctx).Error("Sandbox lease expired", "name", l.b.cfg.name, "holder", l.holder, "error", err)
+		} else if err != nil {
+			l.b.cfg.log(ctx).Error("Failed to renew sandbox lease", "name", l.b.cfg.name, "holder", l.holder, "error", err)
+		}
+	}
+}
+
+// stop ends the renewals without releasing the lease, for when the sandbox itself is gone.
+func (l *leaseKeeper) stop() {
+	l.cancel()
+	<-l.done
+}
+
+func (l *leaseKeeper) Holder() string {
+	return l.holder
+}
+
+func (l *leaseKeeper) ExpiresAt() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.expires
+}
+
+func (l *leaseKeeper) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (l *leaseKeeper) Release() error {
+	return l.ReleaseContext(context.Background())
+}
+
+func (l *leaseKeeper) ReleaseContext(ctx context.Context) error {
+	if !l.b.lease.CompareAndSwap(l, nil) {
+		return ErrNoLease
+	}
+	l.stop()
+	if err := l.b.rpcClient.releaseLease(ctx, &l.b.cfg, l.holder); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToReleaseLease, err)
+	}
+	return nil
+}
+
+// noLease is returned by Lease when no lease is held.
+type noLease struct{}
+
+func (noLease) Holder() string                           { return "" }
+func (noLease) ExpiresAt() time.Time                     { return time.Time{} }
+func (noLease) Err() error                               { return ErrNoLease }
+func (noLease) Release() error                           { return ErrNoLease }
+func (noLease) ReleaseContext(ctx context.Context) error { return ErrNoLease }
+
+// Lease-related errors
+var (
+	ErrNoLease              = errors.New("no lease held")
+	ErrFailedToAcquireLease = errors.New("failed to acquire lease")
+	ErrFailedToReleaseLease = errors.New("failed to release lease")
+)
@@ -0,0 +1,57 @@
+package msb
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle serializes the starts and stops of a handle, so concurrent calls don't race each other
+// to the server. Calls of the same kind overlapping one in progress collapse into it: they issue
+// no request of their own and return its result. A start arriving during a stop (or the other way
+// around) waits for it to finish, then runs.
+type lifecycle struct {
+	mu      sync.Mutex
+	pending *lifecycleCall // the start or stop in progress, if any
+}
+
+type lifecycleCall struct {
+	stop bool
+	done chan struct{}
+	err  error
+}
+
+// do runs fn as a start, or a stop if stop is set, unless a call of that kind is in progress, in
+// which case it returns that call's result instead. Waiting gives up when ctx is done.
+func (lc *lifecycle) do(ctx context.Context, stop bool, fn func() error) error {
+	for {
+		lc.mu.Lock()
+		call := lc.pending
+		if call == nil {
+			call = &lifecycleCall{stop: stop, done: make(chan struct{})}
+			lc.pending = call
+			lc.mu.Unlock()
+			return lc.run(call, fn)
+		}
+		lc.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if call.stop == stop {
+			return call.err
+		}
+	}
+}
+
+func (lc *lifecycle) run(call *lifecycleCall, fn func() error) error {
+	defer func() {
+		lc.mu.Lock()
+		lc.pending = nil
+		lc.mu.Unlock()
+		close(call.done)
+	}()
+	call.err = fn()
+	return call.err
+}
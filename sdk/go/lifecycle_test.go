@@ -0,0 +1,157 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+// overlap runs call n times concurrently. Once the first call has signalled entered, the others
+// are given time to queue behind it before release lets it finish.
+func overlap(n int, call func() error, entered <-chan struct{}, release func()) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = call()
+		}()
+	}
+	<-entered
+	time.Sleep(20 * time.Millisecond)
+	release()
+	wg.Wait()
+	return errs
+}
+
+func TestLifecycleCollapsesCallsOfTheSameKind(t *testing.T) {
+	var lc lifecycle
+	var runs atomic.Int32
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	want := errors.New("start failed")
+	errs := overlap(8, func() error {
+		return lc.do(context.Background(), false, func() error {
+			if runs.Add(1) == 1 {
+				close(entered)
+			}
+			<-unblock
+			return want
+		})
+	}, entered, func() { close(unblock) })
+
+	if n := runs.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+	for i, err := range errs {
+		if err != want {
+			t.Errorf("call %d returned %v, want the shared result", i, err)
+		}
+	}
+}
+
+func TestLifecycleRunsStartAfterStop(t *testing.T) {
+	var lc lifecycle
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	var stopped atomic.Bool
+	go func() {
+		_ = lc.do(context.Background(), true, func() error {
+			close(entered)
+			<-unblock
+			stopped.Store(true)
+			return errors.New("stop failed")
+		})
+	}()
+	<-entered
+
+	done := make(chan error)
+	go func() {
+		done <- lc.do(context.Background(), false, func() error {
+			if !stopped.Load() {
+				t.Error("start ran before the stop finished")
+			}
+			return nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Errorf("start returned %v, want its own result rather than the stop's", err)
+	}
+}
+
+func TestLifecycleWaitGivesUpWithContext(t *testing.T) {
+	var lc lifecycle
+	unblock := make(chan struct{})
+	defer close(unblock)
+	entered := make(chan struct{})
+	go func() {
+		_ = lc.do(context.Background(), false, func() error {
+			close(entered)
+			<-unblock
+			return nil
+		})
+	}()
+	<-entered
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := lc.do(ctx, false, func() error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("do = %v, want context.Canceled", err)
+	}
+}
+
+// TestConcurrentStartsShareOneRequest checks that overlapping starts of one handle send a single
+// sandbox.start and report a single audit event.
+func TestConcurrentStartsShareOneRequest(t *testing.T) {
+	srv := msbtest.NewServer()
+	defer srv.Close()
+	var requests atomic.Int32
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	srv.Handle("sandbox.start", func(params json.RawMessage) (any, error) {
+		if requests.Add(1) == 1 {
+			close(entered)
+		}
+		<-unblock
+		var p struct {
+			Sandbox string `json:"sandbox"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return "Sandbox " + p.Sandbox + " started successfully", nil
+	})
+
+	var mu sync.Mutex
+	var events []AuditEvent
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"), WithAuditSink(func(e AuditEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}))
+	ctx := context.Background()
+	errs := overlap(4, func() error {
+		return sandbox.StartContext(ctx, StartConfig{})
+	}, entered, func() { close(unblock) })
+	defer sandbox.StopContext(ctx)
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("start %d: %v", i, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("server got %d start requests, want 1", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0].Action != AuditStart {
+		t.Errorf("audit events = %+v, want a single start", events)
+	}
+}
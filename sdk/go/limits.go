@@ -0,0 +1,39 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits are resource limits (ulimits) applied to every process in the sandbox, containing fork
+// bombs and file descriptor leaks in user code. Zero fields leave the guest's defaults in place.
+type Limits struct {
+	MaxProcesses   int `json:"max_processes,omitempty"`     // Maximum number of processes (RLIMIT_NPROC)
+	MaxOpenFiles   int `json:"max_open_files,omitempty"`    // Maximum number of open file descriptors per process (RLIMIT_NOFILE)
+	MaxFileSizeMiB int `json:"max_file_size_mib,omitempty"` // Maximum size of a file written by a process (RLIMIT_FSIZE)
+}
+
+func (l Limits) validate() error {
+	switch {
+	case l.MaxProcesses < 0:
+		return fmt.Errorf("%w: negative max processes %d", ErrInvalidLimits, l.MaxProcesses)
+	case l.MaxOpenFiles < 0:
+		return fmt.Errorf("%w: negative max open files %d", ErrInvalidLimits, l.MaxOpenFiles)
+	case l.MaxFileSizeMiB < 0:
+		return fmt.Errorf("%w: negative max file size %d", ErrInvalidLimits, l.MaxFileSizeMiB)
+	}
+	return nil
+}
+
+// rpcParam returns the limits as sent to the server, or nil if none is set.
+func (l Limits) rpcParam() *Limits {
+	if l == (Limits{}) {
+		return nil
+	}
+	return &l
+}
+
+// Limit-related errors
+var (
+	ErrInvalidLimits = errors.New("invalid limits")
+)
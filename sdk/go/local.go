@@ -0,0 +1,275 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalEngine runs code in-process, standing in for the microsandbox server when none is
+// reachable (see WithLocalFallback). Implementations typically host a WebAssembly build of the
+// language's interpreter, which keeps untrusted code away from the host while no VM is
+// available; the localwasm module provides one running CPython under wazero.
+type LocalEngine interface {
+	// NewREPL returns a fresh interpreter for language, "python" or "nodejs". It is called once
+	// per sandbox and language, when code is first run.
+	NewREPL(ctx context.Context, language string) (LocalREPL, error)
+}
+
+// LocalREPL is an interpreter created by a LocalEngine. Like a sandbox's REPL, it keeps state
+// between runs, which never overlap.
+type LocalREPL interface {
+	// Run runs code, returning what it wrote. An error is for failures of the interpreter itself;
+	// errors the code raises are reported through LocalOutput.Failed.
+	Run(ctx context.Context, code string) (LocalOutput, error)
+	// Close releases the interpreter when the sandbox is stopped.
+	Close() error
+}
+
+// LocalOutput is the result of a LocalREPL run.
+type LocalOutput struct {
+	Stdout string
+	Stderr string
+	Failed bool // The code raised an error it didn't handle
+}
+
+// WithLocalFallback runs sandboxes with engine, in-process, when Start can't resolve or connect
+// to the server, e.g. for offline development and unit tests. Such sandboxes run code through the same
+// LangSandBox interface and report SandboxInfo.Local, but can't run commands: those fail with
+// ErrNotSupportedLocally. Output interleaving isn't kept: stdout comes before stderr.
+//
+// A sandbox started on the server keeps using it; the fallback only applies to starts, and not to
+// those with a StartConfig.Network policy, which the engine couldn't enforce.
+func WithLocalFallback(engine LocalEngine) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.localEngine = engine
+	}
+}
+
+// fallbackRPCClient is the rpcClient of handles with a local fallback: sandboxes the server
+// couldn't be reached to start run on the engine, everything else goes to the server.
+type fallbackRPCClient struct {
+	rpcClient
+	engine LocalEngine
+	locks  localLocks
+
+	mu        sync.Mutex
+	sandboxes map[string]*localSandbox // by name
+}
+
+var _ rpcClient = &fallbackRPCClient{}
+
+func newFallbackRPCClient(server rpcClient, engine LocalEngine) *fallbackRPCClient {
+	return &fallbackRPCClient{rpcClient: server, engine: engine, sandboxes: make(map[string]*localSandbox)}
+}
+
+// localSandbox is a sandbox run by a LocalEngine.
+type localSandbox struct {
+	mu    sync.Mutex // serializes runs, which share the REPLs
+	repls map[progLang]LocalREPL
+}
+
+func (f *fallbackRPCClient) local(cfg *config) *localSandbox {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sandboxes[cfg.name]
+}
+
+// isUnreachable reports whether err means the server couldn't be reached at all, as opposed to
+// it failing the request or ctx ending it. A connection that broke after the start request was
+// written doesn't count: the server may be starting the sandbox, which would then be orphaned.
+func isUnreachable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return errors.Is(err, ErrDiscoveryFailed) || errors.Is(err, ErrSendRequestFailed) && neverSent(err)
+}
+
+func (f *fallbackRPCClient) startSandbox(ctx context.Context, cfg *config, sc startConfig) (*startResult, error) {
+	result, err := f.rpcClient.startSandbox(ctx, cfg, sc)
+	if err == nil || !isUnreachable(ctx, err) || sc.Network != nil {
+		return result, err
+	}
+	cfg.log(ctx).Info("Server unreachable, running sandbox locally", "sandbox", cfg.name, "error", err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sandboxes[cfg.name] = &localSandbox{repls: make(map[progLang]LocalREPL)}
+	return &startResult{local: true}, nil
+}
+
+func (f *fallbackRPCClient) stopSandbox(ctx context.Context, cfg *config) error {
+	f.mu.Lock()
+	ls := f.sandboxes[cfg.name]
+	delete(f.sandboxes, cfg.name)
+	f.mu.Unlock()
+	if ls == nil {
+		return f.rpcClient.stopSandbox(ctx, cfg)
+	}
+	f.locks.drop(cfg.name)
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	var errs []error
+	for _, repl := range ls.repls {
+		errs = append(errs, repl.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (f *fallbackRPCClient) runRepl(ctx context.Context, cfg *config, lang progLang, code string) (*executionResult, error) {
+	ls := f.local(cfg)
+	if ls == nil {
+		return f.rpcClient.runRepl(ctx, cfg, lang, code)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	repl, ok := ls.repls[lang]
+	if !ok {
+		var err error
+		if repl, err = f.engine.NewREPL(ctx, lang.String()); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLocalEngineFailed, err)
+		}
+		ls.repls[lang] = repl
+	}
+	cfg.log(ctx).Debug("Executing code locally", "sandbox", cfg.name, "language", lang.String())
+	out, err := repl.Run(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLocalEngineFailed, err)
+	}
+
+	result := ReplResult{Status: "success", Language: lang.String()}
+	if out.Failed {
+		result.Status = "exception"
+	}
+	result.Output = append(localOutputLines("stdout", out.Stdout), localOutputLines("stderr", out.Stderr)...)
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLocalEngineFailed, err)
+	}
+	return &executionResult{output: raw}, nil
+}
+
+// localOutputLines splits text written to stream into output lines.
+func localOutputLines(stream, text string) []OutputLine {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	var lines []OutputLine
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, OutputLine{Stream: stream, Text: line})
+	}
+	return lines
+}
+
+func (f *fallbackRPCClient) runCommand(ctx context.Context, cfg *config, user, command string, args []string) (*executionResult, error) {
+	return f.runCommandTo(ctx, cfg, user, command, args, nil)
+}
+
+func (f *fallbackRPCClient) runCommandTo(ctx context.Context, cfg *config, user, command string, args []string, sink outputSink) (*executionResult, error) {
+	if f.local(cfg) != nil {
+		return nil, fmt.Errorf("%w: command %s", ErrNotSupportedLocally, command)
+	}
+	return f.rpcClient.runCommandTo(ctx, cfg, user, command, args, sink)
+}
+
+func (f *fallbackRPCClient) getMetrics(ctx context.Context, cfg *config) (*sandboxMetrics, error) {
+	if f.local(cfg) != nil {
+		return &sandboxMetrics{Name: cfg.name, Running: true}, nil
+	}
+	return f.rpcClient.getMetrics(ctx, cfg)
+}
+
+func (f *fallbackRPCClient) getStatus(ctx context.Context, cfg *config) (SandboxStatus, error) {
+	if f.local(cfg) != nil {
+		return StatusRunning, nil
+	}
+	return f.rpcClient.getStatus(ctx, cfg)
+}
+
+func (f *fallbackRPCClient) getLogs(ctx context.Context, cfg *config, offset int) (*logsResult, error) {
+	if f.local(cfg) != nil {
+		return &logsResult{Running: true}, nil
+	}
+	return f.rpcClient.getLogs(ctx, cfg, offset)
+}
+
+func (f *fallbackRPCClient) renewLease(ctx context.Context, cfg *config, holder string, ttl time.Duration) (time.Time, error) {
+	if f.local(cfg) != nil {
+		// the sandbox lives exactly as long as this process
+		return time.Now().Add(ttl), nil
+	}
+	return f.rpcClient.renewLease(ctx, cfg, holder, ttl)
+}
+
+func (f *fallbackRPCClient) releaseLease(ctx context.Context, cfg *config, holder string) error {
+	if f.local(cfg) != nil {
+		return nil
+	}
+	return f.rpcClient.releaseLease(ctx, cfg, holder)
+}
+
+func (f *fallbackRPCClient) acquireLock(ctx context.Context, cfg *config, key, holder string, ttl time.Duration) (bool, error) {
+	if f.local(cfg) == nil {
+		return f.rpcClient.acquireLock(ctx, cfg, key, holder, ttl)
+	}
+	return f.locks.acquire(cfg.name, key, holder), nil
+}
+
+func (f *fallbackRPCClient) releaseLock(ctx context.Context, cfg *config, key, holder string) error {
+	if f.local(cfg) == nil {
+		return f.rpcClient.releaseLock(ctx, cfg, key, holder)
+	}
+	f.locks.release(cfg.name, key, holder)
+	return nil
+}
+
+// localLocks arbitrates the locks on sandboxes without a server to do it. They are never
+// contended by other processes, which can't reach those sandboxes.
+type localLocks struct {
+	mu      sync.Mutex
+	holders map[[2]string]string // (sandbox, key) -> holder
+}
+
+func (l *localLocks) acquire(sandbox, key, holder string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if current, held := l.holders[[2]string{sandbox, key}]; held && current != holder {
+		return false
+	}
+	if l.holders == nil {
+		l.holders = make(map[[2]string]string)
+	}
+	l.holders[[2]string{sandbox, key}] = holder
+	return true
+}
+
+func (l *localLocks) release(sandbox, key, holder string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holders[[2]string{sandbox, key}] == holder {
+		delete(l.holders, [2]string{sandbox, key})
+	}
+}
+
+// drop releases every lock on sandbox, once it is stopped.
+func (l *localLocks) drop(sandbox string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.holders {
+		if k[0] == sandbox {
+			delete(l.holders, k)
+		}
+	}
+}
+
+// Local-execution-related errors
+var (
+	ErrNotSupportedLocally = errors.New("not supported by locally run sandboxes")
+	ErrLocalEngineFailed   = errors.New("local engine failed")
+)
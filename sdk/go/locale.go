@@ -0,0 +1,49 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	timezoneRe = regexp.MustCompile(`^[A-Za-z0-9_+-]+(?:/[A-Za-z0-9_+-]+)*$`)
+	localeRe   = regexp.MustCompile(`^(?:C|POSIX|[a-z]{2,3}(?:_[A-Z]{2})?)(?:\.[A-Za-z0-9-]+)?(?:@[A-Za-z0-9]+)?$`)
+)
+
+func validateTimezone(tz string) error {
+	if tz != "" && !timezoneRe.MatchString(tz) {
+		return fmt.Errorf("%w: %q is not an IANA time zone name", ErrInvalidTimezone, tz)
+	}
+	return nil
+}
+
+func validateLocale(locale string) error {
+	if locale != "" && !localeRe.MatchString(locale) {
+		return fmt.Errorf("%w: %q", ErrInvalidLocale, locale)
+	}
+	return nil
+}
+
+// localeEnvs returns envs extended with the variables that apply timezone and locale in the
+// guest. Variables already set in envs take precedence.
+func localeEnvs(envs []string, timezone, locale string) []string {
+	set := func(name, value string) {
+		if value == "" || slices.ContainsFunc(envs, func(env string) bool { return strings.HasPrefix(env, name+"=") }) {
+			return
+		}
+		envs = append(slices.Clip(envs), name+"="+value)
+	}
+	set("TZ", timezone)
+	set("LANG", locale)
+	set("LC_ALL", locale)
+	return envs
+}
+
+// Locale-related errors
+var (
+	ErrInvalidTimezone = errors.New("invalid timezone")
+	ErrInvalidLocale   = errors.New("invalid locale")
+)
@@ -0,0 +1,118 @@
+// Package localwasm provides a msb.LocalEngine running a WASI build of CPython under wazero, so
+// that sandboxes started while no microsandbox server is reachable run their code in
+// WebAssembly, away from the host, rather than not at all:
+//
+//	wasm, err := os.ReadFile("python-3.12.0.wasm")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	engine, err := localwasm.NewPythonEngine(ctx, wasm, localwasm.Config{
+//		Mounts: map[string]string{"/usr/local/lib": "python/usr/local/lib"},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer engine.Close(ctx)
+//
+//	sandbox := msb.NewPythonSandbox(msb.WithLocalFallback(engine))
+//
+// The module is not bundled: any WASI command build of CPython 3 will do, such as those of the
+// CPython project or of the WebAssembly Language Runtimes. It lives in a module of its own so
+// that the SDK itself doesn't depend on wazero.
+package localwasm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+)
+
+// Config configures the interpreters of an Engine.
+type Config struct {
+	// Mounts maps directories of the interpreter's file system to host directories, mounted
+	// read-only. Builds that don't embed the standard library need it mounted where they look for
+	// it, usually "/usr/local/lib".
+	Mounts map[string]string
+	Env    map[string]string // Environment variables of the interpreter, e.g. PYTHONHOME
+}
+
+// Engine is a msb.LocalEngine running Python code with a WASI build of CPython. Each sandbox
+// gets an interpreter of its own, which keeps its state between runs.
+type Engine struct {
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	cfg     Config
+}
+
+var _ msb.LocalEngine = &Engine{}
+
+// NewPythonEngine compiles wasm, a WASI build of CPython, into an Engine. Close releases it.
+func NewPythonEngine(ctx context.Context, wasm []byte, cfg Config) (*Engine, error) {
+	// closing on ctx done is what lets Run abandon code that doesn't return
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidModule, err)
+	}
+	module, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidModule, err)
+	}
+	return &Engine{runtime: runtime, module: module, cfg: cfg}, nil
+}
+
+// NewREPL starts an interpreter for language, which must be "python".
+func (e *Engine) NewREPL(ctx context.Context, language string) (msb.LocalREPL, error) {
+	if language != "python" {
+		return nil, fmt.Errorf("%w: %s", ErrLanguageNotSupported, language)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// the interpreter outlives the ctx of the run that created it
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r := newREPL("__msb_done_"+hex.EncodeToString(nonce)+"__", cancel)
+
+	fsConfig := wazero.NewFSConfig()
+	for guest, host := range e.cfg.Mounts {
+		fsConfig = fsConfig.WithReadOnlyDirMount(host, guest)
+	}
+	// an empty name lets several instances of the module run at once
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs("python", "-c", driverCode, r.marker).
+		WithStdin(r.stdinR).
+		WithStdout(streamWriter{r, false}).
+		WithStderr(streamWriter{r, true}).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	for k, v := range e.cfg.Env {
+		config = config.WithEnv(k, v)
+	}
+	go r.serve(ctx, e.runtime, e.module, config)
+	return r, nil
+}
+
+// Close releases the engine along with the interpreters still running.
+func (e *Engine) Close(ctx context.Context) error {
+	return e.runtime.Close(ctx)
+}
+
+// Engine-related errors
+var (
+	ErrInvalidModule        = errors.New("invalid WebAssembly module")
+	ErrLanguageNotSupported = errors.New("language not supported by engine")
+	ErrInterpreterExited    = errors.New("interpreter exited")
+)
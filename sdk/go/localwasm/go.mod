@@ -0,0 +1,15 @@
+module github.com/microsandbox/microsandbox/sdk/go/localwasm
+
+go 1.25.0
+
+require (
+	github.com/microsandbox/microsandbox/sdk/go v0.0.0
+	github.com/tetratelabs/wazero v1.12.0
+)
+
+require (
+	github.com/google/uuid v1.5.0 // indirect
+	golang.org/x/sys v0.44.0 // indirect
+)
+
+replace github.com/microsandbox/microsandbox/sdk/go => ../
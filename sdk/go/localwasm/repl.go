@@ -0,0 +1,168 @@
+package localwasm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+)
+
+// driverCode is the program the interpreter runs. It reads runs from stdin, each the length of
+// the code in bytes on a line of its own followed by the code, and runs them in one namespace.
+// The output of each run ends with the marker given as argument, followed by "1" if the code
+// raised an error and "0" if not.
+const driverCode = `import io, sys, traceback
+def __msb_serve(marker):
+    runs, out = sys.stdin.buffer, sys.stdout
+    sys.stdin = io.StringIO()
+    ns = {"__name__": "__main__", "__builtins__": __builtins__}
+    while True:
+        header = runs.readline()
+        if not header:
+            return
+        code = runs.read(int(header)).decode()
+        failed = False
+        try:
+            exec(compile(code, "<sandbox>", "exec"), ns)
+        except SystemExit as e:
+            failed = e.code not in (None, 0)
+        except BaseException as e:
+            traceback.print_exception(type(e), e, e.__traceback__.tb_next)
+            failed = True
+        sys.stdout.flush()
+        sys.stderr.flush()
+        out.write(marker + ("1" if failed else "0"))
+        out.flush()
+__msb_serve(sys.argv[1])
+`
+
+// closeTimeout is how long Close waits for the interpreter to exit on its own before
+// terminating it.
+const closeTimeout = 5 * time.Second
+
+// repl is an interpreter running driverCode.
+type repl struct {
+	marker string
+	stdinR *io.PipeReader
+	stdinW *io.PipeWriter
+	cancel context.CancelFunc // terminates the interpreter
+
+	mu sync.Mutex // serializes runs
+
+	outMu   sync.Mutex
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+	results chan msb.LocalOutput // receives the output of each run
+
+	done chan struct{} // closed once the interpreter exited
+	err  error         // why it exited; set before done is closed
+}
+
+var _ msb.LocalREPL = &repl{}
+
+func newREPL(marker string, cancel context.CancelFunc) *repl {
+	r := &repl{marker: marker, cancel: cancel, results: make(chan msb.LocalOutput, 1), done: make(chan struct{})}
+	r.stdinR, r.stdinW = io.Pipe()
+	return r
+}
+
+// serve runs the interpreter until it exits.
+func (r *repl) serve(ctx context.Context, runtime wazero.Runtime, module wazero.CompiledModule, config wazero.ModuleConfig) {
+	defer close(r.done)
+	mod, err := runtime.InstantiateModule(ctx, module, config)
+	if mod != nil {
+		mod.Close(context.WithoutCancel(ctx))
+	}
+
+	r.outMu.Lock()
+	stderr := strings.TrimSpace(r.stderr.String())
+	r.outMu.Unlock()
+	var exitErr *sys.ExitError
+	switch {
+	case err == nil || errors.As(err, &exitErr) && exitErr.ExitCode() == 0:
+		r.err = ErrInterpreterExited
+	case stderr != "":
+		r.err = fmt.Errorf("%w: %w: %s", ErrInterpreterExited, err, stderr)
+	default:
+		r.err = fmt.Errorf("%w: %w", ErrInterpreterExited, err)
+	}
+	// fails runs still writing to the interpreter
+	r.stdinR.CloseWithError(r.err)
+}
+
+// Run runs code. If ctx ends first, the interpreter is terminated, and with it the state of
+// earlier runs: later runs fail with ErrInterpreterExited.
+func (r *repl) Run(ctx context.Context, code string) (msb.LocalOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.done:
+		return msb.LocalOutput{}, r.err
+	default:
+	}
+
+	// a failed write means the interpreter exited, which done reports
+	go fmt.Fprintf(r.stdinW, "%d\n%s", len(code), code)
+	select {
+	case out := <-r.results:
+		return out, nil
+	case <-r.done:
+		return msb.LocalOutput{}, r.err
+	case <-ctx.Done():
+		r.cancel()
+		<-r.done
+		return msb.LocalOutput{}, ctx.Err()
+	}
+}
+
+// Close ends the interpreter's input, which makes it exit, and terminates it if it doesn't.
+func (r *repl) Close() error {
+	r.stdinW.Close()
+	select {
+	case <-r.done:
+	case <-time.After(closeTimeout):
+		r.cancel()
+		<-r.done
+	}
+	r.cancel()
+	return nil
+}
+
+// streamWriter collects what the interpreter writes to stdout or stderr, and hands the output
+// of a run to Run once the marker ending it comes through.
+type streamWriter struct {
+	r      *repl
+	stderr bool
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	r := w.r
+	r.outMu.Lock()
+	defer r.outMu.Unlock()
+	if w.stderr {
+		return r.stderr.Write(p)
+	}
+	r.stdout.Write(p)
+	buf := r.stdout.Bytes()
+	i := bytes.Index(buf, []byte(r.marker))
+	if i < 0 || len(buf) <= i+len(r.marker) {
+		return len(p), nil
+	}
+	r.results <- msb.LocalOutput{
+		Stdout: string(buf[:i]),
+		Stderr: r.stderr.String(),
+		Failed: buf[i+len(r.marker)] == '1',
+	}
+	r.stdout.Reset()
+	r.stderr.Reset()
+	return len(p), nil
+}
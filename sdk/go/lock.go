@@ -0,0 +1,279 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lock blocks until this handle holds the lock named key on the sandbox, or ctx is done.
+//
+// Locks are arbitrated by the server, so they exclude other processes sharing the same named sandbox
+// as well as other goroutines using this handle. A held lock is kept alive in the background and
+// expires on the server shortly after its holder goes away, so a crashed client can't wedge the
+// sandbox. A lock can still be lost while held, e.g. when renewals fail for longer than it lives
+// on the server; LockLost reports that. Every successful Lock must be paired with an Unlock of the
+// same key:
+//
+//	if err := sandbox.Lock(ctx, "repl"); err != nil {
+//		return err
+//	}
+//	defer sandbox.Unlock(context.Background(), "repl")
+func (ls *langSandbox) Lock(ctx context.Context, key string) error {
+	return ls.b.locks.lock(ctx, ls.b, key)
+}
+
+// Unlock releases the lock named key, previously acquired with Lock.
+// Returns ErrLockNotHeld if this handle doesn't hold it, and ErrLockLost if it was lost since.
+func (ls *langSandbox) Unlock(ctx context.Context, key string) error {
+	return ls.b.locks.unlock(ctx, ls.b, key)
+}
+
+// LockLost returns a channel closed once the lock named key, held through this handle, is lost:
+// the server handed it to another holder, it couldn't be renewed before it expired, or the sandbox
+// stopped. Work guarded by the lock should stop when it is closed:
+//
+//	select {
+//	case <-sandbox.LockLost("repl"):
+//		return msb.ErrLockLost
+//	case result := <-work:
+//		...
+//	}
+//
+// The channel is closed already if this handle doesn't hold the lock.
+func (ls *langSandbox) LockLost(key string) <-chan struct{} {
+	return ls.b.locks.lostChan(key)
+}
+
+// Lock timing. Locks are taken out with lockTTL and renewed every third of it while held;
+// contended locks are polled with exponential backoff between the two bounds.
+const (
+	lockTTL                = 15 * time.Second
+	lockPollInitialBackoff = 50 * time.Millisecond
+	lockPollMaxBackoff     = time.Second
+)
+
+// lockTable tracks the locks held (or being acquired) through one handle.
+// The zero value is ready to use.
+type lockTable struct {
+	holderOnce sync.Once
+	holder     string
+
+	mu    sync.Mutex
+	locks map[string]*heldLock
+}
+
+// heldLock is a lock entry; acquired is false while the server hasn't granted the lock yet.
+type heldLock struct {
+	acquired bool
+	released chan struct{} // closed once the entry is removed from the table
+	lost     chan struct{} // closed once the lock is known not to be held anymore
+	lostOnce sync.Once
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func (e *heldLock) markLost() {
+	e.lostOnce.Do(func() { close(e.lost) })
+}
+
+func (e *heldLock) isLost() bool {
+	select {
+	case <-e.lost:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *lockTable) holderID() string {
+	t.holderOnce.Do(func() { t.holder = uuid.NewString() })
+	return t.holder
+}
+
+func (t *lockTable) lock(ctx context.Context, b *baseMicroSandbox, key string) error {
+	if b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+
+	// wait for other goroutines of this handle to be done with the key, then claim it locally
+	entry := &heldLock{released: make(chan struct{}), lost: make(chan struct{})}
+	for {
+		t.mu.Lock()
+		other, busy := t.locks[key]
+		if !busy {
+			if t.locks == nil {
+				t.locks = make(map[string]*heldLock)
+			}
+			t.locks[key] = entry
+			t.mu.Unlock()
+			break
+		}
+		t.mu.Unlock()
+		select {
+		case <-other.released:
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrFailedToAcquireLock, ctx.Err())
+		}
+	}
+
+	if err := t.acquire(ctx, b, key); err != nil {
+		t.remove(key, entry)
+		return err
+	}
+
+	renewCtx, cancel := context.WithCancel(context.Background())
+	t.mu.Lock()
+	// a Stop that ran while the lock was being acquired couldn't drop it: nothing would end its
+	// renewals
+	if b.state.Load() != started {
+		t.mu.Unlock()
+		cancel()
+		if err := b.rpcClient.releaseLock(context.WithoutCancel(ctx), &b.cfg, key, t.holderID()); err != nil {
+			b.cfg.log(ctx).Debug("Failed to release lock of stopped sandbox", "name", b.cfg.name, "key", key, "error", err)
+		}
+		t.remove(key, entry)
+		return ErrSandboxNotStarted
+	}
+	entry.acquired = true
+	entry.cancel = cancel
+	entry.done = make(chan struct{})
+	t.mu.Unlock()
+	go t.renewLoop(renewCtx, b, key, entry)
+	return nil
+}
+
+// acquire polls the server until it grants the lock to this handle.
+func (t *lockTable) acquire(ctx context.Context, b *baseMicroSandbox, key string) error {
+	backoff := lockPollInitialBackoff
+	for {
+		acquired, err := b.rpcClient.acquireLock(ctx, &b.cfg, key, t.holderID(), lockTTL)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrFailedToAcquireLock, err)
+		}
+		if acquired {
+			return nil
+		}
+		b.cfg.log(ctx).Debug("Sandbox lock is held elsewhere, waiting", "name", b.cfg.name, "key", key)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrFailedToAcquireLock, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, lockPollMaxBackoff)
+	}
+}
+
+// renewLoop keeps entry's lock alive until ctx is done, or until the lock is lost: handed to
+// another holder, or left unrenewed for as long as it lives on the server.
+func (t *lockTable) renewLoop(ctx context.Context, b *baseMicroSandbox, key string, entry *heldLock) {
+	defer close(entry.done)
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		acquired, err := b.rpcClient.acquireLock(ctx, &b.cfg, key, t.holderID(), lockTTL)
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err == nil && acquired:
+			renewed = time.Now()
+			continue
+		case err == nil:
+			b.cfg.log(ctx).Error("Sandbox lock lost to another holder", "name", b.cfg.name, "key", key)
+		case time.Since(renewed) < lockTTL:
+			b.cfg.log(ctx).Error("Failed to renew sandbox lock", "name", b.cfg.name, "key", key, "error", err)
+			continue
+		default:
+			b.cfg.log(ctx).Error("Sandbox lock expired before it could be renewed", "name", b.cfg.name, "key", key, "error", err)
+		}
+		entry.markLost()
+		return
+	}
+}
+
+func (t *lockTable) unlock(ctx context.Context, b *baseMicroSandbox, key string) error {
+	t.mu.Lock()
+	entry, ok := t.locks[key]
+	if !ok || !entry.acquired {
+		t.mu.Unlock()
+		return ErrLockNotHeld
+	}
+	delete(t.locks, key)
+	t.mu.Unlock()
+
+	entry.cancel()
+	<-entry.done
+	if entry.isLost() {
+		close(entry.released)
+		return fmt.Errorf("%w: %s", ErrLockLost, key)
+	}
+	// let local waiters proceed only after the server has released the lock, so they don't
+	// needlessly poll against our own stale claim
+	err := b.rpcClient.releaseLock(ctx, &b.cfg, key, t.holderID())
+	close(entry.released)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToReleaseLock, err)
+	}
+	return nil
+}
+
+// lostChan returns the lost channel of the lock held for key, or a closed channel if none is.
+func (t *lockTable) lostChan(key string) <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if entry, ok := t.locks[key]; ok && entry.acquired {
+		return entry.lost
+	}
+	lost := make(chan struct{})
+	close(lost)
+	return lost
+}
+
+// remove drops entry from the table if it is still the one registered for key.
+func (t *lockTable) remove(key string, entry *heldLock) {
+	t.mu.Lock()
+	if t.locks[key] == entry {
+		delete(t.locks, key)
+	}
+	t.mu.Unlock()
+	close(entry.released)
+}
+
+// dropAll forgets every held lock without contacting the server, for when the sandbox is gone.
+func (t *lockTable) dropAll() {
+	t.mu.Lock()
+	var held []*heldLock
+	for key, entry := range t.locks {
+		if entry.acquired {
+			held = append(held, entry)
+			delete(t.locks, key)
+		}
+	}
+	t.mu.Unlock()
+	for _, entry := range held {
+		entry.cancel()
+		<-entry.done
+		entry.markLost()
+		close(entry.released)
+	}
+}
+
+// Lock-related errors
+var (
+	ErrLockNotHeld         = errors.New("lock not held")
+	ErrLockLost            = errors.New("lock lost")
+	ErrFailedToAcquireLock = errors.New("failed to acquire lock")
+	ErrFailedToReleaseLock = errors.New("failed to release lock")
+)
@@ -0,0 +1,118 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+func startLockSandbox(t *testing.T, srv *msbtest.Server) *langSandbox {
+	t.Helper()
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"))
+	if err := sandbox.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+	return sandbox
+}
+
+func TestLockLost(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	sandbox := startLockSandbox(t, srv)
+	ctx := context.Background()
+	if err := sandbox.Lock(ctx, "repl"); err != nil {
+		t.Fatal(err)
+	}
+	lost := sandbox.LockLost("repl")
+	select {
+	case <-lost:
+		t.Fatal("held lock reported lost")
+	default:
+	}
+
+	// as the renewals do once the server hands the lock to someone else
+	sandbox.b.locks.mu.Lock()
+	entry := sandbox.b.locks.locks["repl"]
+	sandbox.b.locks.mu.Unlock()
+	entry.markLost()
+	<-lost
+	if err := sandbox.Unlock(ctx, "repl"); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Unlock of a lost lock = %v, want ErrLockLost", err)
+	}
+	select {
+	case <-sandbox.LockLost("repl"):
+	default:
+		t.Error("lock that isn't held not reported lost")
+	}
+	// the key can be locked again
+	if err := sandbox.Lock(ctx, "repl"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sandbox.Unlock(ctx, "repl"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockLostOnStop(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	sandbox := startLockSandbox(t, srv)
+	if err := sandbox.Lock(context.Background(), "repl"); err != nil {
+		t.Fatal(err)
+	}
+	lost := sandbox.LockLost("repl")
+	if err := sandbox.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock of a stopped sandbox not reported lost")
+	}
+}
+
+// TestLockAcquiredDuringStop checks that a lock the server grants while the sandbox is being
+// stopped isn't kept, and renewed, by the stopped handle.
+func TestLockAcquiredDuringStop(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	requested, grant := make(chan struct{}), make(chan struct{})
+	var acquires, releases atomic.Int32
+	srv.Handle("sandbox.lock.acquire", func(json.RawMessage) (any, error) {
+		if acquires.Add(1) == 1 {
+			close(requested)
+			<-grant
+		}
+		return map[string]any{"acquired": true}, nil
+	})
+	srv.Handle("sandbox.lock.release", func(json.RawMessage) (any, error) {
+		releases.Add(1)
+		return "released", nil
+	})
+	sandbox := startLockSandbox(t, srv)
+
+	errc := make(chan error)
+	go func() { errc <- sandbox.Lock(context.Background(), "repl") }()
+	<-requested
+	if err := sandbox.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	close(grant)
+	if err := <-errc; !errors.Is(err, ErrSandboxNotStarted) {
+		t.Errorf("Lock = %v, want ErrSandboxNotStarted", err)
+	}
+	if n := releases.Load(); n != 1 {
+		t.Errorf("lock released %d times, want once", n)
+	}
+	sandbox.b.locks.mu.Lock()
+	defer sandbox.b.locks.mu.Unlock()
+	if n := len(sandbox.b.locks.locks); n != 0 {
+		t.Errorf("%d locks left in the table of the stopped handle", n)
+	}
+}
@@ -0,0 +1,136 @@
+package msb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LogLevel is the minimum severity of the SDK messages passed on to the Logger.
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota // Every message (default)
+	LogInfo                  // Lifecycle events and errors, but not the per-request debug messages
+	LogError                 // Errors only
+)
+
+// WithLogLevel drops SDK messages below level before they reach the Logger, so loggers that
+// don't filter by level themselves needn't pay for formatting them.
+func WithLogLevel(level LogLevel) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.logLevel = level
+	}
+}
+
+// LogSampling thins out repetitive debug messages, such as the ones logged for every metrics poll
+// or execution. Each distinct message is counted separately: the first First occurrences per
+// Interval are logged, then only every Thereafter-th one. Info and error messages are never sampled.
+type LogSampling struct {
+	First      int           // Occurrences logged per interval before sampling starts
+	Thereafter int           // Log every Thereafter-th occurrence after First; 0 drops them all
+	Interval   time.Duration // Period after which the counts reset; defaults to one second
+}
+
+// WithLogSampling enables sampling of debug messages. Sandboxes derived from a Client share the
+// client's counts, so the volume is bounded for all of them together.
+func WithLogSampling(sampling LogSampling) Option {
+	return func(msb *baseMicroSandbox) {
+		if sampling.Interval <= 0 {
+			sampling.Interval = time.Second
+		}
+		msb.cfg.logSampler = &logSampler{cfg: sampling, counts: make(map[string]int)}
+	}
+}
+
+// logSampler counts debug messages to decide which ones are logged.
+type logSampler struct {
+	cfg LogSampling
+
+	mu     sync.Mutex
+	counts map[string]int // message -> occurrences in the current interval
+	reset  time.Time      // end of the current interval
+}
+
+func (s *logSampler) allow(msg string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.After(s.reset) {
+		clear(s.counts)
+		s.reset = now.Add(s.cfg.Interval)
+	}
+	s.counts[msg]++
+	n := s.counts[msg]
+	if n <= s.cfg.First {
+		return true
+	}
+	return s.cfg.Thereafter > 0 && (n-s.cfg.First)%s.cfg.Thereafter == 0
+}
+
+// filteredLogger applies the configured level and sampling in front of the user's Logger.
+type filteredLogger struct {
+	Logger
+	level   LogLevel
+	sampler *logSampler // nil if debug messages aren't sampled
+}
+
+func (f *filteredLogger) Debug(msg string, args ...any) {
+	if f.level <= LogDebug && f.sampler.allow(msg) {
+		f.Logger.Debug(msg, args...)
+	}
+}
+
+func (f *filteredLogger) Info(msg string, args ...any) {
+	if f.level <= LogInfo {
+		f.Logger.Info(msg, args...)
+	}
+}
+
+func (f *filteredLogger) Error(msg string, args ...any) {
+	f.Logger.Error(msg, args...)
+}
+
+func (f *filteredLogger) DebugContext(ctx context.Context, msg string, args ...any) {
+	if f.level > LogDebug || !f.sampler.allow(msg) {
+		return
+	}
+	if cl, ok := f.Logger.(ContextLogger); ok {
+		cl.DebugContext(ctx, msg, args...)
+		return
+	}
+	f.Logger.Debug(msg, args...)
+}
+
+func (f *filteredLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	if f.level > LogInfo {
+		return
+	}
+	if cl, ok := f.Logger.(ContextLogger); ok {
+		cl.InfoContext(ctx, msg, args...)
+		return
+	}
+	f.Logger.Info(msg, args...)
+}
+
+func (f *filteredLogger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	if cl, ok := f.Logger.(ContextLogger); ok {
+		cl.ErrorContext(ctx, msg, args...)
+		return
+	}
+	f.Logger.Error(msg, args...)
+}
+
+// filterLogger puts logger behind the configured level and sampling, if any. Loggers filtered
+// for a Client are unwrapped first, so sandboxes derived from it can override either setting.
+func filterLogger(logger Logger, level LogLevel, sampler *logSampler) Logger {
+	if f, ok := logger.(*filteredLogger); ok {
+		logger = f.Logger
+	}
+	if level == LogDebug && sampler == nil {
+		return logger
+	}
+	return &filteredLogger{Logger: logger, level: level, sampler: sampler}
+}
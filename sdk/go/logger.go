@@ -1,6 +1,7 @@
 package msb
 
 import (
+	"context"
 	"io"
 	"log/slog"
 )
@@ -17,6 +18,51 @@ type Logger interface {
 	Error(msg string, args ...any)
 }
 
+// ContextLogger is a Logger that also receives the context of the operation being logged, so that
+// adapters can add request-scoped fields such as trace or tenant IDs to SDK log lines. The SDK
+// calls the Context methods wherever a context is at hand, e.g. for every JSON-RPC request.
+// SlogAdapter implements it, passing the context on to the slog.Handler.
+type ContextLogger interface {
+	Logger
+	// DebugContext logs debug-level messages with optional key-value pairs.
+	DebugContext(ctx context.Context, msg string, args ...any)
+	// InfoContext logs info-level messages with optional key-value pairs.
+	InfoContext(ctx context.Context, msg string, args ...any)
+	// ErrorContext logs error-level messages with optional key-value pairs.
+	ErrorContext(ctx context.Context, msg string, args ...any)
+}
+
+// contextLogger binds a context to a ContextLogger, for code logging through the Logger interface.
+type contextLogger struct {
+	ctx    context.Context
+	logger ContextLogger
+}
+
+func (c contextLogger) Debug(msg string, args ...any) {
+	c.logger.DebugContext(c.ctx, msg, args...)
+}
+
+func (c contextLogger) Info(msg string, args ...any) {
+	c.logger.InfoContext(c.ctx, msg, args...)
+}
+
+func (c contextLogger) Error(msg string, args ...any) {
+	c.logger.ErrorContext(c.ctx, msg, args...)
+}
+
+// log returns the configured logger, bound to ctx if it is a ContextLogger, and labeled with the
+// tenant, if any.
+func (c *config) log(ctx context.Context) Logger {
+	logger := c.logger
+	if cl, ok := c.logger.(ContextLogger); ok {
+		logger = contextLogger{ctx: ctx, logger: cl}
+	}
+	if c.tenant != "" {
+		logger = labeledLogger{logger: logger, labels: []any{"tenant", c.tenant}}
+	}
+	return logger
+}
+
 // NoOpLogger is a logger that discards all log messages.
 // This is used as the default logger to avoid forcing logging on users.
 type NoOpLogger struct{}
@@ -0,0 +1,49 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// logPollInterval is how often Logs asks the server for new lines while following.
+const logPollInterval = 500 * time.Millisecond
+
+// Logs writes the log the server keeps for the sandbox (boot output and the output of its Exec
+// command and scripts) to w, one line at a time. With follow, it keeps writing lines as they are
+// produced until the sandbox stops; cancelling ctx ends following without an error. Like Status,
+// it can be called whether or not the sandbox was started through this handle.
+func (ls *langSandbox) Logs(ctx context.Context, w io.Writer, follow bool) error {
+	offset := 0
+	for {
+		logs, err := ls.b.rpcClient.getLogs(ctx, &ls.b.cfg, offset)
+		if err != nil {
+			if follow && ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("%w: %w", ErrFailedToGetLogs, err)
+		}
+		for _, line := range logs.Lines {
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return fmt.Errorf("%w: writing logs: %w", ErrFailedToGetLogs, err)
+			}
+		}
+		offset += len(logs.Lines)
+		if !follow || !logs.Running {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// Log-related errors
+var (
+	ErrFailedToGetLogs = errors.New("failed to get logs")
+)
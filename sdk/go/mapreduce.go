@@ -0,0 +1,125 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MapInputVar is the variable name under which each input is bound inside the map code.
+const MapInputVar = "msb_input"
+
+// MapReduce runs mapCode once per input across the sandboxes of pool and reduces the
+// collected outputs in Go.
+//
+// Inputs are partitioned into one shard per pooled sandbox (up to the number of inputs), and every
+// shard is processed sequentially on the sandbox it was assigned to. Before mapCode runs, the input
+// is JSON-encoded and bound to the MapInputVar variable in the sandbox's language; whatever the map
+// code writes to stdout becomes that input's output. reduceFn receives the outputs in input order.
+//
+// The first failing execution cancels the remaining work, and its error is returned.
+//
+// Example:
+//
+//	total, err := msb.MapReduce(ctx, pool, []int{1, 2, 3, 4},
+//		"print(msb_input * msb_input)",
+//		func(outputs []string) (int, error) {
+//			sum := 0
+//			for _, out := range outputs {
+//				n, err := strconv.Atoi(out)
+//				if err != nil {
+//					return 0, err
+//				}
+//				sum += n
+//			}
+//			return sum, nil
+//		})
+func MapReduce[T, R any](ctx context.Context, pool *Pool, inputs []T, mapCode string, reduceFn func(outputs []string) (R, error)) (R, error) {
+	var zero R
+	if reduceFn == nil {
+		return zero, ErrReduceFuncMissing
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	shards := min(pool.Size(), len(inputs))
+	outputs := make([]string, len(inputs))
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for shard := range shards {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+
+			sb, err := pool.Acquire(ctx)
+			if err != nil {
+				fail(err)
+				return
+			}
+			defer pool.Release(sb)
+
+			for i := shard; i < len(inputs); i += shards {
+				if ctx.Err() != nil {
+					return
+				}
+				out, err := runMap(ctx, sb, inputs[i], mapCode)
+				if err != nil {
+					fail(fmt.Errorf("input %d: %w", i, err))
+					return
+				}
+				outputs[i] = out
+			}
+		}(shard)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return zero, firstErr
+	}
+	return reduceFn(outputs)
+}
+
+func runMap[T any](ctx context.Context, sb LangSandBox, input T, mapCode string) (string, error) {
+	lang, ok := languageOf(sb)
+	if !ok {
+		return "", ErrUnknownLanguage
+	}
+	doc, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrFailedToBindInput, err)
+	}
+	binding, err := lang.bindJSON(MapInputVar, doc)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrFailedToBindInput, err)
+	}
+
+	exec, err := sb.Code().RunContext(ctx, binding+mapCode)
+	if err != nil {
+		return "", err
+	}
+	if exec.HasError() {
+		stderr, _ := exec.GetError()
+		return "", fmt.Errorf("%w: %s", ErrMapFailed, stderr)
+	}
+	return exec.GetOutput()
+}
+
+// MapReduce-related errors
+var (
+	ErrReduceFuncMissing = errors.New("reduce function must be specified")
+	ErrFailedToBindInput = errors.New("failed to bind map input")
+	ErrMapFailed         = errors.New("map execution failed")
+)
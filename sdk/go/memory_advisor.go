@@ -0,0 +1,150 @@
+package msb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMemoryHeadroom is the share of the peak MemoryAdvisor adds on top of it.
+	defaultMemoryHeadroom = 0.25
+	// memoryStepMiB is what recommendations are rounded up to a multiple of.
+	memoryStepMiB = 256
+)
+
+// MemoryRecommendation is MemoryAdvisor's advice on the memory limit of a sandbox.
+type MemoryRecommendation struct {
+	Sandbox        string // Sandbox name
+	LimitMiB       int    // Memory limit the sandbox was last seen running with; 0 if unknown
+	PeakMiB        int    // Highest memory usage seen
+	Samples        int    // Number of readings the advice is based on
+	RecommendedMiB int    // Suggested StartConfig.Memory
+}
+
+// Insufficient reports whether the limit is below the recommendation, so the sandbox risks
+// running out of memory.
+func (r MemoryRecommendation) Insufficient() bool {
+	return r.LimitMiB > 0 && r.LimitMiB < r.RecommendedMiB
+}
+
+// Oversized reports whether the limit is at least twice the recommendation.
+func (r MemoryRecommendation) Oversized() bool {
+	return r.LimitMiB > 0 && r.LimitMiB >= 2*r.RecommendedMiB
+}
+
+// String describes the recommendation, e.g. "512 MiB insufficient; peak 498 MiB, recommend 768 MiB".
+func (r MemoryRecommendation) String() string {
+	switch {
+	case r.LimitMiB == 0:
+		return fmt.Sprintf("peak %d MiB, recommend %d MiB", r.PeakMiB, r.RecommendedMiB)
+	case r.Insufficient():
+		return fmt.Sprintf("%d MiB insufficient; peak %d MiB, recommend %d MiB", r.LimitMiB, r.PeakMiB, r.RecommendedMiB)
+	case r.Oversized():
+		return fmt.Sprintf("%d MiB oversized; peak %d MiB, recommend %d MiB", r.LimitMiB, r.PeakMiB, r.RecommendedMiB)
+	default:
+		return fmt.Sprintf("%d MiB sufficient; peak %d MiB", r.LimitMiB, r.PeakMiB)
+	}
+}
+
+// MemoryAdvisor tracks the peak memory usage of sandboxes by sampling their metrics, to help
+// right-size StartConfig.Memory over time. Peaks are kept per sandbox name, so they carry over
+// restarts of a sandbox with a different limit. MemoryAdvisor is safe for concurrent use.
+type MemoryAdvisor struct {
+	headroom float64
+
+	mu    sync.Mutex
+	peaks map[string]*memoryPeak
+}
+
+type memoryPeak struct {
+	limit   int
+	peak    int
+	samples int
+}
+
+// NewMemoryAdvisor returns an advisor that recommends the peak usage plus headroom, a share of
+// the peak such as 0.25 for 25%, rounded up to a multiple of 256 MiB. A headroom <= 0 defaults
+// to 0.25.
+func NewMemoryAdvisor(headroom float64) *MemoryAdvisor {
+	if headroom <= 0 {
+		headroom = defaultMemoryHeadroom
+	}
+	return &MemoryAdvisor{headroom: headroom, peaks: make(map[string]*memoryPeak)}
+}
+
+// Watch samples the metrics of the sandboxes every interval, like WatchMetrics, until ctx is
+// done. It returns right away; sandboxes that aren't running or can't be read are skipped.
+func (a *MemoryAdvisor) Watch(ctx context.Context, interval time.Duration, sandboxes ...LangSandBox) {
+	samples := WatchMetrics(ctx, interval, sandboxes...)
+	go func() {
+		for round := range samples {
+			for i, sample := range round {
+				if sample.Err != nil || !sample.Metrics.IsRunning {
+					continue
+				}
+				limit := 0
+				if info, err := sandboxes[i].Info(); err == nil {
+					limit = info.Memory
+				}
+				a.Observe(sample.Sandbox, limit, sample.Metrics.MemoryMiB)
+			}
+		}
+	}()
+}
+
+// Observe records a reading of usedMiB for the named sandbox running with a limit of limitMiB,
+// or 0 if unknown, for callers that collect metrics themselves.
+func (a *MemoryAdvisor) Observe(name string, limitMiB, usedMiB int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.peaks[name]
+	if !ok {
+		p = &memoryPeak{}
+		a.peaks[name] = p
+	}
+	if limitMiB > 0 {
+		p.limit = limitMiB
+	}
+	p.peak = max(p.peak, usedMiB)
+	p.samples++
+}
+
+// Recommendation returns the advice for the named sandbox, or false if it hasn't been sampled.
+func (a *MemoryAdvisor) Recommendation(name string) (MemoryRecommendation, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.peaks[name]
+	if !ok {
+		return MemoryRecommendation{}, false
+	}
+	return a.recommend(name, p), true
+}
+
+// Recommendations returns the advice for every sandbox sampled so far, sorted by name.
+func (a *MemoryAdvisor) Recommendations() []MemoryRecommendation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	recs := make([]MemoryRecommendation, 0, len(a.peaks))
+	for name, p := range a.peaks {
+		recs = append(recs, a.recommend(name, p))
+	}
+	slices.SortFunc(recs, func(x, y MemoryRecommendation) int { return strings.Compare(x.Sandbox, y.Sandbox) })
+	return recs
+}
+
+// recommend computes the advice for p; the caller holds mu.
+func (a *MemoryAdvisor) recommend(name string, p *memoryPeak) MemoryRecommendation {
+	want := int(float64(p.peak) * (1 + a.headroom))
+	recommended := max((want+memoryStepMiB-1)/memoryStepMiB*memoryStepMiB, memoryStepMiB)
+	return MemoryRecommendation{
+		Sandbox:        name,
+		LimitMiB:       p.limit,
+		PeakMiB:        p.peak,
+		Samples:        p.samples,
+		RecommendedMiB: recommended,
+	}
+}
@@ -0,0 +1,66 @@
+package msb
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// metadataHeaderPrefix starts the names of the headers request metadata is sent in.
+const metadataHeaderPrefix = "X-Msb-Meta-"
+
+// ContextMetadata extracts metadata about a request from its context, such as the IDs of the end
+// user and session it is made on behalf of.
+type ContextMetadata func(ctx context.Context) map[string]string
+
+// WithContextMetadata attaches the metadata extract returns for each request's context to the
+// request, as an "X-Msb-Meta-<key>" header per key, so server logs can attribute sandbox activity
+// to end users:
+//
+//	msb.WithContextMetadata(func(ctx context.Context) map[string]string {
+//		return map[string]string{"user-id": auth.UserID(ctx), "session-id": auth.SessionID(ctx)}
+//	})
+//
+// Keys consist of ASCII letters, digits and hyphens, with underscores sent as hyphens; empty values
+// are left out. Keys with other characters, and values with control characters, are left out
+// with an error logged, rather than failing the request.
+func WithContextMetadata(extract ContextMetadata) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.ctxMetadata = extract
+	}
+}
+
+// setMetadataHeaders adds the metadata of ctx to h.
+func (c *config) setMetadataHeaders(ctx context.Context, h http.Header) {
+	if c.ctxMetadata == nil {
+		return
+	}
+	for key, value := range c.ctxMetadata(ctx) {
+		if value == "" {
+			continue
+		}
+		key = strings.ReplaceAll(key, "_", "-")
+		if !validMetadataKey(key) || strings.ContainsFunc(value, isControl) {
+			c.log(ctx).Error("Leaving out invalid request metadata", "key", key)
+			continue
+		}
+		h.Set(metadataHeaderPrefix+key, value)
+	}
+}
+
+func validMetadataKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// isControl reports whether r can't appear in a header value.
+func isControl(r rune) bool {
+	return r < ' ' && r != '\t' || r == 0x7f
+}
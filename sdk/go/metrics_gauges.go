@@ -0,0 +1,160 @@
+package msb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Names and units of the gauges MetricsGauges reports, following the OpenTelemetry naming
+// conventions, with the attributes identifying the sandbox each reading is of.
+const (
+	GaugeCPUUtilization = "sandbox.cpu.utilization" // unit "%"
+	GaugeMemoryUsage    = "sandbox.memory.usage"    // unit "By"
+	GaugeDiskUsage      = "sandbox.disk.usage"      // unit "By"
+
+	GaugeAttrSandbox   = "sandbox.name"
+	GaugeAttrNamespace = "sandbox.namespace"
+)
+
+// GaugeReading is the latest reading of a sandbox's metrics held by MetricsGauges.
+type GaugeReading struct {
+	Sandbox   string    // Sandbox name
+	Namespace string    // Tenant the sandbox belongs to; empty if none
+	Time      time.Time // When the reading was taken
+	Metrics   MetricsV2
+}
+
+// MetricsGauges keeps the latest metrics of a fleet of sandboxes, read with WatchMetrics, for a
+// metrics system to report as gauges. The otelmsb module reports them as OpenTelemetry gauges with
+// a single option; by hand, a single callback reports them all:
+//
+//	gauges := msb.WatchMetricsGauges(ctx, 15*time.Second, sandboxes...)
+//	cpu, _ := meter.Float64ObservableGauge(msb.GaugeCPUUtilization, metric.WithUnit("%"))
+//	mem, _ := meter.Int64ObservableGauge(msb.GaugeMemoryUsage, metric.WithUnit("By"))
+//	disk, _ := meter.Int64ObservableGauge(msb.GaugeDiskUsage, metric.WithUnit("By"))
+//	_, err := meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+//		for _, r := range gauges.Readings() {
+//			attrs := metric.WithAttributes(
+//				attribute.String(msb.GaugeAttrSandbox, r.Sandbox),
+//				attribute.String(msb.GaugeAttrNamespace, r.Namespace),
+//			)
+//			o.ObserveFloat64(cpu, r.Metrics.CPU, attrs)
+//			o.ObserveInt64(mem, int64(r.Metrics.MemoryBytes), attrs)
+//			o.ObserveInt64(disk, int64(r.Metrics.DiskBytes), attrs)
+//		}
+//		return nil
+//	}, cpu, mem, disk)
+type MetricsGauges struct {
+	interval time.Duration // between the readings of sandboxes that joined with WithMetricsGauges
+
+	mu       sync.Mutex
+	readings map[string]GaugeReading // sandbox name -> latest reading
+}
+
+// NewMetricsGauges returns an empty MetricsGauges for sandboxes to join with WithMetricsGauges,
+// each read every interval while it runs.
+func NewMetricsGauges(interval time.Duration) *MetricsGauges {
+	return &MetricsGauges{interval: interval, readings: make(map[string]GaugeReading)}
+}
+
+// WithMetricsGauges has every sandbox created with it keep its latest metrics in g from the
+// moment Start returns, or Unmarshal resumes it, until it is stopped, so fleets whose sandboxes
+// come and go are reported without listing them. Passed to NewClient, it applies to all the
+// client's sandboxes. g must come from NewMetricsGauges.
+func WithMetricsGauges(g *MetricsGauges) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.gauges = g
+	}
+}
+
+// joinGauges starts reading the metrics of a freshly started sandbox into its gauges when
+// WithMetricsGauges was configured.
+func joinGauges(b *baseMicroSandbox) {
+	g := b.cfg.gauges
+	if g == nil || g.interval <= 0 {
+		return
+	}
+	// readings must outlive the start call, so they don't inherit its context
+	ctx, cancel := context.WithCancel(context.Background())
+	if old := b.gauges.Swap(&cancel); old != nil {
+		(*old)()
+	}
+	namespaces := map[string]string{b.cfg.name: b.cfg.tenant}
+	samples := WatchMetrics(ctx, g.interval, &langSandbox{b: b})
+	go func() {
+		for round := range samples {
+			g.update(round, namespaces)
+		}
+		g.remove(b.cfg.name)
+	}()
+}
+
+// leaveGauges stops reading the metrics of a sandbox that no longer runs; its reading is dropped
+// once the reading in flight, if any, is done.
+func leaveGauges(b *baseMicroSandbox) {
+	if cancel := b.gauges.Swap(nil); cancel != nil {
+		(*cancel)()
+	}
+}
+
+// WatchMetricsGauges keeps the latest metrics of sandboxes, read every interval with
+// WatchMetrics, until ctx is done. Sandboxes that aren't running, or whose metrics can't be read,
+// have no reading, so their gauges stop being reported rather than going stale.
+func WatchMetricsGauges(ctx context.Context, interval time.Duration, sandboxes ...LangSandBox) *MetricsGauges {
+	g := NewMetricsGauges(0)
+	namespaces := make(map[string]string, len(sandboxes))
+	for _, sb := range sandboxes {
+		if ls, ok := sb.(*langSandbox); ok {
+			namespaces[ls.Name()] = ls.Namespace()
+		}
+	}
+	samples := WatchMetrics(ctx, interval, sandboxes...)
+	go func() {
+		for round := range samples {
+			g.update(round, namespaces)
+		}
+	}()
+	return g
+}
+
+func (g *MetricsGauges) update(round []MetricsSample, namespaces map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range round {
+		if s.Err != nil || !s.Metrics.IsRunning {
+			delete(g.readings, s.Sandbox)
+			continue
+		}
+		g.readings[s.Sandbox] = GaugeReading{
+			Sandbox:   s.Sandbox,
+			Namespace: namespaces[s.Sandbox],
+			Time:      s.Time,
+			Metrics: MetricsV2{
+				Name:        s.Metrics.Name,
+				IsRunning:   s.Metrics.IsRunning,
+				CPU:         s.Metrics.CPU,
+				MemoryBytes: uint64(s.Metrics.MemoryMiB) << 20,
+				DiskBytes:   uint64(s.Metrics.DiskBytes),
+				GPUs:        s.Metrics.GPUs,
+			},
+		}
+	}
+}
+
+func (g *MetricsGauges) remove(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.readings, name)
+}
+
+// Readings returns the latest reading of each sandbox with one, in no particular order.
+func (g *MetricsGauges) Readings() []GaugeReading {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	readings := make([]GaugeReading, 0, len(g.readings))
+	for _, r := range g.readings {
+		readings = append(readings, r)
+	}
+	return readings
+}
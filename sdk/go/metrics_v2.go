@@ -0,0 +1,44 @@
+package msb
+
+import "math"
+
+// MetricsV2 contains resource usage information for a sandbox, with all sizes as byte counts.
+// Unlike Metrics, it doesn't mix units, and its sizes don't overflow on 32-bit platforms.
+type MetricsV2 struct {
+	Name        string       // Sandbox name
+	IsRunning   bool         // Whether the sandbox is currently running
+	CPU         float64      // CPU usage percentage (0-100)
+	MemoryBytes uint64       // Memory usage in bytes
+	DiskBytes   uint64       // Disk usage in bytes
+	GPUs        []GPUMetrics // Usage of each passed-through GPU, when reported by the server
+}
+
+// MemoryMiB returns the memory usage in mebibytes.
+func (m MetricsV2) MemoryMiB() float64 {
+	return float64(m.MemoryBytes) / (1 << 20)
+}
+
+// DiskGiB returns the disk usage in gibibytes.
+func (m MetricsV2) DiskGiB() float64 {
+	return float64(m.DiskBytes) / (1 << 30)
+}
+
+// publicV2 converts the metrics to MetricsV2. The server reports memory in MiB.
+func (m *sandboxMetrics) publicV2() MetricsV2 {
+	return MetricsV2{
+		Name:        m.Name,
+		IsRunning:   m.Running,
+		CPU:         m.CPUUsage,
+		MemoryBytes: m.MemoryUsage << 20,
+		DiskBytes:   m.DiskUsage,
+		GPUs:        m.GPUs,
+	}
+}
+
+// clampInt converts n to an int, capping it at the largest int rather than wrapping around.
+func clampInt(n uint64) int {
+	if n > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(n)
+}
@@ -0,0 +1,176 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MountMode selects how a Mount is attached to the sandbox.
+type MountMode string
+
+const (
+	MountReadWrite MountMode = "rw"    // Host directory shared read-write (same as an entry in StartConfig.Volumes)
+	MountReadOnly  MountMode = "ro"    // Host directory shared read-only; writes from the sandbox fail
+	MountTmpfs     MountMode = "tmpfs" // Memory-backed scratch directory discarded when the sandbox stops
+)
+
+// Mount declares a directory mounted into the sandbox. Unlike the "host:guest" strings of
+// StartConfig.Volumes, mounts can be read-only or ephemeral:
+//
+//	msb.StartConfig{Mounts: []msb.Mount{
+//		msb.ReadOnlyMount("/data/corpus", "/corpus"),
+//		msb.TmpfsMount("/scratch", 256),
+//	}}
+//
+// Read-only and tmpfs mounts require server support; servers without it skip them, so the
+// directory is missing rather than writable.
+type Mount struct {
+	Source  string    `json:"source,omitempty"`   // Host path; empty for tmpfs mounts
+	Target  string    `json:"target"`             // Absolute path inside the sandbox
+	Mode    MountMode `json:"mode"`               // Mount mode; defaults to MountReadWrite
+	SizeMiB int       `json:"size_mib,omitempty"` // Size limit of tmpfs mounts; 0 lets the server decide
+}
+
+// ReadOnlyMount shares the host directory source read-only at target.
+func ReadOnlyMount(source, target string) Mount {
+	return Mount{Source: source, Target: target, Mode: MountReadOnly}
+}
+
+// TmpfsMount creates an ephemeral, memory-backed directory at target, limited to sizeMiB (0 for the server default).
+func TmpfsMount(target string, sizeMiB int) Mount {
+	return Mount{Target: target, Mode: MountTmpfs, SizeMiB: sizeMiB}
+}
+
+func (m Mount) validate() error {
+	if !path.IsAbs(m.Target) {
+		return fmt.Errorf("%w: target %q must be an absolute path", ErrInvalidMount, m.Target)
+	}
+	switch m.Mode {
+	case "", MountReadWrite, MountReadOnly:
+		if m.Source == "" {
+			return fmt.Errorf("%w: %s: source must be specified", ErrInvalidMount, m.Target)
+		}
+	case MountTmpfs:
+		if m.Source != "" {
+			return fmt.Errorf("%w: %s: tmpfs mounts have no source", ErrInvalidMount, m.Target)
+		}
+	default:
+		return fmt.Errorf("%w: %s: unknown mode %q", ErrInvalidMount, m.Target, m.Mode)
+	}
+	if m.SizeMiB < 0 || (m.SizeMiB > 0 && m.Mode != MountTmpfs) {
+		return fmt.Errorf("%w: %s: size only applies to tmpfs mounts", ErrInvalidMount, m.Target)
+	}
+	return nil
+}
+
+// splitMounts validates mounts and splits them into plain read-write volumes, which every server
+// understands, and the ones that need mount-mode support. Host paths are normalized with
+// normalizeHostPath.
+func splitMounts(mounts []Mount, localServer bool) (volumes []string, special []Mount, err error) {
+	for _, m := range mounts {
+		if err := m.validate(); err != nil {
+			return nil, nil, err
+		}
+		if m.Source != "" {
+			if m.Source, err = normalizeHostPath(m.Source, localServer); err != nil {
+				return nil, nil, fmt.Errorf("%w: %s: %w", ErrInvalidMount, m.Target, err)
+			}
+		}
+		if m.Mode == "" || m.Mode == MountReadWrite {
+			volumes = append(volumes, m.Source+":"+m.Target)
+			continue
+		}
+		special = append(special, m)
+	}
+	return volumes, special, nil
+}
+
+// normalizeVolumes normalizes the host paths of "host:guest" volume specs with normalizeHostPath.
+// Specs in other forms, such as a lone path or one with options, are passed on unchanged for the
+// server to interpret.
+func normalizeVolumes(specs []string, localServer bool) ([]string, error) {
+	volumes := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		host, guest, ok := splitVolume(spec)
+		if !ok {
+			volumes = append(volumes, spec)
+			continue
+		}
+		host, err := normalizeHostPath(host, localServer)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidMount, spec, err)
+		}
+		volumes = append(volumes, host+":"+guest)
+	}
+	return volumes, nil
+}
+
+// splitVolume splits a "host:guest" volume spec, reporting whether it is in that form. The host
+// path may start with a Windows drive letter, whose colon is not a separator.
+func splitVolume(spec string) (host, guest string, ok bool) {
+	rest := spec
+	if hasDriveLetter(spec) {
+		rest = spec[2:]
+	}
+	i := strings.Index(rest, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	host, guest = spec[:len(spec)-len(rest)+i], rest[i+1:]
+	if host == "" || strings.Contains(guest, ":") {
+		return "", "", false
+	}
+	return host, guest, true
+}
+
+// normalizeHostPath turns an absolute host path into the cleaned, slash-separated form the server
+// expects. Windows paths keep their drive letter ("C:/data"). When the server runs on this
+// machine, symlinks in paths that exist are resolved, as the hypervisor refuses to share symlinked
+// directories (e.g. /tmp on macOS). Other paths, such as relative ones or volume names, are
+// returned unchanged for the server to interpret.
+func normalizeHostPath(p string, localServer bool) (string, error) {
+	switch {
+	case hasDriveLetter(p) && len(p) > 2 && (p[2] == '/' || p[2] == '\\'):
+		slashed := strings.ReplaceAll(p, `\`, "/")
+		p = slashed[:2] + path.Clean(slashed[2:])
+	case path.IsAbs(p):
+		p = path.Clean(p)
+	default:
+		return p, nil
+	}
+	if localServer {
+		if resolved, err := filepath.EvalSymlinks(filepath.FromSlash(p)); err == nil {
+			p = filepath.ToSlash(resolved)
+		}
+	}
+	rest := p
+	if hasDriveLetter(p) {
+		rest = p[2:]
+	}
+	if strings.Contains(rest, ":") {
+		return "", fmt.Errorf("host path %q must not contain ':'", p)
+	}
+	return p, nil
+}
+
+// isLocalServer reports whether serverURL points at this machine, so host paths can be checked locally.
+func isLocalServer(serverURL string) bool {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return false
+	}
+	return isLoopbackHost(u.Hostname())
+}
+
+func hasDriveLetter(p string) bool {
+	return len(p) >= 2 && p[1] == ':' && (p[0] >= 'A' && p[0] <= 'Z' || p[0] >= 'a' && p[0] <= 'z')
+}
+
+// Mount-related errors
+var (
+	ErrInvalidMount = errors.New("invalid mount")
+)
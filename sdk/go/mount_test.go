@@ -0,0 +1,100 @@
+package msb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeVolumes(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"/data:/data", "/data:/data"},
+		{"/data/../srv/./x:/x", "/srv/x:/x"},
+		{"/data", "/data"},                   // lone path
+		{"/data:/data:ro", "/data:/data:ro"}, // options
+		{"./data:/data", "./data:/data"},     // relative host path
+		{"data:/data", "data:/data"},         // volume name
+		{"/data:relative", "/data:relative"}, // guest path is the server's to check
+		{":/data", ":/data"},                 // no host path
+		{`C:\Users\me\data:/data`, "C:/Users/me/data:/data"},
+		{"C:/data/../srv:/srv", "C:/srv:/srv"},
+		{`C:data:/data`, `C:data:/data`}, // drive-relative
+		{`\\server\share:/data`, `\\server\share:/data`},
+	}
+	for _, tt := range tests {
+		got, err := normalizeVolumes([]string{tt.spec}, false)
+		if err != nil {
+			t.Errorf("normalizeVolumes(%q): %v", tt.spec, err)
+			continue
+		}
+		if got[0] != tt.want {
+			t.Errorf("normalizeVolumes(%q) = %q, want %q", tt.spec, got[0], tt.want)
+		}
+	}
+}
+
+func TestNormalizeVolumesLocalServer(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "real")
+	if err := os.Mkdir(real, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved := filepath.ToSlash(filepath.Join(resolvedDir, "real"))
+	missing := filepath.ToSlash(filepath.Join(dir, "missing"))
+
+	got, err := normalizeVolumes([]string{
+		filepath.ToSlash(link) + ":/data",
+		missing + ":/data",
+		"relative:/data",
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{resolved + ":/data", missing + ":/data", "relative:/data"}
+	if !slices.Equal(got, want) {
+		t.Errorf("normalizeVolumes = %q, want %q", got, want)
+	}
+}
+
+func TestSplitMounts(t *testing.T) {
+	volumes, special, err := splitMounts([]Mount{
+		{Source: "/data/./in", Target: "/in"},
+		ReadOnlyMount("relative", "/ro"),
+		TmpfsMount("/scratch", 64),
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/data/in:/in"}; !slices.Equal(volumes, want) {
+		t.Errorf("volumes = %q, want %q", volumes, want)
+	}
+	if len(special) != 2 || special[0].Source != "relative" || special[1].Mode != MountTmpfs {
+		t.Errorf("special mounts = %+v", special)
+	}
+
+	for _, m := range []Mount{
+		{Source: "/a:b", Target: "/x"},
+		{Target: "relative"},
+		{Target: "/x"},
+		{Source: "/a", Target: "/x", Mode: MountTmpfs},
+		{Source: "/a", Target: "/x", SizeMiB: 1},
+		{Source: "/a", Target: "/x", Mode: "bogus"},
+	} {
+		if _, _, err := splitMounts([]Mount{m}, false); !errors.Is(err, ErrInvalidMount) {
+			t.Errorf("splitMounts(%+v) = %v, want ErrInvalidMount", m, err)
+		}
+	}
+}
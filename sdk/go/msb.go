@@ -40,8 +40,12 @@ package msb
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 )
 
 // Core sandbox interfaces
@@ -51,13 +55,26 @@ type (
 		// Start initializes the sandbox with the specified configuration.
 		// If Image is empty, uses the default image for the configured language.
 		// If Memory <= 0, defaults to 512. If CPUs <= 0, defaults to 1.
+		// Calls overlapping a Start in progress on the same handle send no request of their own
+		// and return its result, whatever their config; a Start during a Stop waits for it.
 		Start(config StartConfig) error
+		// StartContext is like Start but carries ctx into the underlying request.
+		StartContext(ctx context.Context, config StartConfig) error
+		// StartSimple is Start with the image, memory limit in MB and CPU limit as the only
+		// settings, the form earlier releases of the SDK took them in. It stays supported.
+		StartSimple(image string, memory, cpus int) error
 	}
 
 	// Stopper manages sandbox lifecycle shutdown.
 	Stopper interface {
-		// Stop terminates the sandbox and releases its resources.
+		// Stop terminates the sandbox and releases its resources. Like Start, calls overlapping a
+		// Stop in progress on the same handle return its result.
 		Stop() error
+		// StopContext is like Stop but carries ctx into the underlying request.
+		StopContext(ctx context.Context) error
+		// StopWithOptions is like StopContext but runs opts' finalizers, rather than those set
+		// with WithStopOptions, before the sandbox is torn down.
+		StopWithOptions(ctx context.Context, opts StopOptions) error
 	}
 
 	// CodeRunner executes code in the sandbox's REPL environment.
@@ -65,6 +82,19 @@ type (
 		// Run executes the provided code and returns detailed execution results.
 		// The sandbox must be started before calling this method.
 		Run(code string) (CodeExecution, error)
+		// RunContext is like Run but carries ctx into the underlying request,
+		// allowing callers to cancel or bound in-flight executions.
+		RunContext(ctx context.Context, code string) (CodeExecution, error)
+		// RunNotebook runs the code cells of a Jupyter notebook (.ipynb) in order, stopping at the
+		// first that fails, and returns the result of every cell.
+		RunNotebook(ctx context.Context, notebook []byte) (NotebookResult, error)
+		// Format formats code without running it, with black for Python and prettier for
+		// JavaScript as installed in the sandbox. Code that can't be parsed is returned as it is,
+		// with the syntax error as a diagnostic.
+		Format(ctx context.Context, code string) (string, []Diagnostic, error)
+		// Lint checks code without running it and returns the problems found: syntax errors,
+		// and with pyflakes or eslint installed in the sandbox, their findings.
+		Lint(ctx context.Context, code string) ([]Diagnostic, error)
 	}
 
 	// CommandRunner executes shell commands in the sandbox.
@@ -72,29 +102,65 @@ type (
 		// Run executes a shell command with the given arguments.
 		// The sandbox must be started before calling this method.
 		Run(cmd string, args []string) (CommandExecution, error)
+		// RunWithOptions executes a command like Run, but bounded by ctx and with its output
+		// written to host files as it is received, as set in opts.
+		RunWithOptions(ctx context.Context, cmd string, args []string, opts RunOptions) (CommandExecution, error)
+		// Pipeline runs the stages as a shell pipeline (stage1 | stage2 | ...) and reports the
+		// exit code of every stage. Arguments are quoted, so no shell string needs to be assembled.
+		Pipeline(ctx context.Context, stages ...CmdSpec) (PipelineExecution, error)
+		// Start runs a command in the background and returns immediately. ctx bounds the
+		// command's whole run; use the returned Process to wait for it or signal it.
+		Start(ctx context.Context, cmd string, args []string) (*Process, error)
+		// As returns a runner whose commands run as user ("name", "uid" or "uid:gid") instead of
+		// the sandbox's StartConfig.User, e.g. As(msb.RootUser) to escalate a single command.
+		// An invalid user makes every command fail with ErrInvalidUser.
+		As(user string) CommandRunner
+		// WithEnv returns a runner whose commands see env on top of the sandbox's environment,
+		// overriding variables of the same name. Commands are run through env(1) to apply it.
+		WithEnv(env map[string]string) CommandRunner
 	}
 
 	// MetricsReader provides access to sandbox resource metrics.
 	MetricsReader interface {
 		// All returns comprehensive metrics for the sandbox.
 		All() (Metrics, error)
+		// AllV2 is like All but reports sizes as byte counts that can't overflow.
+		AllV2() (MetricsV2, error)
 		// CPU returns current CPU usage as a percentage (0-100).
 		CPU() (float64, error)
 		// MemoryMiB returns current memory usage in mebibytes.
+		//
+		// Deprecated: Use AllV2 and MetricsV2.MemoryBytes.
 		MemoryMiB() (int, error)
-		// DiskBytes returns current disk usage in bytes.
+		// DiskBytes returns current disk usage in bytes, capped at the largest int.
+		//
+		// Deprecated: Use AllV2 and MetricsV2.DiskBytes.
 		DiskBytes() (int, error)
 		// IsRunning reports whether the sandbox is currently running.
 		IsRunning() (bool, error)
+		// GPUs returns usage of the GPUs passed through to the sandbox; empty if it has none or
+		// the server doesn't report GPU metrics.
+		GPUs() ([]GPUMetrics, error)
 	}
 
-	// Metrics contains resource usage information for a sandbox.
+	// Metrics contains resource usage information for a sandbox. Its sizes mix units and
+	// overflow on 32-bit platforms; MetricsV2, returned by MetricsReader.AllV2, replaces it.
 	Metrics struct {
 		Name      string  // Sandbox name
 		IsRunning bool    // Whether the sandbox is currently running
 		CPU       float64 // CPU usage percentage (0-100)
-		MemoryMiB int     // Memory usage in mebibytes
-		DiskBytes int     // Disk usage in bytes
+
+		// Memory usage in mebibytes.
+		//
+		// Deprecated: Use MetricsV2.MemoryBytes.
+		MemoryMiB int
+
+		// Disk usage in bytes, capped at the largest int.
+		//
+		// Deprecated: Use MetricsV2.DiskBytes.
+		DiskBytes int
+
+		GPUs []GPUMetrics // Usage of each passed-through GPU, when reported by the server
 	}
 )
 
@@ -104,113 +170,461 @@ type StartConfig struct {
 	Memory    int               // Memory limit in MB
 	CPUs      int               // CPU limit
 	Volumes   []string          // Volumes to mount
+	Mounts    []Mount           // Volumes to mount with a mode (read-only, tmpfs)
+	Network   NetworkPolicy     // Egress policy; the zero value leaves it to the server
+	DNS       DNSConfig         // Resolver and /etc/hosts overrides
+	GPUs      GPUConfig         // GPU devices to pass through
+	DiskMiB   int               // Root filesystem size limit in MiB (0 = server default)
+	SwapMiB   int               // Swap limit in MiB (0 = server default)
+	Limits    Limits            // ulimits for processes in the sandbox
+	User      string            // User code and commands run as ("1000:1000" or a name); empty for the image's default, usually root
+	Timezone  string            // IANA time zone, e.g. "Europe/Berlin"; the image needs tzdata for zones other than UTC
+	Locale    string            // Locale, e.g. "en_US.UTF-8"; must be available in the image
 	Ports     []string          // Ports to expose
-	Envs      []string          // Environment variables to use
+	Envs      []string          // Environment variables to use, as "K=V"
+	Env       map[string]string // Environment variables to use; overrides Envs entries of the same name
 	DependsOn []string          // Sandboxes to depend on
 	Workdir   string            // Working directory to use
 	Shell     string            // Shell to use
 	Scripts   map[string]string // Scripts that can be run
 	Exec      string            // Exec command to run
+
+	// PullPolicy decides when the server pulls Image. PullNever pins the sandbox to images already
+	// cached on the server; the zero value leaves it to the server.
+	PullPolicy PullPolicy
+
+	// Platform selects the image variant of multi-arch images ("linux/arm64", "linux/amd64"),
+	// instead of the server's native platform.
+	Platform string
+
+	// StartTimeout bounds the whole start, including image pulls and waiting for a sandbox the
+	// server reports as still initializing. Zero means no limit beyond the caller's context,
+	// except that without any deadline the wait for an initializing sandbox gives up after 15
+	// minutes with ErrStartTimedOut. A sandbox that stops or crashes while initializing fails the
+	// start right away.
+	StartTimeout time.Duration
+
+	// ExecTimeout bounds every code or command execution on the sandbox whose context has no
+	// earlier deadline. Background processes (Command().Start) are not affected. Zero means no limit.
+	ExecTimeout time.Duration
+
+	// OnNameConflict decides what happens when a sandbox with the same name is already running
+	// on the server. The zero value skips the check and leaves the outcome to the server.
+	OnNameConflict NameConflictPolicy
+
+	// InitCommands and InitCode warm the sandbox up once it runs, before Start returns, so a
+	// started sandbox is ready for its workload: the commands first (e.g. to seed data), then
+	// the code in the REPL (e.g. imports and model loading). They count against StartTimeout. If
+	// one fails, the sandbox is stopped again and Start fails with ErrInitFailed. Sandboxes
+	// attached to with NameConflictAttach are assumed to be warm already.
+	InitCommands []CmdSpec
+	InitCode     string
 }
 
 // --- API Implementation ---
 
 type starter struct {
 	b *baseMicroSandbox
+	l progLang // language of the REPL InitCode runs in
 }
 
 func (s starter) Start(cfg StartConfig) error {
+	return s.StartContext(context.Background(), cfg)
+}
+
+func (s starter) StartSimple(image string, memory, cpus int) error {
+	return s.Start(StartConfig{Image: image, Memory: memory, CPUs: cpus})
+}
+
+func (s starter) StartContext(ctx context.Context, cfg StartConfig) error {
+	return s.b.lifecycle.do(ctx, false, func() error {
+		begin := time.Now()
+		err := s.start(ctx, cfg)
+		s.b.audit(ctx, begin, err, AuditEvent{Action: AuditStart, Image: cfg.Image})
+		return err
+	})
+}
+
+func (s starter) start(ctx context.Context, cfg StartConfig) error {
 	if s.b.state.Load() == started {
 		return ErrSandboxAlreadyStarted
 	}
+	if s.b.cfg.nameErr != nil {
+		return s.b.cfg.nameErr
+	}
+	sc, err := cfg.params(isLocalServer(s.b.cfg.serverUrl))
+	if err != nil {
+		return err
+	}
+
+	begin := time.Now()
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = s.b.cfg.timeouts.Start
+	}
+	if cfg.StartTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, cfg.StartTimeout, ErrStartTimedOut)
+		defer cancel()
+	}
+	fail := func(phase StartPhase, err error) error {
+		if cause := context.Cause(ctx); errors.Is(cause, ErrStartTimedOut) {
+			err = fmt.Errorf("%w: %w", cause, err)
+		}
+		return &StartError{Sandbox: s.b.cfg.name, Phase: phase, Elapsed: time.Since(begin), Err: err}
+	}
+
+	if !liveNames.reserve(s.b.cfg.serverUrl, s.b.cfg.name) {
+		return fail(PhaseStarting, &NameConflictError{Name: s.b.cfg.name})
+	}
+
+	observeStartPhase(ctx, PhaseStarting)
+	if cfg.OnNameConflict != NameConflictUnchecked {
+		attached, err := resolveNameConflict(ctx, s.b, cfg.OnNameConflict)
+		if err != nil {
+			liveNames.release(s.b.cfg.serverUrl, s.b.cfg.name)
+			return fail(PhaseStarting, err)
+		}
+		if attached {
+			if err := s.adopt(ctx, cfg, sc); err != nil {
+				// the sandbox isn't this handle's to stop
+				abandon(s.b)
+				return fail(PhaseInitializing, err)
+			}
+			return nil
+		}
+	}
+
+	stopWatch := watchPull(ctx, s.b, cfg.Image)
+	defer stopWatch()
+	result, err := s.b.rpcClient.startSandbox(ctx, &s.b.cfg, sc)
+	if err != nil {
+		liveNames.release(s.b.cfg.serverUrl, s.b.cfg.name)
+		switch {
+		case cfg.Platform != "" && isPlatformMissing(err):
+			err = &PlatformNotAvailableError{Image: cfg.Image, Platform: cfg.Platform, Err: err}
+		case cfg.PullPolicy == PullNever && isImageMissing(err):
+			err = &ImageNotPresentError{Image: cfg.Image, Policy: cfg.PullPolicy, Err: err}
+		}
+		return fail(PhaseStarting, err)
+	}
+	// from here on the server owns a sandbox under this name, so Stop must be able to reclaim it
+	info := newSandboxInfo(s.b.cfg.name, cfg, sc, false)
+	info.Local, info.Container = result.local, result.container
+	s.b.info.Store(info)
+	s.b.state.Store(started)
+	if err := holdLease(ctx, s.b); err != nil {
+		if stopErr := (stopper{b: s.b}).stop(context.WithoutCancel(ctx)); stopErr != nil {
+			s.b.cfg.log(ctx).Error("Failed to stop sandbox after failed lease", "name", s.b.cfg.name, "error", stopErr)
+		}
+		return fail(PhaseInitializing, err)
+	}
+
+	if result.pending {
+		observeStartPhase(ctx, PhaseInitializing)
+		if err := waitUntilRunning(ctx, s.b); err != nil {
+			return fail(PhaseInitializing, err)
+		}
+	}
+	if len(cfg.InitCommands) > 0 || strings.TrimSpace(cfg.InitCode) != "" {
+		observeStartPhase(ctx, PhaseInitializing)
+		err := runSteps(ctx, codeRunner{s.b, s.l}, commandRunner{b: s.b}, cfg.InitCommands, cfg.InitCode)
+		if err != nil {
+			if stopErr := (stopper{b: s.b}).stop(context.WithoutCancel(ctx)); stopErr != nil {
+				s.b.cfg.log(ctx).Error("Failed to stop sandbox after failed initialization", "name", s.b.cfg.name, "error", stopErr)
+			}
+			return fail(PhaseInitializing, fmt.Errorf("%w: %w", ErrInitFailed, err))
+		}
+	}
+	// only now is the sandbox known to run: pings while it is still being pulled or booted could
+	// take it for stopped
+	startKeepAlive(s.b)
+	joinGauges(s.b)
+	return nil
+}
+
+// adopt makes the handle drive the sandbox of its name already running on the server, recording
+// cfg and sc as what the handle requested. The name must be reserved.
+func (s starter) adopt(ctx context.Context, cfg StartConfig, sc startConfig) error {
+	s.b.info.Store(newSandboxInfo(s.b.cfg.name, cfg, sc, true))
+	s.b.state.Store(started)
+	if err := holdLease(ctx, s.b); err != nil {
+		return err
+	}
+	startKeepAlive(s.b)
+	joinGauges(s.b)
+	return nil
+}
+
+// params validates cfg and converts it to the start RPC's parameters, filling in the default
+// memory and CPU limits. localServer is whether the server runs on this machine, which decides how
+// host paths in volumes are checked.
+func (cfg StartConfig) params(localServer bool) (startConfig, error) {
 	if cfg.Memory <= 0 {
 		cfg.Memory = 512
 	}
 	if cfg.CPUs <= 0 {
 		cfg.CPUs = 1
 	}
-	sc := startConfig{
+	volumes, mounts, err := splitMounts(cfg.Mounts, localServer)
+	if err != nil {
+		return startConfig{}, err
+	}
+	plainVolumes, err := normalizeVolumes(cfg.Volumes, localServer)
+	if err != nil {
+		return startConfig{}, err
+	}
+	if err := cfg.Network.validate(); err != nil {
+		return startConfig{}, err
+	}
+	if err := cfg.DNS.validate(); err != nil {
+		return startConfig{}, err
+	}
+	if err := cfg.GPUs.validate(); err != nil {
+		return startConfig{}, err
+	}
+	if err := cfg.Limits.validate(); err != nil {
+		return startConfig{}, err
+	}
+	if err := validateUser(cfg.User); err != nil {
+		return startConfig{}, err
+	}
+	envs, err := mergeEnvs(cfg.Envs, cfg.Env)
+	if err != nil {
+		return startConfig{}, err
+	}
+	if err := cfg.PullPolicy.validate(); err != nil {
+		return startConfig{}, err
+	}
+	if err := validatePlatform(cfg.Platform); err != nil {
+		return startConfig{}, err
+	}
+	if err := validateTimezone(cfg.Timezone); err != nil {
+		return startConfig{}, err
+	}
+	if err := validateLocale(cfg.Locale); err != nil {
+		return startConfig{}, err
+	}
+	return startConfig{
 		Image:     cfg.Image,
 		Memory:    cfg.Memory,
 		CPUs:      cfg.CPUs,
-		Volumes:   cfg.Volumes,
+		Volumes:   append(plainVolumes, volumes...),
+		Mounts:    mounts,
+		Network:   cfg.Network.rpcParam(),
+		DNS:       cfg.DNS.rpcParam(),
+		GPUs:      cfg.GPUs.rpcParam(),
+		DiskMiB:   max(cfg.DiskMiB, 0),
+		SwapMiB:   max(cfg.SwapMiB, 0),
+		Limits:    cfg.Limits.rpcParam(),
+		User:      cfg.User,
 		Ports:     cfg.Ports,
-		Envs:      cfg.Envs,
+		Envs:      localeEnvs(envs, cfg.Timezone, cfg.Locale),
 		DependsOn: cfg.DependsOn,
 		Workdir:   cfg.Workdir,
 		Shell:     cfg.Shell,
 		Scripts:   cfg.Scripts,
 		Exec:      cfg.Exec,
-	}
-	err := s.b.rpcClient.startSandbox(context.Background(), &s.b.cfg, sc)
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrFailedToStartSandbox, err)
-	}
-	s.b.state.Store(started)
-	return nil
+
+		PullPolicy: cfg.PullPolicy,
+		Platform:   cfg.Platform,
+	}, nil
 }
 
 type stopper struct {
 	b *baseMicroSandbox
+	l progLang
 }
 
 func (s stopper) Stop() error {
+	return s.StopContext(context.Background())
+}
+
+func (s stopper) StopContext(ctx context.Context) error {
+	return s.StopWithOptions(ctx, s.b.cfg.stopOptions)
+}
+
+func (s stopper) StopWithOptions(ctx context.Context, opts StopOptions) error {
+	return s.b.lifecycle.do(ctx, true, func() error {
+		finalizeErr := s.finalize(ctx, opts)
+		begin := time.Now()
+		err := s.stop(ctx)
+		s.b.audit(ctx, begin, err, AuditEvent{Action: AuditStop})
+		return errors.Join(finalizeErr, err)
+	})
+}
+
+func (s stopper) stop(ctx context.Context) error {
 	if s.b.state.Load() == off {
 		return ErrSandboxNotStarted
 	}
-	ctx := context.Background()
+	ctx, cancel := withTimeout(ctx, s.b.cfg.timeouts.Stop)
+	defer cancel()
 	err := s.b.rpcClient.stopSandbox(ctx, &s.b.cfg)
 	if err != nil {
 		return fmt.Errorf("%w: %w", ErrFailedToStopSandbox, err)
 	}
 	s.b.state.Store(off)
+	markStopped(s.b)
 	return nil
 }
 
+// markStopped releases what the handle holds for its sandbox, once the handle has been moved to
+// the stopped state because the sandbox is gone or was let go of.
+func markStopped(b *baseMicroSandbox) {
+	dropLease(b)
+	stopKeepAlive(b)
+	leaveGauges(b)
+	b.locks.dropAll()
+	b.venv.Store(nil)
+	b.node.Store(nil)
+	b.image.Store(nil)
+	b.envGen.Store(nil)
+	liveNames.release(b.cfg.serverUrl, b.cfg.name)
+}
+
 type codeRunner struct {
 	b *baseMicroSandbox
 	l progLang
 }
 
 func (cr codeRunner) Run(code string) (CodeExecution, error) {
+	return cr.RunContext(context.Background(), code)
+}
+
+func (cr codeRunner) RunContext(ctx context.Context, code string) (CodeExecution, error) {
+	begin := time.Now()
+	exec, err := cr.run(ctx, code)
+	if cr.b.cfg.auditSink != nil {
+		event := AuditEvent{Action: AuditRunCode, Language: cr.l.String(), CodeSHA256: codeSHA256(code), CodeBytes: len(code)}
+		cr.b.audit(ctx, begin, err, event)
+	}
+	return exec, err
+}
+
+func (cr codeRunner) run(ctx context.Context, code string) (CodeExecution, error) {
 	if cr.b.state.Load() != started {
 		return CodeExecution{}, ErrSandboxNotStarted
 	}
-	ctx := context.Background()
-	result, err := cr.b.rpcClient.runRepl(ctx, &cr.b.cfg, cr.l, code)
+	if err := cr.screen(ctx, code); err != nil {
+		return CodeExecution{}, err
+	}
+	cacheKey := cr.execCacheKey(ctx, code)
+	if cacheKey != "" {
+		if exec, ok := cr.cachedExecution(ctx, cacheKey); ok {
+			return exec, nil
+		}
+	}
+	ctx, cancel := cr.b.execContext(ctx)
+	defer cancel()
+	ctx, done := cr.b.calls.track(ctx, inflightCall{lang: cr.l})
+	defer done()
+	var result *executionResult
+	var err error
+	if cr.b.cfg.chunked(code) {
+		result, err = cr.runChunked(ctx, code)
+	} else {
+		result, err = cr.b.rpcClient.runRepl(ctx, &cr.b.cfg, cr.l, code)
+	}
 	if err != nil {
-		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, cr.b.crashErr(ctx, abortedErr(ctx, err)))
 	}
+	result.request = execRequest{Language: cr.l.String(), Code: code, lang: cr.l}
 
-	exec := CodeExecution{Output: result.output}
-	// Parse the output for convenience methods
-	if err := json.Unmarshal(result.output, &exec.parsed); err == nil {
-		exec.parsedOK = true
-	}
+	exec := cr.b.codeExecution(ctx, result)
 
+	stderr, _ := exec.GetError()
+	if err := diskQuotaErr(exec.HasError(), stderr); err != nil {
+		return exec, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	if cacheKey != "" {
+		cr.cacheExecution(ctx, cacheKey, exec)
+	}
 	return exec, nil
 }
 
 type commandRunner struct {
-	b *baseMicroSandbox
+	b       *baseMicroSandbox
+	user    string            // user commands run as; empty for the sandbox's StartConfig.User
+	env     map[string]string // variables added to the sandbox's environment
+	pidFile string            // PID file of the background process the command launches, if any
+}
+
+func (cr commandRunner) As(user string) CommandRunner {
+	cr.user = user
+	return cr
+}
+
+func (cr commandRunner) WithEnv(env map[string]string) CommandRunner {
+	merged := maps.Clone(cr.env)
+	if merged == nil {
+		merged = make(map[string]string, len(env))
+	}
+	maps.Copy(merged, env)
+	cr.env = merged
+	return cr
+}
+
+// validate checks the settings of runners derived with As and WithEnv.
+func (cr commandRunner) validate() error {
+	if err := validateUser(cr.user); err != nil {
+		return err
+	}
+	for name := range cr.env {
+		if err := validateEnvName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs a command with the runner's user and environment.
+func (cr commandRunner) run(ctx context.Context, cmd string, args []string) (*executionResult, error) {
+	return cr.runTo(ctx, cmd, args, nil)
+}
+
+// runTo runs a command like run, passing its output to sink unless that is nil.
+func (cr commandRunner) runTo(ctx context.Context, cmd string, args []string, sink outputSink) (result *executionResult, err error) {
+	begin := time.Now()
+	event := AuditEvent{Action: AuditRunCommand, Command: cmd, Args: args, User: cr.user}
+	defer func() { cr.b.audit(ctx, begin, err, event) }()
+	// the request is recorded as the caller made it, with the names of the variables but not
+	// their values, which may be secrets
+	env := cr.b.toolEnv(cr.env)
+	request := execRequest{Command: cmd, Args: args, User: cr.user, Env: slices.Sorted(maps.Keys(env))}
+	// env(1) execs the command, which then runs with the caller's command line
+	ctx, done := cr.b.calls.track(ctx, inflightCall{argv: append([]string{cmd}, args...), pidFile: cr.pidFile})
+	defer done()
+	cmd, args = withEnv(env, cmd, args)
+	result, err = cr.b.rpcClient.runCommandTo(ctx, &cr.b.cfg, cr.user, cmd, args, sink)
+	if err != nil {
+		return nil, cr.b.crashErr(ctx, abortedErr(ctx, err))
+	}
+	result.request = request
+	return result, nil
 }
 
 func (cr commandRunner) Run(cmd string, args []string) (CommandExecution, error) {
 	if cr.b.state.Load() != started {
 		return CommandExecution{}, ErrSandboxNotStarted
 	}
-	ctx := context.Background()
-	result, err := cr.b.rpcClient.runCommand(ctx, &cr.b.cfg, cmd, args)
+	if err := cr.validate(); err != nil {
+		return CommandExecution{}, err
+	}
+	if err := cr.b.cfg.policy.check(cmd, args); err != nil {
+		return CommandExecution{}, err
+	}
+	ctx, cancel := cr.b.execContext(context.Background())
+	defer cancel()
+	result, err := cr.run(ctx, cmd, args)
 	if err != nil {
 		return CommandExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
 	}
 
-	exec := CommandExecution{Output: result.output}
-	// Parse the output for convenience methods
-	if err := json.Unmarshal(result.output, &exec.parsed); err == nil {
-		exec.parsedOK = true
+	exec := cr.b.commandExecution(ctx, result)
+	stderr, _ := exec.GetError()
+	if err := diskQuotaErr(!exec.IsSuccess(), stderr); err != nil {
+		return exec, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
 	}
-
 	return exec, nil
 }
 
@@ -223,19 +637,29 @@ func (mr metricsReader) All() (Metrics, error) {
 		return Metrics{}, ErrSandboxNotStarted
 	}
 
-	ctx := context.Background()
+	ctx, cancel := withTimeout(context.Background(), mr.b.cfg.timeouts.Metrics)
+	defer cancel()
 	metrics, err := mr.b.rpcClient.getMetrics(ctx, &mr.b.cfg)
 	if err != nil {
 		return Metrics{}, fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
 	}
 
-	return Metrics{
-		Name:      metrics.Name,
-		IsRunning: metrics.Running,
-		CPU:       metrics.CPUUsage,
-		MemoryMiB: metrics.MemoryUsage,
-		DiskBytes: metrics.DiskUsage,
-	}, nil
+	return metrics.public(), nil
+}
+
+func (mr metricsReader) AllV2() (MetricsV2, error) {
+	if mr.b.state.Load() != started {
+		return MetricsV2{}, ErrSandboxNotStarted
+	}
+
+	ctx, cancel := withTimeout(context.Background(), mr.b.cfg.timeouts.Metrics)
+	defer cancel()
+	metrics, err := mr.b.rpcClient.getMetrics(ctx, &mr.b.cfg)
+	if err != nil {
+		return MetricsV2{}, fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
+	}
+
+	return metrics.publicV2(), nil
 }
 
 func (mr metricsReader) CPU() (float64, error) {
@@ -269,3 +693,11 @@ func (mr metricsReader) IsRunning() (bool, error) {
 	}
 	return metrics.IsRunning, nil
 }
+
+func (mr metricsReader) GPUs() ([]GPUMetrics, error) {
+	metrics, err := mr.All()
+	if err != nil {
+		return nil, err
+	}
+	return metrics.GPUs, nil
+}
@@ -0,0 +1,146 @@
+package msb
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+// The benchmarks run against the in-process fake server from msbtest, measuring the SDK's
+// client-side overhead rather than sandbox execution time, unless MSB_BENCH_SERVER points them
+// at a real server; MSB_BENCH_API_KEY and MSB_BENCH_IMAGE then give its API key and the image to
+// start sandboxes from. cmd/bench runs them and checks the results for regressions.
+
+// benchTarget is the server the benchmarks run against.
+type benchTarget struct {
+	serverURL string // empty for a fresh fake server per benchmark
+	apiKey    string
+	image     string
+}
+
+func benchServer() benchTarget {
+	return benchTarget{
+		serverURL: os.Getenv("MSB_BENCH_SERVER"),
+		apiKey:    os.Getenv("MSB_BENCH_API_KEY"),
+		image:     os.Getenv("MSB_BENCH_IMAGE"),
+	}
+}
+
+func (t benchTarget) startConfig() StartConfig {
+	return StartConfig{Image: t.image}
+}
+
+// connect returns a constructor for sandboxes on the target server, and a function releasing the
+// server once the benchmark is done.
+func (t benchTarget) connect() (func() LangSandBox, func()) {
+	if t.serverURL != "" {
+		client := NewClient(WithServerUrl(t.serverURL), WithApiKey(t.apiKey))
+		return func() LangSandBox { return client.NewPythonSandbox() }, func() {}
+	}
+	srv := msbtest.NewServer()
+	client := NewClient(WithServerUrl(srv.URL), WithApiKey("bench"))
+	return func() LangSandBox { return client.NewPythonSandbox() }, srv.Close
+}
+
+func (t benchTarget) startSandbox(b *testing.B) LangSandBox {
+	newSandbox, cleanup := t.connect()
+	b.Cleanup(cleanup)
+	sandbox := newSandbox()
+	if err := sandbox.Start(t.startConfig()); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = sandbox.Stop() })
+	return sandbox
+}
+
+// reportThroughput reports round-trips per second, which reads better than ns/op for real servers.
+func reportThroughput(b *testing.B) {
+	if elapsed := b.Elapsed(); elapsed > 0 {
+		b.ReportMetric(float64(b.N)/elapsed.Seconds(), "runs/s")
+	}
+}
+
+// BenchmarkStart measures starting a sandbox; stopping it again is not timed.
+func BenchmarkStart(b *testing.B) {
+	target := benchServer()
+	newSandbox, cleanup := target.connect()
+	defer cleanup()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		sandbox := newSandbox()
+		if err := sandbox.Start(target.startConfig()); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		if err := sandbox.Stop(); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
+
+// BenchmarkCodeRun measures a single small REPL round-trip.
+func BenchmarkCodeRun(b *testing.B) {
+	benchCodeRun(b, "print('hello')")
+}
+
+// BenchmarkCodeRunLargeOutput measures a REPL round-trip whose request and response are both
+// ~256 KiB.
+func BenchmarkCodeRunLargeOutput(b *testing.B) {
+	benchCodeRun(b, strings.Repeat("print('0123456789abcdef')\n", 10_000))
+}
+
+func benchCodeRun(b *testing.B, code string) {
+	sandbox := benchServer().startSandbox(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(code)))
+	for b.Loop() {
+		if _, err := sandbox.Code().Run(code); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportThroughput(b)
+}
+
+// BenchmarkCodeRunParallel measures small REPL round-trips issued concurrently through one
+// sandbox handle.
+func BenchmarkCodeRunParallel(b *testing.B) {
+	sandbox := benchServer().startSandbox(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := sandbox.Code().Run("print('hello')"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	reportThroughput(b)
+}
+
+// BenchmarkCommandRun measures a single command round-trip.
+func BenchmarkCommandRun(b *testing.B) {
+	sandbox := benchServer().startSandbox(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := sandbox.Command().Run("echo", []string{"hello"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportThroughput(b)
+}
+
+// BenchmarkMetricsPoll measures a metrics query, the overhead a dashboard polling a sandbox adds.
+func BenchmarkMetricsPoll(b *testing.B) {
+	sandbox := benchServer().startSandbox(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := sandbox.Metrics().All(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package msbtest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+type image struct {
+	ID      string
+	Size    int64
+	Created time.Time
+}
+
+type build struct {
+	lines   []string
+	err     string
+	imageID string
+}
+
+type imageBuildParams struct {
+	Tag        string            `json:"tag"`
+	Dockerfile string            `json:"dockerfile"`
+	Args       map[string]string `json:"args"`
+	Context    []byte            `json:"context"`
+}
+
+// imageBuild "builds" an image by echoing the instructions of the Dockerfile in the context as
+// build steps. The build completes immediately; its log is served by imageBuildLogs.
+func (s *Server) imageBuild(params json.RawMessage) (any, error) {
+	var p imageBuildParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	files, err := untar(p.Context)
+	if err != nil {
+		return nil, fmt.Errorf("invalid build context: %w", err)
+	}
+
+	b := &build{}
+	dockerfile, ok := files[p.Dockerfile]
+	if !ok {
+		b.err = fmt.Sprintf("dockerfile %s not found in build context", p.Dockerfile)
+	} else {
+		var steps []string
+		for _, line := range strings.Split(string(dockerfile), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				steps = append(steps, line)
+			}
+		}
+		for i, step := range steps {
+			b.lines = append(b.lines, fmt.Sprintf("Step %d/%d : %s", i+1, len(steps), step))
+		}
+		sum := sha256.Sum256(p.Context)
+		b.imageID = "sha256:" + hex.EncodeToString(sum[:])
+		b.lines = append(b.lines, "Successfully tagged "+p.Tag)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b.err == "" {
+		s.images[p.Tag] = image{ID: b.imageID, Size: int64(len(p.Context)), Created: time.Now()}
+	}
+	id := fmt.Sprintf("build-%d", len(s.builds)+1)
+	s.builds[id] = b
+	return map[string]any{"build_id": id}, nil
+}
+
+type buildLogsParams struct {
+	BuildID string `json:"build_id"`
+	Offset  int    `json:"offset"`
+}
+
+func (s *Server) imageBuildLogs(params json.RawMessage) (any, error) {
+	var p buildLogsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.builds[p.BuildID]
+	if !ok {
+		return nil, fmt.Errorf("unknown build %q", p.BuildID)
+	}
+	lines := b.lines[min(max(p.Offset, 0), len(b.lines)):]
+	return map[string]any{"lines": lines, "done": true, "error": b.err, "image_id": b.imageID}, nil
+}
+
+func (s *Server) imageLoadBegin(json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+	s.uploads[id] = &bytes.Buffer{}
+	return map[string]any{"upload_id": id}, nil
+}
+
+type imageLoadChunkParams struct {
+	UploadID string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+	Data     []byte `json:"data"`
+}
+
+func (s *Server) imageLoadChunk(params json.RawMessage) (any, error) {
+	var p imageLoadChunkParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf, ok := s.uploads[p.UploadID]
+	if !ok {
+		return nil, fmt.Errorf("unknown upload %q", p.UploadID)
+	}
+	if p.Offset != int64(buf.Len()) {
+		return nil, fmt.Errorf("chunk at offset %d, expected %d", p.Offset, buf.Len())
+	}
+	buf.Write(p.Data)
+	return "ok", nil
+}
+
+type imageLoadCommitParams struct {
+	UploadID string `json:"upload_id"`
+}
+
+// imageLoadCommit registers the images named in an uploaded archive: the RepoTags of a
+// "docker save" manifest.json, or the ref.name annotations of an OCI index.json.
+func (s *Server) imageLoadCommit(params json.RawMessage) (any, error) {
+	var p imageLoadCommitParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	buf, ok := s.uploads[p.UploadID]
+	delete(s.uploads, p.UploadID)
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown upload %q", p.UploadID)
+	}
+
+	files, err := untar(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid image archive: %w", err)
+	}
+	var refs []string
+	if manifest, ok := files["manifest.json"]; ok {
+		var entries []struct{ RepoTags []string }
+		if err := json.Unmarshal(manifest, &entries); err != nil {
+			return nil, fmt.Errorf("invalid manifest.json: %w", err)
+		}
+		for _, e := range entries {
+			refs = append(refs, e.RepoTags...)
+		}
+	} else if index, ok := files["index.json"]; ok {
+		var idx struct {
+			Manifests []struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"manifests"`
+		}
+		if err := json.Unmarshal(index, &idx); err != nil {
+			return nil, fmt.Errorf("invalid index.json: %w", err)
+		}
+		for _, m := range idx.Manifests {
+			if ref := m.Annotations["org.opencontainers.image.ref.name"]; ref != "" {
+				refs = append(refs, ref)
+			}
+		}
+	} else {
+		return nil, errors.New("archive is neither an OCI layout nor a docker save tarball")
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ref := range refs {
+		s.images[ref] = image{ID: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(buf.Len()), Created: time.Now()}
+	}
+	return map[string]any{"images": refs}, nil
+}
+
+func (img image) info(ref string) map[string]any {
+	return map[string]any{"ref": ref, "id": img.ID, "size": img.Size, "created": img.Created}
+}
+
+func (s *Server) imageList(json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	images := make([]map[string]any, 0, len(s.images))
+	for _, ref := range slices.Sorted(maps.Keys(s.images)) {
+		images = append(images, s.images[ref].info(ref))
+	}
+	return map[string]any{"images": images}, nil
+}
+
+type imageInspectParams struct {
+	Ref string `json:"ref"`
+}
+
+// imageInspect reports every image as consisting of a single layer.
+func (s *Server) imageInspect(params json.RawMessage) (any, error) {
+	var p imageInspectParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	img, ok := s.images[p.Ref]
+	if !ok {
+		return nil, fmt.Errorf("image not found: %s", p.Ref)
+	}
+	info := img.info(p.Ref)
+	info["layers"] = []map[string]any{{"digest": img.ID, "size": img.Size}}
+	return info, nil
+}
+
+type imagePruneParams struct {
+	OlderThan int `json:"older_than"`
+}
+
+// imagePrune removes images created more than older_than seconds ago. The fake doesn't track
+// which images sandboxes use, so none is protected from removal.
+func (s *Server) imagePrune(params json.RawMessage) (any, error) {
+	var p imagePruneParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-time.Duration(p.OlderThan) * time.Second)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := []string{}
+	var reclaimed int64
+	for _, ref := range slices.Sorted(maps.Keys(s.images)) {
+		if img := s.images[ref]; !img.Created.After(cutoff) {
+			removed = append(removed, ref)
+			reclaimed += img.Size
+			delete(s.images, ref)
+		}
+	}
+	return map[string]any{"removed": removed, "reclaimed_bytes": reclaimed}, nil
+}
+
+// untar reads the regular files of a tarball, which may be gzipped.
+func untar(data []byte) (map[string][]byte, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[strings.TrimPrefix(hdr.Name, "./")] = content
+	}
+}
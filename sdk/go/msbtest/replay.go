@@ -0,0 +1,136 @@
+package msbtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Recording is the execution a replay bundle recorded, to be run again against a server
+// replaying it.
+type Recording struct {
+	Sandbox  string          // Name of the sandbox it ran in
+	Language string          // REPL language, for code
+	Code     string          // Code that ran; empty for commands
+	Command  string          // Command that ran; empty for code
+	Args     []string        // Arguments of the command
+	User     string          // User the command ran as, if not the sandbox's default
+	Env      []string        // Names of the environment variables the command ran with
+	Result   json.RawMessage // Result exactly as the server sent it
+}
+
+// IsCode reports whether the recording is of code rather than a command.
+func (r Recording) IsCode() bool {
+	return r.Command == ""
+}
+
+// matchesCommand reports whether a command sent to the server is the recorded one. Commands with
+// environment variables are sent as env(1) invocations; as bundles hold the names of the
+// variables only, their values may differ.
+func (r Recording) matchesCommand(command string, args []string, user string) bool {
+	if user != r.User {
+		return false
+	}
+	if len(r.Env) == 0 {
+		return command == r.Command && slices.Equal(args, r.Args)
+	}
+	if command != "env" || len(args) < len(r.Env)+1 {
+		return false
+	}
+	for i, name := range r.Env {
+		if !strings.HasPrefix(args[i], name+"=") {
+			return false
+		}
+	}
+	return args[len(r.Env)] == r.Command && slices.Equal(args[len(r.Env)+1:], r.Args)
+}
+
+// Replay loads a replay bundle, as written by CodeExecution.ExportBundle and
+// CommandExecution.ExportBundle, and answers executions of the same code in the same language, or
+// of the same command with the same arguments as the same user and with environment variables of
+// the same names, with the recorded result in any sandbox. Other executions are left to the handler registered before.
+func (s *Server) Replay(bundle io.Reader) (Recording, error) {
+	data, err := io.ReadAll(bundle)
+	if err != nil {
+		return Recording{}, err
+	}
+	files, err := untar(data)
+	if err != nil {
+		return Recording{}, fmt.Errorf("reading bundle: %w", err)
+	}
+	var manifest struct {
+		Version int    `json:"version"`
+		Kind    string `json:"kind"`
+		Sandbox string `json:"sandbox"`
+		Request struct {
+			Language string   `json:"language"`
+			Command  string   `json:"command"`
+			Args     []string `json:"args"`
+			User     string   `json:"user"`
+			Env      []string `json:"env"`
+		} `json:"request"`
+		CodeFile string `json:"code_file"`
+	}
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		return Recording{}, fmt.Errorf("reading bundle manifest: %w", err)
+	}
+	if manifest.Version != 1 {
+		return Recording{}, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+	rec := Recording{
+		Sandbox:  manifest.Sandbox,
+		Language: manifest.Request.Language,
+		Command:  manifest.Request.Command,
+		Args:     manifest.Request.Args,
+		User:     manifest.Request.User,
+		Env:      manifest.Request.Env,
+		Result:   files["result.json"],
+	}
+	if rec.Result == nil {
+		return Recording{}, errors.New("bundle holds no result")
+	}
+
+	method := "sandbox.command.run"
+	switch manifest.Kind {
+	case "code":
+		code, ok := files[manifest.CodeFile]
+		if !ok {
+			return Recording{}, errors.New("bundle holds no code")
+		}
+		rec.Code, method = string(code), "sandbox.repl.run"
+	case "command":
+		if rec.Command == "" {
+			return Recording{}, errors.New("bundle holds no command")
+		}
+	default:
+		return Recording{}, fmt.Errorf("unknown bundle kind %q", manifest.Kind)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.handlers[method]
+	s.handlers[method] = func(params json.RawMessage) (any, error) {
+		var p struct {
+			Language string   `json:"language"`
+			Code     string   `json:"code"`
+			Command  string   `json:"command"`
+			Args     []string `json:"args"`
+			User     string   `json:"user"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if rec.IsCode() && p.Language == rec.Language && p.Code == rec.Code ||
+			!rec.IsCode() && rec.matchesCommand(p.Command, p.Args, p.User) {
+			return rec.Result, nil
+		}
+		if next == nil {
+			return nil, errors.New("no recorded execution matches")
+		}
+		return next(params)
+	}
+	return rec, nil
+}
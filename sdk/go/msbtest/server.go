@@ -0,0 +1,564 @@
+// Package msbtest provides an in-process fake Microsandbox server for tests, examples and benchmarks.
+//
+// The fake speaks the same JSON-RPC protocol as the real server and keeps track of started sandboxes,
+// so SDK code can be exercised without a KVM-capable host:
+//
+//	srv := msbtest.NewServer()
+//	defer srv.Close()
+//
+//	sandbox := msb.NewPythonSandbox(
+//		msb.WithServerUrl(srv.URL),
+//		msb.WithApiKey("test"),
+//	)
+//
+// By default REPL and command executions echo their input back on stdout. Individual methods can be
+// overridden with Handle.
+package msbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HandlerFunc handles the params of a single JSON-RPC method and returns its result.
+// A non-nil error is reported to the client as a JSON-RPC error.
+type HandlerFunc func(params json.RawMessage) (any, error)
+
+// Server is a fake Microsandbox server listening on a local loopback address.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	handlers  map[string]HandlerFunc
+	sandboxes map[string]bool
+	logs      map[string][]string // sandbox -> log lines
+	events    []event
+	created   map[string]bool                 // sandboxes started at least once
+	crashes   map[string]crash                // sandbox -> how it died, until restarted
+	leases    map[string]map[string]time.Time // sandbox -> holder -> expiry
+	locks     map[lockID]heldLock
+	images    map[string]image // tag -> image
+	builds    map[string]*build
+	uploads   map[string]*bytes.Buffer
+}
+
+// NewServer starts a fake server with default handlers for all core sandbox methods.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		handlers:  make(map[string]HandlerFunc),
+		sandboxes: make(map[string]bool),
+		logs:      make(map[string][]string),
+		created:   make(map[string]bool),
+		crashes:   make(map[string]crash),
+		leases:    make(map[string]map[string]time.Time),
+		locks:     make(map[lockID]heldLock),
+		images:    make(map[string]image),
+		builds:    make(map[string]*build),
+		uploads:   make(map[string]*bytes.Buffer),
+	}
+	s.handlers["sandbox.start"] = s.start
+	s.handlers["sandbox.stop"] = s.stop
+	s.handlers["sandbox.repl.run"] = s.replRun
+	s.handlers["sandbox.command.run"] = s.commandRun
+	s.handlers["sandbox.metrics.get"] = s.metricsGet
+	s.handlers["sandbox.status.get"] = s.statusGet
+	s.handlers["sandbox.logs.get"] = s.logsGet
+	s.handlers["sandbox.events.get"] = s.eventsGet
+	s.handlers["sandbox.diagnostics.get"] = s.diagnosticsGet
+	s.handlers["sandbox.lease.renew"] = s.leaseRenew
+	s.handlers["sandbox.lease.release"] = s.leaseRelease
+	s.handlers["sandbox.lock.acquire"] = s.lockAcquire
+	s.handlers["sandbox.lock.release"] = s.lockRelease
+	s.handlers["image.build"] = s.imageBuild
+	s.handlers["image.build.logs"] = s.imageBuildLogs
+	s.handlers["image.load.begin"] = s.imageLoadBegin
+	s.handlers["image.load.chunk"] = s.imageLoadChunk
+	s.handlers["image.load.commit"] = s.imageLoadCommit
+	s.handlers["image.list"] = s.imageList
+	s.handlers["image.inspect"] = s.imageInspect
+	s.handlers["image.prune"] = s.imagePrune
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle registers h for method, replacing any existing handler.
+// A nil h removes the method, making the server report it as not found.
+func (s *Server) Handle(method string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h == nil {
+		delete(s.handlers, method)
+		return
+	}
+	s.handlers[method] = h
+}
+
+// AppendLog adds lines to the log of the named sandbox, as returned by Logs.
+func (s *Server) AppendLog(name string, lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs[name] = append(s.logs[name], lines...)
+}
+
+// AddEvent records a lifecycle event of the named sandbox, such as "oom_killed", for clients
+// following events. Starts and stops are recorded automatically.
+func (s *Server) AddEvent(typ, name, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addEvent(typ, name, message)
+}
+
+func (s *Server) addEvent(typ, name, message string) {
+	s.events = append(s.events, event{Type: typ, Sandbox: name, Time: time.Now(), Message: message})
+}
+
+// Crash makes the named sandbox die as if its VM had been killed, for reason "oom" or
+// "kernel_panic" for instance, with memoryMiB in use. Executions in it fail until it is started
+// again, and its status and diagnostics report the crash.
+func (s *Server) Crash(name, reason string, memoryMiB int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sandboxes, name)
+	delete(s.leases, name)
+	s.dropLocks(name)
+	s.crashes[name] = crash{Reason: reason, Time: time.Now(), ExitCode: 137, MemoryMiB: memoryMiB}
+	s.logs[name] = append(s.logs[name], fmt.Sprintf("Sandbox %s crashed: %s", name, reason))
+	if reason == "oom" {
+		s.addEvent("oom_killed", name, "")
+	}
+	s.addEvent("stopped", name, reason)
+}
+
+// Running reports whether the named sandbox has been started and not stopped since.
+func (s *Server) Running(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sandboxes[name]
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      any             `json:"id,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string    `json:"jsonrpc"`
+	Result  any       `json:"result,omitempty"`
+	Error   *rpcError `json:"error,omitempty"`
+	ID      any       `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// like the real server, only the v1 endpoint exists
+	if r.URL.Path != "/api/v1/rpc" {
+		http.NotFound(w, r)
+		return
+	}
+	var req rpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, rpcResponse{
+			JSONRPC: "2.0",
+			Error:   &rpcError{Code: -32700, Message: err.Error()},
+		})
+		return
+	}
+
+	s.mu.Lock()
+	s.reapExpiredLeases()
+	h, ok := s.handlers[req.Method]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, rpcResponse{
+			JSONRPC: "2.0",
+			Error:   &rpcError{Code: -32601, Message: "Method not found: " + req.Method},
+			ID:      req.ID,
+		})
+		return
+	}
+
+	result, err := h(req.Params)
+	if err != nil {
+		writeJSON(w, http.StatusOK, rpcResponse{
+			JSONRPC: "2.0",
+			Error:   &rpcError{Code: -32000, Message: err.Error()},
+			ID:      req.ID,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// --- default handlers ---
+
+type sandboxParams struct {
+	Sandbox string `json:"sandbox"`
+}
+
+func (s *Server) start(params json.RawMessage) (any, error) {
+	var p sandboxParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandboxes[p.Sandbox] = true
+	delete(s.crashes, p.Sandbox)
+	s.logs[p.Sandbox] = append(s.logs[p.Sandbox], fmt.Sprintf("Sandbox %s started", p.Sandbox))
+	if !s.created[p.Sandbox] {
+		s.created[p.Sandbox] = true
+		s.addEvent("created", p.Sandbox, "")
+	}
+	s.addEvent("started", p.Sandbox, "")
+	return fmt.Sprintf("Sandbox %s started successfully", p.Sandbox), nil
+}
+
+func (s *Server) stop(params json.RawMessage) (any, error) {
+	var p sandboxParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sandboxes, p.Sandbox)
+	delete(s.leases, p.Sandbox)
+	s.dropLocks(p.Sandbox)
+	s.logs[p.Sandbox] = append(s.logs[p.Sandbox], fmt.Sprintf("Sandbox %s stopped", p.Sandbox))
+	s.addEvent("stopped", p.Sandbox, "")
+	return fmt.Sprintf("Sandbox %s stopped successfully", p.Sandbox), nil
+}
+
+func (s *Server) logsGet(params json.RawMessage) (any, error) {
+	var p struct {
+		Sandbox string `json:"sandbox"`
+		Offset  int    `json:"offset"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.logs[p.Sandbox]
+	if p.Offset < len(lines) {
+		lines = lines[p.Offset:]
+	} else {
+		lines = []string{}
+	}
+	return map[string]any{
+		"lines":   lines,
+		"running": s.sandboxes[p.Sandbox],
+	}, nil
+}
+
+type event struct {
+	Type    string    `json:"type"`
+	Sandbox string    `json:"sandbox"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message,omitempty"`
+}
+
+// eventsGet returns the events after the cursor, which is the number of events already read.
+func (s *Server) eventsGet(params json.RawMessage) (any, error) {
+	var p struct {
+		Cursor    string   `json:"cursor"`
+		Types     []string `json:"types"`
+		Sandboxes []string `json:"sandboxes"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := []event{}
+	if p.Cursor != "" {
+		offset, err := strconv.Atoi(p.Cursor)
+		if err != nil || offset < 0 || offset > len(s.events) {
+			return nil, fmt.Errorf("invalid cursor %q", p.Cursor)
+		}
+		for _, e := range s.events[offset:] {
+			if (len(p.Types) == 0 || slices.Contains(p.Types, e.Type)) &&
+				(len(p.Sandboxes) == 0 || slices.Contains(p.Sandboxes, e.Sandbox)) {
+				events = append(events, e)
+			}
+		}
+	}
+	return map[string]any{"events": events, "cursor": strconv.Itoa(len(s.events))}, nil
+}
+
+type crash struct {
+	Reason    string    `json:"reason"`
+	Time      time.Time `json:"time"`
+	ExitCode  int       `json:"exit_code"`
+	MemoryMiB int       `json:"memory_usage"`
+}
+
+func (s *Server) diagnosticsGet(params json.RawMessage) (any, error) {
+	var p struct {
+		Sandbox string `json:"sandbox"`
+		Lines   int    `json:"lines"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.crashes[p.Sandbox]
+	if !ok {
+		return nil, fmt.Errorf("sandbox %s has not crashed", p.Sandbox)
+	}
+	lines := s.logs[p.Sandbox]
+	if p.Lines > 0 && len(lines) > p.Lines {
+		lines = lines[len(lines)-p.Lines:]
+	}
+	return map[string]any{
+		"reason":       c.Reason,
+		"time":         c.Time,
+		"exit_code":    c.ExitCode,
+		"memory_usage": c.MemoryMiB,
+		"log_lines":    lines,
+	}, nil
+}
+
+// crashed returns an error if the named sandbox has crashed.
+func (s *Server) crashed(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.crashes[name]; ok {
+		return fmt.Errorf("sandbox %s terminated unexpectedly: %s", name, c.Reason)
+	}
+	return nil
+}
+
+// OutputLine is a single line of execution output, as reported by the server.
+type OutputLine struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// Stdout splits text into stdout output lines.
+func Stdout(text string) []OutputLine {
+	var lines []OutputLine
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, OutputLine{Stream: "stdout", Text: line})
+	}
+	return lines
+}
+
+func (s *Server) replRun(params json.RawMessage) (any, error) {
+	var p struct {
+		Sandbox  string `json:"sandbox"`
+		Language string `json:"language"`
+		Code     string `json:"code"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if err := s.crashed(p.Sandbox); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"status":   "success",
+		"language": p.Language,
+		"output":   Stdout(p.Code),
+	}, nil
+}
+
+func (s *Server) commandRun(params json.RawMessage) (any, error) {
+	var p struct {
+		Sandbox string   `json:"sandbox"`
+		Command string   `json:"command"`
+		Args    []string `json:"args"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if err := s.crashed(p.Sandbox); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"command":   p.Command,
+		"args":      p.Args,
+		"exit_code": 0,
+		"success":   true,
+		"output":    Stdout(strings.Join(append([]string{p.Command}, p.Args...), " ")),
+	}, nil
+}
+
+func (s *Server) statusGet(params json.RawMessage) (any, error) {
+	var p sandboxParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := "stopped"
+	if s.sandboxes[p.Sandbox] {
+		status = "running"
+	} else if _, ok := s.crashes[p.Sandbox]; ok {
+		status = "crashed"
+	}
+	return map[string]any{"status": status}, nil
+}
+
+func (s *Server) metricsGet(params json.RawMessage) (any, error) {
+	var p sandboxParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sandboxes []map[string]any
+	for name := range s.sandboxes {
+		if p.Sandbox != "" && name != p.Sandbox {
+			continue
+		}
+		sandboxes = append(sandboxes, map[string]any{
+			"name":         name,
+			"running":      true,
+			"cpu_usage":    0.0,
+			"memory_usage": 0,
+			"disk_usage":   0,
+		})
+	}
+	return map[string]any{"sandboxes": sandboxes}, nil
+}
+
+type leaseParams struct {
+	Sandbox string `json:"sandbox"`
+	Holder  string `json:"holder"`
+	TTL     int    `json:"ttl"`
+}
+
+func (s *Server) leaseRenew(params json.RawMessage) (any, error) {
+	var p leaseParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.sandboxes[p.Sandbox] {
+		return nil, fmt.Errorf("sandbox %s is not running", p.Sandbox)
+	}
+	if s.leases[p.Sandbox] == nil {
+		s.leases[p.Sandbox] = make(map[string]time.Time)
+	}
+	expires := time.Now().Add(time.Duration(p.TTL) * time.Second)
+	s.leases[p.Sandbox][p.Holder] = expires
+	return map[string]any{"expires_at": expires}, nil
+}
+
+func (s *Server) leaseRelease(params json.RawMessage) (any, error) {
+	var p leaseParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	holders := s.leases[p.Sandbox]
+	delete(holders, p.Holder)
+	if holders != nil && len(holders) == 0 {
+		// the last holder gave up its lease, so the sandbox is reclaimed right away
+		delete(s.leases, p.Sandbox)
+		delete(s.sandboxes, p.Sandbox)
+		s.dropLocks(p.Sandbox)
+	}
+	return fmt.Sprintf("Lease on %s released", p.Sandbox), nil
+}
+
+// reapExpiredLeases stops leased sandboxes none of whose holders renewed in time.
+// The caller must hold s.mu.
+func (s *Server) reapExpiredLeases() {
+	now := time.Now()
+	for name, holders := range s.leases {
+		alive := false
+		for _, expires := range holders {
+			if now.Before(expires) {
+				alive = true
+				break
+			}
+		}
+		if !alive {
+			delete(s.leases, name)
+			delete(s.sandboxes, name)
+			s.dropLocks(name)
+		}
+	}
+}
+
+type lockID struct {
+	sandbox, key string
+}
+
+type heldLock struct {
+	holder  string
+	expires time.Time
+}
+
+type lockParams struct {
+	Sandbox string `json:"sandbox"`
+	Key     string `json:"key"`
+	Holder  string `json:"holder"`
+	TTL     int    `json:"ttl"`
+}
+
+func (s *Server) lockAcquire(params json.RawMessage) (any, error) {
+	var p lockParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.sandboxes[p.Sandbox] {
+		return nil, fmt.Errorf("sandbox %s is not running", p.Sandbox)
+	}
+	id := lockID{p.Sandbox, p.Key}
+	if cur, ok := s.locks[id]; ok && cur.holder != p.Holder && time.Now().Before(cur.expires) {
+		return map[string]any{"acquired": false}, nil
+	}
+	s.locks[id] = heldLock{holder: p.Holder, expires: time.Now().Add(time.Duration(p.TTL) * time.Second)}
+	return map[string]any{"acquired": true}, nil
+}
+
+func (s *Server) lockRelease(params json.RawMessage) (any, error) {
+	var p lockParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := lockID{p.Sandbox, p.Key}
+	if cur, ok := s.locks[id]; ok && cur.holder == p.Holder {
+		delete(s.locks, id)
+	}
+	return fmt.Sprintf("Lock %s on %s released", p.Key, p.Sandbox), nil
+}
+
+// dropLocks forgets all locks on a sandbox that went away. The caller must hold s.mu.
+func (s *Server) dropLocks(sandbox string) {
+	for id := range s.locks {
+		if id.sandbox == sandbox {
+			delete(s.locks, id)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// NameGenerator produces sandbox names for sandboxes created without WithName.
+type NameGenerator func() string
+
+// maxNameAttempts bounds how often a generator is asked for a name that isn't already in use.
+const maxNameAttempts = 8
+
+// WithNameGenerator configures a custom generator for sandbox names, used when WithName is not given.
+// Names already held by a started sandbox of this process are skipped by asking the generator again.
+func WithNameGenerator(gen NameGenerator) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.nameGen = gen
+	}
+}
+
+// WithNamePrefix generates predictable sandbox names of the form "<prefix>-1", "<prefix>-2", ...
+// The counter is shared by every sandbox created through the same option value, e.g. all sandboxes
+// of one Client, so names are unique within it and reproducible across runs.
+func WithNamePrefix(prefix string) Option {
+	var counter atomic.Uint64
+	return WithNameGenerator(func() string {
+		return fmt.Sprintf("%s-%d", prefix, counter.Add(1))
+	})
+}
+
+// generateName asks cfg's generator for a name that no started sandbox of this process is using,
+// comparing names scoped to cfg's tenant, the form they are reserved in. If every attempt
+// collides, the last name is returned and Start reports the conflict.
+func generateName(cfg *config) string {
+	for attempt := 1; ; attempt++ {
+		name := cfg.nameGen()
+		if name == "" || attempt == maxNameAttempts || !liveNames.inUse(cfg.serverUrl, cfg.scopedName(name)) {
+			return name
+		}
+	}
+}
+
+// NameConflictPolicy decides how Start handles a sandbox of the same name already running on the server.
+type NameConflictPolicy int
+
+const (
+	NameConflictUnchecked NameConflictPolicy = iota // Don't check; the server decides what happens
+	NameConflictFail                                // Fail with a *NameConflictError
+	NameConflictAttach                              // Adopt the running sandbox instead of starting a new one
+	NameConflictReplace                             // Stop the running sandbox, then start a new one
+)
+
+// NameConflictError reports that a sandbox name is already in use.
+// It matches ErrNameConflict with errors.Is.
+type NameConflictError struct {
+	Name   string // Conflicting sandbox name
+	Remote bool   // Whether the conflicting sandbox was found on the server rather than in this process
+}
+
+func (e *NameConflictError) Error() string {
+	if e.Remote {
+		return fmt.Sprintf("%v on server: %q", ErrNameConflict, e.Name)
+	}
+	return fmt.Sprintf("%v: %q", ErrNameConflict, e.Name)
+}
+
+func (e *NameConflictError) Is(target error) bool {
+	return target == ErrNameConflict
+}
+
+// resolveNameConflict looks the sandbox's name up on the server and applies policy if a sandbox of
+// that name is already running. It reports whether the running sandbox was attached to.
+func resolveNameConflict(ctx context.Context, b *baseMicroSandbox, policy NameConflictPolicy) (attached bool, err error) {
+	existing, err := b.rpcClient.getMetrics(ctx, &b.cfg)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrFailedToCheckName, err)
+	}
+	if existing.Name != b.cfg.name || !existing.Running {
+		return false, nil
+	}
+
+	switch policy {
+	case NameConflictAttach:
+		b.cfg.log(ctx).Info("Attaching to running sandbox", "name", b.cfg.name)
+		return true, nil
+	case NameConflictReplace:
+		b.cfg.log(ctx).Info("Replacing running sandbox", "name", b.cfg.name)
+		if err := b.rpcClient.stopSandbox(ctx, &b.cfg); err != nil {
+			return false, fmt.Errorf("%w: %w", ErrFailedToStopSandbox, err)
+		}
+		return false, nil
+	default:
+		return false, &NameConflictError{Name: b.cfg.name, Remote: true}
+	}
+}
+
+// nameRegistry tracks the names of sandboxes started by this process, per server,
+// so two handles can't drive the same server-side sandbox by accident.
+type nameRegistry struct {
+	mu    sync.Mutex
+	names map[string]struct{}
+}
+
+var liveNames = &nameRegistry{names: make(map[string]struct{})}
+
+func (r *nameRegistry) key(serverUrl, name string) string {
+	return serverUrl + "\x00" + name
+}
+
+// reserve marks name as in use, reporting false if it already was.
+func (r *nameRegistry) reserve(serverUrl, name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := r.key(serverUrl, name)
+	if _, taken := r.names[k]; taken {
+		return false
+	}
+	r.names[k] = struct{}{}
+	return true
+}
+
+func (r *nameRegistry) release(serverUrl, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.names, r.key(serverUrl, name))
+}
+
+func (r *nameRegistry) inUse(serverUrl, name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, taken := r.names[r.key(serverUrl, name)]
+	return taken
+}
+
+// maxNameLen is the longest sandbox name the server accepts.
+const maxNameLen = 63
+
+// Kinds of names InvalidNameError reports on.
+const (
+	nameKindSandbox = "sandbox name"
+	nameKindTenant  = "tenant ID"
+	nameKindVenv    = "virtualenv name"
+)
+
+// InvalidNameError reports a sandbox name, tenant ID or virtualenv name that isn't allowed, with the
+// offending character, if any, in brackets. It matches ErrInvalidName with errors.Is, and tenant
+// IDs also ErrInvalidTenant.
+type InvalidNameError struct {
+	Kind   string // "sandbox name", "tenant ID" or "virtualenv name"
+	Name   string // Name rejected
+	Offset int    // Byte offset of the offending character; -1 if the name as a whole is the problem
+	Reason string // What is wrong
+}
+
+func (e *InvalidNameError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("%v: %s %q %s", ErrInvalidName, e.Kind, e.Name, e.Reason)
+	}
+	_, size := utf8.DecodeRuneInString(e.Name[e.Offset:])
+	end := e.Offset + size
+	return fmt.Sprintf("%v: %s %q: %s: %s[%s]%s", ErrInvalidName, e.Kind, e.Name, e.Reason,
+		e.Name[:e.Offset], e.Name[e.Offset:end], e.Name[end:])
+}
+
+func (e *InvalidNameError) Is(target error) bool {
+	return target == ErrInvalidName || (target == ErrInvalidTenant && e.Kind == nameKindTenant)
+}
+
+// validateName checks a sandbox name against the server's rules: 1 to 63 ASCII letters, digits,
+// hyphens and underscores, starting with a letter or digit.
+func validateName(name string) error {
+	return checkName(nameKindSandbox, name, maxNameLen, "-_")
+}
+
+// checkName checks that name consists of 1 to maxLen ASCII letters, digits and characters of
+// punct, starting with a letter or digit.
+func checkName(kind, name string, maxLen int, punct string) error {
+	invalid := func(offset int, reason string) error {
+		return &InvalidNameError{Kind: kind, Name: name, Offset: offset, Reason: reason}
+	}
+	if name == "" {
+		return invalid(-1, "is empty")
+	}
+	pos := 0
+	for i, r := range name {
+		pos++
+		isAlnum := 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+		switch {
+		case i == 0 && !isAlnum:
+			return invalid(i, fmt.Sprintf("must start with an ASCII letter or digit, not %q", r))
+		case !isAlnum && !strings.ContainsRune(punct, r):
+			return invalid(i, fmt.Sprintf("character %q at position %d is not allowed, only %s are", r, pos, allowedChars(punct)))
+		}
+	}
+	if len(name) > maxLen {
+		return invalid(-1, fmt.Sprintf("is %d characters long, at most %d are allowed", len(name), maxLen))
+	}
+	return nil
+}
+
+// allowedChars describes the characters checkName allows, e.g. "ASCII letters, digits, '-' and '_'".
+func allowedChars(punct string) string {
+	allowed := []string{"ASCII letters", "digits"}
+	for _, r := range punct {
+		allowed = append(allowed, fmt.Sprintf("%q", r))
+	}
+	return strings.Join(allowed[:len(allowed)-1], ", ") + " and " + allowed[len(allowed)-1]
+}
+
+// Name-related errors
+var (
+	ErrNameConflict      = errors.New("sandbox name already in use")
+	ErrFailedToCheckName = errors.New("failed to check for existing sandbox")
+	ErrInvalidName       = errors.New("invalid name")
+)
@@ -0,0 +1,40 @@
+package msb
+
+import (
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+// TestGeneratedNamesSkipNamesInUse checks that generated names of tenant-scoped sandboxes skip the
+// names of started ones, which are reserved with the tenant prefix.
+func TestGeneratedNamesSkipNamesInUse(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	names := []string{"a", "a", "b"}
+	gen := func() string {
+		name := names[0]
+		names = names[1:]
+		return name
+	}
+	client, err := NewClient(WithServerUrl(srv.URL), WithApiKey("k"), WithNameGenerator(gen)).WithTenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := client.NewPythonSandbox()
+	if err := first.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = first.Stop() })
+
+	second := client.NewPythonSandbox()
+	if first.Name() != "acme_a" || second.Name() != "acme_b" {
+		t.Fatalf("names = %s, %s, want acme_a, acme_b", first.Name(), second.Name())
+	}
+	if err := second.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Stop(); err != nil {
+		t.Error(err)
+	}
+}
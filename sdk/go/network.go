@@ -0,0 +1,114 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NetworkMode controls a sandbox's outbound network access.
+type NetworkMode string
+
+const (
+	NetworkDefault    NetworkMode = ""           // Leave egress to the server's default
+	NetworkNone       NetworkMode = "none"       // No network access at all
+	NetworkRestricted NetworkMode = "restricted" // Only the hosts in NetworkPolicy.AllowHosts are reachable
+	NetworkOpen       NetworkMode = "open"       // Unrestricted egress
+)
+
+// NetworkPolicy is the egress policy of a sandbox, e.g. to cut LLM-generated code off from the
+// internet or limit it to a known set of APIs:
+//
+//	msb.StartConfig{Network: msb.NetworkPolicy{
+//		Mode:       msb.NetworkRestricted,
+//		AllowHosts: []string{"pypi.org", "files.pythonhosted.org", "10.0.0.0/8"},
+//	}}
+//
+// The policy is enforced by the server. Start fails with ErrNetworkPolicyUnsupported, rather than
+// run the sandbox with the server's default egress, on servers that don't report enforcing
+// network policies.
+type NetworkPolicy struct {
+	Mode       NetworkMode `json:"mode"`
+	AllowHosts []string    `json:"allow_hosts,omitempty"` // Host names ("*.example.com" wildcards allowed), IPs or CIDR ranges; restricted mode only
+}
+
+func (n NetworkPolicy) validate() error {
+	switch n.Mode {
+	case NetworkDefault, NetworkNone, NetworkOpen:
+		if len(n.AllowHosts) > 0 {
+			return fmt.Errorf("%w: allow list requires %q mode", ErrInvalidNetworkPolicy, NetworkRestricted)
+		}
+	case NetworkRestricted:
+		if len(n.AllowHosts) == 0 {
+			return fmt.Errorf("%w: %q mode requires at least one allowed host", ErrInvalidNetworkPolicy, NetworkRestricted)
+		}
+		for _, host := range n.AllowHosts {
+			if !validAllowHost(host) {
+				return fmt.Errorf("%w: invalid host %q", ErrInvalidNetworkPolicy, host)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidNetworkPolicy, n.Mode)
+	}
+	return nil
+}
+
+// validAllowHost accepts IP addresses, CIDR ranges and host names (optionally "*."-prefixed wildcards).
+func validAllowHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if _, _, err := net.ParseCIDR(host); err == nil {
+		return true
+	}
+	name := strings.TrimPrefix(host, "*.")
+	return name != "" && !strings.ContainsAny(name, " \t/:*")
+}
+
+// rpcParam returns the policy as sent to the server, or nil to leave the server default.
+func (n NetworkPolicy) rpcParam() *NetworkPolicy {
+	if n.Mode == NetworkDefault {
+		return nil
+	}
+	return &n
+}
+
+// DNSConfig overrides name resolution inside a sandbox, e.g. to resolve internal service names
+// or to point the sandbox at a filtering resolver without baking either into the image.
+type DNSConfig struct {
+	Servers    []string          `json:"servers,omitempty"`     // Resolver IP addresses, replacing the image's /etc/resolv.conf servers
+	ExtraHosts map[string]string `json:"extra_hosts,omitempty"` // Host name to IP address entries added to /etc/hosts
+}
+
+func (d DNSConfig) validate() error {
+	for _, server := range d.Servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("%w: DNS server %q is not an IP address", ErrInvalidDNSConfig, server)
+		}
+	}
+	for host, ip := range d.ExtraHosts {
+		if host == "" || strings.ContainsAny(host, " \t/:*") {
+			return fmt.Errorf("%w: invalid host name %q", ErrInvalidDNSConfig, host)
+		}
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("%w: %s: %q is not an IP address", ErrInvalidDNSConfig, host, ip)
+		}
+	}
+	return nil
+}
+
+// rpcParam returns the configuration as sent to the server, or nil if nothing is overridden.
+func (d DNSConfig) rpcParam() *DNSConfig {
+	if len(d.Servers) == 0 && len(d.ExtraHosts) == 0 {
+		return nil
+	}
+	return &d
+}
+
+// Network-related errors
+var (
+	ErrInvalidNetworkPolicy     = errors.New("invalid network policy")
+	ErrNetworkPolicyUnsupported = errors.New("server doesn't enforce network policies")
+	ErrInvalidDNSConfig         = errors.New("invalid DNS configuration")
+)
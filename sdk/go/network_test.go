@@ -0,0 +1,66 @@
+package msb
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+func TestNetworkPolicyRequiresServerSupport(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	var starts atomic.Int32
+	srv.Handle("sandbox.start", func(json.RawMessage) (any, error) {
+		starts.Add(1)
+		return "Sandbox started successfully", nil
+	})
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"))
+	err := sandbox.Start(StartConfig{Network: NetworkPolicy{Mode: NetworkNone}})
+	if !errors.Is(err, ErrNetworkPolicyUnsupported) {
+		t.Errorf("Start = %v, want ErrNetworkPolicyUnsupported", err)
+	}
+	if n := starts.Load(); n != 0 {
+		t.Errorf("%d sandboxes started without their network policy", n)
+	}
+
+	// the default mode needs nothing from the server
+	if err := sandbox.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sandbox.Stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetworkPolicySent(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.Handle("server.features", func(json.RawMessage) (any, error) {
+		return map[string]any{"features": []string{"network_policy"}}, nil
+	})
+	var sent atomic.Pointer[NetworkPolicy]
+	srv.Handle("sandbox.start", func(params json.RawMessage) (any, error) {
+		var p struct {
+			Config struct {
+				Network *NetworkPolicy `json:"network"`
+			} `json:"config"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		sent.Store(p.Config.Network)
+		return "Sandbox started successfully", nil
+	})
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"))
+	policy := NetworkPolicy{Mode: NetworkRestricted, AllowHosts: []string{"pypi.org"}}
+	if err := sandbox.Start(StartConfig{Network: policy}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+	if got := sent.Load(); got == nil || got.Mode != policy.Mode || len(got.AllowHosts) != 1 {
+		t.Errorf("network policy sent = %+v, want %+v", got, policy)
+	}
+}
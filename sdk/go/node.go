@@ -0,0 +1,254 @@
+package msb
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultNodeProjectDir is the directory of the sandbox lockfile installs go to, unless
+// NodeOptions.ProjectDir sets another.
+const defaultNodeProjectDir = "/tmp/.msb-node"
+
+// PackageManager is a Node.js package manager.
+type PackageManager string
+
+const (
+	PackageManagerNPM  PackageManager = "npm"
+	PackageManagerPNPM PackageManager = "pnpm"
+	PackageManagerYarn PackageManager = "yarn"
+	PackageManagerBun  PackageManager = "bun"
+)
+
+// lockfiles lists the lockfile names of each package manager, the default one first. Installs
+// clear them all from the project directory.
+var lockfiles = map[PackageManager][]string{
+	PackageManagerNPM:  {"package-lock.json", "npm-shrinkwrap.json"},
+	PackageManagerPNPM: {"pnpm-lock.yaml"},
+	PackageManagerYarn: {"yarn.lock"},
+	PackageManagerBun:  {"bun.lock", "bun.lockb"},
+}
+
+// installArgs returns the command installing exactly what the lockfile locks, failing if it is
+// out of date with package.json.
+func (pm PackageManager) installArgs() []string {
+	switch pm {
+	case PackageManagerNPM:
+		return []string{"npm", "ci"}
+	default:
+		return []string{string(pm), "install", "--frozen-lockfile"}
+	}
+}
+
+// NodeOptions configures how Node sandboxes install packages.
+type NodeOptions struct {
+	PackageManager PackageManager // Package manager to install with; inferred from the lockfile if empty
+	ProjectDir     string         // Absolute directory of the sandbox to install into; "/tmp/.msb-node" if empty
+}
+
+// WithNodeOptions sets how InstallFromLockfile installs packages in Node sandboxes.
+func WithNodeOptions(o NodeOptions) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.nodeOptions = o
+	}
+}
+
+// Lockfile is a lockfile along with the package.json it locks, read from host files or given
+// as contents.
+type Lockfile struct {
+	Path        string // Host path of the lockfile; package.json is read from the same directory
+	Name        string // File name, e.g. "pnpm-lock.yaml"; if empty, that of Path or else the default of NodeOptions.PackageManager
+	Data        []byte // Lockfile contents, used instead of reading Path
+	PackageJSON []byte // package.json contents, used instead of reading it next to Path
+}
+
+// NodeManager installs packages in a Node sandbox.
+type NodeManager interface {
+	// InstallFromLockfile installs exactly the packages lockfile locks into NodeOptions.ProjectDir,
+	// with npm ci or the "--frozen-lockfile" install of the other package managers, and makes them
+	// available to code and to commands run through the handle: require finds them from any
+	// directory, and the binaries they provide are on PATH. Installs replace the package.json and
+	// lockfile of earlier ones. A lockfile out of date with its package.json fails the install; a
+	// package manager the sandbox lacks fails with ErrPackageManagerNotFound.
+	InstallFromLockfile(ctx context.Context, lockfile Lockfile) error
+}
+
+// nodeProject is the project directory installed into with NodeManager.
+type nodeProject struct {
+	dir string
+	env map[string]string // variables commands run with
+}
+
+func (ls *langSandbox) Node() NodeManager {
+	return nodeManager{ls.b, ls.l}
+}
+
+type nodeManager struct {
+	b *baseMicroSandbox
+	l progLang
+}
+
+func (n nodeManager) InstallFromLockfile(ctx context.Context, lockfile Lockfile) error {
+	if n.l != langNodeJs {
+		return fmt.Errorf("%w: sandbox is %s", ErrNotNodeSandbox, n.l)
+	}
+	if n.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	opts := n.b.cfg.nodeOptions
+	dir := cmp.Or(opts.ProjectDir, defaultNodeProjectDir)
+	if !path.IsAbs(dir) {
+		return fmt.Errorf("%w: project directory %q is not absolute", ErrInvalidLockfile, dir)
+	}
+	name, lock, pkg, err := lockfile.load(opts.PackageManager)
+	if err != nil {
+		return err
+	}
+	pm := opts.PackageManager
+	if pm == "" {
+		pm = packageManagerOf(name)
+	}
+	n.b.cfg.log(ctx).Info("Installing packages from lockfile", "sandbox", n.b.cfg.name, "lockfile", name, "package_manager", pm, "dir", dir)
+
+	n.b.bumpEnvGeneration(ctx, string(pm), dir, name, string(lock), string(pkg))
+
+	// clear the manifests of earlier installs, which may be of another package manager
+	clear := `mkdir -p "$1" && cd "$1" &&
+rm -f package.json package-lock.json npm-shrinkwrap.json pnpm-lock.yaml yarn.lock bun.lock bun.lockb`
+	if err := n.runCommand(ctx, "sh", []string{"-c", clear, "sh", dir}); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToInstallPackages, err)
+	}
+	if err := n.b.upload(ctx, dir+"/package.json", pkg); err != nil {
+		return fmt.Errorf("%w: package.json: %w", ErrFailedToInstallPackages, err)
+	}
+	if err := n.b.upload(ctx, dir+"/"+name, lock); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrFailedToInstallPackages, name, err)
+	}
+
+	// installs with the package manager's output on stderr, then prints the PATH commands run
+	// with, minus the bin directory of an earlier install
+	script := `command -v "$2" >/dev/null 2>&1 || exit 127
+cd "$1" && shift && "$@" >&2 || exit
+p=$PATH
+[ -n "$NODE_PATH" ] && p=${p#"$NODE_PATH/.bin:"}
+printf %s "$p"`
+	args := append([]string{"-c", script, "sh", dir}, pm.installArgs()...)
+	exec, err := commandRunner{b: n.b}.RunWithOptions(ctx, "sh", args, RunOptions{})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToInstallPackages, err)
+	}
+	if exec.GetExitCode() == 127 {
+		return fmt.Errorf("%w: %s", ErrPackageManagerNotFound, pm)
+	}
+	if err := commandErr(exec); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToInstallPackages, err)
+	}
+	basePath, _ := exec.GetOutput()
+	modules := dir + "/node_modules"
+	project := &nodeProject{dir: dir, env: map[string]string{
+		"NODE_PATH": modules,
+		"PATH":      modules + "/.bin:" + strings.TrimSpace(basePath),
+	}}
+
+	code, err := codeRunner{n.b, n.l}.RunContext(ctx, nodePathCode(modules))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToInstallPackages, err)
+	}
+	if code.HasError() {
+		stderr, _ := code.GetError()
+		return fmt.Errorf("%w: %s", ErrFailedToInstallPackages, strings.TrimSpace(stderr))
+	}
+	n.b.node.Store(project)
+	return nil
+}
+
+// runCommand runs a command, failing if it doesn't succeed.
+func (n nodeManager) runCommand(ctx context.Context, cmd string, args []string) error {
+	exec, err := commandRunner{b: n.b}.RunWithOptions(ctx, cmd, args, RunOptions{})
+	if err != nil {
+		return err
+	}
+	return commandErr(exec)
+}
+
+// load returns the lockfile's name and contents and those of its package.json.
+func (lf Lockfile) load(pm PackageManager) (name string, lock, pkg []byte, err error) {
+	if pm != "" && lockfiles[pm] == nil {
+		return "", nil, nil, fmt.Errorf("%w: %q", ErrUnknownPackageManager, pm)
+	}
+	name, lock, pkg = lf.Name, lf.Data, lf.PackageJSON
+	if lock == nil {
+		if lf.Path == "" {
+			return "", nil, nil, fmt.Errorf("%w: neither a path nor contents given", ErrInvalidLockfile)
+		}
+		if lock, err = os.ReadFile(lf.Path); err != nil {
+			return "", nil, nil, fmt.Errorf("%w: %w", ErrInvalidLockfile, err)
+		}
+	}
+	if name == "" && lf.Path != "" {
+		name = filepath.Base(lf.Path)
+	}
+	if name == "" && pm != "" {
+		name = lockfiles[pm][0]
+	}
+	owner := packageManagerOf(name)
+	switch {
+	case name == "":
+		return "", nil, nil, fmt.Errorf("%w: name or package manager needed", ErrInvalidLockfile)
+	case owner == "":
+		return "", nil, nil, fmt.Errorf("%w: %q is not a known lockfile", ErrInvalidLockfile, name)
+	case pm != "" && owner != pm:
+		return "", nil, nil, fmt.Errorf("%w: %s is a lockfile of %s, not %s", ErrInvalidLockfile, name, owner, pm)
+	}
+	if pkg == nil {
+		if lf.Path == "" {
+			return "", nil, nil, fmt.Errorf("%w: package.json missing", ErrInvalidLockfile)
+		}
+		if pkg, err = os.ReadFile(filepath.Join(filepath.Dir(lf.Path), "package.json")); err != nil {
+			return "", nil, nil, fmt.Errorf("%w: %w", ErrInvalidLockfile, err)
+		}
+	}
+	return name, lock, pkg, nil
+}
+
+// packageManagerOf returns the package manager whose lockfile is named name; empty if none.
+func packageManagerOf(name string) PackageManager {
+	for pm, names := range lockfiles {
+		for _, n := range names {
+			if n == name {
+				return pm
+			}
+		}
+	}
+	return ""
+}
+
+// nodePathCode returns JavaScript code putting modules first on the REPL's module search path,
+// in place of those of an earlier install.
+func nodePathCode(modules string) string {
+	return `(() => {
+	const path = require("path");
+	const dir = ` + strconv.Quote(modules) + `;
+	const key = Symbol.for("msb.nodePath");
+	const prev = globalThis[key];
+	const rest = (process.env.NODE_PATH || "").split(path.delimiter).filter((p) => p && p !== dir && p !== prev);
+	process.env.NODE_PATH = [dir, ...rest].join(path.delimiter);
+	globalThis[key] = dir;
+	require("module").Module._initPaths();
+})();
+`
+}
+
+// Node-related errors
+var (
+	ErrNotNodeSandbox         = errors.New("not a Node sandbox")
+	ErrInvalidLockfile        = errors.New("invalid lockfile")
+	ErrUnknownPackageManager  = errors.New("unknown package manager")
+	ErrPackageManagerNotFound = errors.New("package manager not found in sandbox")
+)
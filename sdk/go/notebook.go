@@ -0,0 +1,157 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NotebookResult is the result of running a Jupyter notebook with CodeRunner.RunNotebook.
+type NotebookResult struct {
+	Cells []NotebookCell // Code cells in notebook order; markdown and raw cells are left out
+}
+
+// Failed returns the first cell that failed to run or reported an error, or nil if none did.
+func (nr NotebookResult) Failed() *NotebookCell {
+	for i := range nr.Cells {
+		if cell := &nr.Cells[i]; cell.Err != nil || cell.Execution.HasError() {
+			return cell
+		}
+	}
+	return nil
+}
+
+// NotebookCell is the result of a notebook's code cell.
+type NotebookCell struct {
+	Index     int           // Position of the cell among all cells of the notebook
+	Source    string        // Code of the cell
+	Skipped   bool          // Whether the cell didn't run because a cell before it failed
+	Execution CodeExecution // Result of the run; zero if the cell was skipped or failed to run
+	Output    string        // Standard output
+	Error     string        // Error output
+	Duration  time.Duration // How long the cell took to run
+	Err       error         // Why the cell failed to run
+}
+
+// ipynb is the part of the Jupyter notebook format (nbformat 4) needed to run a notebook.
+type ipynb struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"` // a string or a list of lines
+	} `json:"cells"`
+}
+
+func (cr codeRunner) RunNotebook(ctx context.Context, notebook []byte) (NotebookResult, error) {
+	if cr.b.state.Load() != started {
+		return NotebookResult{}, ErrSandboxNotStarted
+	}
+	var nb ipynb
+	if err := json.Unmarshal(notebook, &nb); err != nil {
+		return NotebookResult{}, fmt.Errorf("%w: %w", ErrInvalidNotebook, err)
+	}
+	if nb.NBFormat != 4 {
+		return NotebookResult{}, fmt.Errorf("%w: nbformat %d is not supported", ErrInvalidNotebook, nb.NBFormat)
+	}
+	if lang := notebookLanguage(nb); lang != "" && lang != cr.l.String() {
+		return NotebookResult{}, fmt.Errorf("%w: notebook is %s, sandbox is %s", ErrInvalidNotebook, lang, cr.l)
+	}
+
+	var result NotebookResult
+	for i, c := range nb.Cells {
+		if c.CellType != "code" {
+			continue
+		}
+		source, err := cellSource(c.Source)
+		if err != nil {
+			return NotebookResult{}, fmt.Errorf("%w: cell %d: %w", ErrInvalidNotebook, i, err)
+		}
+		result.Cells = append(result.Cells, NotebookCell{Index: i, Source: source})
+	}
+
+	for i := range result.Cells {
+		cell := &result.Cells[i]
+		if failed := result.Failed(); failed != nil && failed != cell {
+			cell.Skipped = true
+			continue
+		}
+		if strings.TrimSpace(cell.Source) == "" {
+			continue
+		}
+		code := cell.Source
+		if cr.l == langPython {
+			code = translateShellEscapes(code)
+		}
+		start := time.Now()
+		cell.Execution, cell.Err = cr.RunContext(ctx, code)
+		cell.Duration = time.Since(start)
+		cell.Output, _ = cell.Execution.GetOutput()
+		cell.Error, _ = cell.Execution.GetError()
+	}
+	return result, nil
+}
+
+// notebookLanguage returns the language the notebook declares, in the SDK's naming.
+func notebookLanguage(nb ipynb) string {
+	lang := nb.Metadata.LanguageInfo.Name
+	if lang == "" {
+		lang = nb.Metadata.KernelSpec.Language
+	}
+	switch lang = strings.ToLower(lang); lang {
+	case "javascript", "node", "nodejs":
+		return langNodeJs.String()
+	default:
+		return lang
+	}
+}
+
+// cellSource joins a cell's source, which nbformat allows to be a string or a list of lines.
+func cellSource(raw json.RawMessage) (string, error) {
+	var source string
+	if err := json.Unmarshal(raw, &source); err == nil {
+		return source, nil
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// translateShellEscapes rewrites the IPython shell escapes plain Python doesn't understand,
+// "!command" and "%pip ...", into subprocess calls; other magics are left as they are.
+func translateShellEscapes(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := line[:len(line)-len(trimmed)]
+		var command string
+		switch {
+		case strings.HasPrefix(trimmed, "!"):
+			command = trimmed[1:]
+		case strings.HasPrefix(trimmed, "%pip "):
+			command = "pip " + trimmed[len("%pip "):]
+		default:
+			continue
+		}
+		lines[i] = indent + `__import__("subprocess").run(` + strconv.Quote(command) + `, shell=True)`
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Notebook-related errors
+var (
+	ErrInvalidNotebook = errors.New("invalid notebook")
+)
@@ -16,14 +16,22 @@ type Option func(*baseMicroSandbox)
 
 // WithServerUrl configures the Microsandbox server URL.
 // If not specified, defaults to MSB_SERVER_URL environment variable or http://127.0.0.1:5555.
+// The scheme defaults to http, and a trailing slash is ignored. An invalid URL, or one with a
+// scheme other than http or https, makes every request fail with ErrInvalidServerURL. IPv6
+// hosts may be given with or without brackets ("[::1]:5555", "::1"). Overrides WithDiscovery.
 func WithServerUrl(serverUrl string) Option {
 	return func(msb *baseMicroSandbox) {
 		msb.cfg.serverUrl = serverUrl
+		msb.cfg.discovery = nil
+		msb.cfg.clock = nil // another server, another clock
 	}
 }
 
-// WithName sets a custom name for the sandbox instance.
-// If not specified, a random name will be generated.
+// WithName sets a custom name for the sandbox instance: 1 to 63 ASCII letters, digits, hyphens and
+// underscores, starting with a letter or digit. Other names make every request fail with an
+// *InvalidNameError.
+// If not specified, a name is produced by the configured name generator (see WithNameGenerator
+// and WithNamePrefix), or a random one is generated.
 func WithName(name string) Option {
 	return func(msb *baseMicroSandbox) {
 		msb.cfg.name = name
@@ -35,6 +43,18 @@ func WithName(name string) Option {
 func WithApiKey(apiKey string) Option {
 	return func(msb *baseMicroSandbox) {
 		msb.cfg.apiKey = apiKey
+		msb.cfg.noAuth = false
+		msb.cfg.refreshed = nil // don't inherit keys refreshed for a Client's key
+	}
+}
+
+// WithNoAuth configures the sandbox for servers that don't require authentication, such as local
+// development servers: no API key is needed and requests are sent without an Authorization header.
+func WithNoAuth() Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.apiKey = ""
+		msb.cfg.noAuth = true
+		msb.cfg.refreshed = nil
 	}
 }
 
@@ -55,13 +75,29 @@ func WithReqIdProducer(reqIdPrd ReqIdProducer) Option {
 }
 
 // WithHTTPClient configures a custom HTTP client for server communication.
-// Useful for setting timeouts, proxies, or other HTTP-level configuration.
+// Useful for setting timeouts, proxies, or other HTTP-level configuration. The client's transport
+// is used as is, so it only goes through a proxy if its Proxy field says so.
 func WithHTTPClient(c *http.Client) Option {
 	return func(msb *baseMicroSandbox) {
 		msb.rpcClient = newJsonRPCHTTPClient(c)
 	}
 }
 
+// WithRetryPolicy configures how RPCs that failed before reaching the server, or that the
+// server rejected as overloaded, are retried. By default, requests are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.retry = policy
+	}
+}
+
+// needsAPIKey reports whether requests need an API key that no option provided.
+func (c *config) needsAPIKey() bool {
+	// tenants with a token provider get their key on the first request
+	tenantKey := c.tenant != "" && c.tenantTokens != nil && c.refreshed != nil
+	return c.apiKey == "" && !c.noAuth && !tenantKey && c.containerRuntime == ""
+}
+
 // --- internal constructor operations ---
 
 func fillDefaultConfigs() Option {
@@ -73,6 +109,20 @@ func fillDefaultConfigs() Option {
 				msb.cfg.serverUrl = defaultServerUrl
 			}
 		}
+		if d := msb.cfg.discovery; d != nil {
+			// identifies the servers behind the SRV name, e.g. in the API versions negotiated
+			msb.cfg.serverUrl, msb.cfg.serverUrlErr = d.scheme+"://"+d.name, nil
+		} else if serverUrl, err := normalizeServerURL(msb.cfg.serverUrl); err != nil {
+			msb.cfg.serverUrlErr = err
+		} else {
+			msb.cfg.serverUrl, msb.cfg.serverUrlErr = serverUrl, nil
+		}
+		if msb.cfg.endpointPath == "" {
+			msb.cfg.endpointPath = defaultEndpointPath
+		}
+		if msb.cfg.name == "" && msb.cfg.nameGen != nil {
+			msb.cfg.name = generateName(&msb.cfg)
+		}
 		if msb.cfg.name == "" {
 			b := make([]byte, 4) // 4 bytes == 8 hex chars
 			if _, err := rand.Read(b); err != nil {
@@ -80,7 +130,9 @@ func fillDefaultConfigs() Option {
 			}
 			msb.cfg.name = fmt.Sprintf(defaultNameTemplate, b)
 		}
-		if msb.cfg.apiKey == "" {
+		msb.cfg.name = msb.cfg.scopedName(msb.cfg.name)
+		msb.cfg.nameErr = validateName(msb.cfg.name)
+		if msb.cfg.needsAPIKey() {
 			if envApiKey := os.Getenv("MSB_API_KEY"); envApiKey != "" {
 				msb.cfg.apiKey = envApiKey
 			} else {
@@ -90,6 +142,12 @@ func fillDefaultConfigs() Option {
 		if msb.cfg.reqIDPrd == nil {
 			msb.cfg.reqIDPrd = uuid.NewString
 		}
+		if msb.cfg.refreshed == nil {
+			msb.cfg.refreshed = &refreshedKey{}
+		}
+		if msb.cfg.clock == nil {
+			msb.cfg.clock = &clockEstimator{}
+		}
 	}
 }
 
@@ -98,13 +156,20 @@ func fillDefaultLogger() Option {
 		if msb.cfg.logger == nil {
 			msb.cfg.logger = NoOpLogger{}
 		}
+		msb.cfg.logger = filterLogger(msb.cfg.logger, msb.cfg.logLevel, msb.cfg.logSampler)
 	}
 }
 
 func fillDefaultRPCClient() Option {
 	return func(msb *baseMicroSandbox) {
 		if msb.rpcClient == nil {
-			msb.rpcClient = newDefaultJsonRPCHTTPClient()
+			msb.rpcClient = newDefaultJsonRPCHTTPClient(proxyFromEnvironment().proxy)
+		}
+		if _, ok := msb.rpcClient.(*containerRPCClient); msb.cfg.containerRuntime != "" && !ok {
+			msb.rpcClient = &containerRPCClient{runtime: msb.cfg.containerRuntime}
+		}
+		if _, wrapped := msb.rpcClient.(*fallbackRPCClient); msb.cfg.localEngine != nil && !wrapped {
+			msb.rpcClient = newFallbackRPCClient(msb.rpcClient, msb.cfg.localEngine)
 		}
 	}
 }
@@ -113,5 +178,5 @@ func fillDefaultRPCClient() Option {
 var (
 	ErrLanguageMustBeSpecified    = errors.New("language must be specified")
 	ErrFailedToGenerateRandomName = errors.New("failed to generate random name")
-	ErrAPIKeyMustBeSpecified      = errors.New("API key must be specified either via WithApiKey() or MSB_API_KEY environment variable")
+	ErrAPIKeyMustBeSpecified      = errors.New("API key must be specified either via WithApiKey() or MSB_API_KEY environment variable, or disabled with WithNoAuth()")
 )
@@ -0,0 +1,75 @@
+// Package otelmsb reports the resource usage of Microsandbox sandboxes as OpenTelemetry metrics.
+//
+// A single option has every sandbox created with it report its CPU, memory and disk usage as
+// observable gauges, labeled with its name and namespace, from the moment it starts until it
+// stops:
+//
+//	client := msb.NewClient(
+//		msb.WithServerUrl("http://localhost:5555"),
+//		otelmsb.WithMetrics(nil, 15*time.Second), // nil for the global MeterProvider
+//	)
+//
+// otelmsb is a module of its own, so the SDK doesn't depend on OpenTelemetry.
+package otelmsb
+
+import (
+	"context"
+	"time"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ScopeName is the instrumentation scope of the meter the gauges are created with.
+const ScopeName = "github.com/microsandbox/microsandbox/sdk/go/otelmsb"
+
+// WithMetrics returns an option having the sandboxes created with it read their metrics every
+// interval and report them as the gauges msb.GaugeCPUUtilization, msb.GaugeMemoryUsage and
+// msb.GaugeDiskUsage of provider, or of the global MeterProvider if provider is nil. The gauges
+// are registered once per call, so the option should be created once and shared, e.g. by passing
+// it to msb.NewClient. Failures to register them are reported to otel.Handle, and leave the
+// sandboxes unreported.
+func WithMetrics(provider metric.MeterProvider, interval time.Duration) msb.Option {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	gauges := msb.NewMetricsGauges(interval)
+	if err := register(provider.Meter(ScopeName), gauges); err != nil {
+		otel.Handle(err)
+	}
+	return msb.WithMetricsGauges(gauges)
+}
+
+// register has meter report the readings of gauges.
+func register(meter metric.Meter, gauges *msb.MetricsGauges) error {
+	cpu, err := meter.Float64ObservableGauge(msb.GaugeCPUUtilization,
+		metric.WithUnit("%"), metric.WithDescription("CPU utilization of the sandbox"))
+	if err != nil {
+		return err
+	}
+	mem, err := meter.Int64ObservableGauge(msb.GaugeMemoryUsage,
+		metric.WithUnit("By"), metric.WithDescription("Memory used by the sandbox"))
+	if err != nil {
+		return err
+	}
+	disk, err := meter.Int64ObservableGauge(msb.GaugeDiskUsage,
+		metric.WithUnit("By"), metric.WithDescription("Disk space used by the sandbox"))
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, r := range gauges.Readings() {
+			attrs := metric.WithAttributes(
+				attribute.String(msb.GaugeAttrSandbox, r.Sandbox),
+				attribute.String(msb.GaugeAttrNamespace, r.Namespace),
+			)
+			o.ObserveFloat64(cpu, r.Metrics.CPU, attrs)
+			o.ObserveInt64(mem, int64(r.Metrics.MemoryBytes), attrs)
+			o.ObserveInt64(disk, int64(r.Metrics.DiskBytes), attrs)
+		}
+		return nil
+	}, cpu, mem, disk)
+	return err
+}
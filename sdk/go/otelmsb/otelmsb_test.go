@@ -0,0 +1,83 @@
+package otelmsb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	msb "github.com/microsandbox/microsandbox/sdk/go"
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collect returns the sandboxes each gauge has a data point for.
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string][]string {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	points := make(map[string][]string)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			var sandboxes []string
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					name, _ := dp.Attributes.Value(msb.GaugeAttrSandbox)
+					sandboxes = append(sandboxes, name.AsString())
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					name, _ := dp.Attributes.Value(msb.GaugeAttrSandbox)
+					sandboxes = append(sandboxes, name.AsString())
+				}
+			}
+			points[m.Name] = sandboxes
+		}
+	}
+	return points
+}
+
+func TestWithMetrics(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	reader := sdkmetric.NewManualReader()
+	client := msb.NewClient(msb.WithServerUrl(srv.URL), msb.WithApiKey("k"),
+		WithMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), 10*time.Millisecond))
+
+	sandbox := client.NewPythonSandbox(msb.WithName("reported"))
+	if err := sandbox.Start(msb.StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		points := collect(t, reader)
+		if len(points[msb.GaugeMemoryUsage]) == 1 {
+			for _, gauge := range []string{msb.GaugeCPUUtilization, msb.GaugeMemoryUsage, msb.GaugeDiskUsage} {
+				if got := points[gauge]; len(got) != 1 || got[0] != "reported" {
+					t.Errorf("%s reported for %q, want the started sandbox", gauge, got)
+				}
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gauges never reported the started sandbox: %v", points)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := sandbox.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if points := collect(t, reader); len(points[msb.GaugeMemoryUsage]) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("gauges still report the stopped sandbox")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
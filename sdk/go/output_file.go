@@ -0,0 +1,255 @@
+package msb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// RunOptions control where the output of CommandRunner.RunWithOptions goes, and what else is
+// recorded about the run.
+type RunOptions struct {
+	// StdoutFile and StderrFile are paths on the host the command's standard output and error are
+	// written to, a line at a time as they are read from the server's response, so multi-hundred-MB
+	// build logs never have to fit in memory. The files are created, or truncated, before the
+	// command runs. Both may name the same file to interleave the streams as they were produced.
+	// Output written to a file is left out of the returned CommandExecution.
+	StdoutFile string
+	StderrFile string
+
+	// TrackFSChanges lists the files the command created, modified and deleted under its working
+	// directory, for CommandExecution.FSChanges, so agents find the artifacts a command produced
+	// without guessing paths. The directory is listed with find(1) before and after the command,
+	// which costs two extra round trips and time proportional to the number of files in it.
+	TrackFSChanges bool
+}
+
+func (cr commandRunner) RunWithOptions(ctx context.Context, cmd string, args []string, opts RunOptions) (CommandExecution, error) {
+	if cr.b.state.Load() != started {
+		return CommandExecution{}, ErrSandboxNotStarted
+	}
+	if err := cr.validate(); err != nil {
+		return CommandExecution{}, err
+	}
+	if err := cr.b.cfg.policy.check(cmd, args); err != nil {
+		return CommandExecution{}, err
+	}
+	sink, err := openOutputFiles(opts)
+	if err != nil {
+		return CommandExecution{}, fmt.Errorf("%w: %w: %w", ErrFailedToRunCommand, ErrFailedToWriteOutput, err)
+	}
+	defer sink.close()
+
+	ctx, cancel := cr.b.execContext(ctx)
+	defer cancel()
+	var snap *fsSnapshot
+	if opts.TrackFSChanges {
+		if snap, err = cr.snapshotFS(ctx); err != nil {
+			return CommandExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+		}
+	}
+	result, err := cr.runTo(ctx, cmd, args, sink)
+	if err == nil {
+		if closeErr := sink.close(); closeErr != nil {
+			err = fmt.Errorf("%w: %w", ErrFailedToWriteOutput, closeErr)
+		}
+	}
+	if err != nil {
+		if snap != nil {
+			cr.discardSnapshot(ctx, snap)
+		}
+		return CommandExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+	}
+
+	exec := cr.b.commandExecution(ctx, result)
+	if snap != nil {
+		// the command did run, so its result is returned along with the error
+		if exec.fsChanges, err = cr.diffFS(ctx, snap); err != nil {
+			return exec, err
+		}
+	}
+	stderr, _ := exec.GetError()
+	if err := diskQuotaErr(!exec.IsSuccess(), stderr); err != nil {
+		return exec, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+	}
+	return exec, nil
+}
+
+// outputSink receives the output lines of a command result while the response is being decoded.
+type outputSink interface {
+	// writer returns where lines of stream go, or nil to keep them in the result.
+	writer(stream string) io.Writer
+}
+
+// outputFiles is the outputSink writing to the files named in RunOptions.
+type outputFiles struct {
+	files   []*os.File
+	writers map[string]*bufio.Writer // stream -> writer
+	closed  bool
+}
+
+func openOutputFiles(opts RunOptions) (*outputFiles, error) {
+	o := &outputFiles{writers: make(map[string]*bufio.Writer)}
+	byPath := make(map[string]*bufio.Writer)
+	for stream, path := range map[string]string{"stdout": opts.StdoutFile, "stderr": opts.StderrFile} {
+		if path == "" {
+			continue
+		}
+		if w, ok := byPath[path]; ok {
+			o.writers[stream] = w
+			continue
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+		o.files = append(o.files, f)
+		byPath[path] = bufio.NewWriter(f)
+		o.writers[stream] = byPath[path]
+	}
+	return o, nil
+}
+
+func (o *outputFiles) writer(stream string) io.Writer {
+	if w, ok := o.writers[stream]; ok {
+		return w
+	}
+	return nil
+}
+
+// close flushes and closes the files, reporting the first error. Closing twice is a no-op.
+func (o *outputFiles) close() error {
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	var firstErr error
+	for _, w := range o.writers {
+		if err := w.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, f := range o.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// decodeResultTo decodes a command result from dec, passing the output lines of streams sink has a
+// writer for to that writer, each followed by a newline, instead of keeping them in the result.
+// Only the remaining fields and lines are held in memory.
+func decodeResultTo(dec *json.Decoder, sink outputSink) (json.RawMessage, error) {
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok == nil {
+		return json.RawMessage("null"), nil
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("result is %v, not an object", tok)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		if key != "output" {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			fields[key] = value
+			continue
+		}
+		kept, err := decodeOutputTo(dec, sink)
+		if err != nil {
+			return nil, err
+		}
+		if fields[key], err = json.Marshal(kept); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil { // closing '}'
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// decodeOutputTo decodes an "output" array, returning the lines that weren't written to sink.
+func decodeOutputTo(dec *json.Decoder, sink outputSink) ([]OutputLine, error) {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		return nil, err
+	}
+	if tok != json.Delim('[') {
+		return nil, fmt.Errorf("output is %v, not an array", tok)
+	}
+	kept := []OutputLine{}
+	for dec.More() {
+		var line OutputLine
+		if err := dec.Decode(&line); err != nil {
+			return nil, err
+		}
+		w := sink.writer(line.Stream)
+		if w == nil {
+			kept = append(kept, line)
+			continue
+		}
+		if _, err := io.WriteString(w, line.Text+"\n"); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToWriteOutput, err)
+		}
+	}
+	_, err = dec.Token() // closing ']'
+	return kept, err
+}
+
+// decodeResponseTo decodes a JSON-RPC response from r, passing the command result's output to sink
+// with decodeResultTo rather than reading the whole response into memory first.
+func decodeResponseTo(r io.Reader, sink outputSink) (jsonRPCResponse, error) {
+	var resp jsonRPCResponse
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return resp, err
+	} else if tok != json.Delim('{') {
+		return resp, fmt.Errorf("%w: %v", errNotJSONObject, tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return resp, err
+		}
+		switch key, _ := tok.(string); key {
+		case "jsonrpc":
+			err = dec.Decode(&resp.JSONRPC)
+		case "id":
+			err = dec.Decode(&resp.ID)
+		case "error":
+			err = dec.Decode(&resp.Error)
+		case "result":
+			resp.Result, err = decodeResultTo(dec, sink)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return resp, err
+		}
+	}
+	_, err := dec.Token() // closing '}'
+	return resp, err
+}
+
+// Output-file-related errors
+var (
+	ErrFailedToWriteOutput = errors.New("failed to write output")
+
+	errNotJSONObject = errors.New("response is not an object")
+)
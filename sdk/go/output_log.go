@@ -0,0 +1,52 @@
+package msb
+
+import "context"
+
+// defaultOutputLogLineLength is how much of each output line WithOutputLogging logs, unless
+// OutputLogging.MaxLineLength sets another length.
+const defaultOutputLogLineLength = 1024
+
+// OutputLogging configures how WithOutputLogging mirrors output into the Logger.
+type OutputLogging struct {
+	Level         LogLevel // Level the lines are logged at
+	MaxLineLength int      // Bytes of each line logged, the rest cut off; 0 for 1024, negative for no limit
+}
+
+// WithOutputLogging mirrors the output of every code run and command into the Logger, a line per
+// message with the sandbox name, stream and line as attributes, so what sandboxes are doing shows
+// in existing log aggregation without touching the code running them. Lines are logged once the
+// execution has finished; output written to files with RunOptions is not logged. The messages
+// are subject to WithLogLevel and, at LogDebug, WithLogSampling like the SDK's own.
+func WithOutputLogging(o OutputLogging) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.outputLogging = &o
+	}
+}
+
+// logOutput mirrors lines into the Logger, if WithOutputLogging is set.
+func (b *baseMicroSandbox) logOutput(ctx context.Context, lines []OutputLine) {
+	o := b.cfg.outputLogging
+	if o == nil {
+		return
+	}
+	logger := b.cfg.log(ctx)
+	log := logger.Debug
+	switch o.Level {
+	case LogInfo:
+		log = logger.Info
+	case LogError:
+		log = logger.Error
+	}
+	limit := o.MaxLineLength
+	if limit == 0 {
+		limit = defaultOutputLogLineLength
+	}
+	for _, line := range lines {
+		args := []any{"sandbox", b.cfg.name, "stream", line.Stream, "line", line.Text}
+		if limit > 0 && len(line.Text) > limit {
+			args[5] = headBytes(line.Text, limit) // a single line, so cut at a character boundary
+			args = append(args, "truncated_from", len(line.Text))
+		}
+		log("Sandbox output", args...)
+	}
+}
@@ -0,0 +1,121 @@
+package msb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// OutputReader returns a reader over the standard output of the execution.
+// The content matches GetOutput, but is decoded lazily from the raw JSON one line at a time,
+// so large outputs can be streamed to a file or parser without building one giant string.
+func (ce CodeExecution) OutputReader() io.Reader {
+	return newStreamReader(ce.Output, "stdout")
+}
+
+// ErrorReader returns a reader over the error output of the execution, decoded lazily like OutputReader.
+func (ce CodeExecution) ErrorReader() io.Reader {
+	return newStreamReader(ce.Output, "stderr")
+}
+
+// OutputReader returns a reader over the standard output of the command.
+// The content matches GetOutput, but is decoded lazily from the raw JSON one line at a time,
+// so large outputs can be streamed to a file or parser without building one giant string.
+func (ce CommandExecution) OutputReader() io.Reader {
+	return newStreamReader(ce.Output, "stdout")
+}
+
+// ErrorReader returns a reader over the error output of the command, decoded lazily like OutputReader.
+func (ce CommandExecution) ErrorReader() io.Reader {
+	return newStreamReader(ce.Output, "stderr")
+}
+
+// streamReader walks the "output" array of an execution result with a token-level decoder
+// and yields the text of the lines belonging to a single stream, newline-separated.
+type streamReader struct {
+	dec     *json.Decoder
+	stream  string
+	pending []byte
+	inArray bool
+	wrote   bool
+	err     error
+}
+
+func newStreamReader(raw json.RawMessage, stream string) *streamReader {
+	return &streamReader{
+		dec:    json.NewDecoder(bytes.NewReader(raw)),
+		stream: stream,
+	}
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.advance()
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// advance decodes up to the next line of the selected stream and stages it in pending.
+// It returns io.EOF once the output array has been fully consumed.
+func (r *streamReader) advance() error {
+	if !r.inArray {
+		if err := r.seekOutputArray(); err != nil {
+			return err
+		}
+		r.inArray = true
+	}
+	for r.dec.More() {
+		var line OutputLine
+		if err := r.dec.Decode(&line); err != nil {
+			return fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+		}
+		if line.Stream != r.stream {
+			continue
+		}
+		if r.wrote {
+			r.pending = append(r.pending, '\n')
+		}
+		r.pending = append(r.pending, line.Text...)
+		r.wrote = true
+		return nil
+	}
+	return io.EOF
+}
+
+// seekOutputArray positions the decoder just inside the top-level "output" array.
+func (r *streamReader) seekOutputArray() error {
+	if tok, err := r.dec.Token(); err != nil || tok != json.Delim('{') {
+		return ErrExecutionNotParsed
+	}
+	for r.dec.More() {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+		}
+		if key, _ := tok.(string); key == "output" {
+			tok, err := r.dec.Token()
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+			}
+			if tok == nil { // "output": null carries no lines
+				return io.EOF
+			}
+			if tok != json.Delim('[') {
+				return ErrExecutionNotParsed
+			}
+			return nil
+		}
+		// skip the value of any other field
+		var skip json.RawMessage
+		if err := r.dec.Decode(&skip); err != nil {
+			return fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+		}
+	}
+	return io.EOF
+}
@@ -0,0 +1,56 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// defaultMaxRequestBytes is the request size limit unless WithMaxRequestBytes sets another.
+const defaultMaxRequestBytes = 16 << 20
+
+// WithMaxRequestBytes limits the size of the encoded requests sent to the server to n bytes
+// (16 MiB by default), so that code embedding a large dataset fails right away with
+// ErrPayloadTooLarge instead of deep in the HTTP stack or at a proxy. A negative n removes the
+// limit.
+func WithMaxRequestBytes(n int) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.maxRequestBytes = n
+	}
+}
+
+// PayloadTooLargeError reports a request that was too large to send, either by the limit set
+// with WithMaxRequestBytes or by the server's. It matches ErrPayloadTooLarge with errors.Is.
+type PayloadTooLargeError struct {
+	Method string // JSON-RPC method of the request
+	Size   int    // Size of the encoded request in bytes; 0 if rejected by the server
+	Limit  int    // Limit it exceeded; 0 if rejected by the server
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	const hint = "pass large data to the sandbox through a mount (StartConfig.Mounts) or download it from within, rather than embedding it in code or arguments"
+	if e.Limit == 0 {
+		return fmt.Sprintf("%v: %s rejected by the server; %s", ErrPayloadTooLarge, e.Method, hint)
+	}
+	return fmt.Sprintf("%v: %s request of %d bytes exceeds the limit of %d bytes; %s", ErrPayloadTooLarge, e.Method, e.Size, e.Limit, hint)
+}
+
+func (e *PayloadTooLargeError) Is(target error) bool {
+	return target == ErrPayloadTooLarge
+}
+
+// checkRequestSize returns a *PayloadTooLargeError if a request of size bytes exceeds the limit.
+func (c *config) checkRequestSize(method string, size int) error {
+	limit := c.maxRequestBytes
+	if limit == 0 {
+		limit = defaultMaxRequestBytes
+	}
+	if limit > 0 && size > limit {
+		return &PayloadTooLargeError{Method: method, Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// Payload-related errors
+var (
+	ErrPayloadTooLarge = errors.New("request payload too large")
+)
@@ -0,0 +1,142 @@
+package msb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PipelineExecution is the result of running a pipeline with CommandRunner.Pipeline.
+// The embedded CommandExecution holds the output of the last stage and the pipeline's overall
+// exit code, which, as in a shell, is the last stage's.
+type PipelineExecution struct {
+	CommandExecution
+	ExitCodes []int // Exit code of every stage, in order; -1 if a stage's status couldn't be read
+}
+
+// Failed returns the index of the first stage that exited with a non-zero code, or -1 if all succeeded.
+func (pe PipelineExecution) Failed() int {
+	for i, code := range pe.ExitCodes {
+		if code != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (cr commandRunner) Pipeline(ctx context.Context, stages ...CmdSpec) (PipelineExecution, error) {
+	if len(stages) == 0 {
+		return PipelineExecution{}, ErrEmptyPipeline
+	}
+	if cr.b.state.Load() != started {
+		return PipelineExecution{}, ErrSandboxNotStarted
+	}
+	if err := cr.validate(); err != nil {
+		return PipelineExecution{}, err
+	}
+	for _, stage := range stages {
+		if err := cr.b.cfg.policy.check(stage.Name, stage.Args); err != nil {
+			return PipelineExecution{}, err
+		}
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return PipelineExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+	}
+	marker := "__msb_pipestatus_" + hex.EncodeToString(nonce) + "__"
+	script := pipelineScript(stages, marker)
+
+	ctx, cancel := cr.b.execContext(ctx)
+	defer cancel()
+	result, err := cr.run(ctx, "sh", []string{"-c", script})
+	if err != nil {
+		return PipelineExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+	}
+	return parsePipelineResult(result.output, marker, len(stages))
+}
+
+// pipelineScript renders stages as a POSIX shell pipeline. Each stage records its exit status in
+// a scratch directory (sh has no PIPESTATUS), and the statuses are printed after the last stage's
+// output, behind marker, for parsePipelineResult to pick up.
+func pipelineScript(stages []CmdSpec, marker string) string {
+	var sb strings.Builder
+	sb.WriteString("d=$(mktemp -d) || exit 127\n")
+	for i, stage := range stages {
+		if i > 0 {
+			sb.WriteString(" | ")
+		}
+		fmt.Fprintf(&sb, `{ %s; echo $? >"$d/%d"; }`, stage.String(), i)
+	}
+	sb.WriteString("\ns=\n")
+	indices := make([]string, len(stages))
+	for i := range stages {
+		indices[i] = strconv.Itoa(i)
+	}
+	fmt.Fprintf(&sb, `for i in %s; do s="$s $(cat "$d/$i" 2>/dev/null || echo -1)"; done`+"\n", strings.Join(indices, " "))
+	fmt.Fprintf(&sb, `printf '%%s%%s\n' %s "$s"`+"\n", marker)
+	fmt.Fprintf(&sb, `rc=$(cat "$d/%d" 2>/dev/null || echo 1)`+"\n", len(stages)-1)
+	sb.WriteString(`rm -rf "$d"` + "\n")
+	sb.WriteString(`exit "$rc"`)
+	return sb.String()
+}
+
+// parsePipelineResult extracts the per-stage statuses from the output and strips the status line,
+// so the returned execution only carries what the stages themselves printed.
+func parsePipelineResult(raw json.RawMessage, marker string, n int) (PipelineExecution, error) {
+	var fields map[string]json.RawMessage
+	var res CommandResult
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return PipelineExecution{}, fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return PipelineExecution{}, fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+	}
+
+	codes := make([]int, n)
+	for i := range codes {
+		codes[i] = -1
+	}
+	for i := len(res.Output) - 1; i >= 0; i-- {
+		line := res.Output[i]
+		at := strings.LastIndex(line.Text, marker)
+		if line.Stream != "stdout" || at < 0 {
+			continue
+		}
+		for j, field := range strings.Fields(line.Text[at+len(marker):]) {
+			if code, err := strconv.Atoi(field); err == nil && j < n {
+				codes[j] = code
+			}
+		}
+		// the marker is glued to the last stage's output if that didn't end in a newline
+		if at > 0 {
+			res.Output[i].Text = line.Text[:at]
+		} else {
+			res.Output = append(res.Output[:i], res.Output[i+1:]...)
+		}
+		break
+	}
+
+	output, err := json.Marshal(res.Output)
+	if err != nil {
+		return PipelineExecution{}, fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+	}
+	fields["output"] = output
+	cleaned, err := json.Marshal(fields)
+	if err != nil {
+		return PipelineExecution{}, fmt.Errorf("%w: %w", ErrExecutionNotParsed, err)
+	}
+
+	exec := CommandExecution{Output: cleaned, parsed: res, parsedOK: true}
+	return PipelineExecution{CommandExecution: exec, ExitCodes: codes}, nil
+}
+
+// Pipeline-related errors
+var (
+	ErrEmptyPipeline = errors.New("pipeline must have at least one stage")
+)
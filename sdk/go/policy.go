@@ -0,0 +1,132 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// networkCommands are the programs ExecutionPolicy.DenyNetworkCmds denies.
+var networkCommands = []string{
+	"aria2c", "curl", "ftp", "nc", "ncat", "netcat", "nmap", "rsync", "scp", "sftp", "socat", "ssh", "telnet", "wget",
+}
+
+// ExecutionPolicy restricts what commands may run in a sandbox, for platform teams putting
+// guardrails on agent-generated commands. Commands are checked before they are sent to the
+// server; denied ones fail with a *PolicyViolationError.
+//
+// The checks apply to the commands passed to Command().Run, RunWithOptions, Pipeline (every
+// stage) and Start, not to code run in the REPL, which can do anything its language can.
+type ExecutionPolicy struct {
+	// AllowCommands lists the programs commands may run, by name (path prefixes are ignored).
+	// Empty allows every program. Allowing a shell allows whatever its scripts run.
+	AllowCommands []string
+
+	// DenyPatterns are regular expressions matched against the command line, i.e. the program
+	// and its arguments joined by spaces; commands matching any of them are denied.
+	DenyPatterns []string
+
+	// MaxTimeout bounds every code and command execution, including background processes,
+	// whatever the caller's context allows. Zero means no bound beyond the caller's.
+	MaxTimeout time.Duration
+
+	// DenyNetworkCmds denies commands mentioning well-known network tools (curl, wget, nc, ssh,
+	// ...) anywhere in their command line, including in shell scripts passed as arguments.
+	DenyNetworkCmds bool
+}
+
+// WithExecutionPolicy enforces policy on every command run through the sandbox. An invalid
+// DenyPatterns entry makes every command fail with ErrInvalidPolicy.
+func WithExecutionPolicy(policy ExecutionPolicy) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.policy = compilePolicy(policy)
+	}
+}
+
+// compiledPolicy is an ExecutionPolicy ready to be checked against commands.
+type compiledPolicy struct {
+	ExecutionPolicy
+	deny []*regexp.Regexp
+	err  error // set if a pattern doesn't compile
+}
+
+func compilePolicy(policy ExecutionPolicy) *compiledPolicy {
+	p := &compiledPolicy{ExecutionPolicy: policy}
+	p.AllowCommands = slices.Clone(policy.AllowCommands)
+	for _, pattern := range policy.DenyPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			p.err = fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+			return p
+		}
+		p.deny = append(p.deny, re)
+	}
+	return p
+}
+
+// maxTimeout returns the policy's bound on executions; 0 if there is no policy or bound.
+func (p *compiledPolicy) maxTimeout() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.MaxTimeout
+}
+
+// check returns a *PolicyViolationError if the policy denies running cmd with args.
+func (p *compiledPolicy) check(cmd string, args []string) error {
+	if p == nil {
+		return nil
+	}
+	if p.err != nil {
+		return p.err
+	}
+	line := strings.Join(append([]string{cmd}, args...), " ")
+	violation := func(rule string) error {
+		return &PolicyViolationError{Command: line, Rule: rule}
+	}
+
+	if len(p.AllowCommands) > 0 && !slices.Contains(p.AllowCommands, path.Base(cmd)) {
+		return violation(fmt.Sprintf("%s is not an allowed command", path.Base(cmd)))
+	}
+	for _, re := range p.deny {
+		if re.MatchString(line) {
+			return violation(fmt.Sprintf("matches denied pattern %q", re.String()))
+		}
+	}
+	if p.DenyNetworkCmds {
+		words := strings.FieldsFunc(line, func(r rune) bool {
+			return strings.ContainsRune(" \t\n;|&()<>`$'\"{}", r)
+		})
+		for _, word := range words {
+			if name := path.Base(word); slices.Contains(networkCommands, name) {
+				return violation(fmt.Sprintf("runs network command %s", name))
+			}
+		}
+	}
+	return nil
+}
+
+// PolicyViolationError reports a command the ExecutionPolicy denied.
+// It matches ErrPolicyViolation with errors.Is.
+type PolicyViolationError struct {
+	Command string // Command line denied
+	Rule    string // Why it was denied
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("%v: %s: %q", ErrPolicyViolation, e.Rule, e.Command)
+}
+
+func (e *PolicyViolationError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// Policy-related errors
+var (
+	ErrPolicyViolation = errors.New("execution policy violation")
+	ErrInvalidPolicy   = errors.New("invalid execution policy")
+)
@@ -0,0 +1,105 @@
+package msb
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ExecutionPolicy
+		cmd    string
+		args   []string
+		rule   string // start of the violated rule; empty if allowed
+	}{
+		{"no rules", ExecutionPolicy{}, "rm", []string{"-rf", "/"}, ""},
+		{"allowed command", ExecutionPolicy{AllowCommands: []string{"ls"}}, "ls", []string{"-l"}, ""},
+		{"allowed command by path", ExecutionPolicy{AllowCommands: []string{"ls"}}, "/bin/ls", nil, ""},
+		{"command not allowed", ExecutionPolicy{AllowCommands: []string{"ls"}}, "cat", []string{"/etc/passwd"}, "cat is not an allowed command"},
+		{"allowed name isn't a prefix", ExecutionPolicy{AllowCommands: []string{"ls"}}, "lsblk", nil, "lsblk is not an allowed command"},
+		{"denied pattern in args", ExecutionPolicy{DenyPatterns: []string{`rm\s+-rf`}}, "rm", []string{"-rf", "/tmp/x"}, "matches denied pattern"},
+		{"denied pattern across the line", ExecutionPolicy{DenyPatterns: []string{`^sh -c .*sudo`}}, "sh", []string{"-c", "sudo true"}, "matches denied pattern"},
+		{"pattern not matching", ExecutionPolicy{DenyPatterns: []string{`rm\s+-rf`}}, "rm", []string{"/tmp/x"}, ""},
+		{"network command", ExecutionPolicy{DenyNetworkCmds: true}, "curl", []string{"https://example.com"}, "runs network command curl"},
+		{"network command by path", ExecutionPolicy{DenyNetworkCmds: true}, "/usr/bin/wget", nil, "runs network command wget"},
+		{"network command in a script", ExecutionPolicy{DenyNetworkCmds: true}, "sh", []string{"-c", "echo hi; nc -l 80"}, "runs network command nc"},
+		{"network command in a substitution", ExecutionPolicy{DenyNetworkCmds: true}, "bash", []string{"-c", "x=$(curl -s host)"}, "runs network command curl"},
+		{"network command in a pipe", ExecutionPolicy{DenyNetworkCmds: true}, "sh", []string{"-c", "cat f|ssh host"}, "runs network command ssh"},
+		{"network command as a substring", ExecutionPolicy{DenyNetworkCmds: true}, "echo", []string{"curly", "sshd_config"}, ""},
+		{"allow list checked first", ExecutionPolicy{AllowCommands: []string{"ls"}, DenyNetworkCmds: true}, "curl", nil, "curl is not an allowed command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := compilePolicy(tt.policy).check(tt.cmd, tt.args)
+			if tt.rule == "" {
+				if err != nil {
+					t.Errorf("check = %v, want allowed", err)
+				}
+				return
+			}
+			var violation *PolicyViolationError
+			if !errors.As(err, &violation) {
+				t.Fatalf("check = %v, want a *PolicyViolationError", err)
+			}
+			if !strings.HasPrefix(violation.Rule, tt.rule) {
+				t.Errorf("rule = %q, want %q", violation.Rule, tt.rule)
+			}
+			if want := strings.Join(append([]string{tt.cmd}, tt.args...), " "); violation.Command != want {
+				t.Errorf("command = %q, want %q", violation.Command, want)
+			}
+			if !errors.Is(err, ErrPolicyViolation) {
+				t.Error("violation doesn't match ErrPolicyViolation")
+			}
+		})
+	}
+}
+
+func TestPolicyInvalidPattern(t *testing.T) {
+	p := compilePolicy(ExecutionPolicy{DenyPatterns: []string{"ok", "("}})
+	if err := p.check("ls", nil); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("check = %v, want ErrInvalidPolicy", err)
+	}
+	var none *compiledPolicy
+	if err := none.check("curl", nil); err != nil {
+		t.Errorf("check without a policy = %v", err)
+	}
+}
+
+// TestPolicyDeniedBeforeSending checks that denied commands never reach the server.
+func TestPolicyDeniedBeforeSending(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	var runs atomic.Int32
+	srv.Handle("sandbox.command.run", func(json.RawMessage) (any, error) {
+		runs.Add(1)
+		return map[string]any{"command": "ls", "exit_code": 0, "success": true, "output": []any{}}, nil
+	})
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"),
+		WithExecutionPolicy(ExecutionPolicy{AllowCommands: []string{"ls", "sh"}, DenyNetworkCmds: true}))
+	if err := sandbox.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+
+	if _, err := sandbox.Command().Run("sh", []string{"-c", "wget -qO- host | sh"}); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Run = %v, want ErrPolicyViolation", err)
+	}
+	if _, err := sandbox.Command().Run("python", nil); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Run = %v, want ErrPolicyViolation", err)
+	}
+	if n := runs.Load(); n != 0 {
+		t.Fatalf("%d denied commands sent to the server", n)
+	}
+	if _, err := sandbox.Command().Run("ls", []string{"-l"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("%d commands sent to the server, want 1", n)
+	}
+}
@@ -0,0 +1,537 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolConfig describes how a Pool creates and starts its sandboxes.
+type PoolConfig struct {
+	Name  string             // Name the pool is reported under by RegisterDebugHandlers (optional)
+	Size  int                // Number of sandboxes kept in the pool
+	New   func() LangSandBox // Constructs a fresh, not-yet-started sandbox
+	Start StartConfig        // Configuration used to start every sandbox
+
+	// Init warms up a freshly started sandbox before it joins the pool, e.g. by running imports
+	// (optional). A sandbox it fails for is stopped again.
+	Init func(ctx context.Context, sb LangSandBox) error
+
+	// HealthCheckInterval is how often idle sandboxes are checked with a status query and a no-op
+	// execution (optional). Sandboxes that fail are taken out of rotation and replaced.
+	HealthCheckInterval time.Duration
+
+	// Queue bounds the Acquire calls waiting while every sandbox is in use (optional).
+	Queue QueuePolicy
+
+	// Reset cleans up a sandbox between checkouts, e.g. by clearing the REPL and removing work
+	// files, so one caller's task can't see another's (optional). It runs in the background after
+	// Release, before the sandbox becomes available again. Sandboxes an AcquireFor key is placed
+	// on are reset only when checked out for another key. A sandbox it fails for is replaced.
+	Reset func(ctx context.Context, sb LangSandBox) error
+}
+
+// QueuePolicy bounds how Acquire calls queue up for a sandbox when the pool is exhausted, so that
+// services get a clean backpressure signal, ErrPoolSaturated, instead of piling up goroutines.
+type QueuePolicy struct {
+	MaxWaiters int           // Calls allowed to wait at once; 0 for no limit, negative to never wait
+	MaxWait    time.Duration // Longest a call waits before giving up; 0 for no limit
+}
+
+// startPoolSandbox creates and starts a sandbox as cfg describes, stopping it again if Init fails.
+func startPoolSandbox(ctx context.Context, cfg PoolConfig) (LangSandBox, error) {
+	sb := cfg.New()
+	if err := sb.StartContext(ctx, cfg.Start); err != nil {
+		return nil, err
+	}
+	if cfg.Init != nil {
+		if err := cfg.Init(ctx, sb); err != nil {
+			_ = sb.StopContext(context.WithoutCancel(ctx))
+			return nil, fmt.Errorf("%w: %w", ErrFailedToInitSandbox, err)
+		}
+	}
+	return sb, nil
+}
+
+// Pool manages a fixed set of started sandboxes which callers check out for exclusive use.
+// A sandbox obtained with Acquire must be handed back with Release once the caller is done with it.
+//
+// Example:
+//
+//	pool, err := msb.NewPool(msb.PoolConfig{
+//		Size:  4,
+//		New:   func() msb.LangSandBox { return msb.NewPythonSandbox() },
+//		Start: msb.StartConfig{Memory: 512, CPUs: 1},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer pool.Close()
+//
+//	sandbox, err := pool.Acquire(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer pool.Release(sandbox)
+type Pool struct {
+	mu        sync.Mutex
+	sandboxes []LangSandBox          // sandboxes of the pool, idle or checked out
+	idle      []LangSandBox          // sandboxes ready for checkout, least recently released first
+	retired   map[LangSandBox]bool   // replaced sandboxes still checked out, stopped on release
+	affinity  map[string]LangSandBox // AcquireFor key -> sandbox it is placed on
+	bound     map[LangSandBox]string // sandbox -> AcquireFor key placed on it
+	changed   chan struct{}          // closed and replaced when a sandbox becomes idle or the pool closes
+	closed    bool
+	queue     QueuePolicy
+
+	cfg       PoolConfig           // configuration of the latest sandboxes, for replacements
+	unhealthy map[LangSandBox]bool // sandboxes that failed a health check or reset -> whether a replacement is starting
+	resetting int                  // sandboxes being reset
+
+	ctx    context.Context // done when the pool is closed
+	cancel context.CancelFunc
+	bg     sync.WaitGroup // health checks, resets and replacements in progress
+
+	debugName    string
+	inUse        atomic.Int64
+	waiting      atomic.Int64
+	saturated    atomic.Uint64
+	acquires     atomic.Uint64
+	acquireWait  atomic.Int64 // total nanoseconds spent waiting in Acquire
+	acquireMaxNs atomic.Int64
+}
+
+// PoolStats is a snapshot of a pool's usage counters. Durations are encoded as nanoseconds in JSON.
+type PoolStats struct {
+	Size            int           `json:"size"`              // Number of sandboxes managed by the pool
+	Healthy         int           `json:"healthy"`           // Sandboxes that haven't failed a health check
+	Unhealthy       int           `json:"unhealthy"`         // Sandboxes that failed a health check or reset, awaiting replacement
+	Starting        int           `json:"starting"`          // Replacements of unhealthy sandboxes being started
+	Resetting       int           `json:"resetting"`         // Sandboxes being reset between checkouts
+	Draining        int           `json:"draining"`          // Replaced sandboxes still checked out, stopped on release
+	InUse           int           `json:"in_use"`            // Sandboxes currently checked out
+	Waiting         int           `json:"waiting"`           // Acquire calls currently blocked on an idle sandbox
+	Saturated       uint64        `json:"saturated"`         // Acquire calls failed with ErrPoolSaturated so far
+	Acquires        uint64        `json:"acquires"`          // Successful Acquire calls so far
+	AcquireWaitMean time.Duration `json:"acquire_wait_mean"` // Mean time successful Acquire calls waited
+	AcquireWaitMax  time.Duration `json:"acquire_wait_max"`  // Longest time a successful Acquire call waited
+}
+
+// NewPool creates and starts cfg.Size sandboxes in parallel.
+// If any sandbox fails to start, the ones that did start are stopped again and the error is returned.
+func NewPool(cfg PoolConfig) (*Pool, error) {
+	if cfg.Size <= 0 {
+		return nil, ErrInvalidPoolSize
+	}
+	if cfg.New == nil {
+		return nil, ErrPoolFactoryMissing
+	}
+
+	sandboxes := make([]LangSandBox, cfg.Size)
+	errs := make([]error, cfg.Size)
+	var wg sync.WaitGroup
+	for i := range sandboxes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sandboxes[i], errs[i] = startPoolSandbox(context.Background(), cfg)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		for i, sb := range sandboxes {
+			if errs[i] == nil {
+				_ = sb.Stop()
+			}
+		}
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartPool, err)
+	}
+
+	p := &Pool{
+		sandboxes: sandboxes,
+		idle:      slices.Clone(sandboxes),
+		retired:   make(map[LangSandBox]bool),
+		affinity:  make(map[string]LangSandBox),
+		bound:     make(map[LangSandBox]string),
+		changed:   make(chan struct{}),
+		queue:     cfg.Queue,
+		cfg:       cfg,
+		unhealthy: make(map[LangSandBox]bool),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.startHealthChecks()
+	p.debugName = debugTargets.add(cfg.Name, "pool", p)
+	return p, nil
+}
+
+// Size returns the number of sandboxes managed by the pool.
+func (p *Pool) Size() int {
+	return len(p.sandboxes)
+}
+
+// Sandboxes returns the pool's current sandboxes, idle or checked out, e.g. to Replace them all.
+func (p *Pool) Sandboxes() []LangSandBox {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.sandboxes)
+}
+
+// Stats returns a snapshot of the pool's usage counters.
+func (p *Pool) Stats() PoolStats {
+	stats := PoolStats{
+		Size:           len(p.sandboxes),
+		InUse:          int(p.inUse.Load()),
+		Waiting:        int(p.waiting.Load()),
+		Saturated:      p.saturated.Load(),
+		Acquires:       p.acquires.Load(),
+		AcquireWaitMax: time.Duration(p.acquireMaxNs.Load()),
+	}
+	if stats.Acquires > 0 {
+		stats.AcquireWaitMean = time.Duration(p.acquireWait.Load() / int64(stats.Acquires))
+	}
+	p.mu.Lock()
+	stats.Unhealthy = len(p.unhealthy)
+	stats.Healthy = stats.Size - stats.Unhealthy
+	for _, starting := range p.unhealthy {
+		if starting {
+			stats.Starting++
+		}
+	}
+	stats.Resetting = p.resetting
+	stats.Draining = len(p.retired)
+	p.mu.Unlock()
+	return stats
+}
+
+// Acquire checks out an idle sandbox, blocking until one becomes available,
+// ctx is done, or the pool is closed. Beyond the limits of PoolConfig.Queue, it fails with
+// ErrPoolSaturated instead of waiting.
+func (p *Pool) Acquire(ctx context.Context) (LangSandBox, error) {
+	return p.acquire(ctx, "")
+}
+
+// AcquireFor is like Acquire, but places key, typically a user or conversation ID, on a sandbox:
+// as long as that sandbox is alive, AcquireFor checks it out for key again, waiting for it if it
+// is in use, so REPL state carries over between the calls of a session. Once it is gone, because
+// it stopped or was replaced, key is placed on another sandbox, whose REPL starts afresh.
+// Sandboxes no key is placed on are handed out first; when there are none, a sandbox is taken
+// from the key placed on it.
+func (p *Pool) AcquireFor(ctx context.Context, key string) (LangSandBox, error) {
+	return p.acquire(ctx, key)
+}
+
+func (p *Pool) acquire(ctx context.Context, key string) (LangSandBox, error) {
+	var begin time.Time
+	var expired <-chan time.Time
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if sb, stolen, ok := p.take(key); ok {
+			if reset := p.cfg.Reset; reset != nil && stolen {
+				// the state of the key the sandbox was placed on must not carry over
+				p.resetting++
+				p.mu.Unlock()
+				err := reset(ctx, sb)
+				p.mu.Lock()
+				p.resetting--
+				if err != nil {
+					err = fmt.Errorf("%w: %w", ErrFailedToResetSandbox, err)
+				}
+				if err != nil || p.retired[sb] {
+					stop := p.putBack(sb, err)
+					p.mu.Unlock()
+					if stop {
+						_ = sb.Stop()
+					}
+					continue
+				}
+			}
+			p.mu.Unlock()
+			var wait time.Duration
+			if !begin.IsZero() {
+				wait = time.Since(begin)
+			}
+			p.recordAcquire(wait)
+			return sb, nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		if begin.IsZero() {
+			begin = time.Now()
+			waiting := p.waiting.Add(1)
+			defer p.waiting.Add(-1)
+			if limit := p.queue.MaxWaiters; limit != 0 && waiting > int64(limit) {
+				p.saturated.Add(1)
+				return nil, fmt.Errorf("%w: %d callers waiting", ErrPoolSaturated, waiting-1)
+			}
+			if p.queue.MaxWait > 0 {
+				timer := time.NewTimer(p.queue.MaxWait)
+				defer timer.Stop()
+				expired = timer.C
+			}
+		}
+		select {
+		case <-changed:
+		case <-expired:
+			p.saturated.Add(1)
+			return nil, fmt.Errorf("%w: no sandbox available within %v", ErrPoolSaturated, p.queue.MaxWait)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// take checks out an idle sandbox for key, or for no key if it is empty, and reports whether
+// there was one, and whether it was taken from another key; the caller holds mu.
+func (p *Pool) take(key string) (sb LangSandBox, stolen, ok bool) {
+	if sb, ok := p.affinity[key]; ok {
+		if p.alive(sb) {
+			i := slices.Index(p.idle, sb)
+			if i < 0 {
+				return nil, false, false // in use; wait for it
+			}
+			p.idle = slices.Delete(p.idle, i, i+1)
+			return sb, false, true
+		}
+		p.unbind(sb)
+	}
+
+	if len(p.idle) == 0 {
+		return nil, false, false
+	}
+	i := slices.IndexFunc(p.idle, func(sb LangSandBox) bool {
+		_, ok := p.bound[sb]
+		return !ok
+	})
+	if i < 0 {
+		i = 0
+	}
+	sb = p.idle[i]
+	p.idle = slices.Delete(p.idle, i, i+1)
+	_, stolen = p.bound[sb]
+	p.unbind(sb)
+	if key != "" {
+		p.affinity[key] = sb
+		p.bound[sb] = key
+	}
+	return sb, stolen, true
+}
+
+// alive reports whether sb is still one of the pool's running sandboxes; the caller holds mu.
+func (p *Pool) alive(sb LangSandBox) bool {
+	if p.retired[sb] || p.unhealthy[sb] || !slices.Contains(p.sandboxes, sb) {
+		return false
+	}
+	_, err := sb.Info()
+	return err == nil
+}
+
+// unbind removes the key placed on sb, if any; the caller holds mu.
+func (p *Pool) unbind(sb LangSandBox) {
+	if key, ok := p.bound[sb]; ok {
+		delete(p.bound, sb)
+		delete(p.affinity, key)
+	}
+}
+
+// notify wakes up the Acquire calls waiting for a sandbox; the caller holds mu.
+func (p *Pool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *Pool) recordAcquire(wait time.Duration) {
+	p.inUse.Add(1)
+	p.acquires.Add(1)
+	p.acquireWait.Add(int64(wait))
+	for {
+		cur := p.acquireMaxNs.Load()
+		if int64(wait) <= cur || p.acquireMaxNs.CompareAndSwap(cur, int64(wait)) {
+			return
+		}
+	}
+}
+
+// Release returns a sandbox previously obtained from Acquire to the pool.
+// A sandbox that was replaced while checked out is stopped instead.
+func (p *Pool) Release(sb LangSandBox) {
+	p.inUse.Add(-1)
+	p.mu.Lock()
+	if p.retired[sb] {
+		delete(p.retired, sb)
+		p.mu.Unlock()
+		_ = sb.Stop()
+		return
+	}
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if _, bound := p.bound[sb]; bound || p.cfg.Reset == nil {
+		p.idle = append(p.idle, sb)
+		p.notify()
+		return
+	}
+	p.resetting++
+	p.bg.Add(1)
+	go p.resetReleased(sb, p.cfg.Reset)
+}
+
+// resetReleased resets a released sandbox and makes it available again.
+func (p *Pool) resetReleased(sb LangSandBox, reset func(context.Context, LangSandBox) error) {
+	defer p.bg.Done()
+	err := reset(p.ctx, sb)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrFailedToResetSandbox, err)
+	}
+	p.mu.Lock()
+	p.resetting--
+	stop := p.putBack(sb, err)
+	p.mu.Unlock()
+	if stop {
+		_ = sb.Stop()
+	}
+}
+
+// putBack makes a sandbox that was out of rotation for a reset or health check available again,
+// or marks it unhealthy if err is set. It reports whether the sandbox was replaced meanwhile and
+// must be stopped; the caller holds mu.
+func (p *Pool) putBack(sb LangSandBox, err error) (stop bool) {
+	switch {
+	case p.closed:
+		return false
+	case p.retired[sb]:
+		delete(p.retired, sb)
+		return true
+	case err != nil:
+		p.unhealthy[sb] = false
+		p.unbind(sb)
+		p.bg.Add(1)
+		go func() {
+			defer p.bg.Done()
+			p.replaceUnhealthy(p.ctx)
+		}()
+	default:
+		p.idle = append(p.idle, sb)
+		p.notify()
+	}
+	return false
+}
+
+// Replace starts a sandbox as cfg describes, warms it up with cfg.Init and swaps it into the pool
+// in place of old, so that images or configurations can be rolled out without taking the pool
+// down; cfg.Size and cfg.Name are ignored. An idle old sandbox is stopped right away; one that
+// is checked out keeps serving its caller and is stopped when released. The pool's health checks
+// replace failed sandboxes as cfg describes from then on.
+//
+// Returns ErrNotInPool if old isn't one of the pool's sandboxes, and leaves the pool unchanged if
+// the replacement fails to start.
+func (p *Pool) Replace(ctx context.Context, old LangSandBox, cfg PoolConfig) (LangSandBox, error) {
+	if cfg.New == nil {
+		return nil, ErrPoolFactoryMissing
+	}
+	p.mu.Lock()
+	closed, known := p.closed, slices.Contains(p.sandboxes, old)
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrPoolClosed
+	}
+	if !known {
+		return nil, ErrNotInPool
+	}
+
+	sb, err := startPoolSandbox(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReplaceSandbox, err)
+	}
+
+	p.mu.Lock()
+	i := slices.Index(p.sandboxes, old)
+	if i < 0 || p.closed {
+		// replaced concurrently, or the pool was closed while the replacement started
+		closed := p.closed
+		p.mu.Unlock()
+		_ = sb.StopContext(context.WithoutCancel(ctx))
+		if closed {
+			return nil, ErrPoolClosed
+		}
+		return nil, ErrNotInPool
+	}
+	p.sandboxes[i] = sb
+	p.cfg = cfg
+	p.unbind(old)
+	j := slices.Index(p.idle, old)
+	stopNow := j >= 0 || p.unhealthy[old]
+	switch {
+	case j >= 0:
+		p.idle = slices.Delete(p.idle, j, j+1)
+	case p.unhealthy[old]:
+		delete(p.unhealthy, old)
+	default:
+		p.retired[old] = true
+	}
+	p.idle = append(p.idle, sb)
+	p.notify()
+	p.mu.Unlock()
+
+	if stopNow {
+		if err := old.StopContext(context.WithoutCancel(ctx)); err != nil {
+			return sb, fmt.Errorf("%w: %w", ErrFailedToStopReplaced, err)
+		}
+	}
+	return sb, nil
+}
+
+// Close stops every sandbox in the pool, including replaced ones still checked out. Pending and
+// future Acquire calls fail with ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.notify()
+	sandboxes := slices.Clone(p.sandboxes)
+	for sb := range p.retired {
+		sandboxes = append(sandboxes, sb)
+	}
+	clear(p.retired)
+	p.idle = nil
+	p.mu.Unlock()
+	p.cancel()
+
+	debugTargets.remove(p.debugName)
+	var errs []error
+	for _, sb := range sandboxes {
+		if err := sb.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.bg.Wait()
+	return errors.Join(errs...)
+}
+
+// Pool-related errors
+var (
+	ErrInvalidPoolSize    = errors.New("pool size must be positive")
+	ErrPoolFactoryMissing = errors.New("pool sandbox constructor must be specified")
+	ErrFailedToStartPool  = errors.New("failed to start pool")
+	ErrPoolClosed         = errors.New("pool closed")
+	ErrPoolSaturated      = errors.New("pool saturated")
+
+	ErrNotInPool              = errors.New("sandbox not in pool")
+	ErrFailedToInitSandbox    = errors.New("failed to initialize sandbox")
+	ErrFailedToReplaceSandbox = errors.New("failed to replace sandbox")
+	ErrFailedToStopReplaced   = errors.New("failed to stop replaced sandbox")
+	ErrFailedToResetSandbox   = errors.New("failed to reset sandbox")
+)
@@ -0,0 +1,130 @@
+package msb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// startHealthChecks starts checking the pool's idle sandboxes if a HealthCheckInterval is set.
+func (p *Pool) startHealthChecks() {
+	if p.cfg.HealthCheckInterval <= 0 {
+		return
+	}
+	p.bg.Add(1)
+	go p.healthLoop(p.ctx, p.cfg.HealthCheckInterval)
+}
+
+func (p *Pool) healthLoop(ctx context.Context, interval time.Duration) {
+	defer p.bg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.checkIdle(ctx, interval)
+		p.replaceUnhealthy(ctx)
+	}
+}
+
+// checkIdle checks the sandboxes that are idle, one at a time so the others remain available.
+// Those that fail are taken out of rotation.
+func (p *Pool) checkIdle(ctx context.Context, timeout time.Duration) {
+	p.mu.Lock()
+	idle := slices.Clone(p.idle)
+	p.mu.Unlock()
+
+	for _, sb := range idle {
+		p.mu.Lock()
+		i := slices.Index(p.idle, sb)
+		if p.closed || i < 0 {
+			// checked out in the meantime
+			p.mu.Unlock()
+			continue
+		}
+		p.idle = slices.Delete(p.idle, i, i+1)
+		p.mu.Unlock()
+
+		err := checkHealth(ctx, sb, timeout)
+
+		p.mu.Lock()
+		stop := p.putBack(sb, err)
+		p.mu.Unlock()
+		if stop {
+			// replaced with Replace while being checked
+			_ = sb.StopContext(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// checkHealth verifies that sb is running and its REPL responds.
+func checkHealth(ctx context.Context, sb LangSandBox, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	status, err := sb.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if status != StatusRunning {
+		return fmt.Errorf("sandbox is %s", status)
+	}
+	code := "pass"
+	if lang, _ := languageOf(sb); lang == langNodeJs {
+		code = "undefined"
+	}
+	exec, err := sb.Code().RunContext(ctx, code)
+	if err != nil {
+		return err
+	}
+	if exec.HasError() {
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("no-op execution failed: %s", stderr)
+	}
+	return nil
+}
+
+// replaceUnhealthy starts a replacement for every unhealthy sandbox not being replaced yet.
+// Replacements that fail to start are retried in the next round of health checks.
+func (p *Pool) replaceUnhealthy(ctx context.Context) {
+	p.mu.Lock()
+	var unhealthy []LangSandBox
+	for sb, starting := range p.unhealthy {
+		if !starting {
+			unhealthy = append(unhealthy, sb)
+			p.unhealthy[sb] = true
+		}
+	}
+	cfg := p.cfg
+	p.mu.Unlock()
+
+	for _, old := range unhealthy {
+		sb, err := startPoolSandbox(ctx, cfg)
+
+		p.mu.Lock()
+		i := slices.Index(p.sandboxes, old)
+		if err != nil {
+			if _, ok := p.unhealthy[old]; ok {
+				p.unhealthy[old] = false
+			}
+			p.mu.Unlock()
+			continue
+		}
+		if p.closed || i < 0 {
+			// closed, or replaced with Replace in the meantime
+			p.mu.Unlock()
+			_ = sb.StopContext(context.WithoutCancel(ctx))
+			continue
+		}
+		p.sandboxes[i] = sb
+		delete(p.unhealthy, old)
+		p.idle = append(p.idle, sb)
+		p.notify()
+		p.mu.Unlock()
+
+		_ = old.StopContext(context.WithoutCancel(ctx))
+	}
+}
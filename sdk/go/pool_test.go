@@ -0,0 +1,51 @@
+package msb
+
+import (
+	"context"
+	"testing"
+)
+
+func startBenchPool(b *testing.B) *Pool {
+	target := benchServer()
+	newSandbox, cleanup := target.connect()
+	b.Cleanup(cleanup)
+	pool, err := NewPool(PoolConfig{Size: 4, New: newSandbox, Start: target.startConfig()})
+	if err != nil {
+		b.Fatalf("starting pool: %v", err)
+	}
+	b.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+// BenchmarkPoolAcquire measures checking a sandbox out of a pool of 4 and handing it back.
+func BenchmarkPoolAcquire(b *testing.B) {
+	pool := startBenchPool(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		sandbox, err := pool.Acquire(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		pool.Release(sandbox)
+	}
+}
+
+// BenchmarkPoolAcquireParallel measures Acquire and Release on a pool of 4 contended by
+// concurrent callers.
+func BenchmarkPoolAcquireParallel(b *testing.B) {
+	pool := startBenchPool(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			sandbox, err := pool.Acquire(ctx)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			pool.Release(sandbox)
+		}
+	})
+}
@@ -0,0 +1,275 @@
+package msb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a problem found in code by CodeRunner.Format or Lint.
+type Diagnostic struct {
+	Line     int    `json:"line"`     // 1-based line; 0 if unknown
+	Column   int    `json:"column"`   // 1-based column; 0 if unknown
+	Severity string `json:"severity"` // "error" or "warning"
+	Message  string `json:"message"`  // Description of the problem
+	Rule     string `json:"rule"`     // ID of the lint rule broken; empty for syntax errors
+	Tool     string `json:"tool"`     // Tool that reported it, e.g. "eslint"
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+const (
+	// pythonLintScript checks the syntax of the file it is given, then, if pyflakes is
+	// installed, runs it, printing the diagnostics as a JSON array.
+	pythonLintScript = `import json, sys
+path = sys.argv[1]
+with open(path) as f:
+    src = f.read()
+out = []
+def report(line, col, severity, message, rule, tool):
+    out.append({"line": line or 0, "column": col or 0, "severity": severity,
+                "message": message, "rule": rule, "tool": tool})
+try:
+    compile(src, path, "exec", dont_inherit=True)
+except SyntaxError as e:
+    report(e.lineno, e.offset, "error", e.msg, "", "python")
+else:
+    try:
+        from pyflakes import api
+    except ImportError:
+        api = None
+    if api is not None:
+        class Collect:
+            def unexpectedError(self, filename, msg):
+                report(0, 0, "error", str(msg), "", "pyflakes")
+            def syntaxError(self, filename, msg, lineno, offset, text):
+                report(lineno, offset, "error", msg, "", "pyflakes")
+            def flake(self, m):
+                report(m.lineno, m.col + 1, "warning", m.message % m.message_args, type(m).__name__, "pyflakes")
+        api.check(src, path, Collect())
+print(json.dumps(out))
+`
+	// jsLintScript runs eslint on the file it is given if installed, and node --check
+	// otherwise, printing the name of the tool first.
+	jsLintScript = `if command -v eslint >/dev/null 2>&1; then echo eslint; exec eslint --format json "$1"; fi
+echo node; exec node --check "$1"`
+	// formatScript runs the formatter given on the file given, and prints the formatted file in
+	// base64, so that its whitespace comes through untouched.
+	formatScript = `command -v "$2" >/dev/null 2>&1 || exit 127
+case $2 in
+black) black -q "$1" && base64 "$1" ;;
+prettier) prettier "$1" > "$1.fmt" && base64 "$1.fmt" ;;
+esac`
+)
+
+func (cr codeRunner) Format(ctx context.Context, code string) (string, []Diagnostic, error) {
+	tool := "black"
+	if cr.l == langNodeJs {
+		tool = "prettier"
+	}
+	exec, err := cr.preflight(ctx, code, func(path string) (string, []string) {
+		return "sh", []string{"-c", formatScript, "sh", path, tool}
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	stdout, _ := exec.GetOutput()
+	stderr, _ := exec.GetError()
+	switch exec.GetExitCode() {
+	case 0:
+		formatted, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(stdout), ""))
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+		return string(formatted), nil, nil
+	case 127:
+		return "", nil, fmt.Errorf("%w: %s", ErrPreflightToolNotFound, tool)
+	}
+	if d, ok := parseFormatError(tool, stderr); ok {
+		return code, []Diagnostic{d}, nil
+	}
+	return "", nil, fmt.Errorf("%w: %s: exit code %d: %s", ErrPreflightFailed, tool, exec.GetExitCode(), strings.TrimSpace(stderr))
+}
+
+func (cr codeRunner) Lint(ctx context.Context, code string) ([]Diagnostic, error) {
+	exec, err := cr.preflight(ctx, code, func(path string) (string, []string) {
+		if cr.l == langNodeJs {
+			return "sh", []string{"-c", jsLintScript, "sh", path}
+		}
+		return "python3", []string{"-c", pythonLintScript, path}
+	})
+	if err != nil {
+		return nil, err
+	}
+	stdout, _ := exec.GetOutput()
+	stderr, _ := exec.GetError()
+	if cr.l != langNodeJs {
+		return decodeDiagnostics(exec, "python", stdout, stderr)
+	}
+
+	tool, stdout, _ := strings.Cut(stdout, "\n")
+	if tool == "eslint" {
+		return parseESLint(exec, stdout, stderr)
+	}
+	switch exec.GetExitCode() {
+	case 0:
+		return []Diagnostic{}, nil
+	case 1:
+		if d, ok := parseNodeCheck(stderr); ok {
+			return []Diagnostic{d}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s: exit code %d: %s", ErrPreflightFailed, tool, exec.GetExitCode(), strings.TrimSpace(stderr))
+}
+
+// preflight uploads code to a file and runs the command command returns for it, in the directory
+// of the Node project installed into, if any, so that tools pick up its configuration. The
+// files are removed afterwards.
+func (cr codeRunner) preflight(ctx context.Context, code string, command func(path string) (string, []string)) (CommandExecution, error) {
+	if cr.b.state.Load() != started {
+		return CommandExecution{}, ErrSandboxNotStarted
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return CommandExecution{}, err
+	}
+	dir := "/tmp"
+	if project := cr.b.node.Load(); project != nil {
+		dir = project.dir
+	}
+	path := dir + "/.msb-preflight-" + hex.EncodeToString(nonce) + cr.l.fileExtension()
+	defer func() {
+		// best effort; the sandbox's /tmp goes away with it anyway
+		rm := []string{"-f", path, path + ".fmt"}
+		if _, err := cr.b.rpcClient.runCommand(context.WithoutCancel(ctx), &cr.b.cfg, "", "rm", rm); err != nil {
+			cr.b.cfg.log(ctx).Debug("Failed to remove preflight file", "path", path, "error", err)
+		}
+	}()
+
+	if err := cr.b.upload(ctx, path, []byte(code)); err != nil {
+		return CommandExecution{}, fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+	}
+	cmd, args := command(path)
+	script := `cd "$1" && shift && exec "$@"`
+	exec, err := commandRunner{b: cr.b}.RunWithOptions(ctx, "sh", append([]string{"-c", script, "sh", dir, cmd}, args...), RunOptions{})
+	if err != nil {
+		return CommandExecution{}, fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+	}
+	return exec, nil
+}
+
+// decodeDiagnostics decodes the diagnostics a successful lint printed as a JSON array.
+func decodeDiagnostics(exec CommandExecution, tool, stdout, stderr string) ([]Diagnostic, error) {
+	if !exec.IsSuccess() {
+		return nil, fmt.Errorf("%w: %s: exit code %d: %s", ErrPreflightFailed, tool, exec.GetExitCode(), strings.TrimSpace(stderr))
+	}
+	diagnostics := []Diagnostic{}
+	if err := json.Unmarshal([]byte(stdout), &diagnostics); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrPreflightFailed, tool, err)
+	}
+	return diagnostics, nil
+}
+
+// parseESLint converts the report of eslint --format json. eslint exits with 1 when it found
+// errors and with 2 when it couldn't lint at all, e.g. for lack of a configuration.
+func parseESLint(exec CommandExecution, stdout, stderr string) ([]Diagnostic, error) {
+	if code := exec.GetExitCode(); code != 0 && code != 1 {
+		return nil, fmt.Errorf("%w: eslint: exit code %d: %s", ErrPreflightFailed, code, strings.TrimSpace(stderr))
+	}
+	var report []struct {
+		Messages []struct {
+			RuleID   string `json:"ruleId"`
+			Severity int    `json:"severity"` // 1 for warnings, 2 for errors
+			Message  string `json:"message"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		return nil, fmt.Errorf("%w: eslint: %w", ErrPreflightFailed, err)
+	}
+	diagnostics := []Diagnostic{}
+	for _, file := range report {
+		for _, m := range file.Messages {
+			severity := "warning"
+			if m.Severity == 2 {
+				severity = "error"
+			}
+			diagnostics = append(diagnostics, Diagnostic{Line: m.Line, Column: m.Column, Severity: severity,
+				Message: m.Message, Rule: m.RuleID, Tool: "eslint"})
+		}
+	}
+	return diagnostics, nil
+}
+
+// parseNodeCheck converts the syntax error node --check reports, which looks like:
+//
+//	/tmp/file.js:3
+//	  foo(;
+//	      ^
+//
+//	SyntaxError: Unexpected token ';'
+func parseNodeCheck(stderr string) (Diagnostic, bool) {
+	lines := strings.Split(stderr, "\n")
+	d := Diagnostic{Severity: "error", Tool: "node"}
+	if i := strings.LastIndexByte(lines[0], ':'); i >= 0 {
+		d.Line, _ = strconv.Atoi(lines[0][i+1:])
+	}
+	for _, line := range lines[1:] {
+		if d.Column == 0 && strings.TrimLeft(line, " \t") == "^" {
+			d.Column = len(line) // the caret's, 1-based
+		}
+		if _, msg, ok := strings.Cut(line, "SyntaxError: "); ok {
+			d.Message = msg
+			return d, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+var (
+	blackErrorRe    = regexp.MustCompile(`Cannot parse[^:]*: (\d+):(\d+): (.*)`)
+	prettierErrorRe = regexp.MustCompile(`SyntaxError: (.*) \((\d+):(\d+)\)`)
+)
+
+// parseFormatError converts the syntax error a formatter reported, if it reported one. black
+// reports "error: cannot format <file>: Cannot parse: 1:6: <line>" with a 0-based column, and
+// prettier "[error] <file>: SyntaxError: <message> (1:7)".
+func parseFormatError(tool, stderr string) (Diagnostic, bool) {
+	d := Diagnostic{Severity: "error", Tool: tool}
+	switch tool {
+	case "black":
+		m := blackErrorRe.FindStringSubmatch(stderr)
+		if m == nil {
+			return Diagnostic{}, false
+		}
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Column, _ = strconv.Atoi(m[2])
+		d.Column++
+		d.Message = "cannot parse: " + m[3]
+	case "prettier":
+		m := prettierErrorRe.FindStringSubmatch(stderr)
+		if m == nil {
+			return Diagnostic{}, false
+		}
+		d.Message = m[1]
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+	}
+	return d, true
+}
+
+// Preflight-related errors
+var (
+	ErrPreflightToolNotFound = errors.New("preflight tool not found in sandbox")
+	ErrPreflightFailed       = errors.New("preflight failed")
+)
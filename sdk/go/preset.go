@@ -0,0 +1,78 @@
+package msb
+
+import "time"
+
+// Preset is a named bundle of sizing settings, so sandboxes are sized consistently across services:
+//
+//	err := sandbox.Start(msb.PresetMedium.With(msb.StartConfig{Image: "my-image"}))
+//
+// Platform teams can define their own presets the same way the built-in ones are defined.
+type Preset struct {
+	Name         string        // Preset name, for logs and diagnostics
+	Memory       int           // Memory limit in MB
+	CPUs         int           // CPU limit
+	DiskMiB      int           // Root filesystem size limit in MiB
+	StartTimeout time.Duration // Bound on the whole start
+	ExecTimeout  time.Duration // Default bound on each execution
+	Network      NetworkPolicy // Egress policy
+}
+
+// Built-in presets. PresetSmall suits short snippets of untrusted code and has no network access,
+// so it needs a server enforcing network policies; the larger presets leave egress to the
+// server's default.
+var (
+	PresetSmall = Preset{
+		Name:         "small",
+		Memory:       512,
+		CPUs:         1,
+		DiskMiB:      1024,
+		StartTimeout: 2 * time.Minute,
+		ExecTimeout:  30 * time.Second,
+		Network:      NetworkPolicy{Mode: NetworkNone},
+	}
+	PresetMedium = Preset{
+		Name:         "medium",
+		Memory:       2048,
+		CPUs:         2,
+		DiskMiB:      4096,
+		StartTimeout: 5 * time.Minute,
+		ExecTimeout:  2 * time.Minute,
+	}
+	PresetLarge = Preset{
+		Name:         "large",
+		Memory:       8192,
+		CPUs:         4,
+		DiskMiB:      16384,
+		StartTimeout: 10 * time.Minute,
+		ExecTimeout:  10 * time.Minute,
+	}
+)
+
+// With returns cfg with every setting the preset covers filled in from the preset, unless cfg
+// already sets it.
+func (p Preset) With(cfg StartConfig) StartConfig {
+	if cfg.Memory <= 0 {
+		cfg.Memory = p.Memory
+	}
+	if cfg.CPUs <= 0 {
+		cfg.CPUs = p.CPUs
+	}
+	if cfg.DiskMiB <= 0 {
+		cfg.DiskMiB = p.DiskMiB
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = p.StartTimeout
+	}
+	if cfg.ExecTimeout <= 0 {
+		cfg.ExecTimeout = p.ExecTimeout
+	}
+	if cfg.Network.Mode == NetworkDefault {
+		cfg.Network = p.Network
+	}
+	return cfg
+}
+
+// Config returns the preset as a StartConfig.
+func (p Preset) Config() StartConfig {
+	return p.With(StartConfig{})
+}
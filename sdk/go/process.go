@@ -0,0 +1,179 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Process is a command started in the background with CommandRunner.Start.
+// Its result is collected with Wait or WaitContext, and it can be interrupted with Signal while it
+// runs.
+type Process struct {
+	ID string // Identifier of the process within the sandbox's process table
+
+	b    *baseMicroSandbox
+	user string // user the process runs as, who may signal it
+	done chan struct{}
+	exec CommandExecution
+	err  error
+}
+
+// procDir is where background processes record their PIDs inside the sandbox.
+const procDir = "/tmp/.msb-procs"
+
+// Signal delivery timing: how long Signal waits for a just-started process to record its PID,
+// and how long KillAll gives processes to exit after SIGINT before sending SIGKILL.
+const (
+	procStartWait = 5 * time.Second
+	killAllGrace  = 2 * time.Second
+)
+
+func (cr commandRunner) Start(ctx context.Context, cmd string, args []string) (*Process, error) {
+	if cr.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	if err := cr.validate(); err != nil {
+		return nil, err
+	}
+	if err := cr.b.cfg.policy.check(cmd, args); err != nil {
+		return nil, err
+	}
+	p := &Process{
+		ID:   uuid.NewString(),
+		b:    cr.b,
+		user: cr.user,
+		done: make(chan struct{}),
+	}
+
+	// an inner shell records its PID and then execs the command, so the recorded PID is the
+	// command's own; the outer shell removes the record once the command exits. Running it in the
+	// foreground matters, as background jobs of non-interactive shells ignore SIGINT.
+	pidFile := procDir + "/" + p.ID + ".pid"
+	inner := ShellJoin(append([]string{"sh", "-c", `echo $$ >"$1"; shift; exec "$@"`, "sh", pidFile, cmd}, args...)...)
+	script := fmt.Sprintf(`mkdir -p %s && { %s; rc=$?; rm -f %s; exit "$rc"; }`, procDir, inner, ShellQuote(pidFile))
+
+	ctx, cancel := withTimeout(ctx, cr.b.cfg.policy.maxTimeout())
+	cr.pidFile = pidFile
+	go func() {
+		defer close(p.done)
+		defer cancel()
+		result, err := cr.run(ctx, "sh", []string{"-c", script})
+		if err != nil {
+			p.err = fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+			return
+		}
+		p.exec = cr.b.commandExecution(ctx, result)
+	}()
+	return p, nil
+}
+
+// Wait blocks until the process exits and returns its result.
+func (p *Process) Wait() (CommandExecution, error) {
+	<-p.done
+	return p.exec, p.err
+}
+
+// WaitContext is like Wait, but gives up with ctx's error once ctx is done, leaving the process
+// running. The server reports the exit on the request that started the process, held open for as
+// long as it runs, so waiting costs no polling however long the process takes.
+func (p *Process) WaitContext(ctx context.Context) (CommandExecution, error) {
+	select {
+	case <-p.done:
+		return p.exec, p.err
+	case <-ctx.Done():
+		return CommandExecution{}, ctx.Err()
+	}
+}
+
+// Done returns a channel that is closed once the process has exited.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Signal delivers sig to the process (but not to children it spawned itself). Only signals common
+// to all platforms are supported: SIGHUP, SIGINT, SIGQUIT, SIGABRT, SIGKILL, SIGPIPE, SIGALRM and
+// SIGTERM. Returns ErrProcessNotRunning if the process has already exited.
+func (p *Process) Signal(ctx context.Context, sig syscall.Signal) error {
+	name, ok := signalName(sig)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnsupportedSignal, sig)
+	}
+	select {
+	case <-p.done:
+		return ErrProcessNotRunning
+	default:
+	}
+
+	pidFile := ShellQuote(procDir + "/" + p.ID + ".pid")
+	polls := int(procStartWait / (100 * time.Millisecond))
+	script := fmt.Sprintf(`i=0; while [ ! -s %[1]s ] && [ $i -lt %[2]d ]; do sleep 0.1; i=$((i+1)); done; [ -s %[1]s ] && kill -s %[3]s "$(cat %[1]s)"`,
+		pidFile, polls, name)
+	result, err := p.b.rpcClient.runCommand(ctx, &p.b.cfg, p.user, "sh", []string{"-c", script})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToSignalProcess, err)
+	}
+	if exec := newCommandExecution(result.output); !exec.IsSuccess() {
+		return ErrProcessNotRunning
+	}
+	return nil
+}
+
+// KillAll interrupts every process started with CommandRunner.Start through any handle of the
+// sandbox: each gets SIGINT, and whatever is still running after a short grace period gets SIGKILL.
+// Code running in the REPL and commands run synchronously with Run are not affected; AbortAll
+// stops those of one handle. The processes are signalled as RootUser, so escalated ones are
+// included.
+func (ls *langSandbox) KillAll(ctx context.Context) error {
+	if ls.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	polls := int(killAllGrace / (100 * time.Millisecond))
+	script := strings.Join([]string{
+		fmt.Sprintf(`for f in %s/*.pid; do [ -s "$f" ] && kill -s INT "$(cat "$f")" 2>/dev/null; done`, procDir),
+		fmt.Sprintf(`i=0; while ls %s/*.pid >/dev/null 2>&1 && [ $i -lt %d ]; do sleep 0.1; i=$((i+1)); done`, procDir, polls),
+		fmt.Sprintf(`for f in %s/*.pid; do [ -s "$f" ] && kill -s KILL "$(cat "$f")" 2>/dev/null; done`, procDir),
+		"true",
+	}, "\n")
+	if _, err := ls.b.rpcClient.runCommand(ctx, &ls.b.cfg, RootUser, "sh", []string{"-c", script}); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToSignalProcess, err)
+	}
+	return nil
+}
+
+// signalName maps sig to the name understood by kill -s. Numbers are not used, as they differ
+// between the client's platform and the sandbox's Linux kernel for some signals.
+func signalName(sig syscall.Signal) (string, bool) {
+	switch sig {
+	case syscall.SIGHUP:
+		return "HUP", true
+	case syscall.SIGINT:
+		return "INT", true
+	case syscall.SIGQUIT:
+		return "QUIT", true
+	case syscall.SIGABRT:
+		return "ABRT", true
+	case syscall.SIGKILL:
+		return "KILL", true
+	case syscall.SIGPIPE:
+		return "PIPE", true
+	case syscall.SIGALRM:
+		return "ALRM", true
+	case syscall.SIGTERM:
+		return "TERM", true
+	default:
+		return "", false
+	}
+}
+
+// Process-related errors
+var (
+	ErrProcessNotRunning     = errors.New("process not running")
+	ErrUnsupportedSignal     = errors.New("unsupported signal")
+	ErrFailedToSignalProcess = errors.New("failed to signal process")
+)
@@ -0,0 +1,88 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PullProgress reports the progress of an image pull triggered by Start.
+type PullProgress struct {
+	Sandbox    string          // Sandbox being started
+	Image      string          // Image being pulled
+	Layers     []LayerProgress // Progress of every layer, in manifest order
+	Downloaded int64           // Bytes downloaded across all layers
+	Total      int64           // Total bytes across all layers; 0 while unknown
+	Done       bool            // Whether the pull has completed
+}
+
+// LayerProgress reports the progress of pulling a single image layer.
+type LayerProgress struct {
+	Digest     string `json:"digest"`     // Layer digest
+	Status     string `json:"status"`     // "waiting", "downloading", "extracting" or "complete"
+	Downloaded int64  `json:"downloaded"` // Bytes downloaded so far
+	Total      int64  `json:"total"`      // Layer size in bytes; 0 while unknown
+}
+
+// WithStartProgress registers a callback receiving layer-by-layer progress whenever Start makes
+// the server pull an image, so CLIs and UIs can show download progress instead of a silent wait.
+// The callback is invoked from a separate goroutine, only when the progress changed. Servers that
+// don't report pull progress never invoke it.
+func WithStartProgress(progress func(PullProgress)) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.pullProgress = progress
+	}
+}
+
+// pullProgressInterval is how often the server is asked for the progress of a pull.
+const pullProgressInterval = 500 * time.Millisecond
+
+// watchPull reports the progress of pulling image to the WithStartProgress callback until the
+// returned function is called, which waits for the last report to be delivered.
+func watchPull(ctx context.Context, b *baseMicroSandbox, image string) (stop func()) {
+	report := b.cfg.pullProgress
+	if report == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var last *pullProgressResult
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pullProgressInterval):
+			}
+			result, err := b.rpcClient.getPullProgress(ctx, &b.cfg, image)
+			if errors.Is(err, ErrMethodNotFound) {
+				return
+			}
+			if err != nil {
+				b.cfg.log(ctx).Debug("Polling image pull progress failed", "image", image, "error", err)
+				continue
+			}
+			if len(result.Layers) == 0 || (last != nil && last.Done == result.Done && slices.Equal(last.Layers, result.Layers)) {
+				continue
+			}
+			last = result
+			p := PullProgress{Sandbox: b.cfg.name, Image: image, Layers: result.Layers, Done: result.Done}
+			for _, layer := range result.Layers {
+				p.Downloaded += layer.Downloaded
+				p.Total += layer.Total
+			}
+			report(p)
+			if result.Done {
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
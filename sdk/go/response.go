@@ -0,0 +1,140 @@
+package msb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxBodySnippet is how much of an unparsable response body a ResponseDecodeError quotes.
+const maxBodySnippet = 512
+
+// utf8BOM is prepended to JSON bodies by some proxies; JSON decoders reject it.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ResponseDecodeError reports a response body that isn't a valid JSON-RPC response, such as an
+// HTML error page served by a proxy with status 200. It matches ErrUnmarshalRespFailed with
+// errors.Is.
+type ResponseDecodeError struct {
+	Status      int    // HTTP status code
+	ContentType string // Content-Type of the response
+	Snippet     string // Start of the (decompressed) body, up to 512 bytes
+	Err         error  // Decoding error
+}
+
+func (e *ResponseDecodeError) Error() string {
+	return fmt.Sprintf("%v: status %d, content type %q: %v; body starts with %q",
+		ErrUnmarshalRespFailed, e.Status, e.ContentType, e.Err, e.Snippet)
+}
+
+func (e *ResponseDecodeError) Is(target error) bool {
+	return target == ErrUnmarshalRespFailed
+}
+
+func (e *ResponseDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// responseBody is a response body decompressed according to its Content-Encoding (or its magic
+// bytes, for proxies that compress without saying so) and stripped of a UTF-8 BOM. It keeps the
+// first bytes read for ResponseDecodeError.
+type responseBody struct {
+	r       io.Reader
+	closers []io.Closer
+	head    []byte
+}
+
+// newResponseBody wraps the body of resp. It fails for encodings it can't decode, except for error
+// responses: their status tells what went wrong, so their body is then left as it is.
+func newResponseBody(resp *http.Response) (*responseBody, error) {
+	body := &responseBody{closers: []io.Closer{resp.Body}}
+	var raw []byte
+	src := io.Reader(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		// error bodies are read whole anyway; kept, they can be passed on if they can't be decoded
+		var err error
+		if raw, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadResponseFailed, err)
+		}
+		src = bytes.NewReader(raw)
+	}
+	r, closer, err := decompress(bufio.NewReader(src), resp.Header.Get("Content-Encoding"))
+	switch {
+	case err != nil && resp.StatusCode == http.StatusOK:
+		return nil, err
+	case err != nil:
+		r = bytes.NewReader(raw)
+	case closer != nil:
+		body.closers = append(body.closers, closer)
+	}
+
+	decoded := bufio.NewReader(r)
+	if bom, _ := decoded.Peek(len(utf8BOM)); bytes.Equal(bom, utf8BOM) {
+		_, _ = decoded.Discard(len(utf8BOM))
+	}
+	body.r = decoded
+	return body, nil
+}
+
+// decompress returns a reader decompressing br according to encoding, or its magic bytes if it
+// has none, and the decompressor to close once done, if any.
+func decompress(br *bufio.Reader, encoding string) (io.Reader, io.Closer, error) {
+	magic, _ := br.Peek(2)
+	switch encoding = strings.ToLower(strings.TrimSpace(encoding)); {
+	case encoding == "gzip", encoding == "x-gzip", encoding == "" && bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: gzip: %w", ErrReadResponseFailed, err)
+		}
+		return zr, zr, nil
+	case encoding == "deflate":
+		// the standard says zlib-wrapped, but plenty of servers send raw deflate
+		if len(magic) == 2 && magic[0]&0x0f == 8 && (uint16(magic[0])<<8|uint16(magic[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: deflate: %w", ErrReadResponseFailed, err)
+			}
+			return zr, zr, nil
+		}
+		fr := flate.NewReader(br)
+		return fr, fr, nil
+	case encoding == "", encoding == "identity":
+		return br, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported content encoding %q", ErrReadResponseFailed, encoding)
+	}
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if room := maxBodySnippet - len(b.head); room > 0 {
+		b.head = append(b.head, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+// Close closes the decompressor, if any, and the underlying body.
+func (b *responseBody) Close() error {
+	var errs []error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		errs = append(errs, b.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// decodeErr describes a failure to decode the body of resp.
+func (b *responseBody) decodeErr(resp *http.Response, err error) error {
+	return &ResponseDecodeError{
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Snippet:     string(b.head),
+		Err:         err,
+	}
+}
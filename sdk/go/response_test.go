@@ -0,0 +1,220 @@
+package msb
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+const testResponse = `{"jsonrpc":"2.0","id":"1","result":{"ok":true}}`
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zlibbed(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func deflated(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResponseBodyDecompresses(t *testing.T) {
+	body := []byte(testResponse)
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+	}{
+		{"identity", "", body},
+		{"explicit identity", "identity", body},
+		{"gzip", "gzip", gzipped(t, body)},
+		{"x-gzip", "x-gzip", gzipped(t, body)},
+		{"gzip with odd spelling", " GZip ", gzipped(t, body)},
+		{"gzip without header", "", gzipped(t, body)},
+		{"zlib deflate", "deflate", zlibbed(t, body)},
+		{"raw deflate", "deflate", deflated(t, body)},
+		{"BOM", "", append(append([]byte{}, utf8BOM...), body...)},
+		{"gzipped BOM", "gzip", gzipped(t, append(append([]byte{}, utf8BOM...), body...))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Encoding": {tt.encoding}},
+				Body:       io.NopCloser(bytes.NewReader(tt.data)),
+			}
+			rb, err := newResponseBody(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(rb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != testResponse {
+				t.Errorf("body = %q, want %q", got, testResponse)
+			}
+			if string(rb.head) != testResponse {
+				t.Errorf("snippet = %q, want the decompressed body", rb.head)
+			}
+			if err := rb.Close(); err != nil {
+				t.Errorf("Close = %v", err)
+			}
+		})
+	}
+}
+
+func TestResponseBodyRejected(t *testing.T) {
+	corrupt := gzipped(t, []byte(testResponse))
+	corrupt[len(corrupt)-10] ^= 0xff
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+	}{
+		{"gzip that isn't", "gzip", []byte(testResponse)},
+		{"corrupt gzip", "gzip", corrupt},
+		{"unsupported encoding", "br", []byte(testResponse)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Encoding": {tt.encoding}},
+				Body:       io.NopCloser(bytes.NewReader(tt.data)),
+			}
+			rb, err := newResponseBody(resp)
+			if err != nil {
+				if !errors.Is(err, ErrReadResponseFailed) {
+					t.Errorf("newResponseBody = %v, want ErrReadResponseFailed", err)
+				}
+				return
+			}
+			// a bad stream behind a valid header only shows once it is read
+			if _, err := io.ReadAll(rb); err == nil {
+				t.Error("decoded without error")
+			}
+		})
+	}
+}
+
+// TestCompressedResponses checks that calls go through a proxy compressing the server's
+// responses, with or without saying so.
+func TestCompressedResponses(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, encoding := range map[string]string{"labeled": "gzip", "unlabeled": ""} {
+		t.Run(name, func(t *testing.T) {
+			proxy := httputil.NewSingleHostReverseProxy(target)
+			proxy.ModifyResponse = func(resp *http.Response) error {
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					return err
+				}
+				_ = resp.Body.Close()
+				resp.Body = io.NopCloser(bytes.NewReader(gzipped(t, body)))
+				resp.ContentLength = -1
+				resp.Header.Del("Content-Length")
+				if encoding != "" {
+					resp.Header.Set("Content-Encoding", encoding)
+				}
+				return nil
+			}
+			front := httptest.NewServer(proxy)
+			t.Cleanup(front.Close)
+
+			sandbox := NewPythonSandbox(WithServerUrl(front.URL), WithApiKey("k"))
+			if err := sandbox.Start(StartConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = sandbox.Stop() })
+			if _, err := sandbox.Command().Run("true", nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestErrorResponsesWithUndecodableBodies checks that error responses are told apart by their
+// status even when their body can't be decompressed.
+func TestErrorResponsesWithUndecodableBodies(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{"unauthorized", http.StatusUnauthorized, "denied", func(err error) bool {
+			var unauthorized *UnauthorizedError
+			return errors.As(err, &unauthorized)
+		}},
+		{"method not found", http.StatusNotFound, `{"jsonrpc":"2.0","id":"1","error":{"code":-32601,"message":"no such method"}}`, func(err error) bool {
+			return err == nil // pings of servers without server.ping succeed
+		}},
+		{"server error", http.StatusInternalServerError, "boom", func(err error) bool {
+			var serverErr *ServerError
+			return errors.As(err, &serverErr)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			t.Cleanup(srv.Close)
+			client := NewClient(WithServerUrl(srv.URL), WithApiKey("k"), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+			if err := client.Ping(context.Background()); !tt.check(err) {
+				t.Errorf("Ping = %v", err)
+			}
+		})
+	}
+}
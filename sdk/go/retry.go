@@ -0,0 +1,42 @@
+package msb
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how failed RPCs are retried.
+//
+// Only failures that are known to be safe are retried: requests that never reached the server
+// (connection refused, DNS failures, ...) and requests the server rejected as overloaded
+// (HTTP 429 and 503). Executions that may have started are never retried. When the server says how
+// long to wait with a Retry-After header, that delay replaces the backoff, and requests whose
+// context would expire first fail right away.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first; values <= 1 disable retries
+	InitialBackoff time.Duration // Delay before the first retry; defaults to 100ms
+	MaxBackoff     time.Duration // Upper bound for the delay between attempts; defaults to 5s
+}
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// backoff returns the delay before the retry following the given (1-based) attempt.
+// Delays grow exponentially and are jittered to avoid synchronized retries across clients.
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	initial, ceiling := rp.InitialBackoff, rp.MaxBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if ceiling <= 0 {
+		ceiling = defaultMaxBackoff
+	}
+	d := initial << (attempt - 1)
+	if d <= 0 || d > ceiling { // d <= 0 guards against shift overflow
+		d = ceiling
+	}
+	// equal jitter: half fixed, half random
+	return d/2 + rand.N(d/2+1)
+}
@@ -7,17 +7,43 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // rpcClient is an internal interface for keeping the microsandbox interactions decoupled from the kind of transport being used
 type rpcClient interface {
-	startSandbox(ctx context.Context, cfg *config, sc startConfig) error
+	startSandbox(ctx context.Context, cfg *config, sc startConfig) (*startResult, error)
 	stopSandbox(ctx context.Context, cfg *config) error
 	runRepl(ctx context.Context, cfg *config, lang progLang, code string) (*executionResult, error)
-	runCommand(ctx context.Context, cfg *config, command string, args []string) (*executionResult, error)
+	runCommand(ctx context.Context, cfg *config, user, command string, args []string) (*executionResult, error)
+	runCommandTo(ctx context.Context, cfg *config, user, command string, args []string, sink outputSink) (*executionResult, error)
 	getMetrics(ctx context.Context, cfg *config) (*sandboxMetrics, error)
+	ping(ctx context.Context, cfg *config) error
+	getStatus(ctx context.Context, cfg *config) (SandboxStatus, error)
+	renewLease(ctx context.Context, cfg *config, holder string, ttl time.Duration) (time.Time, error)
+	releaseLease(ctx context.Context, cfg *config, holder string) error
+	acquireLock(ctx context.Context, cfg *config, key, holder string, ttl time.Duration) (bool, error)
+	releaseLock(ctx context.Context, cfg *config, key, holder string) error
+	buildImage(ctx context.Context, cfg *config, params imageBuildParams) (string, error)
+	buildLogs(ctx context.Context, cfg *config, buildID string, offset int) (*buildLogsResult, error)
+	beginImageLoad(ctx context.Context, cfg *config) (string, error)
+	uploadImageChunk(ctx context.Context, cfg *config, uploadID string, offset int64, data []byte) error
+	commitImageLoad(ctx context.Context, cfg *config, uploadID string) ([]string, error)
+	listImages(ctx context.Context, cfg *config) ([]ImageInfo, error)
+	inspectImage(ctx context.Context, cfg *config, ref string) (*ImageInfo, error)
+	pruneImages(ctx context.Context, cfg *config, olderThan time.Duration) (*imagePruneResult, error)
+	getPullProgress(ctx context.Context, cfg *config, image string) (*pullProgressResult, error)
+	getLogs(ctx context.Context, cfg *config, offset int) (*logsResult, error)
+	getEvents(ctx context.Context, cfg *config, params eventsParams) (*eventsResult, error)
+	listMetrics(ctx context.Context, cfg *config) ([]sandboxMetrics, error)
+	getDiagnostics(ctx context.Context, cfg *config, logLines int) (*Diagnostics, error)
 }
 
 // rpcMethod represents a JSON-RPC method name
@@ -25,15 +51,45 @@ type rpcMethod string
 
 // JSON-RPC method constants
 const (
-	methodSandboxStart      rpcMethod = "sandbox.start"
-	methodSandboxStop       rpcMethod = "sandbox.stop"
-	methodSandboxReplRun    rpcMethod = "sandbox.repl.run"
-	methodSandboxCommandRun rpcMethod = "sandbox.command.run"
-	methodSandboxMetricsGet rpcMethod = "sandbox.metrics.get"
+	methodSandboxStart        rpcMethod = "sandbox.start"
+	methodSandboxStop         rpcMethod = "sandbox.stop"
+	methodSandboxReplRun      rpcMethod = "sandbox.repl.run"
+	methodSandboxCommandRun   rpcMethod = "sandbox.command.run"
+	methodSandboxMetricsGet   rpcMethod = "sandbox.metrics.get"
+	methodSandboxStatusGet    rpcMethod = "sandbox.status.get"
+	methodSandboxLogsGet      rpcMethod = "sandbox.logs.get"
+	methodSandboxEventsGet    rpcMethod = "sandbox.events.get"
+	methodSandboxDiagnostics  rpcMethod = "sandbox.diagnostics.get"
+	methodSandboxLeaseRenew   rpcMethod = "sandbox.lease.renew"
+	methodSandboxLeaseRelease rpcMethod = "sandbox.lease.release"
+	methodSandboxLockAcquire  rpcMethod = "sandbox.lock.acquire"
+	methodSandboxLockRelease  rpcMethod = "sandbox.lock.release"
+	methodImageBuild          rpcMethod = "image.build"
+	methodImageBuildLogs      rpcMethod = "image.build.logs"
+	methodImageLoadBegin      rpcMethod = "image.load.begin"
+	methodImageLoadChunk      rpcMethod = "image.load.chunk"
+	methodImageLoadCommit     rpcMethod = "image.load.commit"
+	methodImageList           rpcMethod = "image.list"
+	methodImageInspect        rpcMethod = "image.inspect"
+	methodImagePrune          rpcMethod = "image.prune"
+	methodImagePullProgress   rpcMethod = "image.pull.progress"
+
+	// methodServerPing is the method pings call. Servers answer methods they don't implement
+	// with method not found, once the request is authenticated and without doing any work.
+	methodServerPing rpcMethod = "server.ping"
+
+	// methodServerFeatures lists the optional features a server implements, for settings that
+	// servers without them would silently ignore.
+	methodServerFeatures rpcMethod = "server.features"
+)
+
+// Features servers list in answer to methodServerFeatures
+const (
+	featureNetworkPolicy = "network_policy" // Enforces startConfig.Network
 )
 
-// endpoint routing path
-const endpointRoute = "/api/v1/rpc"
+// JSON-RPC error codes
+const rpcCodeMethodNotFound = -32601
 
 // JSON-RPC request/response types
 type jsonRPCRequest struct {
@@ -41,6 +97,8 @@ type jsonRPCRequest struct {
 	Method  string `json:"method"`
 	Params  any    `json:"params"`
 	ID      string `json:"id,omitempty"`
+
+	sink outputSink // receives the output of a command result while it is decoded; nil to buffer it
 }
 
 type jsonRPCResponse struct {
@@ -67,6 +125,14 @@ type startConfig struct {
 	Memory    int               `json:"memory"`
 	CPUs      int               `json:"cpus"`
 	Volumes   []string          `json:"volumes,omitempty"`
+	Mounts    []Mount           `json:"mounts,omitempty"`
+	Network   *NetworkPolicy    `json:"network,omitempty"`
+	DNS       *DNSConfig        `json:"dns,omitempty"`
+	GPUs      *GPUConfig        `json:"gpus,omitempty"`
+	DiskMiB   int               `json:"disk_mib,omitempty"`
+	SwapMiB   int               `json:"swap_mib,omitempty"`
+	Limits    *Limits           `json:"limits,omitempty"`
+	User      string            `json:"user,omitempty"`
 	Ports     []string          `json:"ports,omitempty"`
 	Envs      []string          `json:"envs,omitempty"`
 	DependsOn []string          `json:"depends_on,omitempty"`
@@ -74,6 +140,9 @@ type startConfig struct {
 	Shell     string            `json:"shell,omitempty"`
 	Scripts   map[string]string `json:"scripts,omitempty"`
 	Exec      string            `json:"exec,omitempty"`
+
+	PullPolicy PullPolicy `json:"pull_policy,omitempty"`
+	Platform   string     `json:"platform,omitempty"`
 }
 
 type stopParams struct {
@@ -90,16 +159,171 @@ type commandRunParams struct {
 	Sandbox string   `json:"sandbox"`
 	Command string   `json:"command"`
 	Args    []string `json:"args"`
-	Timeout int      `json:"timeout,omitempty"`
+	User    string   `json:"user,omitempty"`
+	Timeout int      `json:"timeout,omitempty"` // seconds
 }
 
 type metricsGetParams struct {
 	SandboxName string `json:"sandbox"`
 }
 
+type pingParams struct{}
+
+type featuresResult struct {
+	Features []string `json:"features"`
+}
+
+type statusGetParams struct {
+	Sandbox string `json:"sandbox"`
+}
+
+type leaseRenewParams struct {
+	Sandbox string `json:"sandbox"`
+	Holder  string `json:"holder"`
+	TTL     int    `json:"ttl"` // seconds
+}
+
+type leaseReleaseParams struct {
+	Sandbox string `json:"sandbox"`
+	Holder  string `json:"holder"`
+}
+
+type lockAcquireParams struct {
+	Sandbox string `json:"sandbox"`
+	Key     string `json:"key"`
+	Holder  string `json:"holder"`
+	TTL     int    `json:"ttl"` // seconds
+}
+
+type lockReleaseParams struct {
+	Sandbox string `json:"sandbox"`
+	Key     string `json:"key"`
+	Holder  string `json:"holder"`
+}
+
+type imageBuildParams struct {
+	Tag        string            `json:"tag"`
+	Dockerfile string            `json:"dockerfile"`
+	Args       map[string]string `json:"args,omitempty"`
+	Platform   string            `json:"platform,omitempty"`
+	Context    []byte            `json:"context"` // gzipped tarball, base64-encoded by encoding/json
+}
+
+type buildLogsParams struct {
+	BuildID string `json:"build_id"`
+	Offset  int    `json:"offset"`
+}
+
+type imageLoadBeginParams struct{}
+
+type imageLoadChunkParams struct {
+	UploadID string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+	Data     []byte `json:"data"` // base64-encoded by encoding/json
+}
+
+type imageLoadCommitParams struct {
+	UploadID string `json:"upload_id"`
+}
+
+type imageListParams struct{}
+
+type imageInspectParams struct {
+	Ref string `json:"ref"`
+}
+
+type imagePruneParams struct {
+	OlderThan int `json:"older_than"` // seconds
+}
+
+type pullProgressParams struct {
+	Sandbox string `json:"sandbox"`
+	Image   string `json:"image"`
+}
+
+type logsParams struct {
+	Sandbox string `json:"sandbox"`
+	Offset  int    `json:"offset"` // lines already read
+}
+
+type diagnosticsParams struct {
+	Sandbox string `json:"sandbox"`
+	Lines   int    `json:"lines"` // log lines to include
+}
+
+type eventsParams struct {
+	Cursor    string      `json:"cursor,omitempty"` // position after the events already read; empty for "now"
+	Types     []EventType `json:"types,omitempty"`
+	Sandboxes []string    `json:"sandboxes,omitempty"`
+}
+
 // Response types
+type startResult struct {
+	pending   bool // the server accepted the sandbox but it wasn't running yet when the call returned
+	local     bool // no server was reachable, so the sandbox runs on the local engine
+	container bool // the sandbox runs in a container on this host (see WithContainerBackend)
+}
+
 type executionResult struct {
-	output json.RawMessage `json:"-"` // Store raw JSON for flexible parsing
+	output   json.RawMessage `json:"-"` // Store raw JSON for flexible parsing
+	sent     time.Time       // When the request was sent, for the execution's timing
+	received time.Time       // When the result arrived
+	request  execRequest     // What ran, for the execution's replay bundle
+}
+
+type statusResult struct {
+	Status string `json:"status"`
+}
+
+type leaseResult struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type imageBuildResult struct {
+	BuildID string `json:"build_id"`
+}
+
+type buildLogsResult struct {
+	Lines   []string `json:"lines"`
+	Done    bool     `json:"done"`
+	Error   string   `json:"error,omitempty"`
+	ImageID string   `json:"image_id,omitempty"`
+}
+
+type imageLoadBeginResult struct {
+	UploadID string `json:"upload_id"`
+}
+
+type imageLoadCommitResult struct {
+	Images []string `json:"images"`
+}
+
+type imageListResult struct {
+	Images []ImageInfo `json:"images"`
+}
+
+type imagePruneResult struct {
+	Removed        []string `json:"removed"`
+	ReclaimedBytes int64    `json:"reclaimed_bytes"`
+}
+
+type pullProgressResult struct {
+	Layers []LayerProgress `json:"layers"`
+	Done   bool            `json:"done"`
+}
+
+type logsResult struct {
+	Lines   []string `json:"lines"`
+	Running bool     `json:"running"`
+}
+
+type eventsResult struct {
+	Events []Event `json:"events"`
+	Cursor string  `json:"cursor"`
+}
+
+type lockResult struct {
+	Acquired bool `json:"acquired"`
 }
 
 type metricsResult struct {
@@ -107,112 +331,360 @@ type metricsResult struct {
 }
 
 type sandboxMetrics struct {
-	Name        string  `json:"name"`
-	Running     bool    `json:"running"`
-	CPUUsage    float64 `json:"cpu_usage"`
-	MemoryUsage int     `json:"memory_usage"`
-	DiskUsage   int     `json:"disk_usage"`
+	Name        string       `json:"name"`
+	Running     bool         `json:"running"`
+	CPUUsage    float64      `json:"cpu_usage"`
+	MemoryUsage uint64       `json:"memory_usage"` // MiB
+	DiskUsage   uint64       `json:"disk_usage"`   // bytes
+	GPUs        []GPUMetrics `json:"gpus,omitempty"`
+}
+
+// public converts the metrics to the SDK's exported representation.
+func (m *sandboxMetrics) public() Metrics {
+	return Metrics{
+		Name:      m.Name,
+		IsRunning: m.Running,
+		CPU:       m.CPUUsage,
+		MemoryMiB: clampInt(m.MemoryUsage),
+		DiskBytes: clampInt(m.DiskUsage),
+		GPUs:      m.GPUs,
+	}
 }
 
 var _ rpcClient = &jsonRPCHTTPClient{}
 
 type jsonRPCHTTPClient struct {
 	*http.Client
+	noStatusRPC atomic.Bool // set once the server turned out not to support methodSandboxStatusGet
+	features    sync.Map    // server URL -> []string, the features it listed
 }
 
-func newDefaultJsonRPCHTTPClient() rpcClient {
+func newDefaultJsonRPCHTTPClient(proxy func(*http.Request) (*url.URL, error)) rpcClient {
 	return newJsonRPCHTTPClient(
 		&http.Client{
 			Transport: &http.Transport{
-				MaxIdleConns:       10,
-				IdleConnTimeout:    30 * time.Second,
-				DisableCompression: true,
+				Proxy:               proxy,
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 10, // every request goes to the same server
+				IdleConnTimeout:     30 * time.Second,
+				DisableCompression:  true,
 			},
 		},
 	)
 }
 
 func newJsonRPCHTTPClient(c *http.Client) rpcClient {
-	return &jsonRPCHTTPClient{c}
+	return &jsonRPCHTTPClient{Client: c}
 }
 
-func (d *jsonRPCHTTPClient) makeJSONRPCRequest(ctx context.Context, serverURL string, method rpcMethod, params any, apiKey string, logger Logger, reqIdPrd ReqIdProducer) (resp jsonRPCResponse, err error) {
+func (d *jsonRPCHTTPClient) makeJSONRPCRequest(ctx context.Context, cfg *config, method rpcMethod, params any) (jsonRPCResponse, error) {
+	return d.sendJSONRPCRequest(ctx, cfg, newJSONRPCRequest(cfg, method, params))
+}
+
+func newJSONRPCRequest(cfg *config, method rpcMethod, params any) *jsonRPCRequest {
 	req := &jsonRPCRequest{
 		JSONRPC: "2.0",
 		Method:  string(method),
 		Params:  params,
 	}
-	if reqIdPrd != nil {
-		req.ID = reqIdPrd()
+	if cfg.reqIDPrd != nil {
+		req.ID = cfg.reqIDPrd()
+	}
+	return req
+}
+
+// sendJSONRPCRequest sends req, retrying it as the retry policy, API version negotiation and API
+// key refreshes call for.
+func (d *jsonRPCHTTPClient) sendJSONRPCRequest(ctx context.Context, cfg *config, req *jsonRPCRequest) (jsonRPCResponse, error) {
+	if cfg.serverUrlErr != nil {
+		return jsonRPCResponse{}, cfg.serverUrlErr
+	}
+	if cfg.nameErr != nil {
+		return jsonRPCResponse{}, cfg.nameErr
+	}
+	if err := cfg.tenantAPIKey(ctx); err != nil {
+		return jsonRPCResponse{}, err
+	}
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		version := cfg.currentAPIVersion()
+		release, err := cfg.scheduler.acquire(ctx, cfg.tenant)
+		if err != nil {
+			return jsonRPCResponse{}, err
+		}
+		resp, retryable, err := d.doJSONRPCRequest(ctx, cfg, version, req)
+		release()
+		if errors.Is(err, ErrEndpointNotFound) && cfg.fallBackAPIVersion(version) {
+			// the server never saw the request, so it is resent as part of the negotiation
+			attempt--
+			continue
+		}
+		var authErr *UnauthorizedError
+		if !refreshed && errors.As(err, &authErr) {
+			// a refreshed key gets one more try, which doesn't count against the retry policy
+			refreshed = true
+			retry, refreshErr := cfg.refreshAPIKey(ctx, authErr.Status)
+			if refreshErr != nil {
+				return resp, fmt.Errorf("%w: %w", err, refreshErr)
+			}
+			if retry {
+				attempt--
+				continue
+			}
+		}
+		if err == nil || !retryable || attempt >= cfg.retry.MaxAttempts {
+			return resp, err
+		}
+
+		backoff := cfg.retry.backoff(attempt)
+		var serverErr *ServerError
+		if errors.As(err, &serverErr) && serverErr.RetryAfter > 0 {
+			// the server knows best when it will take requests again
+			backoff = serverErr.RetryAfter
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+				return resp, err
+			}
+		}
+		cfg.log(ctx).Debug("Retrying JSON-RPC request", "method", req.Method, "id", req.ID, "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return resp, fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-time.After(backoff):
+		}
 	}
+}
 
-	logger.Debug("Making JSON-RPC request", "method", string(method), "id", req.ID)
+// doJSONRPCRequest performs a single attempt of req. retryable reports whether the failure is
+// safe to retry, i.e. the server never saw the request or explicitly rejected it as overloaded.
+func (d *jsonRPCHTTPClient) doJSONRPCRequest(ctx context.Context, cfg *config, version APIVersion, req *jsonRPCRequest) (resp jsonRPCResponse, retryable bool, err error) {
+	logger := cfg.log(ctx)
+	method := req.Method
 
-	reqBytes, err := json.Marshal(req)
+	base, err := cfg.baseURL(ctx)
 	if err != nil {
-		logger.Error("Failed to marshal JSON-RPC request", "method", string(method), "error", err)
-		return resp, fmt.Errorf("%w: %w", ErrMarshalReqFailed, err)
+		logger.Error("Failed to discover server", "method", method, "error", err)
+		return resp, ctx.Err() == nil, err
 	}
+	url := cfg.endpointURL(base, version)
+	logger.Debug("Making JSON-RPC request", "method", method, "id", req.ID, "url", url)
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s%s", serverURL, endpointRoute), bytes.NewReader(reqBytes))
+	// request bodies aren't pooled: the transport may still read one after Do returns
+	// (golang.org/issue/51907)
+	payload, err := json.Marshal(req)
 	if err != nil {
-		logger.Error("Failed to create HTTP request", "method", string(method), "error", err)
-		return resp, fmt.Errorf("%w: %w", ErrCreateRequestFailed, err)
+		logger.Error("Failed to marshal JSON-RPC request", "method", method, "error", err)
+		return resp, false, fmt.Errorf("%w: %w", ErrMarshalReqFailed, err)
+	}
+	if err := cfg.checkRequestSize(method, len(payload)); err != nil {
+		logger.Error("JSON-RPC request too large", "method", method, "size", len(payload))
+		return resp, false, err
 	}
 
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		logger.Error("Failed to create HTTP request", "method", method, "error", err)
+		return resp, false, fmt.Errorf("%w: %w", ErrCreateRequestFailed, err)
+	}
+
+	if cfg.traceHdrs != nil {
+		cfg.traceHdrs(ctx, httpReq.Header)
+	}
+	cfg.setMetadataHeaders(ctx, httpReq.Header)
 	httpReq.Header.Set("Content-Type", "application/json")
-	if apiKey != "" {
+	if apiKey := cfg.currentAPIKey(); apiKey != "" {
 		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 	}
 
 	httpResp, err := d.Do(httpReq)
 	if err != nil {
-		logger.Error("Failed to send HTTP request", "method", string(method), "error", err)
-		return resp, fmt.Errorf("%w: %w", ErrSendRequestFailed, err)
+		logger.Error("Failed to send HTTP request", "method", method, "error", err)
+		if isClientTimeout(ctx, err) {
+			// the server may well be processing the request, so it is not safe to send it again
+			return resp, false, fmt.Errorf("%w: %w: %w", ErrSendRequestFailed, ErrClientTimeout, err)
+		}
+		if cfg.discovery != nil {
+			// the retry goes to whichever server the records list now
+			cfg.discovery.forget(base)
+		}
+		// the connection may have broken after the request was written, in which case the server
+		// may be running it, so only failures to connect at all are retried; a cancelled or
+		// expired ctx stays matchable with errors.Is through err
+		return resp, ctx.Err() == nil && neverSent(err), fmt.Errorf("%w: %w", ErrSendRequestFailed, err)
 	}
 	defer func() {
 		if closeErr := httpResp.Body.Close(); closeErr != nil && err == nil {
 			err = fmt.Errorf("%w: %w", ErrResponseBodyCloseFailed, closeErr)
 		}
 	}()
+	respBody, err := newResponseBody(httpResp)
+	if err != nil {
+		logger.Error("Failed to decode HTTP response", "method", method, "status", httpResp.StatusCode, "error", err)
+		return resp, false, err
+	}
+	httpResp.Body = respBody
 
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
-		logger.Error("HTTP request failed", "method", string(method), "status", httpResp.StatusCode, "body", string(body))
-		return resp, fmt.Errorf("%w: status %d: %s", ErrRequestFailed, httpResp.StatusCode, string(body))
+		// unknown methods are reported with a 404 carrying a regular JSON-RPC error
+		var rpcResp jsonRPCResponse
+		if json.Unmarshal(body, &rpcResp) == nil && rpcResp.Error != nil && rpcResp.Error.Code == rpcCodeMethodNotFound {
+			logger.Debug("JSON-RPC method not supported by server", "method", method)
+			return resp, false, fmt.Errorf("%w: %w: %s", ErrRPCCall, ErrMethodNotFound, method)
+		}
+		// the server reports missing resources with a JSON body; anything else answered with a 404
+		// comes from a router or proxy that doesn't know the path
+		if httpResp.StatusCode == http.StatusNotFound && !json.Valid(body) {
+			logger.Debug("JSON-RPC endpoint not found", "method", method, "url", url)
+			return resp, false, fmt.Errorf("%w: %w: %s", ErrRequestFailed, ErrEndpointNotFound, url)
+		}
+		if httpResp.StatusCode == http.StatusRequestEntityTooLarge {
+			logger.Error("JSON-RPC request rejected as too large", "method", method)
+			return resp, false, &PayloadTooLargeError{Method: method}
+		}
+		if httpResp.StatusCode == http.StatusUnauthorized || httpResp.StatusCode == http.StatusForbidden {
+			logger.Error("API key rejected by server", "method", method, "status", httpResp.StatusCode)
+			logger.Debug("Authentication failure response", "method", method, "body", string(body))
+			return resp, false, &UnauthorizedError{Status: httpResp.StatusCode}
+		}
+		logger.Error("HTTP request failed", "method", method, "status", httpResp.StatusCode, "body", string(body))
+		if isExecutionTimeout(method, string(body)) {
+			return resp, false, fmt.Errorf("%w: %w: %s", ErrRequestFailed, ErrExecutionTimedOut, string(body))
+		}
+		if isExecution(method) && isDiskFull(string(body)) {
+			return resp, false, fmt.Errorf("%w: %w: %s", ErrRequestFailed, ErrDiskQuotaExceeded, string(body))
+		}
+		retryable := httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode == http.StatusServiceUnavailable
+		return resp, retryable, newServerError(httpResp, body)
 	}
 
-	respBytes, err := io.ReadAll(httpResp.Body)
+	jsonResp, err := readJSONRPCResponse(ctx, httpResp, respBody, req.sink)
 	if err != nil {
-		return resp, fmt.Errorf("%w: %w", ErrReadResponseFailed, err)
+		return resp, false, err
+	}
+
+	if jsonResp.Error != nil && jsonResp.Error.Code == rpcCodeMethodNotFound {
+		logger.Debug("JSON-RPC method not supported by server", "method", method)
+		return resp, false, fmt.Errorf("%w: %w: %s", ErrRPCCall, ErrMethodNotFound, method)
+	}
+	if jsonResp.Error != nil {
+		logger.Error("JSON-RPC error", "method", method, "error", jsonResp.Error.Message, "code", jsonResp.Error.Code)
+		if isExecutionTimeout(method, jsonResp.Error.Message) {
+			return resp, false, fmt.Errorf("%w: %w: %s", ErrRPCCall, ErrExecutionTimedOut, jsonResp.Error.Message)
+		}
+		if isExecution(method) && isDiskFull(jsonResp.Error.Message) {
+			return resp, false, fmt.Errorf("%w: %w: %s", ErrRPCCall, ErrDiskQuotaExceeded, jsonResp.Error.Message)
+		}
+		return resp, false, fmt.Errorf("%w: %s", ErrRPCCall, jsonResp.Error.Message)
 	}
 
+	logger.Debug("JSON-RPC request completed successfully", "method", method, "id", req.ID)
+	return jsonResp, false, nil
+}
+
+// readJSONRPCResponse reads a successful response from body. With a sink, the response is
+// decoded as it is read rather than buffered first.
+func readJSONRPCResponse(ctx context.Context, httpResp *http.Response, body *responseBody, sink outputSink) (jsonRPCResponse, error) {
 	var jsonResp jsonRPCResponse
-	if err := json.Unmarshal(respBytes, &jsonResp); err != nil {
-		return resp, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	if sink != nil {
+		jsonResp, err := decodeResponseTo(body, sink)
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case err == nil || errors.Is(err, ErrFailedToWriteOutput):
+			return jsonResp, err
+		case isClientTimeout(ctx, err):
+			return jsonResp, fmt.Errorf("%w: %w: %w", ErrReadResponseFailed, ErrClientTimeout, err)
+		case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, errNotJSONObject):
+			return jsonResp, body.decodeErr(httpResp, err)
+		default:
+			return jsonResp, fmt.Errorf("%w: %w", ErrReadResponseFailed, err)
+		}
 	}
 
-	if jsonResp.Error != nil {
-		logger.Error("JSON-RPC error", "method", string(method), "error", jsonResp.Error.Message, "code", jsonResp.Error.Code)
-		return resp, fmt.Errorf("%w: %s", ErrRPCCall, jsonResp.Error.Message)
+	respBuf := getBuffer()
+	defer putBuffer(respBuf)
+	if _, err := respBuf.ReadFrom(body); err != nil {
+		if isClientTimeout(ctx, err) {
+			return jsonResp, fmt.Errorf("%w: %w: %w", ErrReadResponseFailed, ErrClientTimeout, err)
+		}
+		return jsonResp, fmt.Errorf("%w: %w", ErrReadResponseFailed, err)
 	}
 
-	logger.Debug("JSON-RPC request completed successfully", "method", string(method), "id", req.ID)
+	// Unmarshal copies the result out of respBuf, so the buffer can be safely reused afterwards
+	if err := json.Unmarshal(respBuf.Bytes(), &jsonResp); err != nil {
+		return jsonResp, body.decodeErr(httpResp, err)
+	}
 	return jsonResp, nil
 }
 
-func (d *jsonRPCHTTPClient) startSandbox(ctx context.Context, cfg *config, sc startConfig) error {
+// isClientTimeout reports whether err was caused by the HTTP client's own deadline
+// (http.Client.Timeout or a transport timeout) rather than by ctx.
+func isClientTimeout(ctx context.Context, err error) bool {
+	var netErr net.Error
+	return ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// neverSent reports whether err shows that a request never left the client: the server's name
+// couldn't be resolved or no connection to it could be made.
+func neverSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isExecutionTimeout reports whether the server failed an execution because it exceeded its
+// server-side time limit. The sandbox reports this as "Evaluation timeout after N seconds" for
+// REPL runs and "Command timeout after N seconds" for commands.
+func isExecutionTimeout(method, message string) bool {
+	if !isExecution(method) {
+		return false
+	}
+	return strings.Contains(message, "timeout after") || strings.Contains(message, "timed out after")
+}
+
+// isExecution reports whether method runs user code or commands in the sandbox.
+func isExecution(method string) bool {
+	return method == string(methodSandboxReplRun) || method == string(methodSandboxCommandRun)
+}
+
+func (d *jsonRPCHTTPClient) startSandbox(ctx context.Context, cfg *config, sc startConfig) (*startResult, error) {
+	// a server unaware of network policies would run the sandbox with its default egress
+	if sc.Network != nil {
+		ok, err := d.supports(ctx, cfg, featureNetworkPolicy)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %q network mode", ErrNetworkPolicyUnsupported, sc.Network.Mode)
+		}
+	}
 	params := startParams{
 		Sandbox: cfg.name,
 		Config:  sc,
 	}
 
-	cfg.logger.Info("Starting sandbox", "name", cfg.name, "image", sc.Image, "memory", sc.Memory, "cpus", sc.CPUs)
-	_, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxStart, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
-	if err == nil {
-		cfg.logger.Info("Sandbox started successfully", "name", cfg.name)
+	cfg.log(ctx).Info("Starting sandbox", "name", cfg.name, "image", sc.Image, "memory", sc.Memory, "cpus", sc.CPUs)
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxStart, params)
+	if err != nil {
+		return nil, err
 	}
-	return err
+
+	// The server answers with a status message. When the sandbox is still pulling its image or
+	// booting once the server stops waiting for it, the message says so instead of failing the call.
+	var message string
+	_ = json.Unmarshal(resp.Result, &message)
+	result := &startResult{
+		pending: strings.Contains(message, "timed out waiting") || strings.Contains(message, "couldn't verify"),
+	}
+	if result.pending {
+		cfg.log(ctx).Info("Sandbox accepted but still initializing", "name", cfg.name, "message", message)
+	} else {
+		cfg.log(ctx).Info("Sandbox started successfully", "name", cfg.name)
+	}
+	return result, nil
 }
 
 func (d *jsonRPCHTTPClient) stopSandbox(ctx context.Context, cfg *config) error {
@@ -220,10 +692,10 @@ func (d *jsonRPCHTTPClient) stopSandbox(ctx context.Context, cfg *config) error
 		Sandbox: cfg.name,
 	}
 
-	cfg.logger.Info("Stopping sandbox", "name", cfg.name)
-	_, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxStop, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	cfg.log(ctx).Info("Stopping sandbox", "name", cfg.name)
+	_, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxStop, params)
 	if err == nil {
-		cfg.logger.Info("Sandbox stopped successfully", "name", cfg.name)
+		cfg.log(ctx).Info("Sandbox stopped successfully", "name", cfg.name)
 	}
 	return err
 }
@@ -235,30 +707,39 @@ func (d *jsonRPCHTTPClient) runRepl(ctx context.Context, cfg *config, lang progL
 		Code:     code,
 	}
 
-	cfg.logger.Debug("Executing code in REPL", "sandbox", cfg.name, "language", lang.String())
-	resp, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxReplRun, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	cfg.log(ctx).Debug("Executing code in REPL", "sandbox", cfg.name, "language", lang.String())
+	sent := time.Now()
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxReplRun, params)
 	if err != nil {
 		return nil, err
 	}
 
-	return &executionResult{output: resp.Result}, nil
+	return &executionResult{output: resp.Result, sent: sent, received: time.Now()}, nil
 }
 
-func (d *jsonRPCHTTPClient) runCommand(ctx context.Context, cfg *config, command string, args []string) (*executionResult, error) {
+func (d *jsonRPCHTTPClient) runCommand(ctx context.Context, cfg *config, user, command string, args []string) (*executionResult, error) {
+	return d.runCommandTo(ctx, cfg, user, command, args, nil)
+}
+
+func (d *jsonRPCHTTPClient) runCommandTo(ctx context.Context, cfg *config, user, command string, args []string, sink outputSink) (*executionResult, error) {
 	params := commandRunParams{
 		Sandbox: cfg.name,
 		Command: command,
 		Args:    args,
-		Timeout: int(d.Timeout),
+		User:    user,
+		Timeout: serverTimeout(ctx, d.Timeout),
 	}
 
-	cfg.logger.Debug("Executing command", "sandbox", cfg.name, "command", command, "args", args)
-	resp, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxCommandRun, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	cfg.log(ctx).Debug("Executing command", "sandbox", cfg.name, "command", command, "args", args)
+	req := newJSONRPCRequest(cfg, methodSandboxCommandRun, params)
+	req.sink = sink
+	sent := time.Now()
+	resp, err := d.sendJSONRPCRequest(ctx, cfg, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &executionResult{output: resp.Result}, nil
+	return &executionResult{output: resp.Result, sent: sent, received: time.Now()}, nil
 }
 
 func (d *jsonRPCHTTPClient) getMetrics(ctx context.Context, cfg *config) (*sandboxMetrics, error) {
@@ -266,15 +747,15 @@ func (d *jsonRPCHTTPClient) getMetrics(ctx context.Context, cfg *config) (*sandb
 		SandboxName: cfg.name,
 	}
 
-	cfg.logger.Debug("Getting sandbox metrics", "sandbox", cfg.name)
-	resp, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxMetricsGet, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	cfg.log(ctx).Debug("Getting sandbox metrics", "sandbox", cfg.name)
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxMetricsGet, params)
 	if err != nil {
 		return nil, err
 	}
 
 	var result metricsResult
 	if err := json.Unmarshal(resp.Result, &result); err != nil {
-		cfg.logger.Error("Failed to unmarshal metrics result", "error", err)
+		cfg.log(ctx).Error("Failed to unmarshal metrics result", "error", err)
 		return nil, fmt.Errorf("%w: %w", ErrUnmarshalMetricsFailed, err)
 	}
 
@@ -286,6 +767,337 @@ func (d *jsonRPCHTTPClient) getMetrics(ctx context.Context, cfg *config) (*sandb
 	return &result.Sandboxes[0], nil
 }
 
+// listMetrics returns the metrics of every sandbox the server reports.
+func (d *jsonRPCHTTPClient) listMetrics(ctx context.Context, cfg *config) ([]sandboxMetrics, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxMetricsGet, pingParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result metricsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalMetricsFailed, err)
+	}
+	return result.Sandboxes, nil
+}
+
+// ping makes a cheap authenticated round trip. Unlike a metrics query, whose cost grows with the
+// number of sandboxes, server.ping needs no work on the server: an answer of method not found is
+// as good as any, as the server only gives it to authenticated requests that reached its RPC
+// endpoint.
+func (d *jsonRPCHTTPClient) ping(ctx context.Context, cfg *config) error {
+	cfg.log(ctx).Debug("Pinging server", "url", cfg.currentServerURL())
+	_, err := d.makeJSONRPCRequest(ctx, cfg, methodServerPing, pingParams{})
+	if errors.Is(err, ErrMethodNotFound) {
+		return nil
+	}
+	return err
+}
+
+// supports reports whether the server lists feature among the ones it implements. Servers that
+// predate methodServerFeatures implement none. The answer is kept for the life of the client.
+func (d *jsonRPCHTTPClient) supports(ctx context.Context, cfg *config, feature string) (bool, error) {
+	server := cfg.currentServerURL()
+	if features, ok := d.features.Load(server); ok {
+		return slices.Contains(features.([]string), feature), nil
+	}
+	var features []string
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodServerFeatures, pingParams{})
+	switch {
+	case errors.Is(err, ErrMethodNotFound):
+	case err != nil:
+		return false, err
+	default:
+		var result featuresResult
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return false, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		}
+		features = result.Features
+	}
+	d.features.Store(server, features)
+	return slices.Contains(features, feature), nil
+}
+
+// getStatus asks the server for the sandbox's lifecycle status. Servers that predate the status RPC
+// are queried through the metrics call instead, which only tells whether a sandbox is running: one
+// that isn't may be stopped but may as well still be starting, so it is reported as unknown.
+func (d *jsonRPCHTTPClient) getStatus(ctx context.Context, cfg *config) (SandboxStatus, error) {
+	if !d.noStatusRPC.Load() {
+		params := statusGetParams{
+			Sandbox: cfg.name,
+		}
+		resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxStatusGet, params)
+		if err == nil {
+			var result statusResult
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				return StatusUnknown, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+			}
+			return parseSandboxStatus(result.Status), nil
+		}
+		if !errors.Is(err, ErrMethodNotFound) {
+			return StatusUnknown, err
+		}
+		cfg.log(ctx).Debug("Falling back to metrics for sandbox status", "sandbox", cfg.name)
+		d.noStatusRPC.Store(true)
+	}
+
+	metrics, err := d.getMetrics(ctx, cfg)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	if metrics.Name == cfg.name && metrics.Running {
+		return StatusRunning, nil
+	}
+	return StatusUnknown, nil
+}
+
+// renewLease acquires or renews the holder's lease on the sandbox and returns its new expiry.
+// Servers that don't report the expiry are assumed to have granted the full TTL.
+func (d *jsonRPCHTTPClient) renewLease(ctx context.Context, cfg *config, holder string, ttl time.Duration) (time.Time, error) {
+	params := leaseRenewParams{
+		Sandbox: cfg.name,
+		Holder:  holder,
+		TTL:     int((ttl + time.Second - 1) / time.Second),
+	}
+
+	requested := time.Now()
+	cfg.log(ctx).Debug("Renewing sandbox lease", "sandbox", cfg.name, "holder", holder, "ttl", ttl)
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxLeaseRenew, params)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var result leaseResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result.ExpiresAt.IsZero() {
+		return requested.Add(ttl), nil
+	}
+	return result.ExpiresAt, nil
+}
+
+func (d *jsonRPCHTTPClient) releaseLease(ctx context.Context, cfg *config, holder string) error {
+	params := leaseReleaseParams{
+		Sandbox: cfg.name,
+		Holder:  holder,
+	}
+
+	cfg.log(ctx).Debug("Releasing sandbox lease", "sandbox", cfg.name, "holder", holder)
+	_, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxLeaseRelease, params)
+	return err
+}
+
+// acquireLock tries once to take (or refresh) the holder's lock on key. It reports false, without
+// an error, when the lock is currently held by someone else.
+func (d *jsonRPCHTTPClient) acquireLock(ctx context.Context, cfg *config, key, holder string, ttl time.Duration) (bool, error) {
+	params := lockAcquireParams{
+		Sandbox: cfg.name,
+		Key:     key,
+		Holder:  holder,
+		TTL:     int((ttl + time.Second - 1) / time.Second),
+	}
+
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxLockAcquire, params)
+	if err != nil {
+		return false, err
+	}
+
+	var result lockResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return result.Acquired, nil
+}
+
+func (d *jsonRPCHTTPClient) releaseLock(ctx context.Context, cfg *config, key, holder string) error {
+	params := lockReleaseParams{
+		Sandbox: cfg.name,
+		Key:     key,
+		Holder:  holder,
+	}
+
+	_, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxLockRelease, params)
+	return err
+}
+
+func (d *jsonRPCHTTPClient) buildImage(ctx context.Context, cfg *config, params imageBuildParams) (string, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImageBuild, params)
+	if err != nil {
+		return "", err
+	}
+
+	var result imageBuildResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return result.BuildID, nil
+}
+
+// buildLogs returns the build's log lines from offset on, and whether the build has finished.
+func (d *jsonRPCHTTPClient) buildLogs(ctx context.Context, cfg *config, buildID string, offset int) (*buildLogsResult, error) {
+	params := buildLogsParams{
+		BuildID: buildID,
+		Offset:  offset,
+	}
+
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImageBuildLogs, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result buildLogsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &result, nil
+}
+
+func (d *jsonRPCHTTPClient) beginImageLoad(ctx context.Context, cfg *config) (string, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImageLoadBegin, imageLoadBeginParams{})
+	if err != nil {
+		return "", err
+	}
+
+	var result imageLoadBeginResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return result.UploadID, nil
+}
+
+func (d *jsonRPCHTTPClient) uploadImageChunk(ctx context.Context, cfg *config, uploadID string, offset int64, data []byte) error {
+	params := imageLoadChunkParams{
+		UploadID: uploadID,
+		Offset:   offset,
+		Data:     data,
+	}
+
+	cfg.log(ctx).Debug("Uploading image archive chunk", "upload", uploadID, "offset", offset, "size", len(data))
+	_, err := d.makeJSONRPCRequest(ctx, cfg, methodImageLoadChunk, params)
+	return err
+}
+
+func (d *jsonRPCHTTPClient) commitImageLoad(ctx context.Context, cfg *config, uploadID string) ([]string, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImageLoadCommit, imageLoadCommitParams{UploadID: uploadID})
+	if err != nil {
+		return nil, err
+	}
+
+	var result imageLoadCommitResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return result.Images, nil
+}
+
+func (d *jsonRPCHTTPClient) listImages(ctx context.Context, cfg *config) ([]ImageInfo, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImageList, imageListParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result imageListResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return result.Images, nil
+}
+
+func (d *jsonRPCHTTPClient) inspectImage(ctx context.Context, cfg *config, ref string) (*ImageInfo, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImageInspect, imageInspectParams{Ref: ref})
+	if err != nil {
+		return nil, err
+	}
+
+	var result ImageInfo
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &result, nil
+}
+
+func (d *jsonRPCHTTPClient) pruneImages(ctx context.Context, cfg *config, olderThan time.Duration) (*imagePruneResult, error) {
+	params := imagePruneParams{
+		OlderThan: int(olderThan / time.Second),
+	}
+
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImagePrune, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result imagePruneResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &result, nil
+}
+
+func (d *jsonRPCHTTPClient) getPullProgress(ctx context.Context, cfg *config, image string) (*pullProgressResult, error) {
+	params := pullProgressParams{
+		Sandbox: cfg.name,
+		Image:   image,
+	}
+
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodImagePullProgress, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result pullProgressResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &result, nil
+}
+
+func (d *jsonRPCHTTPClient) getLogs(ctx context.Context, cfg *config, offset int) (*logsResult, error) {
+	params := logsParams{
+		Sandbox: cfg.name,
+		Offset:  offset,
+	}
+
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxLogsGet, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result logsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &result, nil
+}
+
+func (d *jsonRPCHTTPClient) getEvents(ctx context.Context, cfg *config, params eventsParams) (*eventsResult, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxEventsGet, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result eventsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &result, nil
+}
+
+func (d *jsonRPCHTTPClient) getDiagnostics(ctx context.Context, cfg *config, logLines int) (*Diagnostics, error) {
+	params := diagnosticsParams{
+		Sandbox: cfg.name,
+		Lines:   logLines,
+	}
+
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxDiagnostics, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Diagnostics
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &result, nil
+}
+
 // --- Error definitions ---
 var (
 	ErrMarshalReqFailed        = errors.New("failed to marshal request")
@@ -297,4 +1109,7 @@ var (
 	ErrUnmarshalMetricsFailed  = errors.New("failed to unmarshal metrics result")
 	ErrRequestFailed           = errors.New("request failed")
 	ErrRPCCall                 = errors.New("RPC error")
+	ErrMethodNotFound          = errors.New("method not supported by server")
+	ErrClientTimeout           = errors.New("client timeout exceeded")
+	ErrExecutionTimedOut       = errors.New("execution timed out on server")
 )
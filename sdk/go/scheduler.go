@@ -0,0 +1,148 @@
+package msb
+
+import (
+	"context"
+	"sync"
+)
+
+// FairScheduler bounds the requests a client has in flight and shares them out fairly between
+// the tenants of its clients derived with WithTenant, so a burst of executions from one tenant
+// can't starve the others. See WithFairScheduler.
+type FairScheduler struct {
+	MaxConcurrent int            // Requests in flight at once, across all tenants
+	Weights       map[string]int // Relative share of each tenant ID; unlisted tenants weigh 1
+}
+
+// WithFairScheduler queues requests once s.MaxConcurrent of them are in flight, and hands freed
+// slots out by weighted fair queuing: while tenants compete, each gets a share of the requests
+// proportional to its weight, whatever the number of requests it has queued. Requests of sandboxes
+// not scoped to a tenant form a tenant of their own. Retries wait for a new slot, and queued
+// requests give up when their context is done. The scheduler is shared by the clients and
+// sandboxes derived from the client it is set on. A non-positive MaxConcurrent disables it.
+//
+//	client := msb.NewClient(msb.WithFairScheduler(msb.FairScheduler{
+//		MaxConcurrent: 64,
+//		Weights:       map[string]int{"enterprise-co": 4},
+//	}))
+func WithFairScheduler(s FairScheduler) Option {
+	return func(msb *baseMicroSandbox) {
+		if s.MaxConcurrent <= 0 {
+			msb.cfg.scheduler = nil
+			return
+		}
+		msb.cfg.scheduler = newFairScheduler(s)
+	}
+}
+
+// fairScheduler implements start-time fair queuing: each tenant's requests are tagged with the
+// virtual time they may start at, advancing by 1/weight per request, and the lowest tag goes first.
+type fairScheduler struct {
+	slots   int
+	weights map[string]int
+
+	mu      sync.Mutex
+	inUse   int
+	waiting int
+	vtime   float64 // start tag of the request dispatched last
+	queues  map[string]*tenantQueue
+}
+
+type tenantQueue struct {
+	finish  float64         // tag the tenant's next request starts at, unless it was idle
+	waiters []chan struct{} // closed when granted a slot, in FIFO order
+}
+
+func newFairScheduler(s FairScheduler) *fairScheduler {
+	weights := make(map[string]int, len(s.Weights))
+	for tenant, w := range s.Weights {
+		weights[tenant] = w
+	}
+	return &fairScheduler{slots: s.MaxConcurrent, weights: weights, queues: make(map[string]*tenantQueue)}
+}
+
+// acquire waits for a slot for a request of tenant, returning the function that frees it. A nil
+// scheduler grants slots right away.
+func (s *fairScheduler) acquire(ctx context.Context, tenant string) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	q := s.queue(tenant)
+	if s.inUse < s.slots && s.waiting == 0 {
+		s.dispatch(tenant, q)
+		s.mu.Unlock()
+		return s.release, nil
+	}
+	granted := make(chan struct{})
+	q.waiters = append(q.waiters, granted)
+	s.waiting++
+	s.mu.Unlock()
+
+	select {
+	case <-granted:
+		return s.release, nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	for i, ch := range q.waiters {
+		if ch == granted {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			s.waiting--
+			s.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	s.mu.Unlock()
+	// granted while giving up: pass the slot on
+	s.release()
+	return nil, ctx.Err()
+}
+
+func (s *fairScheduler) queue(tenant string) *tenantQueue {
+	q, ok := s.queues[tenant]
+	if !ok {
+		q = &tenantQueue{}
+		s.queues[tenant] = q
+	}
+	return q
+}
+
+// dispatch takes a slot for the next request of tenant.
+func (s *fairScheduler) dispatch(tenant string, q *tenantQueue) {
+	// a tenant coming back from idle starts at the current virtual time, banking no credit
+	start := max(q.finish, s.vtime)
+	q.finish = start + 1/float64(max(s.weights[tenant], 1))
+	s.vtime = start
+	s.inUse++
+}
+
+func (s *fairScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inUse--
+	for s.inUse < s.slots && s.waiting > 0 {
+		var next string
+		var nextQ *tenantQueue
+		var nextStart float64
+		for tenant, q := range s.queues {
+			if len(q.waiters) == 0 {
+				continue
+			}
+			start := max(q.finish, s.vtime)
+			if nextQ == nil || start < nextStart || start == nextStart && tenant < next {
+				next, nextQ, nextStart = tenant, q, start
+			}
+		}
+		granted := nextQ.waiters[0]
+		nextQ.waiters = nextQ.waiters[1:]
+		s.waiting--
+		s.dispatch(next, nextQ)
+		close(granted)
+	}
+	for tenant, q := range s.queues {
+		// an idle tenant's tag no longer matters once the virtual time has passed it
+		if len(q.waiters) == 0 && q.finish <= s.vtime {
+			delete(s.queues, tenant)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+// waitQueued waits until n requests are queued on s.
+func waitQueued(t *testing.T, s *fairScheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		waiting := s.waiting
+		s.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests queued, want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// grantOrder queues requests of the tenants, in order, behind release, a request holding s's only
+// slot, then frees it and returns the tenants in the order they were granted slots.
+func grantOrder(t *testing.T, s *fairScheduler, release func(), tenants ...string) []string {
+	t.Helper()
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for i, tenant := range tenants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, err := s.acquire(context.Background(), tenant)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, tenant)
+			mu.Unlock()
+			done()
+		}()
+		// queued one at a time, so tenants' requests queue in the order given
+		waitQueued(t, s, i+1)
+	}
+	release()
+	wg.Wait()
+	return order
+}
+
+func countTenant(tenants []string, tenant string) int {
+	n := 0
+	for _, t := range tenants {
+		if t == tenant {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFairSchedulerBoundsConcurrency(t *testing.T) {
+	s := newFairScheduler(FairScheduler{MaxConcurrent: 2})
+	ctx := context.Background()
+	first, _ := s.acquire(ctx, "a")
+	second, _ := s.acquire(ctx, "b")
+
+	granted := make(chan func())
+	go func() {
+		release, err := s.acquire(ctx, "a")
+		if err != nil {
+			t.Error(err)
+		}
+		granted <- release
+	}()
+	waitQueued(t, s, 1)
+	select {
+	case <-granted:
+		t.Fatal("third request granted with both slots in use")
+	case <-time.After(20 * time.Millisecond):
+	}
+	first()
+	(<-granted)()
+	second()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inUse != 0 || s.waiting != 0 {
+		t.Errorf("in use %d, waiting %d after every release", s.inUse, s.waiting)
+	}
+}
+
+func TestFairSchedulerWeights(t *testing.T) {
+	s := newFairScheduler(FairScheduler{MaxConcurrent: 1, Weights: map[string]int{"big": 3}})
+	hold, _ := s.acquire(context.Background(), "")
+	// the small tenant queued all of its requests first, which buys it nothing
+	order := grantOrder(t, s, hold,
+		"small", "small", "small", "small", "small", "small", "small", "small",
+		"big", "big", "big", "big", "big", "big", "big", "big")
+	if small := countTenant(order[:8], "small"); small != 2 {
+		t.Errorf("grant order %v: small tenant got %d of the first 8 slots, want 2", order, small)
+	}
+}
+
+func TestFairSchedulerIdleTenantBanksNoCredit(t *testing.T) {
+	s := newFairScheduler(FairScheduler{MaxConcurrent: 1})
+	ctx := context.Background()
+	// "busy" runs alone for a while, then "late" shows up: "late" gets no backlog of slots
+	for range 10 {
+		release, _ := s.acquire(ctx, "busy")
+		release()
+	}
+	hold, _ := s.acquire(ctx, "busy")
+	order := grantOrder(t, s, hold, "busy", "busy", "busy", "busy", "late", "late", "late", "late")
+	if late := countTenant(order[:4], "late"); late != 2 {
+		t.Errorf("grant order %v: late tenant got %d of the first 4 slots, want 2", order, late)
+	}
+}
+
+func TestFairSchedulerCancel(t *testing.T) {
+	s := newFairScheduler(FairScheduler{MaxConcurrent: 1})
+	hold, _ := s.acquire(context.Background(), "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := s.acquire(ctx, "b")
+		errc <- err
+	}()
+	waitQueued(t, s, 1)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled acquire = %v, want context.Canceled", err)
+	}
+	hold()
+
+	// the cancelled request holds no slot
+	done := make(chan struct{})
+	go func() {
+		release, _ := s.acquire(context.Background(), "c")
+		release()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slot leaked by the cancelled request")
+	}
+}
+
+// TestFairSchedulerRequests checks that a client's requests to the server stay within
+// MaxConcurrent.
+func TestFairSchedulerRequests(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	var inFlight, peak atomic.Int32
+	srv.Handle("sandbox.command.run", func(json.RawMessage) (any, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return map[string]any{"command": "true", "exit_code": 0, "success": true, "output": []any{}}, nil
+	})
+	client := NewClient(WithServerUrl(srv.URL), WithApiKey("k"), WithFairScheduler(FairScheduler{MaxConcurrent: 2}))
+	sandbox := client.NewPythonSandbox()
+	if err := sandbox.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sandbox.Command().Run("true", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 2 {
+		t.Errorf("%d requests in flight at once, want at most 2", p)
+	}
+}
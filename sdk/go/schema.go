@@ -0,0 +1,217 @@
+package msb
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jsonSchemaDialect is the JSON Schema version SchemaFor generates.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaFor returns a JSON Schema of the JSON encoding of v's type, so agent frameworks and API
+// gateways can validate and document sandbox responses without hand-written schemas. For a
+// CodeExecution or CommandExecution, it describes the result payload returned by Raw, i.e. a
+// ReplResult or CommandResult. Other types, such as Metrics, MetricsV2, SandboxInfo or PoolStats,
+// are described as encoding/json encodes them: fields always encoded are required, and nil
+// slices, maps and pointers may encode as null. Objects may have properties beyond those described,
+// as newer servers add fields to results. v may be a zero value or a nil pointer:
+//
+//	schema, err := msb.SchemaFor(msb.CommandExecution{})
+//
+// Types that can't be encoded as JSON, such as functions and channels, fail with
+// ErrUnsupportedSchemaType.
+func SchemaFor(v any) (json.RawMessage, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("%w: nil", ErrUnsupportedSchemaType)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	title := t.Name()
+	switch t {
+	case reflect.TypeFor[CodeExecution]():
+		t = reflect.TypeFor[ReplResult]()
+	case reflect.TypeFor[CommandExecution]():
+		t = reflect.TypeFor[CommandResult]()
+	}
+
+	g := schemaGenerator{visiting: make(map[reflect.Type]bool)}
+	schema, err := g.schema(t)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedSchemaType, err)
+	}
+	schema["$schema"] = jsonSchemaDialect
+	if title != "" {
+		schema["title"] = title
+	}
+	return json.Marshal(schema)
+}
+
+// schemaGenerator builds the schema of a type, following the rules of encoding/json.
+type schemaGenerator struct {
+	visiting map[reflect.Type]bool // struct types being described, to reject recursive ones
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	durationType      = reflect.TypeFor[time.Duration]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func (g schemaGenerator) schema(t reflect.Type) (map[string]any, error) {
+	if t.Kind() == reflect.Pointer {
+		return g.nullable(t.Elem())
+	}
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case durationType:
+		return map[string]any{"type": "integer", "description": "duration in nanoseconds"}, nil
+	case rawMessageType:
+		return map[string]any{}, nil
+	}
+	switch {
+	case t.Implements(jsonMarshalerType), reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// encodes itself any way it likes
+		return map[string]any{}, nil
+	case t.Implements(textMarshalerType), reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": []string{"string", "null"}, "contentEncoding": "base64"}, nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": []string{"array", "null"}, "items": items}, nil
+	case reflect.Array:
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items, "minItems": t.Len(), "maxItems": t.Len()}, nil
+	case reflect.Map:
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": values}, nil
+	case reflect.Struct:
+		return g.object(t)
+	}
+	return nil, fmt.Errorf("%s can't be encoded as JSON", t)
+}
+
+// nullable returns the schema of t, also allowing null.
+func (g schemaGenerator) nullable(t reflect.Type) (map[string]any, error) {
+	schema, err := g.schema(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(schema) == 0 {
+		return schema, nil // anything already
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}, nil
+}
+
+func (g schemaGenerator) object(t reflect.Type) (map[string]any, error) {
+	if g.visiting[t] {
+		return nil, fmt.Errorf("%s is recursive", t)
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	properties := make(map[string]any)
+	required := []string{}
+	if err := g.fields(t, properties, &required); err != nil {
+		return nil, err
+	}
+	// other properties are allowed, as newer servers may add fields to results
+	return map[string]any{"type": "object", "properties": properties, "required": required}, nil
+}
+
+// fields adds the properties of the fields of struct type t, including those promoted from
+// untagged embedded structs.
+func (g schemaGenerator) fields(t reflect.Type, properties map[string]any, required *[]string) error {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := g.fields(ft, properties, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema, err := g.schema(f.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		if hasTagOption(opts, "string") {
+			switch f.Type.Kind() {
+			case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+				reflect.Float32, reflect.Float64, reflect.String:
+				schema = map[string]any{"type": "string"}
+			}
+		}
+		properties[name] = schema
+		if !hasTagOption(opts, "omitempty") && !hasTagOption(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+func hasTagOption(opts, option string) bool {
+	for opt := range strings.SplitSeq(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema-related errors
+var (
+	ErrUnsupportedSchemaType = errors.New("unsupported schema type")
+)
@@ -0,0 +1,56 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CodeScreener inspects code before it is run in a sandbox's REPL, e.g. with an AST or secret
+// scanner or an LLM-based safety check, and returns an error to block it. lang is the REPL
+// language, "python" or "nodejs".
+type CodeScreener func(ctx context.Context, lang, code string) error
+
+// WithCodeScreening has Code().Run pass every snippet to screener before sending it to the
+// sandbox. Snippets the screener returns an error for fail with a *CodeRejectedError instead of
+// running. The screener is called concurrently when code is run concurrently.
+func WithCodeScreening(screener CodeScreener) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.screener = screener
+	}
+}
+
+// CodeRejectedError reports code the CodeScreener blocked. It matches ErrCodeRejected with
+// errors.Is, and the screener's error with errors.Is and errors.As.
+type CodeRejectedError struct {
+	Language string // REPL language
+	Err      error  // Error returned by the screener
+}
+
+func (e *CodeRejectedError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrCodeRejected, e.Err)
+}
+
+func (e *CodeRejectedError) Is(target error) bool {
+	return target == ErrCodeRejected
+}
+
+func (e *CodeRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// screen passes code to the configured CodeScreener, if any.
+func (cr codeRunner) screen(ctx context.Context, code string) error {
+	if cr.b.cfg.screener == nil {
+		return nil
+	}
+	if err := cr.b.cfg.screener(ctx, cr.l.String(), code); err != nil {
+		return &CodeRejectedError{Language: cr.l.String(), Err: err}
+	}
+	return nil
+}
+
+// Screening-related errors
+var (
+	ErrCodeRejected = errors.New("code rejected by screening")
+)
@@ -0,0 +1,115 @@
+package msb
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerError is a request the server (or a proxy in front of it) failed with an HTTP status other
+// than 200. Error bodies in a known envelope are parsed into its fields: RFC 9457 problem details
+// ({"type", "title", "detail", ...}), JSON-RPC error responses ({"error": {"code", "message"}})
+// and {"code", "message"} objects. Other bodies become the Message as they are. It matches
+// ErrRequestFailed with errors.Is.
+type ServerError struct {
+	Status     int           // HTTP status code
+	Code       string        // Machine-readable error code or problem type; empty if none was given
+	Message    string        // Human-readable description, or the raw body if it wasn't understood
+	RequestID  string        // ID the server logged the request under, for support; empty if unknown
+	RetryAfter time.Duration // How long the server asked to wait before retrying; 0 if it didn't say
+}
+
+func (e *ServerError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v: status %d", ErrRequestFailed, e.Status)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " (%s)", e.Code)
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " [request ID %s]", e.RequestID)
+	}
+	return b.String()
+}
+
+func (e *ServerError) Is(target error) bool {
+	return target == ErrRequestFailed
+}
+
+// errorEnvelope holds the fields of the error bodies ServerError knows.
+type errorEnvelope struct {
+	// RFC 9457 problem details
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+
+	Code       json.RawMessage `json:"code"`
+	Message    string          `json:"message"`
+	Error      json.RawMessage `json:"error"` // a message, or an object with a code and message
+	RequestID  string          `json:"request_id"`
+	RetryAfter float64         `json:"retry_after"` // seconds
+}
+
+// newServerError describes the failed response resp with the given body.
+func newServerError(resp *http.Response, body []byte) *ServerError {
+	e := &ServerError{
+		Status:     resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	var env errorEnvelope
+	if json.Unmarshal(body, &env) == nil {
+		var msg string
+		var nested errorEnvelope
+		switch {
+		case json.Unmarshal(env.Error, &msg) == nil:
+			env.Message = cmp.Or(env.Message, msg)
+		case json.Unmarshal(env.Error, &nested) == nil:
+			env.Code, env.Message = nested.Code, nested.Message
+			env.RequestID = cmp.Or(env.RequestID, nested.RequestID)
+		}
+		e.Code = cmp.Or(rawString(env.Code), env.Type)
+		e.Message = cmp.Or(env.Detail, env.Message, env.Title)
+		e.RequestID = cmp.Or(e.RequestID, env.RequestID)
+		if e.RetryAfter == 0 && env.RetryAfter > 0 {
+			e.RetryAfter = time.Duration(env.RetryAfter * float64(time.Second))
+		}
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+// rawString returns a JSON string or number as text, or "" for anything else.
+func rawString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
+
+// parseRetryAfter parses a Retry-After header, given in seconds or as an HTTP date, into the time
+// left to wait from now. It returns 0 for an absent or invalid header.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package msb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+)
+
+// sessionTokenPrefix versions the token format; it is also authenticated as additional data.
+const sessionTokenPrefix = "msb1."
+
+// WithSessionKey sets the key Marshal encrypts session tokens with and Unmarshal decrypts them
+// with: 16, 24 or 32 bytes for AES-128, AES-192 or AES-256. Every replica resuming sessions needs
+// the same key.
+func WithSessionKey(key []byte) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.sessionKey = key
+	}
+}
+
+// WithSessionMetadata attaches metadata to the session tokens Marshal produces, such as the user
+// or conversation the sandbox serves. Unmarshal returns it, and sandboxes resumed from a token
+// carry it over into the tokens they produce.
+func WithSessionMetadata(metadata map[string]string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.sessionMeta = maps.Clone(metadata)
+	}
+}
+
+// session is what a session token captures of a started sandbox.
+type session struct {
+	ServerUrl string            `json:"server_url"`
+	Name      string            `json:"name"`
+	Language  string            `json:"language"`
+	Info      SandboxInfo       `json:"info"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	IssuedAt  time.Time         `json:"issued_at"`
+}
+
+// Marshal returns an encrypted token for the started sandbox, capturing the server URL, the
+// sandbox name and configuration, and the metadata set with WithSessionMetadata, so Unmarshal can
+// resume the session in another process. The API key is not part of the token. The token is
+// URL-safe text. Requires WithSessionKey.
+func (ls *langSandbox) Marshal() ([]byte, error) {
+	info, err := ls.Info()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := ls.b.cfg.sessionCipher()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(session{
+		ServerUrl: ls.b.cfg.currentServerURL(),
+		Name:      ls.b.cfg.name,
+		Language:  ls.l.String(),
+		Info:      info,
+		Metadata:  ls.b.cfg.sessionMeta,
+		IssuedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToMarshalSession, err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToMarshalSession, err)
+	}
+	sealed := aead.Seal(nonce, nonce, plain, []byte(sessionTokenPrefix))
+	return []byte(sessionTokenPrefix + base64.RawURLEncoding.EncodeToString(sealed)), nil
+}
+
+// Unmarshal resumes a sandbox session from a token produced by Marshal, returning a started
+// handle to the sandbox and the session's metadata. The options supply what the token doesn't
+// carry, such as the API key, and must include the WithSessionKey the token was encrypted with.
+// The server isn't contacted, so a sandbox stopped in the meantime only shows when the handle is
+// used; Status checks it up front. Resumed handles hold no lease.
+func Unmarshal(data []byte, options ...Option) (LangSandBox, map[string]string, error) {
+	return unmarshal(data, nil, options)
+}
+
+// Unmarshal is like the package-level Unmarshal, but the sandbox shares the client's transport
+// and settings.
+func (c *Client) Unmarshal(data []byte, options ...Option) (LangSandBox, map[string]string, error) {
+	return unmarshal(data, []Option{c.inherit()}, options)
+}
+
+func unmarshal(data []byte, base, options []Option) (LangSandBox, map[string]string, error) {
+	// only the session key is needed to open the token, so no defaults are filled in
+	probe := &baseMicroSandbox{}
+	for _, opt := range append(base, options...) {
+		opt(probe)
+	}
+	s, err := probe.cfg.openSession(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !probe.cfg.inScope(s.Name) {
+		return nil, nil, fmt.Errorf("%w: sandbox %s is not in tenant %s", ErrInvalidSessionToken, s.Name, probe.cfg.tenant)
+	}
+	lang := langPython
+	switch s.Language {
+	case langPython.String():
+	case langNodeJs.String():
+		lang = langNodeJs
+	default:
+		return nil, nil, fmt.Errorf("%w: %w: %q", ErrInvalidSessionToken, ErrUnknownLanguage, s.Language)
+	}
+
+	opts := append(append(base, options...),
+		WithServerUrl(s.ServerUrl), WithName(s.Name), WithSessionMetadata(s.Metadata))
+	sandbox := newLangSandbox(lang, opts...)
+	info := s.Info
+	info.Attached = true
+	sandbox.b.info.Store(&info)
+	sandbox.b.state.Store(started)
+	startKeepAlive(sandbox.b)
+	joinGauges(sandbox.b)
+	return sandbox, maps.Clone(s.Metadata), nil
+}
+
+// openSession decrypts and decodes a session token.
+func (c *config) openSession(data []byte) (*session, error) {
+	aead, err := c.sessionCipher()
+	if err != nil {
+		return nil, err
+	}
+	encoded, ok := strings.CutPrefix(string(data), sessionTokenPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown format", ErrInvalidSessionToken)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidSessionToken)
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte(sessionTokenPrefix))
+	if err != nil {
+		// wrong key or tampered with; the two can't be told apart
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSessionToken, err)
+	}
+	var s session
+	if err := json.Unmarshal(plain, &s); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSessionToken, err)
+	}
+	if s.ServerUrl == "" || s.Name == "" {
+		return nil, fmt.Errorf("%w: missing sandbox", ErrInvalidSessionToken)
+	}
+	return &s, nil
+}
+
+// sessionCipher returns the AES-GCM cipher for the key set with WithSessionKey.
+func (c *config) sessionCipher() (cipher.AEAD, error) {
+	if len(c.sessionKey) == 0 {
+		return nil, ErrNoSessionKey
+	}
+	block, err := aes.NewCipher(c.sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoSessionKey, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Session-related errors
+var (
+	ErrNoSessionKey           = errors.New("no valid session key")
+	ErrInvalidSessionToken    = errors.New("invalid session token")
+	ErrFailedToMarshalSession = errors.New("failed to marshal session")
+)
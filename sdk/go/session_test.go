@@ -0,0 +1,136 @@
+package msb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+var testSessionKey = bytes.Repeat([]byte{0x42}, 32)
+
+// marshalSession starts a Node sandbox and returns a session token for it.
+func marshalSession(t *testing.T, srv *msbtest.Server, options ...Option) []byte {
+	t.Helper()
+	options = append([]Option{WithServerUrl(srv.URL), WithApiKey("k"), WithName("session")}, options...)
+	sandbox := NewNodeSandbox(options...)
+	if err := sandbox.Start(StartConfig{Image: "node:22", Memory: 256}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+	token, err := sandbox.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	metadata := map[string]string{"user": "u-1"}
+	token := marshalSession(t, srv, WithSessionKey(testSessionKey), WithSessionMetadata(metadata))
+	if !strings.HasPrefix(string(token), sessionTokenPrefix) {
+		t.Errorf("token %q lacks the %q prefix", token, sessionTokenPrefix)
+	}
+	if bytes.Contains(token, []byte("node:22")) || bytes.Contains(token, []byte("u-1")) {
+		t.Error("token isn't encrypted")
+	}
+
+	sandbox, got, err := Unmarshal(token, WithApiKey("k"), WithSessionKey(testSessionKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, metadata) {
+		t.Errorf("metadata = %v, want %v", got, metadata)
+	}
+	if sandbox.Name() != "session" || sandbox.ServerURL() != srv.URL {
+		t.Errorf("resumed %s on %s, want session on %s", sandbox.Name(), sandbox.ServerURL(), srv.URL)
+	}
+	info, err := sandbox.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Image != "node:22" || info.Memory != 256 || info.Language != langNodeJs.String() || !info.Attached {
+		t.Errorf("info = %+v", info)
+	}
+
+	// the resumed handle marshals the same session again, with a fresh nonce
+	again, err := sandbox.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again, token) {
+		t.Error("tokens of the same session are identical")
+	}
+	if _, got, err := Unmarshal(again, WithApiKey("k"), WithSessionKey(testSessionKey)); err != nil || !maps.Equal(got, metadata) {
+		t.Errorf("re-marshalled token: metadata %v, error %v", got, err)
+	}
+}
+
+func TestSessionRejected(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	token := marshalSession(t, srv, WithSessionKey(testSessionKey))
+
+	sealed, err := base64.RawURLEncoding.DecodeString(string(token[len(sessionTokenPrefix):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)/2] ^= 1
+	tampered := []byte(sessionTokenPrefix + base64.RawURLEncoding.EncodeToString(sealed))
+	otherKey := bytes.Repeat([]byte{0x24}, 32)
+
+	tests := []struct {
+		name  string
+		token []byte
+		key   []byte
+		want  error
+	}{
+		{"wrong key", token, otherKey, ErrInvalidSessionToken},
+		{"tampered", tampered, testSessionKey, ErrInvalidSessionToken},
+		{"other version", []byte("msb2." + string(token[len(sessionTokenPrefix):])), testSessionKey, ErrInvalidSessionToken},
+		{"truncated", token[:len(sessionTokenPrefix)+4], testSessionKey, ErrInvalidSessionToken},
+		{"not base64", []byte(sessionTokenPrefix + "!!!!"), testSessionKey, ErrInvalidSessionToken},
+		{"no key", token, nil, ErrNoSessionKey},
+		{"key of invalid size", token, testSessionKey[:20], ErrNoSessionKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Unmarshal(tt.token, WithApiKey("k"), WithSessionKey(tt.key))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Unmarshal = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionOutsideTenant(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	token := marshalSession(t, srv, WithSessionKey(testSessionKey))
+	client, err := NewClient(WithServerUrl(srv.URL), WithApiKey("k")).WithTenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Unmarshal(token, WithSessionKey(testSessionKey)); !errors.Is(err, ErrInvalidSessionToken) {
+		t.Errorf("Unmarshal of another tenant's sandbox = %v, want ErrInvalidSessionToken", err)
+	}
+}
+
+func TestMarshalRequiresKey(t *testing.T) {
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"))
+	if err := sandbox.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sandbox.Stop() })
+	if _, err := sandbox.Marshal(); !errors.Is(err, ErrNoSessionKey) {
+		t.Errorf("Marshal = %v, want ErrNoSessionKey", err)
+	}
+}
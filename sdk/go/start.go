@@ -0,0 +1,153 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StartPhase is the lifecycle phase a sandbox is in while it is being started.
+type StartPhase int
+
+const (
+	PhaseWaiting      StartPhase = iota // Waiting for dependencies to become ready (clusters only)
+	PhaseStarting                       // Start request sent; the server is pulling the image and booting the VM
+	PhaseInitializing                   // The server accepted the sandbox, which is still pulling or booting; polling until it runs
+	PhaseReady                          // Sandbox started successfully
+	PhaseFailed                         // Sandbox (or one of its dependencies) failed to start
+)
+
+func (p StartPhase) String() string {
+	switch p {
+	case PhaseWaiting:
+		return "waiting"
+	case PhaseStarting:
+		return "starting"
+	case PhaseInitializing:
+		return "initializing"
+	case PhaseReady:
+		return "ready"
+	case PhaseFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("StartPhase(%d)", int(p))
+	}
+}
+
+// StartError is returned by Start when the sandbox could not be started.
+// It records the phase the start failed in and how long it had been going on, and matches
+// ErrFailedToStartSandbox (and ErrStartTimedOut when StartConfig.StartTimeout elapsed) with errors.Is.
+type StartError struct {
+	Sandbox string        // Sandbox name
+	Phase   StartPhase    // Phase the failure occurred in
+	Elapsed time.Duration // Time spent starting before the failure
+	Err     error         // Underlying cause
+}
+
+func (e *StartError) Error() string {
+	return fmt.Sprintf("%v %s while %s (after %s): %v", ErrFailedToStartSandbox, e.Sandbox, e.Phase, e.Elapsed.Round(time.Millisecond), e.Err)
+}
+
+func (e *StartError) Unwrap() []error {
+	return []error{ErrFailedToStartSandbox, e.Err}
+}
+
+// Backoff bounds for polling a sandbox that is still initializing after the start call returned.
+const (
+	startPollInitialInterval = 250 * time.Millisecond
+	startPollMaxInterval     = 5 * time.Second
+)
+
+// defaultInitializingTimeout bounds the wait for a sandbox that is still initializing when
+// neither StartConfig.StartTimeout nor the caller's context sets a deadline.
+const defaultInitializingTimeout = 15 * time.Minute
+
+// waitUntilRunning polls the sandbox's status with exponential backoff until it reports running.
+// It fails as soon as the server reports the sandbox stopped or crashed, leaving the handle
+// stopped.
+func waitUntilRunning(ctx context.Context, b *baseMicroSandbox) error {
+	caller := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultInitializingTimeout)
+		defer cancel()
+	}
+	interval := startPollInitialInterval
+	for {
+		status, err := readStatus(ctx, b)
+		switch {
+		case err != nil:
+			b.cfg.log(ctx).Debug("Polling sandbox status failed", "name", b.cfg.name, "error", err)
+		case status == StatusRunning:
+			return nil
+		case status == StatusCrashed:
+			return ErrSandboxCrashed
+		case status == StatusStopped:
+			return ErrSandboxExited
+		}
+
+		select {
+		case <-ctx.Done():
+			done := ctx.Err()
+			if caller.Err() == nil {
+				done = fmt.Errorf("%w: %w", ErrStartTimedOut, done)
+			}
+			if err != nil {
+				return fmt.Errorf("%w (last poll: %w)", done, err)
+			}
+			return done
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, startPollMaxInterval)
+	}
+}
+
+// startObserverKey carries a start phase observer through a context, so that wrappers like
+// Cluster can follow the phases of a Start call they delegate to a LangSandBox.
+type startObserverKey struct{}
+
+func withStartObserver(ctx context.Context, observe func(StartPhase)) context.Context {
+	return context.WithValue(ctx, startObserverKey{}, observe)
+}
+
+func observeStartPhase(ctx context.Context, phase StartPhase) {
+	if observe, ok := ctx.Value(startObserverKey{}).(func(StartPhase)); ok {
+		observe(phase)
+	}
+}
+
+// runSteps runs commands, then src in the REPL, in a started sandbox, failing on the first that
+// doesn't succeed. It does the init work of Start and Template, and the finalize work of Stop.
+func runSteps(ctx context.Context, code CodeRunner, command CommandRunner, commands []CmdSpec, src string) error {
+	for _, spec := range commands {
+		exec, err := command.RunWithOptions(ctx, spec.Name, spec.Args, RunOptions{})
+		if err != nil {
+			return fmt.Errorf("%s: %w", spec, err)
+		}
+		if !exec.IsSuccess() {
+			stderr, _ := exec.GetError()
+			return fmt.Errorf("%s: exit code %d: %s", spec, exec.GetExitCode(), strings.TrimSpace(stderr))
+		}
+	}
+	if strings.TrimSpace(src) == "" {
+		return nil
+	}
+	exec, err := code.RunContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	if exec.HasError() {
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("code: %s", strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// Start-related errors
+var (
+	ErrStartTimedOut = errors.New("start timed out")
+	ErrInitFailed    = errors.New("sandbox initialization failed")
+	ErrSandboxExited = errors.New("sandbox stopped while initializing")
+)
@@ -0,0 +1,62 @@
+package msb
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/microsandbox/microsandbox/sdk/go/msbtest"
+)
+
+// pendingServer is a server whose sandboxes are still initializing when their start call returns,
+// and then report the given statuses, the last one from then on.
+func pendingServer(t *testing.T, statuses ...string) *msbtest.Server {
+	t.Helper()
+	srv := msbtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.Handle("sandbox.start", func(json.RawMessage) (any, error) {
+		return "Sandbox started but timed out waiting for it to run", nil
+	})
+	var polls atomic.Int32
+	srv.Handle("sandbox.status.get", func(json.RawMessage) (any, error) {
+		i := min(int(polls.Add(1)), len(statuses)) - 1
+		return map[string]any{"status": statuses[i]}, nil
+	})
+	return srv
+}
+
+func TestStartWaitsUntilRunning(t *testing.T) {
+	srv := pendingServer(t, "starting", "running")
+	sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"))
+	if err := sandbox.Start(StartConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sandbox.Stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStartFailsWhenSandboxExits(t *testing.T) {
+	tests := []struct {
+		status string
+		want   error
+	}{
+		{"stopped", ErrSandboxExited},
+		{"crashed", ErrSandboxCrashed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			srv := pendingServer(t, "starting", tt.status)
+			sandbox := NewPythonSandbox(WithServerUrl(srv.URL), WithApiKey("k"))
+			err := sandbox.Start(StartConfig{})
+			var startErr *StartError
+			if !errors.As(err, &startErr) || startErr.Phase != PhaseInitializing || !errors.Is(err, tt.want) {
+				t.Fatalf("Start = %v, want a StartError while initializing matching %v", err, tt.want)
+			}
+			if err := sandbox.Stop(); !errors.Is(err, ErrSandboxNotStarted) {
+				t.Errorf("Stop = %v, want ErrSandboxNotStarted", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SandboxStatus is the lifecycle status of a sandbox as reported by the server.
+type SandboxStatus int
+
+const (
+	StatusUnknown  SandboxStatus = iota // The server reported a status this SDK doesn't know, or none
+	StatusStarting                      // The sandbox is pulling its image or booting
+	StatusRunning                       // The sandbox is up and accepting work
+	StatusPaused                        // The sandbox is suspended
+	StatusStopped                       // The sandbox is not running (or doesn't exist)
+	StatusCrashed                       // The sandbox terminated unexpectedly
+)
+
+func (s SandboxStatus) String() string {
+	switch s {
+	case StatusStarting:
+		return "starting"
+	case StatusRunning:
+		return "running"
+	case StatusPaused:
+		return "paused"
+	case StatusStopped:
+		return "stopped"
+	case StatusCrashed:
+		return "crashed"
+	default:
+		return "unknown"
+	}
+}
+
+func parseSandboxStatus(s string) SandboxStatus {
+	switch s {
+	case "starting":
+		return StatusStarting
+	case "running":
+		return StatusRunning
+	case "paused":
+		return StatusPaused
+	case "stopped":
+		return StatusStopped
+	case "crashed":
+		return StatusCrashed
+	default:
+		return StatusUnknown
+	}
+}
+
+// Status returns the sandbox's lifecycle status using a lightweight status RPC (falling back to the
+// metrics call on servers that don't provide one, which reports StatusUnknown for any sandbox that
+// isn't running). It can be called whether or not the sandbox was started through this handle.
+//
+// A started handle whose sandbox the server reports as stopped or crashed is moved back to the
+// stopped state, so subsequent calls fail fast with ErrSandboxNotStarted and Start can be retried.
+func (ls *langSandbox) Status(ctx context.Context) (SandboxStatus, error) {
+	ctx, cancel := withTimeout(ctx, ls.b.cfg.timeouts.Metrics)
+	defer cancel()
+	return readStatus(ctx, ls.b)
+}
+
+func readStatus(ctx context.Context, b *baseMicroSandbox) (SandboxStatus, error) {
+	status, err := b.rpcClient.getStatus(ctx, &b.cfg)
+	if err != nil {
+		return StatusUnknown, fmt.Errorf("%w: %w", ErrFailedToGetStatus, err)
+	}
+	if (status == StatusStopped || status == StatusCrashed) && b.state.CompareAndSwap(started, off) {
+		b.cfg.log(ctx).Info("Sandbox no longer running on server", "name", b.cfg.name, "status", status.String())
+		markStopped(b)
+	}
+	return status, nil
+}
+
+// Status-related errors
+var (
+	ErrFailedToGetStatus = errors.New("failed to get status")
+)
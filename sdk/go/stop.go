@@ -0,0 +1,62 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultFinalizeTimeout bounds the finalizers when StopOptions.FinalizeTimeout is unset.
+const defaultFinalizeTimeout = 30 * time.Second
+
+// StopOptions holds work run in the sandbox right before it is stopped, such as flushing results,
+// syncing buffers or uploading artifacts, so data is persisted while the VM still exists.
+// Finalize commands run first, then finalize code in the REPL, stopping at the first that fails.
+// The sandbox is stopped whether or not the finalizers succeed; if they don't, Stop returns an
+// error matching ErrFinalizeFailed alongside any error stopping the sandbox.
+type StopOptions struct {
+	FinalizeCommands []CmdSpec     // Commands to run before stopping
+	FinalizeCode     string        // Code to run in the REPL before stopping, after FinalizeCommands
+	FinalizeTimeout  time.Duration // Bound on the finalizers as a whole (default 30s)
+}
+
+// WithStopOptions sets the finalizers Stop and StopContext run before tearing the sandbox down,
+// so callers needn't remember to persist data themselves. StopWithOptions overrides them:
+//
+//	msb.WithStopOptions(msb.StopOptions{
+//		FinalizeCommands: []msb.CmdSpec{msb.Cmd("sync")},
+//		FinalizeCode:     "results.flush()",
+//	})
+func WithStopOptions(opts StopOptions) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.stopOptions = opts
+	}
+}
+
+// finalize runs opts' finalizers, if any, in a started sandbox.
+func (s stopper) finalize(ctx context.Context, opts StopOptions) error {
+	if len(opts.FinalizeCommands) == 0 && strings.TrimSpace(opts.FinalizeCode) == "" {
+		return nil
+	}
+	if s.b.state.Load() != started {
+		return nil
+	}
+	timeout := opts.FinalizeTimeout
+	if timeout <= 0 {
+		timeout = defaultFinalizeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := runSteps(ctx, codeRunner{s.b, s.l}, commandRunner{b: s.b}, opts.FinalizeCommands, opts.FinalizeCode); err != nil {
+		s.b.cfg.log(ctx).Error("Sandbox finalizer failed, stopping anyway", "name", s.b.cfg.name, "error", err)
+		return fmt.Errorf("%w: %w", ErrFinalizeFailed, err)
+	}
+	return nil
+}
+
+// Stop-related errors
+var (
+	ErrFinalizeFailed = errors.New("sandbox finalization failed")
+)
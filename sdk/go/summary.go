@@ -0,0 +1,126 @@
+package msb
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ApproxBytesPerToken is a rough average of bytes per token for LLM tokenizers on code and
+// program output, for turning a token budget into the byte limit Summarize takes:
+//
+//	summary, err := execution.Summarize(500 * msb.ApproxBytesPerToken)
+const ApproxBytesPerToken = 4
+
+// Summary is the output of an execution cut down to a size limit, for feeding back into
+// token-limited LLM prompts, with statistics on the complete output.
+type Summary struct {
+	// Text is stdout and stderr interleaved in the order they were produced. Output over the
+	// limit loses its middle, which is replaced with a marker line such as
+	// "[... 120 lines (5012 bytes) omitted ...]".
+	Text      string
+	Truncated bool // Whether anything was omitted
+
+	TotalLines   int // Lines of output in total
+	TotalBytes   int // Bytes of output in total, counting a newline after each line but the last
+	StdoutLines  int // Lines written to stdout
+	StderrLines  int // Lines written to stderr
+	OmittedLines int // Line breaks within the omitted output, i.e. roughly the lines omitted
+	OmittedBytes int // Bytes omitted
+}
+
+// Summarize returns the execution's output cut down to at most maxBytes bytes, keeping its
+// beginning and end, which usually hold what matters: what ran, and how it ended. Cuts fall on
+// line boundaries where possible. maxBytes <= 0 means no limit.
+// Returns an *UnexpectedResultSchemaError holding the raw JSON if it could not be parsed.
+func (ce CodeExecution) Summarize(maxBytes int) (Summary, error) {
+	if !ce.parsedOK {
+		return Summary{}, notParsedErr(ce.parseErr)
+	}
+	return summarize(ce.parsed.Output, maxBytes), nil
+}
+
+// Summarize returns the command's output cut down to at most maxBytes bytes, like
+// CodeExecution.Summarize.
+func (ce CommandExecution) Summarize(maxBytes int) (Summary, error) {
+	if !ce.parsedOK {
+		return Summary{}, notParsedErr(ce.parseErr)
+	}
+	return summarize(ce.parsed.Output, maxBytes), nil
+}
+
+func summarize(lines []OutputLine, maxBytes int) Summary {
+	var s Summary
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+		if line.Stream == "stderr" {
+			s.StderrLines++
+		} else {
+			s.StdoutLines++
+		}
+	}
+	text := strings.Join(texts, "\n")
+	s.TotalLines = len(lines)
+	s.TotalBytes = len(text)
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		s.Text = text
+		return s
+	}
+
+	s.Truncated = true
+	// sized for the largest counts, so the marker fits whatever is omitted
+	avail := maxBytes - len(omittedMarker(s.TotalLines, s.TotalBytes)) - 2
+	if avail <= 0 {
+		s.Text = tailBytes(text, maxBytes)
+		s.OmittedBytes = len(text) - len(s.Text)
+		s.OmittedLines = strings.Count(text[:s.OmittedBytes], "\n")
+		return s
+	}
+	head := headBytes(text, avail/2)
+	tail := tailBytes(text, avail-avail/2)
+	omitted := text[len(head) : len(text)-len(tail)]
+	s.OmittedBytes = len(omitted)
+	s.OmittedLines = strings.Count(omitted, "\n")
+
+	var b strings.Builder
+	b.WriteString(head)
+	if head != "" && !strings.HasSuffix(head, "\n") {
+		b.WriteByte('\n')
+	}
+	b.WriteString(omittedMarker(s.OmittedLines, s.OmittedBytes))
+	b.WriteByte('\n')
+	b.WriteString(tail)
+	s.Text = b.String()
+	return s
+}
+
+func omittedMarker(lines, bytes int) string {
+	return fmt.Sprintf("[... %d lines (%d bytes) omitted ...]", lines, bytes)
+}
+
+// headBytes returns at most n bytes from the start of text, ending after a newline unless that
+// would give up more than half of them.
+func headBytes(text string, n int) string {
+	head := text[:n]
+	if i := strings.LastIndexByte(head, '\n'); i >= n/2 {
+		return head[:i+1]
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// tailBytes returns at most n bytes from the end of text, starting after a newline unless that
+// would give up more than half of them.
+func tailBytes(text string, n int) string {
+	tail := text[len(text)-n:]
+	if i := strings.IndexByte(tail, '\n'); i != -1 && i < n/2 {
+		return tail[i+1:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	return tail
+}
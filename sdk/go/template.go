@@ -0,0 +1,122 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Template is a named, ready-to-use sandbox environment: how sandboxes are started and what runs
+// in them before they are handed out, so services share environments instead of copy-pasting
+// StartConfigs. Templates are registered with Templates.Register.
+type Template struct {
+	Language     string      // "python" (default) or "nodejs"
+	Start        StartConfig // Configuration sandboxes are started with; Preset.With helps size it
+	InitCode     string      // Code run in the REPL after start, e.g. imports and model loading
+	InitCommands []CmdSpec   // Commands run after start, before InitCode, e.g. to seed data
+	Options      []Option    // Options applied before those passed to NewFromTemplate
+}
+
+// TemplateRegistry holds templates by name. Use the package-level Templates registry unless
+// separate sets of templates are needed. The zero value is ready to use.
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]Template
+}
+
+// Templates is the registry NewFromTemplate and Client.NewFromTemplate look templates up in.
+var Templates = &TemplateRegistry{}
+
+// Register adds the template t under name. Returns ErrTemplateExists if the name is taken, as two
+// packages defining the same environment differently is almost always a mistake.
+func (r *TemplateRegistry) Register(name string, t Template) error {
+	switch t.Language {
+	case "":
+		t.Language = langPython.String()
+	case langPython.String(), langNodeJs.String():
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownLanguage, t.Language)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.templates[name]; ok {
+		return fmt.Errorf("%w: %s", ErrTemplateExists, name)
+	}
+	if r.templates == nil {
+		r.templates = make(map[string]Template)
+	}
+	t.InitCommands = slices.Clone(t.InitCommands)
+	t.Options = slices.Clone(t.Options)
+	r.templates[name] = t
+	return nil
+}
+
+// Get returns the template registered under name.
+func (r *TemplateRegistry) Get(name string) (Template, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[name]
+	return t, ok
+}
+
+// Names returns the names of all registered templates, sorted.
+func (r *TemplateRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewFromTemplate creates a sandbox from the template registered in Templates under name, starts
+// it and runs the template's init commands and code, so the sandbox is ready for its workload
+// when returned. If initialization fails, the sandbox is stopped again and ErrTemplateInitFailed
+// is returned. The options are applied after the template's.
+func NewFromTemplate(ctx context.Context, name string, options ...Option) (LangSandBox, error) {
+	return newFromTemplate(ctx, name, nil, options)
+}
+
+// NewFromTemplate is like the package-level NewFromTemplate, but the sandbox shares the client's
+// transport and settings.
+func (c *Client) NewFromTemplate(ctx context.Context, name string, options ...Option) (LangSandBox, error) {
+	return newFromTemplate(ctx, name, []Option{c.inherit()}, options)
+}
+
+func newFromTemplate(ctx context.Context, name string, base, options []Option) (LangSandBox, error) {
+	t, ok := Templates.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	lang := langPython
+	if t.Language == langNodeJs.String() {
+		lang = langNodeJs
+	}
+	opts := append(append(base, t.Options...), options...)
+	sandbox := newLangSandbox(lang, opts...)
+
+	if err := sandbox.StartContext(ctx, t.Start); err != nil {
+		return nil, err
+	}
+	if err := t.init(ctx, sandbox); err != nil {
+		_ = sandbox.StopContext(context.WithoutCancel(ctx))
+		return nil, fmt.Errorf("%w: %s: %w", ErrTemplateInitFailed, name, err)
+	}
+	return sandbox, nil
+}
+
+// init runs the template's init commands and code in a started sandbox.
+func (t Template) init(ctx context.Context, sandbox LangSandBox) error {
+	return runSteps(ctx, sandbox.Code(), sandbox.Command(), t.InitCommands, t.InitCode)
+}
+
+// Template-related errors
+var (
+	ErrTemplateNotFound   = errors.New("template not found")
+	ErrTemplateExists     = errors.New("template already registered")
+	ErrTemplateInitFailed = errors.New("failed to initialize sandbox from template")
+)
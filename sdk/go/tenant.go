@@ -0,0 +1,115 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tenantSeparator ends the tenant prefix of sandbox names. Tenant IDs can't contain it, so no
+// tenant's prefix is a prefix of another's.
+const tenantSeparator = "_"
+
+// maxTenantIDLen leaves room for sandbox names within the server's limit of 63 characters.
+const maxTenantIDLen = 24
+
+// TenantTokenProvider returns the API key a tenant's requests authenticate with, e.g. a token
+// minted for the tenant by the platform's identity service.
+type TenantTokenProvider func(ctx context.Context, tenantID string) (apiKey string, err error)
+
+// WithTenantTokenProvider sets how Client.WithTenant obtains each tenant's API key. The provider
+// is called before a tenant's first request and again whenever the server rejects the key.
+// Without one, tenants share the client's key.
+func WithTenantTokenProvider(provider TenantTokenProvider) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.tenantTokens = provider
+	}
+}
+
+// WithTenant returns a Client whose sandboxes are confined to the tenant tenantID, sharing this
+// client's transport and settings:
+//
+//   - Sandbox names are namespaced as "<tenantID>_<name>", including names given with WithName,
+//     so one tenant can't address another's sandboxes by name.
+//   - Events only reports the tenant's sandboxes.
+//   - SDK log lines carry a "tenant" attribute.
+//   - Requests authenticate with the tenant's own API key if WithTenantTokenProvider is set.
+//
+// Tenant IDs consist of up to 24 ASCII letters, digits and hyphens, starting with a letter or
+// digit; others fail with an *InvalidNameError. A tenant's client can't be scoped to another
+// tenant.
+func (c *Client) WithTenant(tenantID string) (*Client, error) {
+	if c.cfg.tenant != "" {
+		return nil, fmt.Errorf("%w: already scoped to %s", ErrInvalidTenant, c.cfg.tenant)
+	}
+	if err := checkName(nameKindTenant, tenantID, maxTenantIDLen, "-"); err != nil {
+		return nil, err
+	}
+	t := &Client{cfg: c.cfg, rpcClient: c.rpcClient}
+	t.cfg.tenant = tenantID
+	if provider := c.cfg.tenantTokens; provider != nil {
+		t.cfg.apiKey = ""
+		t.cfg.noAuth = false
+		t.cfg.refreshed = &refreshedKey{} // shared by the tenant's sandboxes only
+		t.cfg.authHandler = func(ctx context.Context, status int) (string, error) {
+			return provider(ctx, tenantID)
+		}
+	}
+	return t, nil
+}
+
+// Tenant returns the tenant the client is scoped to with WithTenant; empty if it isn't.
+func (c *Client) Tenant() string {
+	return c.cfg.tenant
+}
+
+// scopedName returns name within the tenant's namespace.
+func (c *config) scopedName(name string) string {
+	if c.tenant == "" || c.inScope(name) {
+		return name
+	}
+	return c.tenant + tenantSeparator + name
+}
+
+// inScope reports whether the named sandbox belongs to the configured tenant, if any.
+func (c *config) inScope(name string) bool {
+	return c.tenant == "" || strings.HasPrefix(name, c.tenant+tenantSeparator)
+}
+
+// tenantAPIKey obtains the tenant's API key from the TenantTokenProvider before the first request.
+func (c *config) tenantAPIKey(ctx context.Context) error {
+	if c.tenant == "" || c.tenantTokens == nil || c.refreshed == nil || c.refreshed.key.Load() != nil {
+		return nil
+	}
+	key, err := c.tenantTokens(ctx, c.tenant)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrFailedToGetTenantToken, c.tenant, err)
+	}
+	c.refreshed.key.CompareAndSwap(nil, &key)
+	return nil
+}
+
+// labeledLogger adds fixed attributes to every line logged through it.
+type labeledLogger struct {
+	logger Logger
+	labels []any
+}
+
+func (l labeledLogger) Debug(msg string, args ...any) {
+	l.logger.Debug(msg, append(args[:len(args):len(args)], l.labels...)...)
+}
+
+func (l labeledLogger) Info(msg string, args ...any) {
+	l.logger.Info(msg, append(args[:len(args):len(args)], l.labels...)...)
+}
+
+func (l labeledLogger) Error(msg string, args ...any) {
+	l.logger.Error(msg, append(args[:len(args):len(args)], l.labels...)...)
+}
+
+// Tenant-related errors
+var (
+	ErrInvalidTenant          = errors.New("invalid tenant")
+	ErrFailedToGetTenantToken = errors.New("failed to get tenant token")
+)
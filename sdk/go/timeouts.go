@@ -0,0 +1,50 @@
+package msb
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bounds the duration of individual kinds of requests. A single http.Client.Timeout
+// can't represent the very different latencies of, say, a start that pulls an image and a
+// metrics query. Zero fields leave the corresponding requests unbounded.
+type Timeouts struct {
+	Start   time.Duration // Start, unless StartConfig.StartTimeout is set
+	Stop    time.Duration // Stop
+	Run     time.Duration // Code and command executions, in addition to StartConfig.ExecTimeout
+	Metrics time.Duration // Metrics and Status queries
+}
+
+// WithTimeouts configures per-method timeouts, applied as context deadlines on top of the
+// deadlines of the contexts passed in by the caller (whichever expires first wins):
+//
+//	msb.WithTimeouts(msb.Timeouts{Start: 2 * time.Minute, Stop: 30 * time.Second, Run: 5 * time.Minute, Metrics: 5 * time.Second})
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.timeouts = timeouts
+	}
+}
+
+// withTimeout bounds ctx by d, if d is positive.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// serverTimeout returns the time left until ctx's deadline or the HTTP client's timeout,
+// whichever comes first, in whole seconds (rounded up), for the server to bound an execution by.
+// It returns 0 if neither is set.
+func serverTimeout(ctx context.Context, clientTimeout time.Duration) int {
+	left := clientTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if until := time.Until(deadline); left <= 0 || until < left {
+			left = until
+		}
+	}
+	if left <= 0 {
+		return 0
+	}
+	return int((left + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,166 @@
+package msb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ExecutionTiming is when an execution ran, by the server's clock, and when this client sent the
+// request for it and received the result, by the client's. The two clocks differ by the skew
+// ClockSkew estimates.
+type ExecutionTiming struct {
+	Start    time.Time // When the server started the execution
+	End      time.Time // When the server finished it
+	Sent     time.Time // When the request left this client
+	Received time.Time // When the result arrived
+}
+
+// Duration returns how long the execution ran on the server.
+func (t ExecutionTiming) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// Overhead returns the part of the round trip spent outside the execution: on the network, in
+// queues and in the server's handling of the request. Unlike Start and End, it doesn't depend on
+// the clocks agreeing.
+func (t ExecutionTiming) Overhead() time.Duration {
+	return t.Received.Sub(t.Sent) - t.Duration()
+}
+
+// Local returns t with Start and End converted to this client's clock by skew.
+func (t ExecutionTiming) Local(skew ClockSkew) ExecutionTiming {
+	t.Start = t.Start.Add(-skew.Offset)
+	t.End = t.End.Add(-skew.Offset)
+	return t
+}
+
+// GetTiming returns when the code ran, if the server reported it.
+func (ce CodeExecution) GetTiming() (ExecutionTiming, bool) {
+	if !ce.parsedOK {
+		return ExecutionTiming{}, false
+	}
+	return timingOf(ce.parsed.StartedAt, ce.parsed.FinishedAt, ce.sent, ce.received)
+}
+
+// GetTiming returns when the command ran, if the server reported it.
+func (ce CommandExecution) GetTiming() (ExecutionTiming, bool) {
+	if !ce.parsedOK {
+		return ExecutionTiming{}, false
+	}
+	return timingOf(ce.parsed.StartedAt, ce.parsed.FinishedAt, ce.sent, ce.received)
+}
+
+func timingOf(start, end *time.Time, sent, received time.Time) (ExecutionTiming, bool) {
+	if start == nil || end == nil || sent.IsZero() {
+		return ExecutionTiming{}, false
+	}
+	return ExecutionTiming{Start: *start, End: *end, Sent: sent, Received: received}, true
+}
+
+// ClockSkew is an estimate of how far the server's clock is ahead of this client's, drawn from the
+// timing of recent executions the way NTP does: each gives an offset, accurate to within half of
+// its round trip minus the execution itself, and the most accurate recent one is used.
+type ClockSkew struct {
+	Offset      time.Duration // Server clock minus client clock
+	Uncertainty time.Duration // The true offset lies within Offset ± Uncertainty
+	Samples     int           // Executions the estimate was drawn from
+}
+
+// ToLocal converts a time read off the server's clock to this client's.
+func (s ClockSkew) ToLocal(server time.Time) time.Time {
+	return server.Add(-s.Offset)
+}
+
+// ToServer converts a time read off this client's clock to the server's.
+func (s ClockSkew) ToServer(local time.Time) time.Time {
+	return local.Add(s.Offset)
+}
+
+// ClockSkew returns the estimated skew between the server's clock and this client's, learned from
+// the executions of the client's sandboxes; false until one reported its timing.
+func (c *Client) ClockSkew() (ClockSkew, bool) {
+	return c.cfg.clock.estimate()
+}
+
+func (ls *langSandbox) ClockSkew() (ClockSkew, bool) {
+	return ls.b.cfg.clock.estimate()
+}
+
+// clockSampleWindow is how many recent executions the skew estimate is drawn from, so it follows
+// clock drift.
+const clockSampleWindow = 16
+
+// clockEstimator estimates the clock skew from execution timings.
+type clockEstimator struct {
+	mu      sync.Mutex
+	samples [clockSampleWindow]clockSample
+	next    int
+	count   int
+}
+
+type clockSample struct {
+	offset time.Duration
+	delay  time.Duration // round trip minus the execution
+}
+
+// observe adds the timing of an execution to the estimate.
+func (c *clockEstimator) observe(t ExecutionTiming) {
+	if c == nil {
+		return
+	}
+	sample := clockSample{
+		offset: (t.Start.Sub(t.Sent) + t.End.Sub(t.Received)) / 2,
+		delay:  max(t.Overhead(), 0),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samples[c.next] = sample
+	c.next = (c.next + 1) % clockSampleWindow
+	c.count++
+}
+
+func (c *clockEstimator) estimate() (ClockSkew, bool) {
+	if c == nil {
+		return ClockSkew{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := min(c.count, clockSampleWindow)
+	if n == 0 {
+		return ClockSkew{}, false
+	}
+	best := c.samples[0]
+	for _, s := range c.samples[1:n] {
+		if s.delay < best.delay {
+			best = s
+		}
+	}
+	return ClockSkew{Offset: best.offset, Uncertainty: best.delay / 2, Samples: c.count}, true
+}
+
+// codeExecution wraps the result of a code run, recording its timing and where it ran, and
+// logging its output.
+func (b *baseMicroSandbox) codeExecution(ctx context.Context, result *executionResult) CodeExecution {
+	exec := newCodeExecution(result.output)
+	exec.sent, exec.received = result.sent, result.received
+	exec.source = &execSource{b: b, info: b.info.Load(), request: result.request}
+	if t, ok := exec.GetTiming(); ok {
+		b.cfg.clock.observe(t)
+	}
+	b.logOutput(ctx, exec.parsed.Output)
+	return exec
+}
+
+// commandExecution wraps the result of a command, recording its timing and where it ran, and
+// logging its output.
+func (b *baseMicroSandbox) commandExecution(ctx context.Context, result *executionResult) CommandExecution {
+	exec := newCommandExecution(result.output)
+	exec.sent, exec.received = result.sent, result.received
+	exec.source = &execSource{b: b, info: b.info.Load(), request: result.request}
+	if t, ok := exec.GetTiming(); ok {
+		b.cfg.clock.observe(t)
+	}
+	b.logOutput(ctx, exec.parsed.Output)
+	return exec
+}
@@ -0,0 +1,56 @@
+package msb
+
+import (
+	"context"
+	"net/http"
+)
+
+// TraceHeaderExtractor writes the trace propagation headers for the span carried by ctx
+// (typically traceparent, tracestate and baggage) into h.
+type TraceHeaderExtractor func(ctx context.Context, h http.Header)
+
+// WithTraceHeaders configures an extractor that is invoked for every RPC request, so the tracing
+// system in use can propagate its context to the server and server logs can be correlated with
+// client traces. With OpenTelemetry, for example:
+//
+//	msb.WithTraceHeaders(func(ctx context.Context, h http.Header) {
+//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+//	})
+//
+// Headers the SDK sets itself (Content-Type and Authorization) can't be overridden.
+func WithTraceHeaders(extractor TraceHeaderExtractor) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.traceHdrs = extractor
+	}
+}
+
+// TraceContext is a W3C trace context (https://www.w3.org/TR/trace-context/) together with
+// W3C baggage, for callers that track trace identifiers without a tracing library.
+type TraceContext struct {
+	TraceParent string // traceparent header value, e.g. "00-<trace-id>-<parent-id>-01"
+	TraceState  string // tracestate header value (optional)
+	Baggage     string // baggage header value (optional)
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of ctx carrying tc, to be picked up by W3CTraceHeaders.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// W3CTraceHeaders is a TraceHeaderExtractor propagating the TraceContext attached to the request's
+// context with ContextWithTraceContext. Requests without one are sent without trace headers.
+func W3CTraceHeaders(ctx context.Context, h http.Header) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	if !ok || tc.TraceParent == "" {
+		return
+	}
+	h.Set("traceparent", tc.TraceParent)
+	if tc.TraceState != "" {
+		h.Set("tracestate", tc.TraceState)
+	}
+	if tc.Baggage != "" {
+		h.Set("baggage", tc.Baggage)
+	}
+}
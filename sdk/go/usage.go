@@ -0,0 +1,29 @@
+package msb
+
+import "time"
+
+// ResourceUsage is what a single execution consumed, as measured by the server for the process
+// tree of that run alone, so usage can be attributed to the request that caused it rather than to
+// the sandbox as a whole.
+type ResourceUsage struct {
+	CPUTimeMs  float64 `json:"cpu_time_ms"`  // CPU time, user plus system, in milliseconds
+	PeakRSSKiB int64   `json:"peak_rss_kib"` // Peak resident set size in KiB
+}
+
+// CPUTime returns the CPU time the execution consumed.
+func (u ResourceUsage) CPUTime() time.Duration {
+	return time.Duration(u.CPUTimeMs * float64(time.Millisecond))
+}
+
+// PeakRSSBytes returns the execution's peak resident set size in bytes.
+func (u ResourceUsage) PeakRSSBytes() int64 {
+	return u.PeakRSSKiB * 1024
+}
+
+// usageOf returns the usage reported in a parsed result, if any.
+func usageOf(u *ResourceUsage, parsedOK bool) (ResourceUsage, bool) {
+	if !parsedOK || u == nil {
+		return ResourceUsage{}, false
+	}
+	return *u, true
+}
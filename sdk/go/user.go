@@ -0,0 +1,30 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RootUser is the user commands are escalated to with CommandRunner.As.
+const RootUser = "root"
+
+var userPartRe = regexp.MustCompile(`^(?:[0-9]+|[a-z_][a-z0-9_.-]*\$?)$`)
+
+// validateUser checks a "user", "user:group", "uid" or "uid:gid" specification.
+func validateUser(user string) error {
+	if user == "" {
+		return nil
+	}
+	name, group, hasGroup := strings.Cut(user, ":")
+	if !userPartRe.MatchString(name) || (hasGroup && !userPartRe.MatchString(group)) {
+		return fmt.Errorf("%w: %q", ErrInvalidUser, user)
+	}
+	return nil
+}
+
+// User-related errors
+var (
+	ErrInvalidUser = errors.New("invalid user")
+)
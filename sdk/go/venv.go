@@ -0,0 +1,234 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// venvDir is the directory of the sandbox the virtualenvs created with VenvManager live in.
+const venvDir = "/tmp/.msb-venvs"
+
+// VenvManager manages Python virtualenvs inside a sandbox, so executions in one long-lived
+// sandbox can use isolated sets of dependencies without restarting it:
+//
+//	venv := sandbox.Venv()
+//	if err := venv.Create(ctx, "pandas2"); err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := venv.Use(ctx, "pandas2"); err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := venv.Install(ctx, "pandas>=2"); err != nil {
+//		log.Fatal(err)
+//	}
+//	execution, err := sandbox.Code().Run("import pandas; print(pandas.__version__)")
+//
+// The virtualenv in use applies to the code and commands run through the handle it was selected
+// on, and is forgotten when the sandbox stops. Its methods fail with ErrNotPythonSandbox on
+// sandboxes of other languages.
+type VenvManager interface {
+	// Create creates the virtualenv name with the sandbox's python3, unless it exists already.
+	// Names consist of ASCII letters, digits, '-' and '_'.
+	Create(ctx context.Context, name string) error
+	// Use switches code and commands to the virtualenv name, or back to the sandbox's own
+	// interpreter if name is empty. Code runs see the virtualenv's packages in place of the
+	// system's; modules imported before the switch stay loaded. Commands run with VIRTUAL_ENV
+	// set and the virtualenv's bin directory first on PATH. Unknown names fail with
+	// ErrVenvNotFound.
+	Use(ctx context.Context, name string) error
+	// Install installs packages, given as pip requirement specifiers, into the virtualenv in
+	// use, failing with ErrNoVenv if there is none.
+	Install(ctx context.Context, packages ...string) error
+	// Current returns the name of the virtualenv in use; empty if none.
+	Current() string
+}
+
+// activeVenv is the virtualenv selected with VenvManager.Use.
+type activeVenv struct {
+	name string
+	dir  string
+	env  map[string]string // variables commands run with
+}
+
+func (ls *langSandbox) Venv() VenvManager {
+	return venvManager{ls.b, ls.l}
+}
+
+type venvManager struct {
+	b *baseMicroSandbox
+	l progLang
+}
+
+// check fails unless venvs can be managed in the sandbox.
+func (v venvManager) check() error {
+	if v.l != langPython {
+		return fmt.Errorf("%w: sandbox is %s", ErrNotPythonSandbox, v.l)
+	}
+	if v.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	return nil
+}
+
+func (v venvManager) Create(ctx context.Context, name string) error {
+	if err := checkName(nameKindVenv, name, maxNameLen, "-_"); err != nil {
+		return err
+	}
+	if err := v.check(); err != nil {
+		return err
+	}
+	v.b.cfg.log(ctx).Info("Creating virtualenv", "sandbox", v.b.cfg.name, "venv", name)
+	script := `test -x "$1/bin/python" || python3 -m venv "$1"`
+	if err := v.runCommand(ctx, "sh", []string{"-c", script, "sh", venvDir + "/" + name}); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrFailedToCreateVenv, name, err)
+	}
+	return nil
+}
+
+func (v venvManager) Use(ctx context.Context, name string) error {
+	if name != "" {
+		if err := checkName(nameKindVenv, name, maxNameLen, "-_"); err != nil {
+			return err
+		}
+	}
+	if err := v.check(); err != nil {
+		return err
+	}
+
+	var active *activeVenv
+	if name != "" {
+		dir := venvDir + "/" + name
+		// prints the PATH commands run with, minus the bin directory of a virtualenv in use
+		script := `test -x "$1/bin/python" || exit 3
+p=$PATH
+[ -n "$VIRTUAL_ENV" ] && p=${p#"$VIRTUAL_ENV/bin:"}
+printf %s "$p"`
+		exec, err := commandRunner{b: v.b}.RunWithOptions(ctx, "sh", []string{"-c", script, "sh", dir}, RunOptions{})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrFailedToUseVenv, err)
+		}
+		if exec.GetExitCode() == 3 {
+			return fmt.Errorf("%w: %s", ErrVenvNotFound, name)
+		}
+		if err := commandErr(exec); err != nil {
+			return fmt.Errorf("%w: %w", ErrFailedToUseVenv, err)
+		}
+		path, _ := exec.GetOutput()
+		active = &activeVenv{name: name, dir: dir, env: map[string]string{
+			"VIRTUAL_ENV": dir,
+			"PATH":        dir + "/bin:" + strings.TrimSpace(path),
+		}}
+	}
+
+	exec, err := codeRunner{v.b, v.l}.RunContext(ctx, useVenvCode(active))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToUseVenv, err)
+	}
+	if exec.HasError() {
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("%w: %s", ErrFailedToUseVenv, strings.TrimSpace(stderr))
+	}
+	v.b.venv.Store(active)
+	v.b.cfg.log(ctx).Info("Using virtualenv", "sandbox", v.b.cfg.name, "venv", name)
+	return nil
+}
+
+func (v venvManager) Install(ctx context.Context, packages ...string) error {
+	if err := v.check(); err != nil {
+		return err
+	}
+	active := v.b.venv.Load()
+	if active == nil {
+		return ErrNoVenv
+	}
+	if len(packages) == 0 {
+		return nil
+	}
+	for _, pkg := range packages {
+		if pkg == "" || strings.HasPrefix(pkg, "-") {
+			return fmt.Errorf("%w: %q", ErrInvalidPackage, pkg)
+		}
+	}
+	v.b.cfg.log(ctx).Info("Installing packages", "sandbox", v.b.cfg.name, "venv", active.name, "packages", packages)
+	args := append([]string{"-m", "pip", "install", "--disable-pip-version-check", "--"}, packages...)
+	v.b.bumpEnvGeneration(ctx, append([]string{"pip", active.name}, packages...)...)
+	if err := v.runCommand(ctx, active.dir+"/bin/python", args); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToInstallPackages, err)
+	}
+	return nil
+}
+
+func (v venvManager) Current() string {
+	if active := v.b.venv.Load(); active != nil {
+		return active.name
+	}
+	return ""
+}
+
+// runCommand runs a command, failing if it doesn't succeed.
+func (v venvManager) runCommand(ctx context.Context, cmd string, args []string) error {
+	exec, err := commandRunner{b: v.b}.RunWithOptions(ctx, cmd, args, RunOptions{})
+	if err != nil {
+		return err
+	}
+	return commandErr(exec)
+}
+
+// commandErr describes the failure of a command that didn't succeed.
+func commandErr(exec CommandExecution) error {
+	if exec.IsSuccess() {
+		return nil
+	}
+	stderr, _ := exec.GetError()
+	return fmt.Errorf("exit code %d: %s", exec.GetExitCode(), strings.TrimSpace(stderr))
+}
+
+// useVenvCode returns Python code switching the REPL's import path and environment to venv, or
+// back to the interpreter's own if venv is nil. The interpreter's settings are saved on the sys
+// module the first time, so that switching between virtualenvs doesn't stack them.
+func useVenvCode(venv *activeVenv) string {
+	root := ""
+	if venv != nil {
+		root = venv.dir
+	}
+	return `def __msb_use_venv(root):
+    import glob, importlib, os, site, sys
+    if not hasattr(sys, "_msb_base"):
+        sys._msb_base = (list(sys.path), sys.prefix, sys.exec_prefix, os.environ.get("PATH", ""))
+    path, prefix, exec_prefix, env_path = sys._msb_base
+    os.environ.pop("VIRTUAL_ENV", None)
+    found = []
+    if root:
+        found = glob.glob(root + "/lib/python*/site-packages")
+        if not found:
+            raise RuntimeError("no site-packages in " + root)
+        system = set(site.getsitepackages([prefix, exec_prefix]) + [site.getusersitepackages()])
+        path = [p for p in path if p not in system]
+        prefix = exec_prefix = root
+        env_path = root + "/bin" + os.pathsep + env_path
+        os.environ["VIRTUAL_ENV"] = root
+    sys.path[:] = path
+    sys.prefix, sys.exec_prefix = prefix, exec_prefix
+    os.environ["PATH"] = env_path
+    for d in found:
+        site.addsitedir(d)
+    importlib.invalidate_caches()
+
+__msb_use_venv(` + strconv.Quote(root) + `)
+del __msb_use_venv
+`
+}
+
+// Virtualenv-related errors
+var (
+	ErrNotPythonSandbox        = errors.New("not a Python sandbox")
+	ErrVenvNotFound            = errors.New("virtualenv not found")
+	ErrNoVenv                  = errors.New("no virtualenv in use")
+	ErrInvalidPackage          = errors.New("invalid package")
+	ErrFailedToCreateVenv      = errors.New("failed to create virtualenv")
+	ErrFailedToUseVenv         = errors.New("failed to use virtualenv")
+	ErrFailedToInstallPackages = errors.New("failed to install packages")
+)
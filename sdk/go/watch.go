@@ -0,0 +1,87 @@
+package msb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MetricsSample is one reading of a sandbox's metrics, taken by WatchMetrics.
+type MetricsSample struct {
+	Sandbox string    // Sandbox name
+	Time    time.Time // When the reading was taken
+	Metrics Metrics   // Metrics read; zero if Err is set
+	Err     error     // Why the metrics couldn't be read
+}
+
+// WatchMetrics reads the metrics of all sandboxes every interval and sends each round of readings
+// on the returned channel, one sample per sandbox in the order given, so dashboards can redraw a
+// whole fleet at once. The first round is taken right away. The sandboxes are queried
+// concurrently and need not have been started through their handles, like with Status; a sandbox
+// that isn't running reports IsRunning false. The channel is closed once ctx is done; rounds the
+// receiver isn't ready for are dropped rather than queued.
+func WatchMetrics(ctx context.Context, interval time.Duration, sandboxes ...LangSandBox) <-chan []MetricsSample {
+	ch := make(chan []MetricsSample)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			samples := readAllMetrics(ctx, sandboxes)
+			select {
+			case ch <- samples:
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// the receiver is still busy with the previous round; this one is stale already
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch
+}
+
+func readAllMetrics(ctx context.Context, sandboxes []LangSandBox) []MetricsSample {
+	samples := make([]MetricsSample, len(sandboxes))
+	var wg sync.WaitGroup
+	for i, sb := range sandboxes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			samples[i] = readMetrics(ctx, sb)
+		}()
+	}
+	wg.Wait()
+	return samples
+}
+
+func readMetrics(ctx context.Context, sb LangSandBox) MetricsSample {
+	ls, ok := sb.(*langSandbox)
+	if !ok {
+		name, _ := nameOf(sb)
+		metrics, err := sb.Metrics().All()
+		return MetricsSample{Sandbox: name, Time: time.Now(), Metrics: metrics, Err: err}
+	}
+
+	sample := MetricsSample{Sandbox: ls.b.cfg.name}
+	ctx, cancel := withTimeout(ctx, ls.b.cfg.timeouts.Metrics)
+	defer cancel()
+	metrics, err := ls.b.rpcClient.getMetrics(ctx, &ls.b.cfg)
+	sample.Time = time.Now()
+	if err != nil {
+		sample.Err = fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
+		return sample
+	}
+	sample.Metrics = metrics.public()
+	if sample.Metrics.Name == "" {
+		// servers report nothing at all for sandboxes they don't know
+		sample.Metrics.Name = ls.b.cfg.name
+	}
+	return sample
+}